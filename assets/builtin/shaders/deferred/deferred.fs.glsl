@@ -2,6 +2,7 @@
 
 #include "lib/common.glsl"
 #include "lib/objects.glsl"
+#include "lib/dither.glsl"
 
 // Varying
 IN(0, flat uint, object)
@@ -9,21 +10,27 @@ IN(1, vec3, position)
 IN(2, vec3, normal)
 IN(3, vec4, color)
 IN(4, vec2, texcoord)
+IN(5, vec2, motion)
 
 // Return Output
 OUT(0, vec4, diffuse)
 OUT(1, vec4, normal)
 OUT(2, vec4, position)
+OUT(3, vec4, velocity)
 
 OBJECT(1, object, in_object)
 SAMPLER_ARRAY(2, textures)
 
 void main() 
 {
+	lod_dither(object.dither);
+
 	uint texture0 = object.textures[TEX_SLOT_DIFFUSE];
 
 	vec3 tint = mix(vec3(1), in_color.rgb, in_color.a);
 	out_diffuse = vec4(texture_array(textures, texture0, in_texcoord).rgb * tint, 1);
 	out_normal = pack_normal(in_normal);
-	out_position = vec4(in_position, 1);
+	// the mesh layer mask is stored in the alpha channel. background pixels are cleared to zero
+	out_position = vec4(in_position, float(object.layer));
+	out_velocity = vec4(in_motion, 0, 0);
 }
@@ -9,13 +9,14 @@ OUT(1, vec3, position)
 OUT(2, vec3, normal)
 OUT(3, vec4, color)
 OUT(4, vec2, texcoord)
+OUT(5, vec2, motion)
 
 out gl_PerVertex {
 	vec4 gl_Position;   
 };
 
 CAMERA(0, camera)
-OBJECT(1, object, gl_InstanceIndex)
+OBJECT(1, object, get_object_index())
 
 VERTEX_BUFFER(Vertex)
 INDEX_BUFFER(uint)
@@ -27,11 +28,12 @@ void main()
 	// load vertex data
 	Vertex vtx = get_vertex_indexed(object.vertexPtr, object.indexPtr);
 
-	mat4 mv = camera.View * object.model;
+	int instance = get_instance_index();
+	mat4 mv = camera.View * object_model(object, instance);
 
 	// textures
 	out_texcoord = vtx.tex;
-	out_color = vtx.color;
+	out_color = vtx.color * object_color(object, instance);
 
 	// gbuffer position
 	out_position = (mv * vec4(vtx.position, 1)).xyz;
@@ -39,6 +41,9 @@ void main()
 	// gbuffer normal
 	out_normal = normalize((mv * vec4(vtx.normal, 0.0)).xyz);
 
+	// screen space motion caused by object movement
+	out_motion = object_motion(camera.PrevViewProj, object, instance, vtx.position);
+
 	// vertex clip space position
 	gl_Position = camera.Proj * vec4(out_position, 1);
 }
@@ -15,7 +15,7 @@ out gl_PerVertex {
 };
 
 CAMERA(0, camera)
-OBJECT(1, object, gl_InstanceIndex)
+OBJECT(1, object, get_object_index())
 
 VERTEX_BUFFER(Vertex)
 INDEX_BUFFER(uint)
@@ -27,16 +27,19 @@ void main()
 	// load vertex data
 	Vertex v = get_vertex_indexed(object.vertexPtr, object.indexPtr);
 
+	int instance = get_instance_index();
+	mat4 model = object_model(object, instance);
+
 	// texture coords
 	out_texcoord = v.tex;
-	out_color = v.color;
+	out_color = v.color * object_color(object, instance);
 
 	// gbuffer view position
-	out_view_position = (camera.View * object.model * vec4(v.position, 1)).xyz;
-	out_world_position = (object.model * vec4(v.position, 1)).xyz;
+	out_view_position = (camera.View * model * vec4(v.position, 1)).xyz;
+	out_world_position = (model * vec4(v.position, 1)).xyz;
 
 	// world normal
-	out_world_normal = normalize((object.model * vec4(v.normal, 0)).xyz);
+	out_world_normal = normalize((model * vec4(v.normal, 0)).xyz);
 
 	// vertex clip space position
 	gl_Position = camera.Proj * vec4(out_view_position, 1);
@@ -3,6 +3,7 @@
 #include "lib/common.glsl"
 #include "lib/objects.glsl"
 #include "lib/lighting.glsl"
+#include "lib/fog.glsl"
 
 IN(0, flat uint, object)
 IN(1, vec4, color)
@@ -17,7 +18,9 @@ OUT(0, vec4, diffuse)
 CAMERA(0, camera)
 OBJECT(1, object, in_object)
 LIGHTS(2, lights)
-SAMPLER_ARRAY(3, textures)
+CLUSTERS(3, clusters)
+CLUSTER_LIGHTS(4, clusterLights)
+SAMPLER_ARRAY(5, textures)
 
 void main() 
 {
@@ -32,13 +35,16 @@ void main()
 	albedo = vec4(albedo.rgb * tint, albedo.a);
 
 	// calculate lighting
-	int lightCount = lights.settings.Count;
-	vec3 lightColor = ambientLight(lights.settings, 1);
-	for(int i = 0; i < lightCount; i++) {
-		lightColor += calculateLightColor(lights.item[i], in_world_position, in_world_normal, in_view_position.z, lights.settings);
+	vec3 lightColor = environmentDiffuse(lights.settings, in_world_normal, 1);
+	int clusterIdx = clusterIndex(lights.settings, gl_FragCoord.xy, camera.Viewport, in_view_position.z);
+	Cluster cluster = clusters.item[clusterIdx];
+	for(uint i = 0; i < cluster.Count; i++) {
+		uint lightIdx = CLUSTER_LIGHT(clusterLights, cluster, i);
+		lightColor += calculateLightColor(lights.item[lightIdx], in_world_position, in_world_normal, in_view_position.z, lights.settings);
 	}
 
     // gamma correct & write fragment
 	vec3 linearColor = pow(albedo.rgb, vec3(gamma));
-    out_diffuse = vec4(linearColor * lightColor, albedo.a);
+	vec3 shaded = applyFog(lights.settings, linearColor * lightColor, camera.Eye.xyz, in_world_position);
+    out_diffuse = vec4(shaded, albedo.a);
 }
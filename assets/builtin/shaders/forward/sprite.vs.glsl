@@ -15,7 +15,7 @@ out gl_PerVertex {
 };
 
 CAMERA(0, camera)
-OBJECT(1, object, gl_InstanceIndex)
+OBJECT(1, object, get_object_index())
 
 VERTEX_BUFFER(Vertex)
 INDEX_BUFFER(uint)
@@ -27,7 +27,8 @@ void main()
 	// load vertex data
 	Vertex v = get_vertex_indexed(object.vertexPtr, object.indexPtr);
 
-	vec3 center = (object.model * vec4(0, 0, 0, 1.0)).xyz;
+	int instance = get_instance_index();
+	vec3 center = (object_model(object, instance) * vec4(0, 0, 0, 1.0)).xyz;
 	vec3 lookDirection = normalize(center - camera.Eye.xyz);
 	vec3 up = vec3(0, 1, 0);
 
@@ -42,7 +43,7 @@ void main()
 
 	// texture & color
 	out_texcoord = v.tex;
-	out_color = v.color;
+	out_color = v.color * object_color(object, instance);
 
 	// gbuffer view position
 	out_view_position = (camera.View * vec4(out_world_position.xyz, 1.0)).xyz;
@@ -0,0 +1,100 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/lighting.glsl"
+#include "lib/fog.glsl"
+#include "lib/water.glsl"
+
+IN(0, flat uint, water)
+IN(1, vec2, surface)
+IN(2, vec3, view_position)
+IN(3, vec3, world_position)
+
+OUT(0, vec4, diffuse)
+
+CAMERA(0, camera)
+LIGHTS(2, lights)
+CLUSTERS(3, clusters)
+CLUSTER_LIGHTS(4, clusterLights)
+SAMPLER_ARRAY(5, textures)
+
+WATERS(0, waters)
+WATER_SAMPLER(1, scene_color)
+WATER_SAMPLER(2, scene_depth)
+
+// reflectance of water at normal incidence
+const float F0 = 0.02;
+
+// returns the distance from the eye to the opaque scene at a screen position
+float scene_distance(vec2 uv) {
+	float depth = texture(tex_scene_depth, uv).r;
+	vec4 view = camera.ProjInv * vec4(uv * 2 - 1, depth, 1);
+	return length(view.xyz / view.w);
+}
+
+void main()
+{
+	Water water = waters.item[in_water];
+
+	// per fragment normals from the analytic wave derivatives
+	vec3 normal;
+	gerstner(water, in_surface, normal);
+	normal = normalize(mat3(water.Model) * normal);
+
+	// flip the normal when looking at the surface from below
+	vec3 viewDir = normalize(camera.Eye.xyz - in_world_position);
+	if (dot(normal, viewDir) < 0) {
+		normal = -normal;
+	}
+
+	// distance travelled through the water to the scene behind the surface
+	vec2 uv = gl_FragCoord.xy / camera.Viewport;
+	float surfaceDistance = length(in_view_position);
+	float depth = max(scene_distance(uv) - surfaceDistance, 0);
+
+	// refract by offsetting the lookup along the view space normal. the offset fades in with depth,
+	// and is discarded if it would sample geometry in front of the surface
+	vec3 viewNormal = mat3(camera.View) * normal;
+	vec2 refractedUV = clamp(uv + viewNormal.xy * water.Refraction * min(depth, 1), vec2(0), vec2(1));
+	float refractedDepth = scene_distance(refractedUV) - surfaceDistance;
+	float thickness = depth;
+	if (refractedDepth > 0) {
+		thickness = refractedDepth;
+	} else {
+		refractedUV = uv;
+	}
+	vec3 scene = texture(tex_scene_color, refractedUV).rgb;
+
+	// lighting of the scattering water body and foam
+	vec3 lightColor = environmentDiffuse(lights.settings, normal, 1);
+	int clusterIdx = clusterIndex(lights.settings, gl_FragCoord.xy, camera.Viewport, in_view_position.z);
+	Cluster cluster = clusters.item[clusterIdx];
+	for(uint i = 0; i < cluster.Count; i++) {
+		uint lightIdx = CLUSTER_LIGHT(clusterLights, cluster, i);
+		lightColor += calculateLightColor(lights.item[lightIdx], in_world_position, normal, in_view_position.z, lights.settings);
+	}
+
+	// light from the scene behind is absorbed along the path through the water,
+	// and replaced by light scattered by the water body
+	vec3 transmittance = exp(-water.Absorption.rgb * thickness);
+	vec3 body = scene * transmittance + water.Color.rgb * lightColor * (1 - transmittance);
+
+	// reflect the environment using the schlick approximation of the fresnel term.
+	// the irradiance around the reflected direction stands in for the sky radiance
+	vec3 reflected = reflect(-viewDir, normal);
+	vec3 sky = environmentDiffuse(lights.settings, reflected, 1);
+	float fresnel = F0 + (1 - F0) * pow(1 - max(dot(normal, viewDir), 0), 5);
+	vec3 color = mix(body, sky, fresnel);
+
+	// foam where the water is shallow around intersecting geometry, broken up by moving ripples
+	float foamDepth = water.FoamColor.a;
+	if (foamDepth > 0) {
+		float ripples = 0.5 + 0.5 * sin(in_surface.x * 3.1 + water.Time * 1.3) * sin(in_surface.y * 2.7 - water.Time * 0.9);
+		float shore = clamp(1 - depth / foamDepth, 0, 1);
+		float foam = smoothstep(0.3 * ripples, 0.3 * ripples + 0.4, shore);
+		color = mix(color, water.FoamColor.rgb * lightColor, foam);
+	}
+
+	vec3 shaded = applyFog(lights.settings, color, camera.Eye.xyz, in_world_position);
+	out_diffuse = vec4(shaded, 1);
+}
@@ -0,0 +1,34 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/water.glsl"
+
+IN(0, vec3, position)
+
+OUT(0, flat uint, water)
+OUT(1, vec2, surface)
+OUT(2, vec3, view_position)
+OUT(3, vec3, world_position)
+
+out gl_PerVertex {
+	vec4 gl_Position;
+};
+
+CAMERA(0, camera)
+WATERS(0, waters)
+
+void main()
+{
+	// water surfaces are drawn with their index as the instance offset
+	out_water = uint(gl_InstanceIndex);
+	Water water = waters.item[gl_InstanceIndex];
+
+	// displace the undisturbed grid. normals are evaluated per fragment
+	vec3 normal;
+	vec3 local = gerstner(water, in_position.xz, normal);
+
+	out_surface = in_position.xz;
+	out_world_position = (water.Model * vec4(local, 1)).xyz;
+	out_view_position = (camera.View * vec4(out_world_position, 1)).xyz;
+	gl_Position = camera.Proj * vec4(out_view_position, 1);
+}
@@ -0,0 +1,100 @@
+//
+// Single scattering atmosphere model. Mirrors core/sky/atmosphere.go
+//
+
+#define ATMOSPHERE_PRIMARY_STEPS 16
+#define ATMOSPHERE_SECONDARY_STEPS 8
+#define ATMOSPHERE_GROUND_ALBEDO 0.3
+
+struct Atmosphere {
+	vec3 Rayleigh;
+	float RayleighHeight;
+	float Mie;
+	float MieHeight;
+	float MieG;
+	float PlanetRadius;
+	float AtmosphereRadius;
+	float SunIntensity;
+};
+
+const float PI = 3.14159265359;
+
+// returns the near and far ray-sphere intersection distances. misses return far < near
+vec2 intersectSphere(vec3 origin, vec3 dir, float radius) {
+	float b = 2 * dot(dir, origin);
+	float c = dot(origin, origin) - radius * radius;
+	float d = b * b - 4 * c;
+	if (d < 0) {
+		return vec2(1e5, -1e5);
+	}
+	float sq = sqrt(d);
+	return vec2(-b - sq, -b + sq) / 2;
+}
+
+vec3 atmosphereScatter(Atmosphere atm, vec3 dir, vec3 sun) {
+	// observer is placed one meter above the ground
+	vec3 origin = vec3(0, atm.PlanetRadius + 1, 0);
+
+	vec2 hit = intersectSphere(origin, dir, atm.AtmosphereRadius);
+	if (hit.x > hit.y) {
+		return vec3(0);
+	}
+	float stepSize = hit.y / ATMOSPHERE_PRIMARY_STEPS;
+
+	vec3 totalRayleigh = vec3(0);
+	vec3 totalMie = vec3(0);
+	float depthRayleigh = 0;
+	float depthMie = 0;
+	for (int i = 0; i < ATMOSPHERE_PRIMARY_STEPS; i++) {
+		vec3 position = origin + dir * (float(i) + 0.5) * stepSize;
+		float height = length(position) - atm.PlanetRadius;
+
+		// optical depth of this step
+		float stepRayleigh = exp(-height / atm.RayleighHeight) * stepSize;
+		float stepMie = exp(-height / atm.MieHeight) * stepSize;
+		depthRayleigh += stepRayleigh;
+		depthMie += stepMie;
+
+		// points in the shadow of the planet receive no sun light
+		vec2 shadow = intersectSphere(position, sun, atm.PlanetRadius);
+		if (shadow.x < shadow.y && shadow.x > 0) {
+			continue;
+		}
+
+		// optical depth towards the sun
+		float sunStep = intersectSphere(position, sun, atm.AtmosphereRadius).y / ATMOSPHERE_SECONDARY_STEPS;
+		float sunRayleigh = 0;
+		float sunMie = 0;
+		for (int j = 0; j < ATMOSPHERE_SECONDARY_STEPS; j++) {
+			vec3 p = position + sun * (float(j) + 0.5) * sunStep;
+			float h = length(p) - atm.PlanetRadius;
+			sunRayleigh += exp(-h / atm.RayleighHeight) * sunStep;
+			sunMie += exp(-h / atm.MieHeight) * sunStep;
+		}
+
+		// attenuation along the path from the sun to the observer
+		vec3 tau = atm.Rayleigh * (depthRayleigh + sunRayleigh) + atm.Mie * 1.1 * (depthMie + sunMie);
+		vec3 attenuation = exp(-tau);
+
+		totalRayleigh += attenuation * stepRayleigh;
+		totalMie += attenuation * stepMie;
+	}
+
+	// phase functions
+	float mu = dot(dir, sun);
+	float g = atm.MieG;
+	float phaseRayleigh = 3 / (16 * PI) * (1 + mu * mu);
+	float phaseMie = 3 / (8 * PI) * ((1 - g * g) * (mu * mu + 1)) / (pow(1 + g * g - 2 * mu * g, 1.5) * (2 + g * g));
+
+	return atm.SunIntensity * (phaseRayleigh * atm.Rayleigh * totalRayleigh + phaseMie * atm.Mie * totalMie);
+}
+
+// returns the sky radiance in a direction. directions below the horizon return a dim reflection of the horizon
+vec3 atmosphereRadiance(Atmosphere atm, vec3 dir, vec3 sun) {
+	if (dir.y >= 0) {
+		return atmosphereScatter(atm, dir, sun);
+	}
+	vec3 horizon = vec3(dir.x, 0, dir.z);
+	horizon = dot(horizon, horizon) < 1e-8 ? vec3(1, 0, 0) : normalize(horizon);
+	return atmosphereScatter(atm, horizon, sun) * ATMOSPHERE_GROUND_ALBEDO;
+}
@@ -0,0 +1,8 @@
+#define BLOOM_PARAMS(idx) UNIFORM(idx, params, { \
+	float Threshold; \
+	float Knee; \
+	float Radius; \
+	float Intensity; \
+	float DirtIntensity; \
+	int Prefilter; \
+})
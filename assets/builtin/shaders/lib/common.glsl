@@ -6,6 +6,7 @@
 #extension GL_EXT_shader_explicit_arithmetic_types : require
 #extension GL_EXT_scalar_block_layout : require
 #extension GL_EXT_shader_8bit_storage : require
+#extension GL_ARB_shader_draw_parameters : require
 
 #define MAX_TEXTURES 16
 
@@ -14,7 +15,9 @@ const float gamma = 2.2;
 #define SAMPLER_ARRAY(idx,name) \
 	layout (binding = idx) uniform sampler2D[] name; \
 	float _shadow_texture(uint index, vec2 point) { return texture(name[nonuniformEXT(index)], point).r; } \
-	vec2 _shadow_size(uint index) { return textureSize(name[index], 0).xy; }
+	vec2 _shadow_size(uint index) { return textureSize(name[index], 0).xy; } \
+	vec4 _env_texture(uint index, vec2 point) { return texture(name[nonuniformEXT(index)], point); } \
+	vec2 _env_size(uint index) { return textureSize(name[nonuniformEXT(index)], 0).xy; }
 
 #define texture_array(name,index,point) texture(name[nonuniformEXT(index)], point)
 
@@ -36,6 +39,8 @@ const float gamma = 2.2;
 	vec2 Viewport; \
 	float Delta; \
 	float Time; \
+	mat4 PrevViewProj; \
+	vec2 Jitter; \
 } name;
 
 #define IN(idx,type,name) layout (location = idx) in type in_ ## name;
@@ -48,3 +53,26 @@ vec3 unpack_normal(vec3 packed_normal) {
 vec4 pack_normal(vec3 normal) {
 	return vec4((normal + 1.0) / 2.0, 1);
 }
+
+// maps t in [0,1] to a blue-green-yellow-red heat map color
+vec3 debug_heatmap(float t) {
+	t = clamp(t, 0, 1) * 3;
+	if (t < 1) {
+		return mix(vec3(0, 0, 1), vec3(0, 1, 0), t);
+	}
+	if (t < 2) {
+		return mix(vec3(0, 1, 0), vec3(1, 1, 0), t - 1);
+	}
+	return mix(vec3(1, 1, 0), vec3(1, 0, 0), t - 2);
+}
+
+// returns a distinct color for each shadow cascade
+vec3 debug_cascade_color(int index) {
+	const vec3 colors[4] = vec3[4](
+		vec3(1, 0.2, 0.2),
+		vec3(0.2, 1, 0.2),
+		vec3(0.2, 0.4, 1),
+		vec3(1, 1, 0.2)
+	);
+	return colors[clamp(index, 0, 3)];
+}
@@ -0,0 +1,11 @@
+struct Decal {
+	mat4 Model;
+	mat4 ModelInv;
+	vec4 Color;
+	uint Diffuse;
+	int Normal;
+	uint Layers;
+	float AngleFade;
+};
+
+#define DECALS(idx,name) STORAGE_BUFFER(idx,Decal,name)
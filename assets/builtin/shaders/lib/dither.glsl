@@ -0,0 +1,27 @@
+// returns an ordered dithering threshold in the range (0,1) for a screen space pixel
+float bayer4(vec2 coord) {
+	const float matrix[16] = float[](
+		0, 8, 2, 10,
+		12, 4, 14, 6,
+		3, 11, 1, 9,
+		15, 7, 13, 5
+	);
+	ivec2 p = ivec2(coord) & 3;
+	return (matrix[p.y * 4 + p.x] + 0.5) / 16.0;
+}
+
+// discards pixels of objects that are cross-fading between levels of detail.
+// positive factors discard that fraction of the pixels, while negative factors
+// keep only the complementary pixels, so that two levels together cover every pixel exactly once.
+void lod_dither(float factor) {
+	if (factor == 0) {
+		return;
+	}
+	float threshold = bayer4(gl_FragCoord.xy);
+	if (factor > 0 && threshold < factor) {
+		discard;
+	}
+	if (factor < 0 && threshold >= -factor) {
+		discard;
+	}
+}
@@ -0,0 +1,42 @@
+// requires lib/lighting.glsl
+
+// sharpness of the sun in-scattering highlight
+#define FOG_SCATTER_EXPONENT 8.0
+
+// returns the integrated density of exponential height fog along the ray from the eye to a point
+float fogOpticalDepth(LightSettings settings, vec3 eye, vec3 position) {
+	vec3 ray = position - eye;
+	float dist = length(ray);
+	if (dist <= settings.FogStart) {
+		return 0;
+	}
+	vec3 dir = ray / dist;
+	vec3 origin = eye + dir * settings.FogStart;
+	float len = dist - settings.FogStart;
+
+	float density = settings.FogDensity * exp(-settings.FogFalloff * (origin.y - settings.FogHeight));
+
+	// integral of exp(-falloff * dir.y * t) over the ray, divided by its length
+	float k = settings.FogFalloff * dir.y * len;
+	float integral = abs(k) > 0.0001 ? (1 - exp(-k)) / k : 1;
+	return density * len * integral;
+}
+
+// blends a lit color towards the fog color according to the fog between the eye and the shaded point
+vec3 applyFog(LightSettings settings, vec3 color, vec3 eye, vec3 position) {
+	if (settings.FogEnabled == 0) {
+		return color;
+	}
+
+	float transmittance = exp(-fogOpticalDepth(settings, eye, position));
+
+	// tint the fog by the sun when looking towards it
+	vec3 fogColor = settings.FogColor.rgb;
+	if (settings.FogSun.w > 0) {
+		vec3 viewDir = normalize(position - eye);
+		float scatter = pow(max(dot(viewDir, settings.FogSun.xyz), 0), FOG_SCATTER_EXPONENT);
+		fogColor = mix(fogColor, settings.FogSunColor.rgb, clamp(scatter * settings.FogSun.w, 0, 1));
+	}
+
+	return mix(fogColor, color, transmittance);
+}
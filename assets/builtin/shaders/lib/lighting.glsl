@@ -2,11 +2,19 @@
 #define DIRECTIONAL_LIGHT 2
 
 #define SHADOW_CASCADES 4
+#define SHADOW_MAPS 6
+
+// shadow filters. must match engine.ShadowFilter
+#define SHADOW_PCF 0
+#define SHADOW_PCSS 1
+#define SHADOW_HARD 2
 
 struct Light {
-	mat4 ViewProj[SHADOW_CASCADES];
-	int Shadowmap[SHADOW_CASCADES];
-	float Distance[SHADOW_CASCADES];
+	mat4 ViewProj[SHADOW_MAPS];
+	vec4 ShadowRegion[SHADOW_MAPS];
+	vec4 ShadowParams[SHADOW_MAPS];
+	int Shadowmap[SHADOW_MAPS];
+	float Distance[SHADOW_MAPS];
 
 	vec4 Color;
 	vec4 Position;
@@ -16,7 +24,16 @@ struct Light {
 	float Falloff;
 };
 
-#define LIGHT_PADDING 74
+#define ENV_LEVELS 5
+
+// roughness of every surface, until materials carry their own roughness
+#define SURFACE_ROUGHNESS 0.8
+
+// debug modes handled by the lighting pass. must match engine.DebugMode
+#define DEBUG_CASCADES 6
+#define DEBUG_LIGHT_COUNT 7
+
+#define LIGHT_PADDING 85
 struct LightSettings {
 	vec4 AmbientColor;
 	float AmbientIntensity;
@@ -25,14 +42,62 @@ struct LightSettings {
 	float ShadowSampleRadius;
 	float ShadowBias;
 	float NormalOffset;
+	int ShadowFilter;
+	int ClusterX;
+	int ClusterY;
+	int ClusterZ;
+	float ClusterNear;
+	float ClusterFar;
+	float EnvIntensity;
+	int EnvIrradiance;
+	int EnvBRDF;
+	int EnvSpecular[ENV_LEVELS];
+	int _pad0[4];
+	vec4 SkySH[9];
+	int SkyAmbient;
+	float FogDensity;
+	float FogHeight;
+	float FogFalloff;
+	vec4 FogColor;
+	vec4 FogSun;
+	vec4 FogSunColor;
+	float FogStart;
+	int FogEnabled;
+	int DebugMode;
 
 	float _padding[LIGHT_PADDING];
 };
 
 #define LIGHTS(idx,name) layout (std430, binding = idx) readonly buffer LightBuffer { LightSettings settings; Light item[]; } name;
 
+struct Cluster {
+	uint Offset;
+	uint Count;
+	uint _pad0;
+	uint _pad1;
+};
+
+// light indices are packed 4 per element. cluster offsets are given in elements.
+#define CLUSTERS(idx,name) layout (std430, binding = idx) readonly buffer ClusterBuffer { Cluster item[]; } name;
+#define CLUSTER_LIGHTS(idx,name) layout (std430, binding = idx) readonly buffer ClusterLightBuffer { uvec4 item[]; } name;
+#define CLUSTER_LIGHT(lights,cluster,i) lights.item[cluster.Offset + (i) / 4][(i) % 4]
+
 const float SHADOW_POWER = 60;
 
+// maximum filter radius of soft shadows, in texels
+const float SHADOW_MAX_PENUMBRA = 24;
+
+const vec2 POISSON_DISK[16] = vec2[](
+	vec2(-0.94201624, -0.39906216), vec2(0.94558609, -0.76890725),
+	vec2(-0.09418410, -0.92938870), vec2(0.34495938, 0.29387760),
+	vec2(-0.91588581, 0.45771432), vec2(-0.81544232, -0.87912464),
+	vec2(-0.38277543, 0.27676845), vec2(0.97484398, 0.75648379),
+	vec2(0.44323325, -0.97511554), vec2(0.53742981, -0.47373420),
+	vec2(-0.26496911, -0.41893023), vec2(0.79197514, 0.19090188),
+	vec2(-0.24188840, 0.99706507), vec2(-0.81409955, 0.91437590),
+	vec2(0.19984126, 0.78641367), vec2(0.14383161, -0.14100790)
+);
+
 // transforms ndc -> depth texture space
 const mat4 biasMat = mat4( 
 	0.5, 0.0, 0.0, 0.0,
@@ -47,61 +112,123 @@ const mat4 biasMat = mat4(
 
 float _shadow_texture(uint index, vec2 point);
 vec2 _shadow_size(uint index);
-float sampleShadowmap(uint shadowmap, mat4 viewProj, vec3 position, float bias);
-float sampleShadowmapPCF(uint shadowmap, mat4 viewProj, vec3 position, LightSettings settings);
-float blendCascades(Light light, vec3 position, float depth, float blendRange, LightSettings settings);
+vec4 _env_texture(uint index, vec2 point);
+vec2 _env_size(uint index);
+float shadowDepth(float exponential);
+float shadowAtlasDepth(uint atlas, vec4 region, vec2 uv);
+mat2 shadowRotation(vec3 position);
+float shadowPCF(uint atlas, vec4 region, vec2 uv, float depth, float radius, mat2 rotation, int samples);
+float shadowBlockerDepth(uint atlas, vec4 region, vec2 uv, float depth, float radius, mat2 rotation);
+float sampleShadowmap(uint shadowmap, vec4 region, mat4 viewProj, vec3 position, float bias, float penumbra, LightSettings settings);
+float sampleCascade(Light light, int cascade, vec3 position, vec3 normal, float slope, LightSettings settings);
+float blendCascades(Light light, vec3 position, vec3 normal, float depth, float blendRange, float slope, LightSettings settings);
+int cubeFace(vec3 direction);
+int clusterIndex(LightSettings settings, vec2 fragCoord, vec2 viewport, float depth);
+float samplePointShadow(Light light, vec3 position, LightSettings settings);
 float calculatePointLightContrib(Light light, vec3 surfaceToLight, float distanceToLight, vec3 normal);
 vec3 ambientLight(LightSettings settings, float occlusion);
+vec3 sampleCubeStrip(uint index, vec3 direction);
+vec3 irradianceSH(vec4 sh[9], vec3 normal);
+vec3 environmentDiffuse(LightSettings settings, vec3 normal, float occlusion);
+vec3 environmentSpecular(LightSettings settings, vec3 normal, vec3 viewDir, float roughness, float occlusion);
 vec3 calculateLightColor(Light light, vec3 position, vec3 normal, float depth, LightSettings settings);
 
-float sampleShadowmap(uint shadowmap, mat4 viewProj, vec3 position, float bias) {
-	vec4 shadowCoord = biasMat * viewProj * vec4(position, 1);
+// converts an exponential shadow map sample to normalized depth
+float shadowDepth(float exponential) {
+	return 1.0 + log(max(exponential, 1e-30)) / SHADOW_POWER;
+}
 
-	float shadow = 1.0;
-	if (shadowCoord.z > -1.0 && shadowCoord.z < 1.0 && shadowCoord.w > 0) {
-		float dist = _shadow_texture(shadowmap, shadowCoord.st);
-		float actual = exp(SHADOW_POWER * shadowCoord.z - bias) / exp(SHADOW_POWER);
+// samples the depth of a shadow map in the atlas, at texture coordinates local to the shadow map
+float shadowAtlasDepth(uint atlas, vec4 region, vec2 uv) {
+	// keep filters from sampling neighbouring shadow maps
+	float texel = 1.0 / (_shadow_size(atlas).x * region.z);
+	uv = clamp(uv, vec2(0.5 * texel), vec2(1.0 - 0.5 * texel));
+	return shadowDepth(_shadow_texture(atlas, region.xy + uv * region.zw));
+}
+
+// returns a random rotation of the sampling disk for each position, which trades banding for noise
+mat2 shadowRotation(vec3 position) {
+	float angle = 6.2831853 * fract(sin(dot(position, vec3(12.9898, 78.233, 37.719))) * 43758.5453);
+	float s = sin(angle);
+	float c = cos(angle);
+	return mat2(c, s, -s, c);
+}
 
-		if (dist < actual) {
-			shadow = 0;
+// returns the fraction of poisson disk samples within the radius that are not occluded
+float shadowPCF(uint atlas, vec4 region, vec2 uv, float depth, float radius, mat2 rotation, int samples) {
+	float lit = 0.0;
+	for (int i = 0; i < samples; i++) {
+		vec2 offset = rotation * POISSON_DISK[i] * radius;
+		if (shadowAtlasDepth(atlas, region, uv + offset) >= depth) {
+			lit += 1.0;
 		}
 	}
-	return shadow;
+	return lit / float(samples);
 }
 
-float sampleShadowmapPCF(uint shadowmap, mat4 viewProj, vec3 position, LightSettings settings) {
-	if (settings.ShadowSamples <= 0) {
-		return sampleShadowmap(shadowmap, viewProj, position, settings.ShadowBias);
+// returns the average depth of the occluders within the radius, or -1 if there are none
+float shadowBlockerDepth(uint atlas, vec4 region, vec2 uv, float depth, float radius, mat2 rotation) {
+	float sum = 0.0;
+	float count = 0.0;
+	for (int i = 0; i < 16; i++) {
+		vec2 offset = rotation * POISSON_DISK[i] * radius;
+		float blocker = shadowAtlasDepth(atlas, region, uv + offset);
+		if (blocker < depth) {
+			sum += blocker;
+			count += 1.0;
+		}
 	}
+	return count > 0.0 ? sum / count : -1.0;
+}
 
+// samples a shadow map in the atlas. returns 1 for lit surfaces, and 0 for surfaces in shadow.
+// the penumbra scale relates the distance between blocker and receiver to the filter radius of soft shadows.
+float sampleShadowmap(uint shadowmap, vec4 region, mat4 viewProj, vec3 position, float bias, float penumbra, LightSettings settings) {
 	vec4 shadowCoord = biasMat * viewProj * vec4(position, 1);
-    shadowCoord = shadowCoord / shadowCoord.w;
-
-    float shadow = 1.0;
-    if (shadowCoord.z > -1.0 && shadowCoord.z < 1.0 && shadowCoord.w > 0) {
-        vec2 texelSize = 1.0 / _shadow_size(shadowmap);
-        float actual = exp(SHADOW_POWER * (shadowCoord.z - settings.ShadowBias)) / exp(SHADOW_POWER);
-
-        float count = 0.0;
-        int numSamples = settings.ShadowSamples;
-        for (int x = -numSamples; x <= numSamples; ++x) {
-            for (int y = -numSamples; y <= numSamples; ++y) {
-                vec2 offset = vec2(float(x), float(y)) * texelSize * settings.ShadowSampleRadius;
-                float dist = _shadow_texture(shadowmap, shadowCoord.st + offset);
-
-                // Compare the difference between exponential depth values
-                if (dist - actual < 0) {
-                    count += 1.0;
-                }
-            }
-        }
+	if (shadowCoord.w <= 0) {
+		return 1.0;
+	}
+	shadowCoord = shadowCoord / shadowCoord.w;
+	if (shadowCoord.z <= -1.0 || shadowCoord.z >= 1.0) {
+		return 1.0;
+	}
 
-        shadow = 1 - count / float((2 * numSamples + 1) * (2 * numSamples + 1));
-    }
-    return shadow;
+	vec2 uv = shadowCoord.st;
+	float depth = shadowCoord.z - bias;
+	if (settings.ShadowFilter == SHADOW_HARD || settings.ShadowSamples <= 0) {
+		return shadowAtlasDepth(shadowmap, region, uv) >= depth ? 1.0 : 0.0;
+	}
+
+	float texel = 1.0 / (_shadow_size(shadowmap).x * region.z);
+	float radius = settings.ShadowSampleRadius * texel;
+	float maxRadius = SHADOW_MAX_PENUMBRA * texel;
+	int samples = clamp(settings.ShadowSamples, 1, 16);
+	mat2 rotation = shadowRotation(position);
+
+	if (settings.ShadowFilter == SHADOW_PCSS && penumbra > 0) {
+		// search the area that may contain occluders, given the size of the light
+		float search = clamp(penumbra * depth, radius, maxRadius);
+		float blocker = shadowBlockerDepth(shadowmap, region, uv, depth, search, rotation);
+		if (blocker < 0) {
+			return 1.0;
+		}
+
+		// the penumbra widens with the distance between the occluder and the receiver
+		radius = clamp(penumbra * (depth - blocker), radius, maxRadius);
+	}
+
+	return shadowPCF(shadowmap, region, uv, depth, radius, rotation, samples);
 }
 
-float blendCascades(Light light, vec3 position, float depth, float blendRange, LightSettings settings) {
+// samples a directional light cascade, using its depth bias and normal offset
+float sampleCascade(Light light, int cascade, vec3 position, vec3 normal, float slope, LightSettings settings) {
+	vec4 params = light.ShadowParams[cascade];
+	float bias = params.x * (1.0 + slope);
+	position += normal * params.y;
+	return sampleShadowmap(light.Shadowmap[cascade], light.ShadowRegion[cascade], light.ViewProj[cascade], position, bias, params.z, settings);
+}
+
+float blendCascades(Light light, vec3 position, vec3 normal, float depth, float blendRange, float slope, LightSettings settings) {
     // determine the cascade index
     int cascadeIndex = 0;
     for (int i = 0; i < SHADOW_CASCADES; ++i) {
@@ -111,7 +238,7 @@ float blendCascades(Light light, vec3 position, float depth, float blendRange, L
         }
     }
 
-    float shadowCurrent = sampleShadowmapPCF(light.Shadowmap[cascadeIndex], light.ViewProj[cascadeIndex], position, settings);
+    float shadowCurrent = sampleCascade(light, cascadeIndex, position, normal, slope, settings);
 
     // blend with previous cascade to get a smooth transition
     if (cascadeIndex > 0 && blendRange > 0) {
@@ -121,7 +248,7 @@ float blendCascades(Light light, vec3 position, float depth, float blendRange, L
         float blendFactor = smoothstep(cascadeStart, cascadeStart + blendRange, depth);
 
         if (blendFactor > 0) {
-			float shadowPrev = sampleShadowmapPCF(light.Shadowmap[cascadeIndex - 1], light.ViewProj[cascadeIndex - 1], position, settings);
+			float shadowPrev = sampleCascade(light, cascadeIndex - 1, position, normal, slope, settings);
 			return mix(shadowPrev, shadowCurrent, blendFactor);
         }
     }
@@ -129,6 +256,28 @@ float blendCascades(Light light, vec3 position, float depth, float blendRange, L
     return shadowCurrent;
 }
 
+// returns the shadow cube face index for a light-to-surface direction.
+// face order: +X, -X, +Y, -Y, +Z, -Z
+int cubeFace(vec3 direction) {
+	vec3 a = abs(direction);
+	if (a.x >= a.y && a.x >= a.z) {
+		return direction.x > 0 ? 0 : 1;
+	}
+	if (a.y >= a.z) {
+		return direction.y > 0 ? 2 : 3;
+	}
+	return direction.z > 0 ? 4 : 5;
+}
+
+float samplePointShadow(Light light, vec3 position, LightSettings settings) {
+	// point lights without shadows have a blank first shadowmap
+	if (light.Shadowmap[0] == 0) {
+		return 1.0;
+	}
+	int face = cubeFace(position - light.Position.xyz);
+	return sampleShadowmap(light.Shadowmap[face], light.ShadowRegion[face], light.ViewProj[face], position, settings.ShadowBias, 0, settings);
+}
+
 float sqr(float x)
 {
 	return x * x;
@@ -173,10 +322,103 @@ float calculatePointLightContrib(Light light, vec3 surfaceToLight, float distanc
 	return normalCoef * attenuation;
 }
 
+// returns the index of the light cluster containing the given fragment
+int clusterIndex(LightSettings settings, vec2 fragCoord, vec2 viewport, float depth) {
+	ivec2 tile = ivec2(fragCoord / viewport * vec2(settings.ClusterX, settings.ClusterY));
+	tile = clamp(tile, ivec2(0), ivec2(settings.ClusterX - 1, settings.ClusterY - 1));
+
+	// depth slices are distributed exponentially between the near and far planes
+	float slice = log(max(depth, settings.ClusterNear) / settings.ClusterNear) / log(settings.ClusterFar / settings.ClusterNear);
+	int z = clamp(int(slice * settings.ClusterZ), 0, settings.ClusterZ - 1);
+
+	return tile.x + settings.ClusterX * (tile.y + settings.ClusterY * z);
+}
+
 vec3 ambientLight(LightSettings settings, float occlusion) {
 	return settings.AmbientColor.rgb * settings.AmbientIntensity * occlusion;
 }
 
+// samples a cubemap stored as a horizontal strip of faces: +X, -X, +Y, -Y, +Z, -Z
+vec3 sampleCubeStrip(uint index, vec3 direction) {
+	vec3 a = abs(direction);
+	int face;
+	float ma;
+	vec2 st;
+	if (a.x >= a.y && a.x >= a.z) {
+		ma = a.x;
+		face = direction.x > 0 ? 0 : 1;
+		st = vec2(direction.x > 0 ? -direction.z : direction.z, -direction.y);
+	}
+	else if (a.y >= a.z) {
+		ma = a.y;
+		face = direction.y > 0 ? 2 : 3;
+		st = vec2(direction.x, direction.y > 0 ? direction.z : -direction.z);
+	}
+	else {
+		ma = a.z;
+		face = direction.z > 0 ? 4 : 5;
+		st = vec2(direction.z > 0 ? direction.x : -direction.x, -direction.y);
+	}
+	vec2 uv = (st / ma + 1) * 0.5;
+
+	// keep bilinear filtering from bleeding into neighbouring faces
+	float size = _env_size(index).y;
+	uv = clamp(uv, 0.5 / size, 1 - 0.5 / size);
+
+	return _env_texture(index, vec2((face + uv.x) / 6, uv.y)).rgb;
+}
+
+// evaluates cosine convolved spherical harmonics, divided by pi
+vec3 irradianceSH(vec4 sh[9], vec3 n) {
+	vec3 result =
+		sh[0].rgb * 0.282095 +
+		(2.0 / 3.0) * 0.488603 * (sh[1].rgb * n.y + sh[2].rgb * n.z + sh[3].rgb * n.x) +
+		0.25 * (
+			sh[4].rgb * 1.092548 * n.x * n.y +
+			sh[5].rgb * 1.092548 * n.y * n.z +
+			sh[6].rgb * 0.315392 * (3 * n.z * n.z - 1) +
+			sh[7].rgb * 1.092548 * n.x * n.z +
+			sh[8].rgb * 0.546274 * (n.x * n.x - n.y * n.y));
+	return max(result, vec3(0));
+}
+
+// diffuse ambient light from the environment irradiance map or the sky.
+// falls back to the flat ambient color if neither is available
+vec3 environmentDiffuse(LightSettings settings, vec3 normal, float occlusion) {
+	if (settings.EnvIrradiance != 0) {
+		return sampleCubeStrip(settings.EnvIrradiance, normal) * settings.EnvIntensity * occlusion;
+	}
+	if (settings.SkyAmbient != 0) {
+		return irradianceSH(settings.SkySH, normal) * occlusion;
+	}
+	return ambientLight(settings, occlusion);
+}
+
+// specular ambient light of a surface with the given roughness, using the split sum approximation
+vec3 environmentSpecular(LightSettings settings, vec3 normal, vec3 viewDir, float roughness, float occlusion) {
+	if (settings.EnvIrradiance == 0) {
+		return vec3(0);
+	}
+
+	// blend between the two closest prefiltered roughness levels
+	roughness = clamp(roughness, 0, 1);
+	vec3 reflected = reflect(-viewDir, normal);
+	float level = roughness * (ENV_LEVELS - 1);
+	int level0 = int(floor(level));
+	int level1 = min(level0 + 1, ENV_LEVELS - 1);
+	vec3 prefiltered = mix(
+		sampleCubeStrip(settings.EnvSpecular[level0], reflected),
+		sampleCubeStrip(settings.EnvSpecular[level1], reflected),
+		level - level0);
+
+	// dielectric base reflectivity
+	const vec3 F0 = vec3(0.04);
+	float NdotV = max(dot(normal, viewDir), 0);
+	vec2 brdf = _env_texture(settings.EnvBRDF, vec2(NdotV, roughness)).rg;
+
+	return prefiltered * (F0 * brdf.x + brdf.y) * settings.EnvIntensity * occlusion;
+}
+
 vec3 calculateLightColor(Light light, vec3 position, vec3 normal, float depth, LightSettings settings) {
 	float contrib = 0.0;
 	float shadow = 1.0;
@@ -187,20 +429,20 @@ vec3 calculateLightColor(Light light, vec3 position, vec3 normal, float depth, L
 		vec3 surfaceToLight = -lightDir;
 		contrib = max(dot(surfaceToLight, normal), 0.0);
 
-		float bias = settings.ShadowBias * max(0.0, 1.0 - dot(normal, lightDir));
-		position += normal * settings.NormalOffset;
-		shadow = blendCascades(light, position, depth, light.Range, settings);
-
-#if DEBUG_CASCADES
-		int index = -1;
-		for(int i = 0; i < SHADOW_CASCADES; i++) {
-			if (depth < light.Distance[i]) {
-				index = i;
-				break;
+		// surfaces at grazing angles need more bias. cascades apply their own normal offset
+		float slope = 1.0 - contrib;
+		shadow = blendCascades(light, position, normal, depth, light.Range, slope, settings);
+
+		if (settings.DebugMode == DEBUG_CASCADES) {
+			int index = SHADOW_CASCADES - 1;
+			for(int i = 0; i < SHADOW_CASCADES; i++) {
+				if (depth < light.Distance[i]) {
+					index = i;
+					break;
+				}
 			}
+			return contrib * shadow * debug_cascade_color(index);
 		}
-		return contrib * shadow * mix(vec3(0,1,0), vec3(1,0,0), float(index) / (SHADOW_CASCADES - 1));
-#endif
 	}
 	else if (light.Type == POINT_LIGHT) {
 		// calculate light vector & distance
@@ -208,6 +450,10 @@ vec3 calculateLightColor(Light light, vec3 position, vec3 normal, float depth, L
 		float distanceToLight = length(surfaceToLight);
 		surfaceToLight = normalize(surfaceToLight);
 		contrib = calculatePointLightContrib(light, surfaceToLight, distanceToLight, normal);
+
+		if (contrib > 0) {
+			shadow = samplePointShadow(light, position + normal * settings.NormalOffset, settings);
+		}
 	} 
 
 	return light.Color.rgb * light.Intensity * contrib * shadow;
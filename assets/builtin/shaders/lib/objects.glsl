@@ -16,9 +16,59 @@ struct Object {
 
 	uint64_t vertexPtr;
 	uint64_t indexPtr;
+
+	mat4 prevModel;
+
+	uint layer;
+	float dither;
+
+	uint64_t instancePtr;
+};
+
+// Size: 80 bytes
+struct Instance {
+	mat4 model;
+	vec4 color;
 };
 
-#define get_object_index() (gl_InstanceIndex)
+layout(buffer_reference, scalar, buffer_reference_align=16) readonly buffer InstanceBuffer { Instance instance; };
+
+// objects are drawn with their handle as the base instance.
+// instanced meshes draw all their instances with the same object handle.
+#define get_object_index() (gl_BaseInstanceARB)
+#define get_instance_index() (gl_InstanceIndex - gl_BaseInstanceARB)
+
+// returns the model matrix of an object instance
+mat4 object_model(Object obj, int instance) {
+	if (obj.instancePtr == 0) {
+		return obj.model;
+	}
+	return obj.model * InstanceBuffer(obj.instancePtr)[instance].instance.model;
+}
+
+// returns the model matrix of an object instance in the previous frame
+mat4 object_prev_model(Object obj, int instance) {
+	if (obj.instancePtr == 0) {
+		return obj.prevModel;
+	}
+	return obj.prevModel * InstanceBuffer(obj.instancePtr)[instance].instance.model;
+}
+
+// returns the color tint of an object instance
+vec4 object_color(Object obj, int instance) {
+	if (obj.instancePtr == 0) {
+		return vec4(1);
+	}
+	return InstanceBuffer(obj.instancePtr)[instance].instance.color;
+}
+
+// returns the screen space motion of a vertex caused by changes to its object transform since the previous frame.
+// camera motion is not included, it is reconstructed from depth during reprojection.
+vec2 object_motion(mat4 prevViewProj, Object obj, int instance, vec3 position) {
+	vec4 prev = prevViewProj * object_prev_model(obj, instance) * vec4(position, 1);
+	vec4 curr = prevViewProj * object_model(obj, instance) * vec4(position, 1);
+	return (prev.xy / prev.w - curr.xy / curr.w) * 0.5;
+}
 
 #define get_vertex_indexed(vertexPtr, indexPtr) (VertexBuffer(vertexPtr)[IndexBuffer(indexPtr)[gl_VertexIndex].index].vertex)
 #define get_vertex(vertexPtr) (VertexBuffer(vertexPtr)[gl_VertexIndex].vertex)
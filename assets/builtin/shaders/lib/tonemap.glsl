@@ -0,0 +1,81 @@
+// tone mapping operators, mirrors render/tonemap
+#define TONEMAP_EXPONENTIAL 0
+#define TONEMAP_REINHARD 1
+#define TONEMAP_ACES 2
+#define TONEMAP_AGX 3
+#define TONEMAP_FILMIC 4
+
+#define EXPOSURE_KEY 0.18
+#define HISTOGRAM_BINS 64
+#define MIN_LUMINANCE (1.0 / 65536.0)
+
+float luminance(vec3 color) {
+    return dot(color, vec3(0.2126, 0.7152, 0.0722));
+}
+
+vec3 tonemapACES(vec3 x) {
+    const float a = 2.51;
+    const float b = 0.03;
+    const float c = 2.43;
+    const float d = 0.59;
+    const float e = 0.14;
+    return clamp((x * (a * x + b)) / (x * (c * x + d) + e), 0.0, 1.0);
+}
+
+vec3 hable(vec3 x) {
+    const float a = 0.15;
+    const float b = 0.50;
+    const float c = 0.10;
+    const float d = 0.20;
+    const float e = 0.02;
+    const float f = 0.30;
+    return ((x * (a * x + c * b) + d * e) / (x * (a * x + b) + d * f)) - e / f;
+}
+
+vec3 tonemapFilmic(vec3 x) {
+    const float white = 11.2;
+    const float bias = 2.0;
+    return clamp(hable(x * bias) / hable(vec3(white)), 0.0, 1.0);
+}
+
+vec3 agxContrast(vec3 x) {
+    vec3 x2 = x * x;
+    vec3 x4 = x2 * x2;
+    return 15.5 * x4 * x2 - 40.14 * x4 * x + 31.96 * x4 - 6.868 * x2 * x + 0.4298 * x2 + 0.1191 * x - 0.00232;
+}
+
+vec3 tonemapAgX(vec3 color) {
+    const mat3 inset = mat3(
+        0.842479062253094, 0.0423282422610123, 0.0423756549057051,
+        0.0784335999999992, 0.878468636469772, 0.0784336,
+        0.0792237451477643, 0.0791661274605434, 0.879142973793104);
+    const mat3 outset = mat3(
+        1.19687900512017, -0.0528968517574562, -0.0529716355144438,
+        -0.0980208811401368, 1.15190312990417, -0.0980434501171241,
+        -0.0990297440797205, -0.0989611768448433, 1.15107367264116);
+    const float minEV = -12.47393;
+    const float maxEV = 4.026069;
+
+    vec3 c = inset * color;
+    c = clamp(log2(max(c, vec3(1e-10))), minEV, maxEV);
+    c = agxContrast((c - minEV) / (maxEV - minEV));
+    c = outset * c;
+
+    // the curve produces display encoded values, linearize them
+    return pow(clamp(c, 0.0, 1.0), vec3(2.2));
+}
+
+vec3 tonemap(int op, vec3 color) {
+    switch (op) {
+    case TONEMAP_REINHARD:
+        return color / (1.0 + color);
+    case TONEMAP_ACES:
+        return tonemapACES(color);
+    case TONEMAP_AGX:
+        return tonemapAgX(color);
+    case TONEMAP_FILMIC:
+        return tonemapFilmic(color);
+    default:
+        return vec3(1.0) - exp(-color);
+    }
+}
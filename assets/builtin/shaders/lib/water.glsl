@@ -0,0 +1,58 @@
+#define MAX_WAVES 8
+
+// gravitational acceleration used to derive the speed of waves from their wavelength
+#define WATER_GRAVITY 9.81
+
+const float WATER_TAU = 6.28318530718;
+
+// Matches uniform.Water
+struct Water {
+	mat4 Model;
+
+	// direction in xy, steepness in z, wavelength in w
+	vec4 Waves[MAX_WAVES];
+
+	vec4 Absorption;
+	vec4 Color;
+
+	// foam color in rgb, foam depth in a
+	vec4 FoamColor;
+
+	float Time;
+	int WaveCount;
+	float Refraction;
+	float _padding;
+};
+
+// water surfaces are bound in the second descriptor set of the forward pass
+#define WATERS(idx,name) layout (std430, set = 1, binding = idx) readonly buffer WaterBuffer { Water item[]; } name;
+#define WATER_SAMPLER(idx,name) layout (set = 1, binding = idx) uniform sampler2D tex_ ## name;
+
+// displaces a point on the undisturbed surface by the gerstner waves of a water surface, in local space.
+// returns the displaced position, and writes the surface normal. matches water.Water.Displace
+vec3 gerstner(Water water, vec2 point, out vec3 normal) {
+	vec3 position = vec3(point.x, 0, point.y);
+	vec3 tangent = vec3(1, 0, 0);
+	vec3 binormal = vec3(0, 0, 1);
+	for (int i = 0; i < water.WaveCount; i++) {
+		vec4 wave = water.Waves[i];
+		if (wave.w <= 0 || dot(wave.xy, wave.xy) == 0) {
+			continue;
+		}
+
+		float k = WATER_TAU / wave.w;
+		float speed = sqrt(WATER_GRAVITY / k);
+		vec2 dir = normalize(wave.xy);
+		float phase = k * (dot(dir, point) - speed * water.Time);
+		float steepness = wave.z;
+		float amplitude = steepness / k;
+		float s = sin(phase);
+		float c = cos(phase);
+
+		position += vec3(dir.x * amplitude * c, amplitude * s, dir.y * amplitude * c);
+		tangent += vec3(-dir.x * dir.x * steepness * s, dir.x * steepness * c, -dir.x * dir.y * steepness * s);
+		binormal += vec3(-dir.x * dir.y * steepness * s, dir.y * steepness * c, -dir.y * dir.y * steepness * s);
+	}
+	normal = normalize(cross(binormal, tangent));
+	return position;
+}
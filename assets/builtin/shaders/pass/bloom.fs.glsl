@@ -0,0 +1,18 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/bloom.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+BLOOM_PARAMS(0)
+SAMPLER(1, input)
+SAMPLER(2, dirt)
+
+void main() {
+    vec3 bloom = texture(tex_input, in_texcoord).rgb;
+    vec3 dirt = texture(tex_dirt, in_texcoord).rgb * params.DirtIntensity;
+
+    // blended additively with the hdr buffer
+    out_color = vec4(bloom * params.Intensity * (1.0 + dirt), 1);
+}
@@ -0,0 +1,74 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/bloom.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+BLOOM_PARAMS(0)
+SAMPLER(1, input)
+
+float luminance(vec3 color) {
+    return dot(color, vec3(0.2126, 0.7152, 0.0722));
+}
+
+// quadratic soft threshold curve
+vec3 threshold(vec3 color) {
+    float brightness = max(color.r, max(color.g, color.b));
+    float soft = clamp(brightness - params.Threshold + params.Knee, 0.0, 2.0 * params.Knee);
+    soft = soft * soft / (4.0 * params.Knee + 0.00001);
+    float contribution = max(soft, brightness - params.Threshold) / max(brightness, 0.00001);
+    return color * contribution;
+}
+
+// karis average weight, suppresses fireflies from very bright single pixels
+float karis(vec3 color) {
+    return 1.0 / (1.0 + luminance(color));
+}
+
+void main() {
+    vec2 texel = 1.0 / vec2(textureSize(tex_input, 0));
+    vec2 uv = in_texcoord;
+
+    // 13 tap downsample filter
+    vec3 a = texture(tex_input, uv + texel * vec2(-2, -2)).rgb;
+    vec3 b = texture(tex_input, uv + texel * vec2( 0, -2)).rgb;
+    vec3 c = texture(tex_input, uv + texel * vec2( 2, -2)).rgb;
+    vec3 d = texture(tex_input, uv + texel * vec2(-2,  0)).rgb;
+    vec3 e = texture(tex_input, uv).rgb;
+    vec3 f = texture(tex_input, uv + texel * vec2( 2,  0)).rgb;
+    vec3 g = texture(tex_input, uv + texel * vec2(-2,  2)).rgb;
+    vec3 h = texture(tex_input, uv + texel * vec2( 0,  2)).rgb;
+    vec3 i = texture(tex_input, uv + texel * vec2( 2,  2)).rgb;
+    vec3 j = texture(tex_input, uv + texel * vec2(-1, -1)).rgb;
+    vec3 k = texture(tex_input, uv + texel * vec2( 1, -1)).rgb;
+    vec3 l = texture(tex_input, uv + texel * vec2(-1,  1)).rgb;
+    vec3 m = texture(tex_input, uv + texel * vec2( 1,  1)).rgb;
+
+    vec3 groups[5] = vec3[](
+        (j + k + l + m) * 0.25,
+        (a + b + d + e) * 0.25,
+        (b + c + e + f) * 0.25,
+        (d + e + g + h) * 0.25,
+        (e + f + h + i) * 0.25
+    );
+    float weights[5] = float[](0.5, 0.125, 0.125, 0.125, 0.125);
+
+    vec3 result = vec3(0);
+    if (params.Prefilter != 0) {
+        float total = 0.0;
+        for (int n = 0; n < 5; n++) {
+            vec3 group = threshold(groups[n]);
+            float w = weights[n] * karis(group);
+            result += group * w;
+            total += w;
+        }
+        result /= max(total, 0.00001);
+    } else {
+        for (int n = 0; n < 5; n++) {
+            result += groups[n] * weights[n];
+        }
+    }
+
+    out_color = vec4(max(result, vec3(0)), 1);
+}
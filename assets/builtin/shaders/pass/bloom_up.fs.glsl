@@ -0,0 +1,28 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/bloom.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+BLOOM_PARAMS(0)
+SAMPLER(1, input)
+
+void main() {
+    vec2 texel = params.Radius / vec2(textureSize(tex_input, 0));
+    vec2 uv = in_texcoord;
+
+    // 3x3 tent filter
+    vec3 result = texture(tex_input, uv).rgb * 4.0;
+    result += texture(tex_input, uv + texel * vec2( 0, -1)).rgb * 2.0;
+    result += texture(tex_input, uv + texel * vec2(-1,  0)).rgb * 2.0;
+    result += texture(tex_input, uv + texel * vec2( 1,  0)).rgb * 2.0;
+    result += texture(tex_input, uv + texel * vec2( 0,  1)).rgb * 2.0;
+    result += texture(tex_input, uv + texel * vec2(-1, -1)).rgb;
+    result += texture(tex_input, uv + texel * vec2( 1, -1)).rgb;
+    result += texture(tex_input, uv + texel * vec2(-1,  1)).rgb;
+    result += texture(tex_input, uv + texel * vec2( 1,  1)).rgb;
+
+    // blended additively with the level above
+    out_color = vec4(result / 16.0, 1);
+}
@@ -0,0 +1,165 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/objects.glsl"
+
+layout (local_size_x = 64) in;
+
+// must match the constants of the draw culler
+#define MAX_CULL_GROUPS 256
+#define PYRAMID_MAX_LEVELS 16
+
+struct DrawCommand {
+	uint vertexCount;
+	uint instanceCount;
+	int firstVertex;
+	uint firstInstance;
+};
+
+struct CullDraw {
+	vec4 bounds;
+	uint object;
+	uint vertices;
+	uint instances;
+	uint offset;
+	uint group;
+	uint occluded;
+	uint _pad[2];
+};
+
+// set 0 is the descriptor set of the geometry pass
+layout (scalar, set = 0, binding = 1) readonly buffer uniform_objects { Object item[]; } objects;
+
+layout (set = 1, binding = 0) uniform uniform_params {
+	vec4 planes[6];
+	mat4 viewProj;
+	mat4 prevViewProj;
+	vec2 pyramidSize;
+	uint count;
+	uint maxDraws;
+	uint levels;
+	uint occlusion;
+} params;
+layout (std430, set = 1, binding = 1) buffer uniform_draws { CullDraw item[]; } draws;
+layout (std430, set = 1, binding = 2) writeonly buffer uniform_commands { DrawCommand item[]; } commands;
+layout (std430, set = 1, binding = 3) buffer uniform_counts { uint item[]; } counts;
+layout (std430, set = 1, binding = 4) buffer uniform_stats {
+	uint objects;
+	uint frustum;
+	uint occluded;
+	uint recovered;
+} stats;
+layout (set = 1, binding = 5) uniform sampler2D pyramid[];
+
+layout (push_constant) uniform Phase {
+	uint phase;
+} push;
+
+// returns the world space bounding sphere of a draw
+vec4 world_bounds(CullDraw draw) {
+	mat4 model = objects.item[draw.object].model;
+	vec3 center = (model * vec4(draw.bounds.xyz, 1)).xyz;
+	float scale = sqrt(max(dot(model[0].xyz, model[0].xyz), max(dot(model[1].xyz, model[1].xyz), dot(model[2].xyz, model[2].xyz))));
+	return vec4(center, draw.bounds.w * scale);
+}
+
+bool frustum_visible(vec4 sphere) {
+	for (int i = 0; i < 6; i++) {
+		if (dot(params.planes[i].xyz, sphere.xyz) + params.planes[i].w <= -sphere.w) {
+			return false;
+		}
+	}
+	return true;
+}
+
+// tests a bounding sphere against a depth pyramid built using the given view projection.
+// base is the index of the first pyramid level in the sampler array.
+bool occluded(vec4 sphere, mat4 viewProj, uint base) {
+	// project the corners of the bounding box of the sphere onto the screen
+	vec2 lo = vec2(1);
+	vec2 hi = vec2(0);
+	float depth = 1;
+	for (int i = 0; i < 8; i++) {
+		vec3 corner = sphere.xyz + sphere.w * vec3(
+			(i & 1) != 0 ? 1 : -1,
+			(i & 2) != 0 ? 1 : -1,
+			(i & 4) != 0 ? 1 : -1);
+		vec4 clip = viewProj * vec4(corner, 1);
+		if (clip.w <= 0) {
+			// the bounds cross the camera plane
+			return false;
+		}
+		vec3 ndc = clip.xyz / clip.w;
+		vec2 uv = ndc.xy * 0.5 + 0.5;
+		lo = min(lo, uv);
+		hi = max(hi, uv);
+		depth = min(depth, ndc.z);
+	}
+	lo = clamp(lo, 0, 1);
+	hi = clamp(hi, 0, 1);
+
+	// pick the level where the bounds cover at most 2x2 texels
+	vec2 extent = (hi - lo) * params.pyramidSize;
+	uint level = uint(ceil(log2(max(max(extent.x, extent.y), 1))));
+	level = min(level, params.levels - 1);
+
+	uint index = base + level;
+	float farthest = max(
+		max(textureLod(pyramid[nonuniformEXT(index)], vec2(lo.x, lo.y), 0).r,
+		    textureLod(pyramid[nonuniformEXT(index)], vec2(hi.x, lo.y), 0).r),
+		max(textureLod(pyramid[nonuniformEXT(index)], vec2(lo.x, hi.y), 0).r,
+		    textureLod(pyramid[nonuniformEXT(index)], vec2(hi.x, hi.y), 0).r));
+
+	return depth > farthest;
+}
+
+// append a draw to the compacted command list of its render group
+void emit(CullDraw draw, uint phase) {
+	uint slot = atomicAdd(counts.item[phase * MAX_CULL_GROUPS + draw.group], 1);
+	commands.item[phase * params.maxDraws + draw.offset + slot] = DrawCommand(draw.vertices, draw.instances, 0, draw.object);
+}
+
+void main() 
+{
+	uint index = gl_GlobalInvocationID.x;
+	if (index >= params.count) {
+		return;
+	}
+
+	CullDraw draw = draws.item[index];
+
+	// draws without bounds are always visible
+	if (draw.bounds.w <= 0) {
+		if (push.phase == 0) {
+			atomicAdd(stats.objects, 1);
+			emit(draw, 0);
+		}
+		return;
+	}
+
+	vec4 sphere = world_bounds(draw);
+
+	if (push.phase == 0) {
+		atomicAdd(stats.objects, 1);
+		if (!frustum_visible(sphere)) {
+			atomicAdd(stats.frustum, 1);
+			return;
+		}
+		if (params.occlusion != 0 && occluded(sphere, params.prevViewProj, 0)) {
+			// retested against the current frame in the second phase
+			draws.item[index].occluded = 1;
+			atomicAdd(stats.occluded, 1);
+			return;
+		}
+		emit(draw, 0);
+	} else {
+		if (draw.occluded == 0) {
+			return;
+		}
+		if (occluded(sphere, params.viewProj, PYRAMID_MAX_LEVELS)) {
+			return;
+		}
+		atomicAdd(stats.recovered, 1);
+		emit(draw, 1);
+	}
+}
@@ -0,0 +1,81 @@
+#version 450
+
+#include "lib/common.glsl"
+
+// must match engine.DebugMode
+#define DEBUG_ALBEDO 1
+#define DEBUG_NORMALS 2
+#define DEBUG_POSITION 3
+#define DEBUG_DEPTH 4
+#define DEBUG_OCCLUSION 5
+#define DEBUG_CASCADES 6
+#define DEBUG_LIGHT_COUNT 7
+#define DEBUG_OVERDRAW 8
+
+CAMERA(0, camera)
+SAMPLER(1, diffuse)
+SAMPLER(2, normal)
+SAMPLER(3, position)
+SAMPLER(4, depth)
+SAMPLER(5, occlusion)
+SAMPLER(6, lit)
+SAMPLER(7, overdraw)
+
+layout (push_constant) uniform View {
+	int Mode;
+	float Far;
+	float MaxOverdraw;
+} view;
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+
+void main() {
+	vec3 color = vec3(0);
+	switch (view.Mode) {
+	case DEBUG_ALBEDO:
+		color = texture(tex_diffuse, in_texcoord).rgb;
+		break;
+
+	case DEBUG_NORMALS:
+		// normals are stored packed in [0,1]
+		color = texture(tex_normal, in_texcoord).xyz;
+		break;
+
+	case DEBUG_POSITION: {
+		// compress view space positions into [0,1], with the camera at 0.5
+		vec3 pos = texture(tex_position, in_texcoord).xyz;
+		color = 0.5 + 0.5 * pos / (1 + abs(pos));
+		break;
+	}
+
+	case DEBUG_DEPTH: {
+		float depth = texture(tex_depth, in_texcoord).r;
+		vec4 ndc = vec4(in_texcoord * 2 - 1, depth, 1);
+		vec4 viewPos = camera.ProjInv * ndc;
+		float dist = abs(viewPos.z / viewPos.w);
+		color = vec3(sqrt(clamp(dist / view.Far, 0, 1)));
+		break;
+	}
+
+	case DEBUG_OCCLUSION: {
+		float ssao = texture(tex_occlusion, in_texcoord).r;
+		color = vec3(ssao == 0 ? 1 : ssao);
+		break;
+	}
+
+	case DEBUG_CASCADES:
+	case DEBUG_LIGHT_COUNT:
+		// written by the lighting pass
+		color = clamp(texture(tex_lit, in_texcoord).rgb, 0, 1);
+		break;
+
+	case DEBUG_OVERDRAW: {
+		float count = texture(tex_overdraw, in_texcoord).r;
+		color = count > 0 ? debug_heatmap(count / view.MaxOverdraw) : vec3(0);
+		break;
+	}
+	}
+
+	out_color = vec4(color, 1);
+}
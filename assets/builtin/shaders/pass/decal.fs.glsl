@@ -0,0 +1,66 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/decal.glsl"
+
+IN(0, flat uint, decal)
+OUT(0, vec4, diffuse)
+OUT(1, vec4, normal)
+
+CAMERA(0, camera)
+DECALS(1, decals)
+SAMPLER(2, position)
+SAMPLER_ARRAY(3, textures)
+
+void main() 
+{
+	Decal decal = decals.item[in_decal];
+
+	// reconstruct the world position of the surface behind the fragment.
+	// the mesh layer mask is stored in the alpha channel of the position buffer
+	vec2 uv = gl_FragCoord.xy / vec2(textureSize(tex_position, 0));
+	vec4 gposition = texture(tex_position, uv);
+	vec3 world = (camera.ViewInv * vec4(gposition.xyz, 1)).xyz;
+
+	// surface normal from screen space derivatives, facing the camera.
+	// derivatives must be computed before any fragment is discarded
+	vec3 surfaceNormal = normalize(cross(dFdx(world), dFdy(world)));
+	if (dot(surfaceNormal, camera.Eye.xyz - world) < 0) {
+		surfaceNormal = -surfaceNormal;
+	}
+
+	uint layer = uint(gposition.a);
+	if ((layer & decal.Layers) == 0) {
+		discard;
+	}
+
+	// discard surfaces outside the projection box
+	vec3 local = (decal.ModelInv * vec4(world, 1)).xyz;
+	if (any(greaterThan(abs(local), vec3(0.5)))) {
+		discard;
+	}
+
+	// decal axes. the decal projects along its forward axis
+	vec3 right = normalize(decal.Model[0].xyz);
+	vec3 up = normalize(decal.Model[1].xyz);
+	vec3 forward = normalize(decal.Model[2].xyz);
+
+	// fade out on surfaces at steep angles to the projection direction
+	float facing = dot(surfaceNormal, -forward);
+	float fade = smoothstep(0, max(decal.AngleFade, 0.001), facing);
+
+	vec2 texcoord = vec2(local.x + 0.5, 0.5 - local.y);
+	vec4 color = texture_array(textures, decal.Diffuse, texcoord) * decal.Color;
+	float alpha = color.a * fade;
+	out_diffuse = vec4(color.rgb, alpha);
+
+	if (decal.Normal >= 0) {
+		// tangent space normals are oriented along the decal axes
+		vec3 tangentNormal = texture_array(textures, uint(decal.Normal), texcoord).xyz * 2 - 1;
+		vec3 worldNormal = normalize(right * tangentNormal.x + up * tangentNormal.y - forward * tangentNormal.z);
+		vec3 viewNormal = normalize((camera.View * vec4(worldNormal, 0)).xyz);
+		out_normal = vec4(pack_normal(viewNormal).xyz, alpha);
+	} else {
+		out_normal = vec4(0);
+	}
+}
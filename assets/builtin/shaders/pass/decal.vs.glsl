@@ -0,0 +1,21 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/decal.glsl"
+
+IN(0, vec3, position)
+OUT(0, flat uint, decal)
+
+CAMERA(0, camera)
+DECALS(1, decals)
+
+out gl_PerVertex 
+{
+	vec4 gl_Position;   
+};
+
+void main() 
+{
+	out_decal = gl_InstanceIndex;
+	gl_Position = camera.ViewProj * decals.item[gl_InstanceIndex].Model * vec4(in_position, 1);
+}
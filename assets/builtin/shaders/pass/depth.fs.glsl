@@ -1,11 +1,19 @@
 #version 450
 
 #include "lib/common.glsl"
+#include "lib/objects.glsl"
+#include "lib/dither.glsl"
 
 IN(0, vec3, position)
+IN(1, flat uint, object)
 OUT(0, vec4, position)
 
+OBJECT(1, object, in_object)
+
 void main() 
 {
+	// match the level of detail cross-fade of the geometry passes
+	lod_dither(object.dither);
+
     out_position = vec4(in_position, 1);
 }
@@ -4,6 +4,7 @@
 #include "lib/objects.glsl"
 
 OUT(0, vec3, position)
+OUT(1, flat uint, object)
 
 out gl_PerVertex 
 {
@@ -11,17 +12,19 @@ out gl_PerVertex
 };
 
 CAMERA(0, camera)
-OBJECT(1, object, gl_InstanceIndex)
+OBJECT(1, object, get_object_index())
 
 VERTEX_BUFFER(Vertex)
 INDEX_BUFFER(uint)
 
 void main() 
 {
+	out_object = get_object_index();
+
 	// load vertex data
 	Vertex v = get_vertex_indexed(object.vertexPtr, object.indexPtr);
 
-	mat4 mv = camera.View * object.model;
+	mat4 mv = camera.View * object_model(object, get_instance_index());
 
 	// gbuffer view position
 	// todo: can this be removed? probably just a waste of bandwidth
@@ -0,0 +1,26 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, float, depth)
+SAMPLER(0, input)
+
+void main() {
+    // each level is half the size of the level above it, rounded down.
+    // odd sizes make the footprint of a texel up to 3 texels wide.
+    ivec2 inSize = textureSize(tex_input, 0);
+    ivec2 outSize = max(inSize / 2, ivec2(1));
+    ivec2 coord = ivec2(gl_FragCoord.xy);
+    ivec2 start = coord * inSize / outSize;
+    ivec2 end = min(((coord + 1) * inSize + outSize - 1) / outSize, inSize);
+
+    // keep the farthest depth of the footprint, so that the pyramid never hides visible objects
+    float depth = 0;
+    for (int y = start.y; y < end.y; y++) {
+        for (int x = start.x; x < end.x; x++) {
+            depth = max(depth, texelFetch(tex_input, ivec2(x, y), 0).r);
+        }
+    }
+    out_depth = depth;
+}
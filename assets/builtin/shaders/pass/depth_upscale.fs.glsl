@@ -0,0 +1,12 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, vec2, texcoord)
+SAMPLER(0, depth)
+
+void main() 
+{
+	// nearest sampling keeps the depth of a single surface
+	gl_FragDepth = texture(tex_depth, in_texcoord).r;
+}
@@ -0,0 +1,75 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/tonemap.glsl"
+
+// the input is sampled on a regular grid of SAMPLES x SAMPLES points
+#define SAMPLES 64
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, ev)
+UNIFORM(0, params, {
+    float MinEV;
+    float MaxEV;
+    float Speed;
+    float Delta;
+    float Low;
+    float High;
+    int Reset;
+})
+SAMPLER(1, input)
+SAMPLER(2, previous)
+
+void main() {
+    // build a histogram of log2 luminance
+    float bins[HISTOGRAM_BINS];
+    for (int i = 0; i < HISTOGRAM_BINS; i++) {
+        bins[i] = 0.0;
+    }
+    float range = params.MaxEV - params.MinEV;
+    for (int y = 0; y < SAMPLES; y++) {
+        for (int x = 0; x < SAMPLES; x++) {
+            vec2 uv = (vec2(x, y) + 0.5) / float(SAMPLES);
+            float lum = luminance(texture(tex_input, uv).rgb);
+            if (lum < MIN_LUMINANCE) {
+                continue;
+            }
+            float t = (log2(lum) - params.MinEV) / range;
+            int bin = clamp(int(t * HISTOGRAM_BINS), 0, HISTOGRAM_BINS - 1);
+            bins[bin] += 1.0;
+        }
+    }
+
+    // average the bins within the percentile window
+    float total = 0.0;
+    for (int i = 0; i < HISTOGRAM_BINS; i++) {
+        total += bins[i];
+    }
+    float lo = total * params.Low;
+    float hi = total * params.High;
+    float sum = 0.0;
+    float weight = 0.0;
+    float cumulative = 0.0;
+    for (int i = 0; i < HISTOGRAM_BINS; i++) {
+        float start = cumulative;
+        float end = cumulative + bins[i];
+        cumulative = end;
+
+        float included = min(end, hi) - max(start, lo);
+        if (included > 0.0) {
+            float center = params.MinEV + (float(i) + 0.5) / HISTOGRAM_BINS * range;
+            sum += included * center;
+            weight += included;
+        }
+    }
+    float target = weight > 0.0 ? sum / weight : params.MinEV;
+
+    // adapt towards the target
+    float adapted = target;
+    if (params.Reset == 0 && params.Speed > 0.0) {
+        float current = texelFetch(tex_previous, ivec2(0), 0).r;
+        adapted = current + (target - current) * (1.0 - exp(-params.Speed * params.Delta));
+    }
+
+    out_ev = vec4(adapted, 0, 0, 1);
+}
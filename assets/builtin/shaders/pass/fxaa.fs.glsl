@@ -0,0 +1,139 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/tonemap.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+UNIFORM(0, params, {
+    int Enabled;
+})
+SAMPLER(1, input)
+
+#define EDGE_THRESHOLD_MIN 0.0312
+#define EDGE_THRESHOLD_MAX 0.125
+#define SUBPIXEL_QUALITY 0.75
+#define ITERATIONS 12
+
+float luma(vec2 uv) {
+    // the input is already gamma corrected, so its luminance approximates perceived brightness
+    return luminance(texture(tex_input, uv).rgb);
+}
+
+void main() {
+    vec3 center = texture(tex_input, in_texcoord).rgb;
+    if (params.Enabled == 0) {
+        out_color = vec4(center, 1);
+        return;
+    }
+
+    vec2 texel = 1.0 / vec2(textureSize(tex_input, 0));
+    vec2 uv = in_texcoord;
+
+    // local contrast check
+    float lumaC = luminance(center);
+    float lumaD = luma(uv + vec2(0, 1) * texel);
+    float lumaU = luma(uv + vec2(0, -1) * texel);
+    float lumaL = luma(uv + vec2(-1, 0) * texel);
+    float lumaR = luma(uv + vec2(1, 0) * texel);
+
+    float lumaMin = min(lumaC, min(min(lumaD, lumaU), min(lumaL, lumaR)));
+    float lumaMax = max(lumaC, max(max(lumaD, lumaU), max(lumaL, lumaR)));
+    float range = lumaMax - lumaMin;
+    if (range < max(EDGE_THRESHOLD_MIN, lumaMax * EDGE_THRESHOLD_MAX)) {
+        out_color = vec4(center, 1);
+        return;
+    }
+
+    float lumaDL = luma(uv + vec2(-1, 1) * texel);
+    float lumaUR = luma(uv + vec2(1, -1) * texel);
+    float lumaUL = luma(uv + vec2(-1, -1) * texel);
+    float lumaDR = luma(uv + vec2(1, 1) * texel);
+
+    // estimate edge orientation
+    float lumaDU = lumaD + lumaU;
+    float lumaLR = lumaL + lumaR;
+    float lumaLeftCorners = lumaDL + lumaUL;
+    float lumaDownCorners = lumaDL + lumaDR;
+    float lumaRightCorners = lumaDR + lumaUR;
+    float lumaUpCorners = lumaUR + lumaUL;
+
+    float edgeHorizontal = abs(-2.0 * lumaL + lumaLeftCorners) + abs(-2.0 * lumaC + lumaDU) * 2.0 + abs(-2.0 * lumaR + lumaRightCorners);
+    float edgeVertical = abs(-2.0 * lumaU + lumaUpCorners) + abs(-2.0 * lumaC + lumaLR) * 2.0 + abs(-2.0 * lumaD + lumaDownCorners);
+    bool horizontal = edgeHorizontal >= edgeVertical;
+
+    // pick the side of the edge with the steepest gradient
+    float luma1 = horizontal ? lumaU : lumaL;
+    float luma2 = horizontal ? lumaD : lumaR;
+    float gradient1 = luma1 - lumaC;
+    float gradient2 = luma2 - lumaC;
+    bool steepest1 = abs(gradient1) >= abs(gradient2);
+    float gradientScaled = 0.25 * max(abs(gradient1), abs(gradient2));
+
+    float stepLength = horizontal ? texel.y : texel.x;
+    float lumaLocalAverage;
+    if (steepest1) {
+        stepLength = -stepLength;
+        lumaLocalAverage = 0.5 * (luma1 + lumaC);
+    } else {
+        lumaLocalAverage = 0.5 * (luma2 + lumaC);
+    }
+
+    vec2 edgeUV = uv;
+    if (horizontal) {
+        edgeUV.y += stepLength * 0.5;
+    } else {
+        edgeUV.x += stepLength * 0.5;
+    }
+
+    // explore along the edge in both directions until its end is found
+    vec2 offset = horizontal ? vec2(texel.x, 0) : vec2(0, texel.y);
+    vec2 uv1 = edgeUV - offset;
+    vec2 uv2 = edgeUV + offset;
+    float lumaEnd1 = luma(uv1) - lumaLocalAverage;
+    float lumaEnd2 = luma(uv2) - lumaLocalAverage;
+    bool reached1 = abs(lumaEnd1) >= gradientScaled;
+    bool reached2 = abs(lumaEnd2) >= gradientScaled;
+
+    for (int i = 0; i < ITERATIONS && !(reached1 && reached2); i++) {
+        float stride = i < 4 ? 1.0 : 2.0;
+        if (!reached1) {
+            uv1 -= offset * stride;
+            lumaEnd1 = luma(uv1) - lumaLocalAverage;
+            reached1 = abs(lumaEnd1) >= gradientScaled;
+        }
+        if (!reached2) {
+            uv2 += offset * stride;
+            lumaEnd2 = luma(uv2) - lumaLocalAverage;
+            reached2 = abs(lumaEnd2) >= gradientScaled;
+        }
+    }
+
+    float distance1 = horizontal ? (uv.x - uv1.x) : (uv.y - uv1.y);
+    float distance2 = horizontal ? (uv2.x - uv.x) : (uv2.y - uv.y);
+    bool closer1 = distance1 < distance2;
+    float distanceFinal = min(distance1, distance2);
+    float edgeLength = distance1 + distance2;
+    float pixelOffset = -distanceFinal / edgeLength + 0.5;
+
+    // only offset if the luma variation at the closest edge end is consistent with the center
+    bool centerSmaller = lumaC < lumaLocalAverage;
+    bool correctVariation = ((closer1 ? lumaEnd1 : lumaEnd2) < 0.0) != centerSmaller;
+    float finalOffset = correctVariation ? pixelOffset : 0.0;
+
+    // sub-pixel anti-aliasing
+    float lumaAverage = (1.0 / 12.0) * (2.0 * (lumaDU + lumaLR) + lumaLeftCorners + lumaRightCorners);
+    float subPixelOffset1 = clamp(abs(lumaAverage - lumaC) / range, 0.0, 1.0);
+    float subPixelOffset2 = (-2.0 * subPixelOffset1 + 3.0) * subPixelOffset1 * subPixelOffset1;
+    float subPixelOffsetFinal = subPixelOffset2 * subPixelOffset2 * SUBPIXEL_QUALITY;
+    finalOffset = max(finalOffset, subPixelOffsetFinal);
+
+    vec2 finalUV = uv;
+    if (horizontal) {
+        finalUV.y += finalOffset * stepLength;
+    } else {
+        finalUV.x += finalOffset * stepLength;
+    }
+
+    out_color = vec4(texture(tex_input, finalUV).rgb, 1);
+}
@@ -2,6 +2,7 @@
 
 #include "lib/common.glsl"
 #include "lib/lighting.glsl"
+#include "lib/fog.glsl"
 
 CAMERA(0, camera)
 LIGHTS(1, lights)
@@ -9,11 +10,16 @@ SAMPLER(2, diffuse)
 SAMPLER(3, normal)
 SAMPLER(4, position)
 SAMPLER(5, occlusion)
-SAMPLER_ARRAY(6, shadowmaps)
+CLUSTERS(6, clusters)
+CLUSTER_LIGHTS(7, clusterLights)
+SAMPLER_ARRAY(8, shadowmaps)
 
 IN(0, vec2, texcoord)
 OUT(0, vec4, color)
 
+// cluster light count shown as red in the light count debug view
+#define MAX_DEBUG_LIGHTS 16.0
+
 vec3 getWorldPosition(vec3 viewPos); 
 vec3 getWorldNormal(vec3 viewNormal);
 
@@ -34,18 +40,39 @@ void main() {
 		ssao = 1;
 	}
 
-	// accumulate lighting
-	vec3 lightColor = ambientLight(lights.settings, occlusion * ssao);
-	int lightCount = lights.settings.Count;
-	for(int i = 0; i < lightCount; i++) {
-		lightColor += calculateLightColor(lights.item[i], position, normal, viewPos.z, lights.settings);
+	int clusterIdx = clusterIndex(lights.settings, gl_FragCoord.xy, camera.Viewport, viewPos.z);
+	Cluster cluster = clusters.item[clusterIdx];
+
+	if (lights.settings.DebugMode == DEBUG_LIGHT_COUNT) {
+		out_color = vec4(debug_heatmap(float(cluster.Count) / MAX_DEBUG_LIGHTS), 1);
+		return;
+	}
+
+	// accumulate lighting. the cascade debug view only shows direct lighting
+	bool debugCascades = lights.settings.DebugMode == DEBUG_CASCADES;
+	vec3 lightColor = debugCascades ? vec3(0) : environmentDiffuse(lights.settings, normal, occlusion * ssao);
+	for(uint i = 0; i < cluster.Count; i++) {
+		uint lightIdx = CLUSTER_LIGHT(clusterLights, cluster, i);
+		lightColor += calculateLightColor(lights.item[lightIdx], position, normal, viewPos.z, lights.settings);
+	}
+
+	if (debugCascades) {
+		out_color = vec4(lightColor, 1);
+		return;
 	}
 
 	// linearize gbuffer diffuse
 	vec3 linearDiffuse = pow(diffuseColor, vec3(2.2));
 
+	// environment reflections are not tinted by the surface color
+	vec3 viewDir = normalize(camera.Eye.xyz - position);
+	vec3 specular = environmentSpecular(lights.settings, normal, viewDir, SURFACE_ROUGHNESS, occlusion * ssao);
+
+	// atmospheric fog
+	vec3 shaded = applyFog(lights.settings, lightColor * linearDiffuse + specular, camera.Eye.xyz, position);
+
 	// write shaded fragment color
-	out_color = vec4(lightColor * linearDiffuse, 1);
+	out_color = vec4(shaded, 1);
 }
 
 vec3 getWorldPosition(vec3 viewPos) {
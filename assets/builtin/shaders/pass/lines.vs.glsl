@@ -11,7 +11,7 @@ out gl_PerVertex
 };
 
 CAMERA(0, camera)
-OBJECT(1, object, gl_InstanceIndex)
+OBJECT(1, object, get_object_index())
 
 VERTEX_BUFFER(Vertex)
 INDEX_BUFFER(uint)
@@ -23,6 +23,6 @@ void main()
 
     out_color = v.color.rgb;
 
-	mat4 mvp = camera.ViewProj * object.model;
+	mat4 mvp = camera.ViewProj * object_model(object, get_instance_index());
 	gl_Position = mvp * vec4(v.position, 1);
 }
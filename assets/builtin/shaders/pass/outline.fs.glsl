@@ -0,0 +1,51 @@
+#version 450
+
+#include "lib/common.glsl"
+
+#define MAX_OUTLINES 4
+
+SAMPLER(0, mask)
+
+layout (push_constant) uniform Outline {
+	vec4 Colors[MAX_OUTLINES];
+	ivec4 Widths;
+} outline;
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+
+int groupAt(ivec2 pixel, ivec2 size) {
+	return int(round(texelFetch(tex_mask, clamp(pixel, ivec2(0), size - 1), 0).r * 255.0));
+}
+
+void main() {
+	ivec2 size = textureSize(tex_mask, 0);
+	ivec2 pixel = ivec2(in_texcoord * size);
+
+	// outlines are drawn outside of the masked objects
+	if (groupAt(pixel, size) != 0) {
+		discard;
+	}
+
+	int width = max(max(outline.Widths.x, outline.Widths.y), max(outline.Widths.z, outline.Widths.w));
+
+	// find the highest group within its outline width
+	int found = 0;
+	for(int y = -width; y <= width; y++) {
+		for(int x = -width; x <= width; x++) {
+			int group = groupAt(pixel + ivec2(x, y), size);
+			if (group <= found) {
+				continue;
+			}
+			int w = outline.Widths[group - 1];
+			if (x * x + y * y <= w * w) {
+				found = group;
+			}
+		}
+	}
+	if (found == 0) {
+		discard;
+	}
+
+	out_color = outline.Colors[found - 1];
+}
@@ -0,0 +1,15 @@
+#version 450
+
+#include "lib/common.glsl"
+
+layout (push_constant) uniform Mask {
+	float Group;
+} mask;
+
+OUT(0, vec4, mask)
+
+void main() 
+{
+	// groups are stored in an 8-bit unorm mask
+	out_mask = vec4(mask.Group / 255.0, 0, 0, 0);
+}
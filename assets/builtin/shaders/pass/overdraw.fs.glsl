@@ -0,0 +1,11 @@
+#version 450
+
+#include "lib/common.glsl"
+
+OUT(0, vec4, count)
+
+void main() 
+{
+	// each fragment adds one to the counter
+	out_count = vec4(1, 0, 0, 0);
+}
@@ -0,0 +1,24 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/objects.glsl"
+
+out gl_PerVertex 
+{
+	vec4 gl_Position;   
+};
+
+CAMERA(0, camera)
+OBJECT(1, object, get_object_index())
+
+VERTEX_BUFFER(Vertex)
+INDEX_BUFFER(uint)
+
+void main() 
+{
+	// load vertex data
+	Vertex v = get_vertex_indexed(object.vertexPtr, object.indexPtr);
+
+	mat4 mvp = camera.ViewProj * object_model(object, get_instance_index());
+	gl_Position = mvp * vec4(v.position, 1);
+}
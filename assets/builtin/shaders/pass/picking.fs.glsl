@@ -0,0 +1,11 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, flat uint, handle)
+OUT(0, uint, handle)
+
+void main() 
+{
+	out_handle = in_handle;
+}
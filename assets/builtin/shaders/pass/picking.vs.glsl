@@ -0,0 +1,29 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/objects.glsl"
+
+OUT(0, flat uint, handle)
+
+out gl_PerVertex 
+{
+	vec4 gl_Position;   
+};
+
+CAMERA(0, camera)
+OBJECT(1, object, get_object_index())
+
+VERTEX_BUFFER(Vertex)
+INDEX_BUFFER(uint)
+
+void main() 
+{
+	// zero is reserved for empty pixels
+	out_handle = get_object_index() + 1;
+
+	// load vertex data
+	Vertex v = get_vertex_indexed(object.vertexPtr, object.indexPtr);
+
+	mat4 mvp = camera.ViewProj * object_model(object, get_instance_index());
+	gl_Position = mvp * vec4(v.position, 1);
+}
@@ -1,11 +1,18 @@
 #version 450
 
 #include "lib/common.glsl"
+#include "lib/tonemap.glsl"
 
 IN(0, vec2, texcoord)
 OUT(0, vec4, color)
 SAMPLER(0, input)
 SAMPLER(1, lut)
+UNIFORM(2, settings, {
+    int Operator;
+    int Auto;
+    float Compensation;
+})
+SAMPLER(3, exposure)
 
 #define MAXCOLOR 15.0 
 #define COLORS 16.0
@@ -33,14 +40,18 @@ vec3 lookup_color(sampler2D lut, vec3 clr) {
 }
 
 void main() {
-    // todo: expose as uniform setting
-    float exposure = 1.0;
+    // exposure compensation, relative to the adapted scene luminance if enabled
+    float exposure = exp2(settings.Compensation);
+    if (settings.Auto != 0) {
+        float ev = texelFetch(tex_exposure, ivec2(0), 0).r;
+        exposure = EXPOSURE_KEY * exp2(settings.Compensation - ev);
+    }
 
     // get input color
     vec3 hdrColor = texture(tex_input, in_texcoord).rgb;
 
-    // exposure tone mapping
-    vec3 mapped = vec3(1.0) - exp(-hdrColor * exposure);
+    // tone mapping
+    vec3 mapped = tonemap(settings.Operator, hdrColor * exposure);
 
     // gamma correction
     vec3 corrected = pow(mapped, vec3(1/gamma));
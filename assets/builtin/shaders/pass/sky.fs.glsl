@@ -0,0 +1,58 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/lighting.glsl"
+#include "lib/atmosphere.glsl"
+
+#define SKY_PROCEDURAL 0
+#define SKY_SKYBOX 1
+
+// angular radius of the sun disc, as the cosine of the angle
+#define SUN_DISC 0.99996
+
+CAMERA(0, camera)
+UNIFORM(1, sky, {
+	vec4 SunDirection;
+	vec4 Rayleigh;
+	int Mode;
+	int Skybox;
+	float Intensity;
+	float SunIntensity;
+	float PlanetRadius;
+	float AtmosphereRadius;
+	float RayleighHeight;
+	float MieHeight;
+	float Mie;
+	float MieG;
+})
+SAMPLER_ARRAY(2, textures)
+
+IN(0, vec2, ndc)
+OUT(0, vec4, color)
+
+void main() {
+	// reconstruct the world space view ray
+	vec4 far = camera.ViewProjInv * vec4(in_ndc, 1, 1);
+	vec3 dir = normalize(far.xyz / far.w - camera.Eye.xyz);
+
+	vec3 radiance;
+	if (sky.Mode == SKY_SKYBOX) {
+		radiance = sampleCubeStrip(sky.Skybox, dir);
+	} 
+	else {
+		Atmosphere atm = Atmosphere(
+			sky.Rayleigh.xyz, sky.RayleighHeight,
+			sky.Mie, sky.MieHeight, sky.MieG,
+			sky.PlanetRadius, sky.AtmosphereRadius,
+			sky.SunIntensity);
+		vec3 sun = normalize(sky.SunDirection.xyz);
+		radiance = atmosphereRadiance(atm, dir, sun);
+
+		// sun disc, attenuated like the sky around it
+		if (dir.y > 0 && dot(dir, sun) > SUN_DISC) {
+			radiance += radiance * atm.SunIntensity;
+		}
+	}
+
+	out_color = vec4(radiance * sky.Intensity, 1);
+}
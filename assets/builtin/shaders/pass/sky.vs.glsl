@@ -0,0 +1,19 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, vec3, position)
+IN(2, vec2, tex)
+OUT(0, vec2, ndc)
+
+out gl_PerVertex 
+{
+	vec4 gl_Position;   
+};
+
+void main() 
+{
+	// place the sky on the far plane so that it is only drawn behind geometry
+	out_ndc = in_position.xy;
+	gl_Position = vec4(in_position.xy, 1, 1);
+}
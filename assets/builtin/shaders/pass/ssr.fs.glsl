@@ -0,0 +1,142 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, reflection)
+OUT(1, vec4, history)
+
+CAMERA(0, camera)
+UNIFORM(1, params, {
+	int Steps;
+	int Refinements;
+	float MaxDistance;
+	float Thickness;
+	float EdgeFade;
+	uint Layers;
+	int Reset;
+})
+SAMPLER(2, position)
+SAMPLER(3, normal)
+SAMPLER(4, depth)
+SAMPLER(5, color)
+SAMPLER(6, history)
+
+// reflectance of dielectrics at normal incidence
+const float F0 = 0.04;
+
+// returns the view space position of the depth buffer at a screen position
+vec3 view_position(vec2 uv) {
+	float depth = texture(tex_depth, uv).r;
+	vec4 view = camera.ProjInv * vec4(uv * 2 - 1, depth, 1);
+	return view.xyz / view.w;
+}
+
+// projects a view space position to screen coordinates
+vec2 project(vec3 position) {
+	vec4 clip = camera.Proj * vec4(position, 1);
+	return clip.xy / clip.w * 0.5 + 0.5;
+}
+
+// fades out towards the edges of the screen
+float edge_fade(vec2 uv) {
+	vec2 edge = min(uv, 1 - uv);
+	return smoothstep(0, params.EdgeFade, min(edge.x, edge.y));
+}
+
+// per-pixel noise that offsets the ray march, hiding banding between steps
+float interleaved_gradient_noise(vec2 pixel) {
+	return fract(52.9829189 * fract(dot(pixel, vec2(0.06711056, 0.00583715))));
+}
+
+void main()
+{
+	// the lit color of the current frame is captured for the reflections of the next frame
+	out_history = vec4(texture(tex_color, in_texcoord).rgb, 1);
+	out_reflection = vec4(0);
+
+	if (params.Reset != 0) {
+		return;
+	}
+
+	vec4 gposition = texture(tex_position, in_texcoord);
+	vec3 normalEncoded = texture(tex_normal, in_texcoord).xyz;
+	uint layer = uint(gposition.a);
+	if ((layer & params.Layers) == 0 || normalEncoded == vec3(0)) {
+		return;
+	}
+
+	vec3 origin = gposition.xyz;
+	vec3 normal = unpack_normal(normalEncoded);
+	vec3 view = normalize(origin);
+	vec3 dir = normalize(reflect(view, normal));
+
+	// rays travelling towards the camera leave the screen without hitting anything visible
+	float facing = 1 - smoothstep(0, 0.5, -dir.z);
+	if (facing <= 0) {
+		return;
+	}
+
+	// march along the reflected ray until it passes behind the depth buffer
+	float stepLength = params.MaxDistance / float(params.Steps);
+	float t = stepLength * interleaved_gradient_noise(gl_FragCoord.xy);
+	float prevT = 0;
+	bool hit = false;
+	for (int i = 0; i < params.Steps; i++) {
+		t += stepLength;
+		vec3 p = origin + dir * t;
+		if (p.z <= 0) {
+			break;
+		}
+		vec2 uv = project(p);
+		if (any(lessThan(uv, vec2(0))) || any(greaterThan(uv, vec2(1)))) {
+			break;
+		}
+		float delta = p.z - view_position(uv).z;
+		if (delta > 0 && delta < params.Thickness + stepLength) {
+			hit = true;
+			break;
+		}
+		prevT = t;
+	}
+	if (!hit) {
+		return;
+	}
+
+	// binary search between the last step in front of the surface and the first step behind it
+	for (int i = 0; i < params.Refinements; i++) {
+		float mid = 0.5 * (prevT + t);
+		vec3 p = origin + dir * mid;
+		if (p.z - view_position(project(p)).z > 0) {
+			t = mid;
+		} else {
+			prevT = mid;
+		}
+	}
+
+	vec3 hitPos = origin + dir * t;
+	vec2 hitUV = project(hitPos);
+	vec3 surface = view_position(hitUV);
+	if (abs(hitPos.z - surface.z) > params.Thickness) {
+		return;
+	}
+
+	// reproject the hit into the previous frame, which holds the lit color of the reflected surface
+	vec4 world = camera.ViewInv * vec4(surface, 1);
+	vec4 prev = camera.PrevViewProj * world;
+	vec2 prevUV = prev.xy / prev.w * 0.5 + 0.5;
+	if (any(lessThan(prevUV, vec2(0))) || any(greaterThan(prevUV, vec2(1)))) {
+		return;
+	}
+	vec3 color = texture(tex_history, prevUV).rgb;
+
+	// schlick fresnel approximation
+	float cosTheta = clamp(dot(-view, normal), 0, 1);
+	float fresnel = F0 + (1 - F0) * pow(1 - cosTheta, 5);
+
+	float fade = facing;
+	fade *= edge_fade(hitUV) * edge_fade(prevUV);
+	fade *= 1 - clamp(t / params.MaxDistance, 0, 1);
+
+	out_reflection = vec4(color, fresnel * fade);
+}
@@ -0,0 +1,13 @@
+#version 450
+
+#include "lib/common.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+SAMPLER(0, reflection)
+
+void main() 
+{
+	// the alpha channel holds the weight of the reflection, which is mixed into the lit color by blending
+	out_color = texture(tex_reflection, in_texcoord);
+}
@@ -0,0 +1,72 @@
+#version 450
+
+#include "lib/common.glsl"
+#include "lib/tonemap.glsl"
+
+IN(0, vec2, texcoord)
+OUT(0, vec4, color)
+CAMERA(0, camera)
+UNIFORM(1, params, {
+    float Feedback;
+    int Reset;
+})
+SAMPLER(2, input)
+SAMPLER(3, depth)
+SAMPLER(4, velocity)
+SAMPLER(5, history)
+
+void main() {
+    vec3 current = texture(tex_input, in_texcoord).rgb;
+    if (params.Reset != 0) {
+        out_color = vec4(current, 1);
+        return;
+    }
+
+    // find the color bounds of the 3x3 neighborhood, and the closest surface within it.
+    // sampling motion at the closest surface keeps the edges of moving objects anti-aliased
+    vec2 texel = 1.0 / vec2(textureSize(tex_input, 0));
+    vec3 minColor = current;
+    vec3 maxColor = current;
+    float closest = texture(tex_depth, in_texcoord).r;
+    vec2 closestUV = in_texcoord;
+    for (int y = -1; y <= 1; y++) {
+        for (int x = -1; x <= 1; x++) {
+            vec2 uv = in_texcoord + vec2(x, y) * texel;
+            vec3 neighbor = texture(tex_input, uv).rgb;
+            minColor = min(minColor, neighbor);
+            maxColor = max(maxColor, neighbor);
+
+            float depth = texture(tex_depth, uv).r;
+            if (depth < closest) {
+                closest = depth;
+                closestUV = uv;
+            }
+        }
+    }
+
+    // reproject the closest surface into the previous frame to find the camera motion
+    vec4 world = camera.ViewProjInv * vec4(closestUV * 2 - 1, closest, 1);
+    world /= world.w;
+    vec4 prev = camera.PrevViewProj * world;
+    vec2 prevUV = prev.xy / prev.w * 0.5 + 0.5;
+
+    // the history is unjittered, so the jitter offset of the current frame is removed.
+    // object motion is added on top of the camera motion
+    vec2 motion = prevUV - closestUV + camera.Jitter * 0.5 + texture(tex_velocity, closestUV).xy;
+    vec2 historyUV = in_texcoord + motion;
+    if (any(lessThan(historyUV, vec2(0))) || any(greaterThan(historyUV, vec2(1)))) {
+        out_color = vec4(current, 1);
+        return;
+    }
+
+    // clamp the history to the current neighborhood to reject disoccluded samples
+    vec3 history = texture(tex_history, historyUV).rgb;
+    history = clamp(history, minColor, maxColor);
+
+    // reduce flickering by weighting samples by their inverse luminance
+    float currentWeight = (1 - params.Feedback) / (1 + luminance(current));
+    float historyWeight = params.Feedback / (1 + luminance(history));
+    vec3 resolved = (current * currentWeight + history * historyWeight) / (currentWeight + historyWeight);
+
+    out_color = vec4(resolved, 1);
+}
@@ -0,0 +1,10 @@
+#version 450
+
+#include "lib/common.glsl"
+
+OUT(0, vec4, color)
+
+void main() 
+{
+	out_color = vec4(0.2, 1, 0.4, 0.6);
+}
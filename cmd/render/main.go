@@ -0,0 +1,219 @@
+// Command render draws a saved scene without a window and writes the result as PNG images.
+//
+// The scene is loaded with object.Load, either from a file on disk or from the asset filesystem.
+// If the scene does not contain a camera, or a camera position is given, a camera is placed
+// at the given position looking at the target. Cameras of the scene are disabled when a position is given.
+//
+// Example:
+//
+//	go run ./cmd/render -scene scene.scn -out frame.png -camera 4,3,-4 -frames 8
+//
+// To render every frame, include a number verb in the output name, e.g. -out frame-%03d.png
+//
+// The command creates a window system instance, so on CI machines without a display it should run under
+// a virtual frame buffer. A software Vulkan driver such as lavapipe can be selected with VK_ICD_FILENAMES:
+//
+//	VK_ICD_FILENAMES=/usr/share/vulkan/icd.d/lvp_icd.x86_64.json xvfb-run go run ./cmd/render -scene scene.scn
+package main
+
+import (
+	"flag"
+	"fmt"
+	osimage "image"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/assets/fs"
+	"github.com/johanhenriksson/goworld/core/camera"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/app"
+	"github.com/johanhenriksson/goworld/engine/graph"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/quat"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/upload"
+
+	// register serializable scene types
+	_ "github.com/johanhenriksson/goworld/core/decal"
+	_ "github.com/johanhenriksson/goworld/core/effect"
+	_ "github.com/johanhenriksson/goworld/core/light"
+	_ "github.com/johanhenriksson/goworld/core/mesh"
+	_ "github.com/johanhenriksson/goworld/core/sky"
+	_ "github.com/johanhenriksson/goworld/geometry/cube"
+	_ "github.com/johanhenriksson/goworld/geometry/cylinder"
+	_ "github.com/johanhenriksson/goworld/geometry/plane"
+	_ "github.com/johanhenriksson/goworld/geometry/sprite"
+	_ "github.com/johanhenriksson/goworld/physics"
+)
+
+var renderers = map[string]engine.RendererFunc{
+	"default":  graph.Default,
+	"forward":  graph.Forward,
+	"deferred": graph.Deferred,
+}
+
+var antiAliasing = map[string]engine.AntiAliasing{
+	"none": engine.AntiAliasingNone,
+	"fxaa": engine.AntiAliasingFXAA,
+	"taa":  engine.AntiAliasingTAA,
+}
+
+func main() {
+	scenePath := flag.String("scene", "", "scene file, or asset key of the scene")
+	output := flag.String("out", "frame.png", "output file. include a number verb to write every frame")
+	width := flag.Int("width", 800, "image width")
+	height := flag.Int("height", 600, "image height")
+	rendererName := flag.String("renderer", "default", "renderer: default, forward or deferred")
+	aaName := flag.String("aa", "none", "anti-aliasing: none, fxaa or taa")
+	frames := flag.Int("frames", 1, "number of frames to render")
+	fps := flag.Float64("fps", 60, "frames per second of the fixed timestep")
+	device := flag.Int("device", 0, "physical device index")
+	cameraPos := flag.String("camera", "", "camera position x,y,z. defaults to the camera of the scene")
+	cameraTarget := flag.String("target", "0,0,0", "camera target x,y,z")
+	fov := flag.Float64("fov", 60, "camera field of view, in degrees")
+	flag.Parse()
+
+	if *scenePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	renderer, exists := renderers[*rendererName]
+	if !exists {
+		log.Fatalf("unknown renderer %s", *rendererName)
+	}
+	aa, exists := antiAliasing[strings.ToLower(*aaName)]
+	if !exists {
+		log.Fatalf("unknown anti-aliasing mode %s", *aaName)
+	}
+	if *frames < 1 || *fps <= 0 {
+		log.Fatal("at least one frame must be rendered at a positive frame rate")
+	}
+
+	view := cameraView{Fov: float32(*fov)}
+	if *cameraPos != "" {
+		pos, err := parseVec3(*cameraPos)
+		if err != nil {
+			log.Fatalf("invalid camera position: %s", err)
+		}
+		view.Eye = &pos
+	}
+	target, err := parseVec3(*cameraTarget)
+	if err != nil {
+		log.Fatalf("invalid camera target: %s", err)
+	}
+	view.Target = target
+
+	// read scenes from disk if the path exists, otherwise from the asset filesystem
+	sceneFs, sceneKey := assets.FS, *scenePath
+	if _, err := os.Stat(*scenePath); err == nil {
+		sceneFs, sceneKey = fs.NewLocal(filepath.Dir(*scenePath)), filepath.Base(*scenePath)
+	}
+
+	numbered := strings.Contains(*output, "%")
+	err = app.Frames(
+		app.Args{
+			Width:        *width,
+			Height:       *height,
+			Renderer:     renderer,
+			AntiAliasing: aa,
+			Device:       *device,
+		},
+		*frames,
+		float32(1 / *fps),
+		func(frame int, img *osimage.RGBA) error {
+			if !numbered && frame < *frames-1 {
+				return nil
+			}
+			filename := *output
+			if numbered {
+				filename = fmt.Sprintf(*output, frame)
+			}
+			if err := upload.SavePng(img, filename); err != nil {
+				return err
+			}
+			log.Println("saved", filename)
+			return nil
+		},
+		func(pool object.Pool, scene object.Object) {
+			loaded, err := object.Load[object.Object](pool, sceneFs, sceneKey)
+			if err != nil {
+				panic(fmt.Errorf("failed to load scene %s: %w", *scenePath, err))
+			}
+			object.Attach(scene, loaded)
+			placeCamera(pool, scene, view)
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cameraView is the camera given on the command line
+type cameraView struct {
+	// Eye is the camera position, or nil to use the camera of the scene
+	Eye    *vec3.T
+	Target vec3.T
+	Fov    float32
+}
+
+// placeCamera attaches a camera for the given view to the scene.
+// The renderer draws from the first enabled camera, so the cameras of the scene are disabled if an eye position is given.
+// Without an eye position, the camera is only placed if the scene has none, at a default position.
+func placeCamera(pool object.Pool, scene object.Object, view cameraView) {
+	cameras := object.NewQuery[*camera.Camera]().Collect(scene)
+	if view.Eye == nil {
+		if len(cameras) > 0 {
+			return
+		}
+		log.Println("scene has no camera, placing one at the default position")
+		view.Eye = &vec3.T{X: 0, Y: 2, Z: -5}
+	}
+	for _, cam := range cameras {
+		object.Disable(cam)
+	}
+	object.Attach(scene, object.Builder(object.Empty(pool, "Camera")).
+		Position(*view.Eye).
+		Rotation(lookRotation(*view.Eye, view.Target)).
+		Attach(camera.New(pool, camera.Args{
+			Fov:   view.Fov,
+			Near:  0.1,
+			Far:   500,
+			Clear: color.Black,
+		})).
+		Create())
+}
+
+func parseVec3(value string) (vec3.T, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return vec3.Zero, fmt.Errorf("expected x,y,z, got %s", value)
+	}
+	var v [3]float32
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return vec3.Zero, err
+		}
+		v[i] = float32(f)
+	}
+	return vec3.New(v[0], v[1], v[2]), nil
+}
+
+// lookRotation returns a camera rotation that faces the target from the eye position.
+// Cameras face Z+, and the rotation is expressed as pitch and yaw to keep the horizon level.
+func lookRotation(eye, target vec3.T) quat.T {
+	dir := target.Sub(eye)
+	if dir.Length() < 1e-6 {
+		return quat.Ident()
+	}
+	dir = dir.Normalized()
+	pitch := math.RadToDeg(math.Asin(-dir.Y))
+	yaw := math.RadToDeg(math.Atan2(dir.X, dir.Z))
+	return quat.Euler(pitch, yaw, 0)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	osimage "image"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/camera"
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/app"
+	"github.com/johanhenriksson/goworld/engine/graph"
+	"github.com/johanhenriksson/goworld/geometry/cube"
+	"github.com/johanhenriksson/goworld/geometry/plane"
+	"github.com/johanhenriksson/goworld/math/quat"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/material"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/test/util"
+)
+
+var _ = Describe("render command", Label("e2e"), func() {
+	args := app.Args{
+		Width:    256,
+		Height:   256,
+		Renderer: graph.Forward,
+	}
+
+	// testScene builds an asymmetric scene, optionally with a camera of its own
+	testScene := func(withCamera bool) object.SceneFunc {
+		return func(pool object.Pool, scene object.Object) {
+			object.Builder(plane.New(pool, plane.Args{
+				Size: vec2.New(5, 5),
+			})).
+				Parent(scene).
+				Texture(texture.Diffuse, color.White).
+				Create()
+			object.Builder(cube.New(pool, cube.Args{
+				Size: 1,
+				Mat:  material.StandardForward(),
+			})).
+				Position(vec3.New(1, 0.5, 0)).
+				Texture(texture.Diffuse, texture.Checker).
+				Parent(scene).
+				Create()
+			object.Builder(object.Empty(pool, "Sun")).
+				Attach(light.NewDirectional(pool, light.DirectionalArgs{
+					Intensity: 1,
+					Color:     color.White,
+				})).
+				Rotation(quat.Euler(45, 30, 0)).
+				Parent(scene).
+				Create()
+
+			if withCamera {
+				eye := vec3.New(0, 6, -1)
+				object.Builder(object.Empty(pool, "Scene Camera")).
+					Position(eye).
+					Rotation(lookRotation(eye, vec3.Zero)).
+					Attach(camera.New(pool, camera.Args{
+						Fov:   60,
+						Near:  0.1,
+						Far:   500,
+						Clear: color.Black,
+					})).
+					Parent(scene).
+					Create()
+			}
+		}
+	}
+
+	render := func(withCamera bool, view cameraView) *osimage.RGBA {
+		return app.Frame(args, testScene(withCamera), func(pool object.Pool, scene object.Object) {
+			placeCamera(pool, scene, view)
+		})
+	}
+
+	diff := func(expected, actual *osimage.RGBA) float64 {
+		d, err := util.CompareImages(expected, actual, [4]uint8{4, 4, 4, 4})
+		Expect(err).ToNot(HaveOccurred())
+		return d.DiffFraction()
+	}
+
+	It("renders from the camera position given on the command line", func() {
+		eye := vec3.New(4, 3, -4)
+		view := cameraView{Eye: &eye, Target: vec3.Zero, Fov: 60}
+
+		// the reference is rendered from a scene without a camera of its own,
+		// so that the placed camera is the only one
+		reference := render(false, view)
+		flagged := render(true, view)
+		sceneView := render(true, cameraView{Fov: 60})
+
+		Expect(diff(reference, flagged)).To(BeNumerically("<", 0.5), "the camera given on the command line is used")
+		Expect(diff(reference, sceneView)).To(BeNumerically(">", 10), "the views are distinguishable")
+	})
+})
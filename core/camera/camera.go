@@ -4,8 +4,10 @@ import (
 	"github.com/johanhenriksson/goworld/core/draw"
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec2"
 	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/tonemap"
 )
 
 // Camera Group
@@ -22,7 +24,21 @@ type Camera struct {
 	Near object.Property[float32]
 	Far  object.Property[float32]
 
-	state draw.Camera
+	// Tone mapping curve used to convert the HDR image for display
+	ToneMapping object.Property[tonemap.Operator]
+
+	// Exposure compensation in stops
+	Exposure object.Property[float32]
+
+	// AutoExposure enables eye adaptation to the average scene luminance
+	AutoExposure    object.Property[bool]
+	AdaptationSpeed object.Property[float32]
+	MinEV           object.Property[float32]
+	MaxEV           object.Property[float32]
+
+	state        draw.Camera
+	prevViewProj mat4.T
+	hasPrev      bool
 }
 
 type Args struct {
@@ -34,10 +50,18 @@ type Args struct {
 
 // New creates a new camera component.
 func New(pool object.Pool, args Args) *Camera {
+	tm := tonemap.DefaultSettings()
 	return object.NewComponent(pool, &Camera{
 		Fov:  object.NewProperty(args.Fov),
 		Near: object.NewProperty(args.Near),
 		Far:  object.NewProperty(args.Far),
+
+		ToneMapping:     object.NewProperty(tm.Operator),
+		Exposure:        object.NewProperty(tm.Compensation),
+		AutoExposure:    object.NewProperty(tm.Auto),
+		AdaptationSpeed: object.NewProperty(tm.Speed),
+		MinEV:           object.NewProperty(tm.MinEV),
+		MaxEV:           object.NewProperty(tm.MaxEV),
 	})
 }
 
@@ -79,9 +103,17 @@ func (cam *Camera) Refresh(viewport draw.Viewport) draw.Camera {
 	cam.state.Near = cam.Near.Get()
 	cam.state.Far = cam.Far.Get()
 	cam.state.Fov = cam.Fov.Get()
+	cam.state.Tonemap = tonemap.Settings{
+		Operator:     cam.ToneMapping.Get(),
+		Compensation: cam.Exposure.Get(),
+		Auto:         cam.AutoExposure.Get(),
+		Speed:        cam.AdaptationSpeed.Get(),
+		MinEV:        cam.MinEV.Get(),
+		MaxEV:        cam.MaxEV.Get(),
+	}
 
 	// update view & view-projection matrices
-	cam.state.Proj = mat4.Perspective(cam.state.Fov, cam.state.Aspect, cam.state.Near, cam.state.Far)
+	proj := mat4.Perspective(cam.state.Fov, cam.state.Aspect, cam.state.Near, cam.state.Far)
 
 	// calculate the view matrix.
 	// should be the inverse of the cameras transform matrix
@@ -93,6 +125,28 @@ func (cam *Camera) Refresh(viewport draw.Viewport) draw.Camera {
 	cam.state.ViewInv = tf.Matrix()
 	cam.state.View = cam.state.ViewInv.Invert()
 
+	// keep track of the unjittered view projection of the previous frame for reprojection
+	viewProj := proj.Mul(&cam.state.View)
+	if !cam.hasPrev {
+		cam.prevViewProj = viewProj
+		cam.hasPrev = true
+	}
+	cam.state.PrevViewProj = cam.prevViewProj
+	cam.prevViewProj = viewProj
+
+	// apply sub-pixel jitter by offsetting clip space x/y proportionally to w.
+	// the jitter is relative to the pixels of the scene render targets
+	cam.state.Jitter = vec2.Zero
+	if width, height := viewport.RenderSize(); width > 0 && height > 0 {
+		cam.state.Jitter = vec2.New(
+			2*viewport.Jitter.X/float32(width),
+			2*viewport.Jitter.Y/float32(height),
+		)
+	}
+	proj[8] += cam.state.Jitter.X
+	proj[9] += cam.state.Jitter.Y
+
+	cam.state.Proj = proj
 	cam.state.ViewProj = cam.state.Proj.Mul(&cam.state.View)
 	cam.state.ViewProjInv = cam.state.ViewProj.Invert()
 
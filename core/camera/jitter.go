@@ -0,0 +1,24 @@
+package camera
+
+import "github.com/johanhenriksson/goworld/math/vec2"
+
+// JitterSamples is the length of the sub-pixel jitter sequence
+const JitterSamples = 8
+
+// Jitter returns a sub-pixel offset in the range [-0.5, 0.5] for the given frame number,
+// cycling through a Halton (2,3) sequence.
+func Jitter(frame int) vec2.T {
+	i := frame%JitterSamples + 1
+	return vec2.New(halton(i, 2)-0.5, halton(i, 3)-0.5)
+}
+
+func halton(index, base int) float32 {
+	result := float32(0)
+	f := float32(1)
+	for index > 0 {
+		f /= float32(base)
+		result += f * float32(index%base)
+		index /= base
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package decal
+
+import (
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+type Args struct {
+	Size    vec3.T
+	Diffuse assets.Texture
+	Normal  assets.Texture
+	Layers  mesh.Layer
+}
+
+// Decal projects textures onto the geometry inside its box.
+// The box is centered on the object and projects along its forward axis.
+type Decal struct {
+	object.Component
+
+	// Size of the projection box
+	Size object.Property[vec3.T]
+
+	// Diffuse texture. Its alpha channel controls the opacity of the decal
+	Diffuse object.Property[assets.Texture]
+
+	// Normal is an optional tangent space normal map, oriented along the decal axes
+	Normal object.Property[assets.Texture]
+
+	// Color tints the diffuse texture. The alpha channel scales the opacity
+	Color object.Property[color.T]
+
+	// AngleFade is the angle in degrees between the surface normal and the projection direction
+	// at which the decal starts fading out. Surfaces perpendicular to the projector receive nothing.
+	AngleFade object.Property[float32]
+
+	// Layers selects which meshes receive the decal
+	Layers object.Property[mesh.Layer]
+}
+
+func init() {
+	object.Register[*Decal](object.Type{
+		Name: "Decal",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return New(pool, Args{
+				Size:   vec3.One,
+				Layers: mesh.LayerStatic,
+			}), nil
+		},
+	})
+}
+
+func New(pool object.Pool, args Args) *Decal {
+	if args.Diffuse == nil {
+		args.Diffuse = color.White
+	}
+	return object.NewComponent(pool, &Decal{
+		Size:      object.NewProperty(args.Size),
+		Diffuse:   object.NewProperty(args.Diffuse),
+		Normal:    object.NewProperty(args.Normal),
+		Color:     object.NewProperty(color.White),
+		AngleFade: object.NewProperty[float32](60),
+		Layers:    object.NewProperty(args.Layers),
+	})
+}
+
+func (d *Decal) Name() string { return "Decal" }
+
+// Box returns the world matrix of the projection box, mapping a unit cube onto it
+func (d *Decal) Box() mat4.T {
+	model := d.Transform().Matrix()
+	scale := mat4.Scale(d.Size.Get())
+	return model.Mul(&scale)
+}
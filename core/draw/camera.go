@@ -2,7 +2,9 @@ package draw
 
 import (
 	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec2"
 	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/tonemap"
 )
 
 type Camera struct {
@@ -19,4 +21,11 @@ type Camera struct {
 	Far         float32
 	Aspect      float32
 	Fov         float32
+	Tonemap     tonemap.Settings
+
+	// PrevViewProj is the view projection matrix of the previous frame, without jitter
+	PrevViewProj mat4.T
+
+	// Jitter is the sub-pixel offset applied to the projection, in normalized device coordinates
+	Jitter vec2.T
 }
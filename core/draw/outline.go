@@ -0,0 +1,26 @@
+package draw
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// MaxOutlines is the maximum number of outline groups drawn each frame
+const MaxOutlines = 4
+
+// Outline is a group of objects drawn with a colored outline.
+// All meshes in the subtrees of the objects are included in the outline.
+type Outline struct {
+	Objects []object.Component
+	Color   color.T
+
+	// Width of the outline in pixels
+	Width int
+}
+
+// OutlineSource is implemented by scene components that request outlines, such as the editor selection.
+// Later outlines are drawn on top of earlier ones.
+type OutlineSource interface {
+	object.Component
+	Outlines() []Outline
+}
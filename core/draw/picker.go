@@ -0,0 +1,50 @@
+package draw
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/vec2"
+)
+
+// PickFunc receives the result of a pick request.
+// The component is the mesh under the cursor, or nil if nothing was drawn there.
+type PickFunc func(object.Component)
+
+// PickRequest asks the renderer for the object drawn at a screen position
+type PickRequest struct {
+	// Position in normalized device coordinates, as returned by Viewport.NormalizeCursor
+	Position vec2.T
+	Callback PickFunc
+}
+
+// Picker is a component that queues pick requests for the renderer.
+//
+// Picking draws the handles of all meshes into an integer image and reads back the handle under the cursor.
+// The result is only available once the GPU has finished the frame, so callbacks are invoked
+// a few frames after the request, from the render thread.
+type Picker struct {
+	object.Component
+	requests []PickRequest
+}
+
+func NewPicker(pool object.Pool) *Picker {
+	return object.NewComponent(pool, &Picker{
+		requests: make([]PickRequest, 0, 4),
+	})
+}
+
+// Pick requests the object drawn at the given position in normalized device coordinates
+func (p *Picker) Pick(position vec2.T, callback PickFunc) {
+	p.requests = append(p.requests, PickRequest{
+		Position: position,
+		Callback: callback,
+	})
+}
+
+// Take removes and returns up to n queued requests, oldest first
+func (p *Picker) Take(n int) []PickRequest {
+	n = min(n, len(p.requests))
+	taken := make([]PickRequest, n)
+	copy(taken, p.requests)
+	p.requests = append(p.requests[:0], p.requests[n:]...)
+	return taken
+}
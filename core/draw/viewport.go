@@ -6,6 +6,13 @@ type Viewport struct {
 	Width  int
 	Height int
 	Scale  float32
+
+	// Resolution is the scale of the scene render targets relative to the viewport. Zero is treated as 1.
+	// The viewport size is kept at the output size, so that cursor positions and GUI layouts are unaffected.
+	Resolution float32
+
+	// Jitter is a sub-pixel offset applied to the projection, in pixels
+	Jitter vec2.T
 }
 
 func (s Viewport) Aspect() float32 {
@@ -19,3 +26,12 @@ func (s Viewport) Size() vec2.T {
 func (s Viewport) NormalizeCursor(cursor vec2.T) vec2.T {
 	return cursor.Div(s.Size()).Sub(vec2.New(0.5, 0.5)).Scaled(2)
 }
+
+// RenderSize returns the size of the scene render targets, in pixels.
+// Rounding matches engine.TargetSize.Scaled
+func (s Viewport) RenderSize() (int, int) {
+	if s.Resolution <= 0 {
+		return s.Width, s.Height
+	}
+	return max(int(float32(s.Width)*s.Resolution), 1), max(int(float32(s.Height)*s.Resolution), 1)
+}
@@ -0,0 +1,54 @@
+package effect
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+)
+
+// Bloom controls the bloom post-processing effect. Only the first bloom in a scene is used.
+type Bloom struct {
+	object.Component
+
+	// Threshold is the luminance at which pixels start contributing to the bloom
+	Threshold object.Property[float32]
+
+	// Knee softens the transition around the threshold, as a fraction of the threshold
+	Knee object.Property[float32]
+
+	// Intensity scales the bloom before it is added to the scene
+	Intensity object.Property[float32]
+
+	// Radius scales the upsampling filter, spreading the bloom further
+	Radius object.Property[float32]
+
+	// Levels is the number of downsample steps. Limited by the size of the render target
+	Levels object.Property[int]
+
+	// Dirt is the path of a lens dirt texture that modulates the bloom
+	Dirt object.Property[string]
+
+	// DirtIntensity scales the contribution of the lens dirt texture
+	DirtIntensity object.Property[float32]
+}
+
+func init() {
+	object.Register[*Bloom](object.Type{
+		Name: "Bloom",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return NewBloom(pool), nil
+		},
+	})
+}
+
+func NewBloom(pool object.Pool) *Bloom {
+	return object.NewComponent(pool, &Bloom{
+		Threshold:     object.NewProperty[float32](1),
+		Knee:          object.NewProperty[float32](0.5),
+		Intensity:     object.NewProperty[float32](0.3),
+		Radius:        object.NewProperty[float32](1),
+		Levels:        object.NewProperty(6),
+		Dirt:          object.NewProperty(""),
+		DirtIntensity: object.NewProperty[float32](1),
+	})
+}
+
+func (b *Bloom) Name() string { return "Bloom" }
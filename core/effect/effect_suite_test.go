@@ -0,0 +1,13 @@
+package effect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEffect(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "core/effect")
+}
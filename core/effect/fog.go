@@ -0,0 +1,86 @@
+package effect
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// Fog applies exponential distance and height fog to lit surfaces. Only the first fog in a scene is used.
+// The fog density decreases exponentially with altitude above the base height. Without height falloff,
+// the density is uniform and the fog only depends on distance.
+type Fog struct {
+	object.Component
+
+	// Color of the fog, in linear color space
+	Color object.Property[color.T]
+
+	// Density is the extinction coefficient per unit of distance at the base height
+	Density object.Property[float32]
+
+	// Height is the altitude at which the fog has its nominal density
+	Height object.Property[float32]
+
+	// HeightFalloff controls how quickly the fog thins out above the base height. Zero disables height fog
+	HeightFalloff object.Property[float32]
+
+	// Start is the distance from the camera at which the fog begins
+	Start object.Property[float32]
+
+	// Scattering tints the fog towards the color of the sun when looking towards it.
+	// The first directional light in the scene is used as the sun. Zero disables in-scattering
+	Scattering object.Property[float32]
+}
+
+func init() {
+	object.Register[*Fog](object.Type{
+		Name: "Fog",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return NewFog(pool), nil
+		},
+	})
+}
+
+func NewFog(pool object.Pool) *Fog {
+	return object.NewComponent(pool, &Fog{
+		Color:         object.NewProperty(color.RGB(0.5, 0.6, 0.7)),
+		Density:       object.NewProperty[float32](0.02),
+		Height:        object.NewProperty[float32](0),
+		HeightFalloff: object.NewProperty[float32](0.1),
+		Start:         object.NewProperty[float32](0),
+		Scattering:    object.NewProperty[float32](0.5),
+	})
+}
+
+func (f *Fog) Name() string { return "Fog" }
+
+// OpticalDepth returns the integrated fog density along the line between the eye and a point.
+// Matches the fog computation in the lighting shaders.
+func (f *Fog) OpticalDepth(eye, point vec3.T) float32 {
+	ray := point.Sub(eye)
+	dist := ray.Length()
+	start := f.Start.Get()
+	if dist <= start {
+		return 0
+	}
+	dir := ray.Scaled(1 / dist)
+	origin := eye.Add(dir.Scaled(start))
+	length := dist - start
+
+	falloff := f.HeightFalloff.Get()
+	density := f.Density.Get() * math.Exp(-falloff*(origin.Y-f.Height.Get()))
+
+	// integral of exp(-falloff * dir.y * t) over the ray, divided by its length
+	k := falloff * dir.Y * length
+	integral := float32(1)
+	if math.Abs(k) > 0.0001 {
+		integral = (1 - math.Exp(-k)) / k
+	}
+	return density * length * integral
+}
+
+// Transmittance returns the fraction of light that reaches the eye from a point through the fog
+func (f *Fog) Transmittance(eye, point vec3.T) float32 {
+	return math.Exp(-f.OpticalDepth(eye, point))
+}
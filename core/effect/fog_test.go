@@ -0,0 +1,64 @@
+package effect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/effect"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+var _ = Describe("fog", func() {
+	var fog *effect.Fog
+	BeforeEach(func() {
+		fog = effect.NewFog(object.NewPool())
+		fog.Density.Set(0.05)
+		fog.Height.Set(2)
+		fog.HeightFalloff.Set(0.2)
+	})
+
+	// numerically integrates the fog density along the ray
+	integrate := func(eye, point vec3.T) float32 {
+		steps := 10000
+		ray := point.Sub(eye)
+		length := ray.Length()
+		dir := ray.Scaled(1 / length)
+		sum := float32(0)
+		for i := 0; i < steps; i++ {
+			t := (float32(i) + 0.5) / float32(steps) * length
+			if t < fog.Start.Get() {
+				continue
+			}
+			y := eye.Y + dir.Y*t
+			sum += fog.Density.Get() * math.Exp(-fog.HeightFalloff.Get()*(y-fog.Height.Get()))
+		}
+		return sum * length / float32(steps)
+	}
+
+	It("integrates height fog along the ray", func() {
+		eye := vec3.New(0, 10, 0)
+		for _, point := range []vec3.T{
+			vec3.New(100, 0, 0),
+			vec3.New(0, 50, 80),
+			vec3.New(30, 10, 30),
+		} {
+			Expect(fog.OpticalDepth(eye, point)).To(BeNumerically("~", integrate(eye, point), 0.01))
+		}
+	})
+
+	It("starts at the start distance", func() {
+		fog.Start.Set(20)
+		eye := vec3.New(0, 0, 0)
+		Expect(fog.Transmittance(eye, vec3.New(0, 0, 15))).To(BeNumerically("==", 1))
+		Expect(fog.OpticalDepth(eye, vec3.New(60, 0, 0))).To(BeNumerically("~", integrate(eye, vec3.New(60, 0, 0)), 0.01))
+	})
+
+	It("is uniform without height falloff", func() {
+		fog.HeightFalloff.Set(0)
+		eye := vec3.New(0, 0, 0)
+		Expect(fog.OpticalDepth(eye, vec3.New(0, 100, 0))).To(BeNumerically("~", 5, 0.001))
+		Expect(fog.Transmittance(eye, vec3.New(100, 0, 0))).To(BeNumerically("~", math.Exp(-5), 0.0001))
+	})
+})
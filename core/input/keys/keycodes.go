@@ -47,6 +47,19 @@ const (
 	Key8 = Code(glfw.Key8)
 	Key9 = Code(glfw.Key9)
 
+	F1  = Code(glfw.KeyF1)
+	F2  = Code(glfw.KeyF2)
+	F3  = Code(glfw.KeyF3)
+	F4  = Code(glfw.KeyF4)
+	F5  = Code(glfw.KeyF5)
+	F6  = Code(glfw.KeyF6)
+	F7  = Code(glfw.KeyF7)
+	F8  = Code(glfw.KeyF8)
+	F9  = Code(glfw.KeyF9)
+	F10 = Code(glfw.KeyF10)
+	F11 = Code(glfw.KeyF11)
+	F12 = Code(glfw.KeyF12)
+
 	Enter        = Code(glfw.KeyEnter)
 	Escape       = Code(glfw.KeyEscape)
 	Backspace    = Code(glfw.KeyBackspace)
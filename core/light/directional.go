@@ -24,6 +24,9 @@ type Cascade struct {
 	ViewProj  mat4.T
 	NearSplit float32
 	FarSplit  float32
+
+	// Radius of the bounding sphere of the frustum slice covered by the cascade
+	Radius float32
 }
 
 type Directional struct {
@@ -37,6 +40,15 @@ type Directional struct {
 
 	CascadeLambda object.Property[float32]
 	CascadeBlend  object.Property[float32]
+
+	// CascadeBias is the depth bias of each of the first four cascades, in normalized shadow map depth
+	CascadeBias object.Property[vec4.T]
+
+	// NormalBias offsets shadow lookups along the surface normal, in shadow map texels
+	NormalBias object.Property[float32]
+
+	// LightSize is the angular diameter of the light source in degrees, which controls the penumbra of soft shadows
+	LightSize object.Property[float32]
 }
 
 var _ T = &Directional{}
@@ -64,6 +76,9 @@ func NewDirectional(pool object.Pool, args DirectionalArgs) *Directional {
 		Cascades:      object.NewProperty(args.Cascades),
 		CascadeLambda: object.NewProperty[float32](0.9),
 		CascadeBlend:  object.NewProperty[float32](3.0),
+		CascadeBias:   object.NewProperty(vec4.New(0.004, 0.002, 0.001, 0.0005)),
+		NormalBias:    object.NewProperty[float32](1.5),
+		LightSize:     object.NewProperty[float32](1),
 	})
 	return lit
 }
@@ -108,79 +123,64 @@ func (lit *Directional) PreDraw(args draw.Args, scene object.Object) error {
 }
 
 func (lit *Directional) calculateCascade(args draw.Args, cascade, cascades int) Cascade {
-	texSize := float32(2048)
-
-	frustumCorners := []vec3.T{
-		vec3.New(-1, 1, -1),  // NTL
-		vec3.New(1, 1, -1),   // NTR
-		vec3.New(-1, -1, -1), // NBL
-		vec3.New(1, -1, -1),  // NBR
-		vec3.New(-1, 1, 1),   // FTL
-		vec3.New(1, 1, 1),    // FTR
-		vec3.New(-1, -1, 1),  // FBL
-		vec3.New(1, -1, 1),   // FBR
-	}
-
-	// transform frustum into world space
-	for i, corner := range frustumCorners {
-		frustumCorners[i] = args.Camera.ViewProjInv.TransformPoint(corner)
-	}
-
-	// squash
-	nearSplit := nearSplitDist(cascade, cascades, args.Camera.Near, args.Camera.Far, lit.CascadeLambda.Get())
-	farSplit := farSplitDist(cascade, cascades, args.Camera.Near, args.Camera.Far, lit.CascadeLambda.Get())
-	for i := 0; i < 4; i++ {
-		dist := frustumCorners[i+4].Sub(frustumCorners[i])
-		frustumCorners[i] = frustumCorners[i].Add(dist.Scaled(nearSplit))
-		frustumCorners[i+4] = frustumCorners[i].Add(dist.Scaled(farSplit))
-	}
-
-	// calculate frustum center
-	center := vec3.Zero
-	for _, corner := range frustumCorners {
-		center = center.Add(corner)
-	}
-	center = center.Scaled(float32(1) / 8)
-
-	radius := float32(0)
-	for _, corner := range frustumCorners {
-		distance := vec3.Distance(corner, center)
-		radius = math.Max(radius, distance)
-	}
-	radius = math.Snap(radius, 16)
-
-	// create light view matrix looking at the center of the
-	// camera frustum
+	near, far := args.Camera.Near, args.Camera.Far
+	nearSplit := nearSplitDist(cascade, cascades, near, far, lit.CascadeLambda.Get())
+	farSplit := farSplitDist(cascade, cascades, near, far, lit.CascadeLambda.Get())
+	sliceNear := near + (far-near)*nearSplit
+	sliceFar := near + (far-near)*farSplit
+
+	// fit a sphere around the frustum slice. its size only depends on the projection,
+	// so that the cascade does not change size as the camera rotates
+	center, radius := fitSphere(args.Camera.Proj, sliceNear, sliceFar)
+	centerWorld := args.Camera.Position.Add(args.Camera.Forward.Scaled(center))
+
+	// the snapped projection trails the frustum slice by up to one texel, extend it to keep it covered
+	radius *= 1 + 2.0/MinShadowResolution
+	texel := 2 * radius / MinShadowResolution
+
+	// snap the center to the texel grid of the light space.
+	// the light rotation is fixed while the camera moves, so world space points stay on the same texels
 	ldir := lit.Transform().Forward()
-	position := center.Sub(ldir.Scaled(radius))
-	lview := mat4.LookAt(position, center, vec3.UnitY)
-
-	lproj := mat4.Orthographic(
-		-radius-0.01, radius+0.01,
-		-radius-0.01, radius+0.01,
-		0, 2*radius)
-
+	up := vec3.UnitY
+	if math.Abs(ldir.Y) > 0.99 {
+		up = vec3.UnitZ
+	}
+	lrot := mat4.LookAt(vec3.Zero, ldir, up)
+	lrotInv := lrot.Invert()
+	origin := lrot.TransformPoint(centerWorld)
+	origin.X = math.Floor(origin.X/texel) * texel
+	origin.Y = math.Floor(origin.Y/texel) * texel
+	centerWorld = lrotInv.TransformPoint(origin)
+
+	// create light view matrix looking at the center of the camera frustum slice
+	position := centerWorld.Sub(ldir.Scaled(radius))
+	lview := mat4.LookAt(position, centerWorld, up)
+	lproj := mat4.Orthographic(-radius, radius, -radius, radius, 0, 2*radius)
 	lvp := lproj.Mul(&lview)
 
-	// round the center of the lights projection to the nearest texel
-	origin := lvp.TransformPoint(vec3.New(0, 0, 0)).Scaled(texSize / 2.0)
-	offset := origin.Round().Sub(origin)
-	offset.Scale(2.0 / texSize)
-	lproj[12] = offset.X
-	lproj[13] = offset.Y
-
-	// re-create view-projection after rounding
-	lvp = lproj.Mul(&lview)
-
 	return Cascade{
 		Proj:      lproj,
 		View:      lview,
 		ViewProj:  lvp,
-		NearSplit: nearSplit * args.Camera.Far,
-		FarSplit:  farSplit * args.Camera.Far,
+		NearSplit: nearSplit * far,
+		FarSplit:  farSplit * far,
+		Radius:    radius,
 	}
 }
 
+// fitSphere returns the distance along the view axis to the center of the smallest sphere enclosing
+// the part of the view frustum between the given depths, and its radius.
+func fitSphere(proj mat4.T, near, far float32) (float32, float32) {
+	// squared distance from the view axis to the frustum corners, per unit of depth
+	k2 := 1/(proj[0]*proj[0]) + 1/(proj[5]*proj[5])
+
+	// the center is equidistant to the near and far corners, unless that places it beyond the far plane.
+	// in that case the sphere is bounded by the far corners alone
+	center := math.Min(0.5*(near+far)*(1+k2), far)
+	radius := math.Sqrt((far-center)*(far-center) + far*far*k2)
+	return center, radius
+}
+
 func (lit *Directional) LightData(shadowmaps ShadowmapStore) uniform.Light {
 	ldir := lit.Transform().Forward()
 	entry := uniform.Light{
@@ -191,11 +191,21 @@ func (lit *Directional) LightData(shadowmaps ShadowmapStore) uniform.Light {
 		Range:     lit.CascadeBlend.Get(),
 	}
 
+	bias := lit.CascadeBias.Get()
+	biases := [uniform.ShadowCascades]float32{bias.X, bias.Y, bias.Z, bias.W}
+
+	// orthographic cascades cover the same extent in depth and width, so the penumbra width in texture coordinates
+	// is the normalized distance between blocker and receiver, scaled by the angular radius of the light
+	penumbra := math.Tan(math.DegToRad(lit.LightSize.Get()) / 2)
+
 	for cascadeIndex, cascade := range lit.cascades {
 		entry.ViewProj[cascadeIndex] = cascade.ViewProj
 		entry.Distance[cascadeIndex] = cascade.FarSplit
-		if handle, exists := shadowmaps.Lookup(lit, cascadeIndex); exists {
-			entry.Shadowmap[cascadeIndex] = uint32(handle)
+		if shadowmap, exists := shadowmaps.Lookup(lit, cascadeIndex); exists {
+			texel := 2 * cascade.Radius / float32(shadowmap.Size)
+			entry.Shadowmap[cascadeIndex] = uint32(shadowmap.Handle)
+			entry.ShadowRegion[cascadeIndex] = shadowmap.Region
+			entry.ShadowParams[cascadeIndex] = vec4.New(biases[min(cascadeIndex, len(biases)-1)], lit.NormalBias.Get()*texel, penumbra, 0)
 		}
 	}
 
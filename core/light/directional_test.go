@@ -0,0 +1,71 @@
+package light_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+func cameraArgs(position, forward vec3.T) draw.Args {
+	near, far := float32(0.1), float32(100)
+	proj := mat4.Perspective(60, 16.0/9, near, far)
+	view := mat4.LookAt(position, position.Add(forward), vec3.UnitY)
+	vp := proj.Mul(&view)
+	return draw.Args{
+		Camera: draw.Camera{
+			Proj:        proj,
+			View:        view,
+			ViewProj:    vp,
+			ViewProjInv: vp.Invert(),
+			Position:    position,
+			Forward:     forward,
+			Near:        near,
+			Far:         far,
+		},
+	}
+}
+
+var _ = Describe("directional light cascades", func() {
+	var lit *light.Directional
+	BeforeEach(func() {
+		lit = light.NewDirectional(object.NewPool(), light.DirectionalArgs{
+			Color:     color.White,
+			Intensity: 1,
+			Shadows:   true,
+			Cascades:  4,
+		})
+	})
+
+	It("keeps the cascade size as the camera rotates", func() {
+		Expect(lit.PreDraw(cameraArgs(vec3.Zero, vec3.UnitZ), nil)).To(Succeed())
+		before := make([]mat4.T, lit.Shadowmaps())
+		for i := range before {
+			before[i] = lit.ShadowProjection(i).Proj
+		}
+
+		Expect(lit.PreDraw(cameraArgs(vec3.Zero, vec3.New(1, 0.5, 0).Normalized()), nil)).To(Succeed())
+		for i := range before {
+			Expect(lit.ShadowProjection(i).Proj).To(Equal(before[i]))
+		}
+	})
+
+	It("snaps cascades to shadow map texels", func() {
+		for _, x := range []float32{0, 0.013, 0.37, 1.9} {
+			Expect(lit.PreDraw(cameraArgs(vec3.New(x, 1, 2*x), vec3.UnitZ), nil)).To(Succeed())
+			for i := 0; i < lit.Shadowmaps(); i++ {
+				// the world origin must land on a texel corner of the smallest shadow map
+				vp := lit.ShadowProjection(i).ViewProj
+				p := vp.TransformPoint(vec3.Zero)
+				texel := (p.X*0.5 + 0.5) * light.MinShadowResolution
+				Expect(math.Abs(texel - math.Round(texel))).To(BeNumerically("<", 0.01))
+			}
+		}
+	})
+})
@@ -0,0 +1,54 @@
+package light
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/render/ibl"
+)
+
+type EnvironmentArgs struct {
+	Path      string
+	Intensity float32
+}
+
+// Environment provides image based ambient lighting from an equirectangular HDR image.
+// Only the first environment in a scene is used.
+type Environment struct {
+	object.Component
+
+	Path      object.Property[string]
+	Intensity object.Property[float32]
+
+	maps *ibl.Environment
+}
+
+func init() {
+	object.Register[*Environment](object.Type{
+		Name: "Environment Light",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return NewEnvironment(pool, EnvironmentArgs{
+				Intensity: 1,
+			}), nil
+		},
+	})
+}
+
+func NewEnvironment(pool object.Pool, args EnvironmentArgs) *Environment {
+	return object.NewComponent(pool, &Environment{
+		Path:      object.NewProperty(args.Path),
+		Intensity: object.NewProperty(args.Intensity),
+	})
+}
+
+func (e *Environment) Name() string { return "Environment" }
+
+// Maps returns the lighting maps for the current environment image, or nil if no image is set
+func (e *Environment) Maps() *ibl.Environment {
+	path := e.Path.Get()
+	if path == "" {
+		return nil
+	}
+	if e.maps == nil || e.maps.Path() != path {
+		e.maps = ibl.Load(path)
+	}
+	return e.maps
+}
@@ -8,8 +8,24 @@ import (
 	"github.com/johanhenriksson/goworld/render/color"
 )
 
+// MinShadowResolution is the smallest shadow map resolution.
+// Shadow map resolutions are powers of two, so the texels of any shadow map align with the texels of the smallest one.
+const MinShadowResolution = 256
+
+// ShadowMap locates a shadow map within the shadow atlas
+type ShadowMap struct {
+	// Handle is the sampler index of the atlas texture
+	Handle int
+
+	// Region holds the offset of the shadow map within the atlas in xy, and its scale in zw, in texture coordinates
+	Region vec4.T
+
+	// Size is the resolution of the shadow map, in texels
+	Size int
+}
+
 type ShadowmapStore interface {
-	Lookup(T, int) (int, bool)
+	Lookup(T, int) (ShadowMap, bool)
 }
 
 type T interface {
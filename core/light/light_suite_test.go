@@ -8,6 +8,7 @@ import (
 
 	"github.com/johanhenriksson/goworld/core/light"
 	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/vec4"
 	"github.com/johanhenriksson/goworld/render/color"
 )
 
@@ -20,8 +21,12 @@ type TestShadowStore struct{}
 
 var _ light.ShadowmapStore = (*TestShadowStore)(nil)
 
-func (t *TestShadowStore) Lookup(lit light.T, index int) (int, bool) {
-	return index, true
+func (t *TestShadowStore) Lookup(lit light.T, index int) (light.ShadowMap, bool) {
+	return light.ShadowMap{
+		Handle: index,
+		Region: vec4.New(0, 0, 1, 1),
+		Size:   light.MinShadowResolution,
+	}, true
 }
 
 var _ = Describe("serialization", func() {
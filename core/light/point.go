@@ -3,14 +3,37 @@ package light
 import (
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/math/vec4"
 	"github.com/johanhenriksson/goworld/render/color"
 )
 
+// CubeFaces is the number of shadow maps used by an omnidirectional light
+const CubeFaces = 6
+
+// cubeFace describes the view direction of a single shadow cube face.
+// Face order matches the face selection in lighting.glsl: +X, -X, +Y, -Y, +Z, -Z
+type cubeFace struct {
+	Forward vec3.T
+	Up      vec3.T
+}
+
+var cubeFaces = [CubeFaces]cubeFace{
+	{Forward: vec3.UnitX, Up: vec3.UnitY},
+	{Forward: vec3.UnitXN, Up: vec3.UnitY},
+	{Forward: vec3.UnitY, Up: vec3.UnitZN},
+	{Forward: vec3.UnitYN, Up: vec3.UnitZ},
+	{Forward: vec3.UnitZ, Up: vec3.UnitY},
+	{Forward: vec3.UnitZN, Up: vec3.UnitY},
+}
+
 type PointArgs struct {
 	Color     color.T
 	Range     float32
 	Intensity float32
+	Shadows   bool
 }
 
 type Point struct {
@@ -20,6 +43,7 @@ type Point struct {
 	Range     object.Property[float32]
 	Intensity object.Property[float32]
 	Falloff   object.Property[float32]
+	Shadows   object.Property[bool]
 }
 
 var _ T = &Point{}
@@ -43,15 +67,27 @@ func NewPoint(pool object.Pool, args PointArgs) *Point {
 		Range:     object.NewProperty(args.Range),
 		Intensity: object.NewProperty(args.Intensity),
 		Falloff:   object.NewProperty(float32(2)),
+		Shadows:   object.NewProperty(args.Shadows),
 	})
 }
 
 func (lit *Point) Name() string      { return "PointLight" }
 func (lit *Point) Type() Type        { return TypePoint }
-func (lit *Point) CastShadows() bool { return false }
+func (lit *Point) CastShadows() bool { return lit.Shadows.Get() }
+
+// Importance returns a score used to prioritize shadow casting point lights when
+// the number of shadow maps is limited. Bright, large lights close to the eye score higher.
+func (lit *Point) Importance(eye vec3.T) float32 {
+	lightRange := lit.Range.Get()
+	distance := vec3.Distance(eye, lit.Transform().WorldPosition())
+
+	// lights containing the eye are always relevant
+	edge := math.Max(distance-lightRange, 1)
+	return lit.Intensity.Get() * lightRange / edge
+}
 
 func (lit *Point) LightData(shadowmaps ShadowmapStore) uniform.Light {
-	return uniform.Light{
+	entry := uniform.Light{
 		Type:      uint32(TypePoint),
 		Position:  vec4.Extend(lit.Transform().WorldPosition(), 0),
 		Color:     lit.Color.Get(),
@@ -59,12 +95,69 @@ func (lit *Point) LightData(shadowmaps ShadowmapStore) uniform.Light {
 		Range:     lit.Range.Get(),
 		Falloff:   lit.Falloff.Get(),
 	}
+
+	if !lit.CastShadows() {
+		return entry
+	}
+
+	// a zero handle in the first face indicates that the light has no shadows
+	for face := 0; face < CubeFaces; face++ {
+		shadowmap, exists := shadowmaps.Lookup(lit, face)
+		if !exists {
+			entry.Shadowmap = [uniform.ShadowMaps]uint32{}
+			return entry
+		}
+		entry.ViewProj[face] = lit.faceViewProj(face)
+		entry.Shadowmap[face] = uint32(shadowmap.Handle)
+		entry.ShadowRegion[face] = shadowmap.Region
+	}
+
+	return entry
 }
 
 func (lit *Point) Shadowmaps() int {
-	return 0
+	return CubeFaces
+}
+
+func (lit *Point) shadowNear() float32 {
+	return math.Max(lit.Range.Get()*0.01, 0.01)
+}
+
+// faceView returns the view matrix for the given cube face
+func (lit *Point) faceView(face int) mat4.T {
+	position := lit.Transform().WorldPosition()
+	return mat4.LookAt(position, position.Add(cubeFaces[face].Forward), cubeFaces[face].Up)
+}
+
+// faceProj returns a 90 degree perspective projection covering the light range
+func (lit *Point) faceProj() mat4.T {
+	near, far := lit.shadowNear(), lit.Range.Get()
+	return mat4.T{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, far / (far - near), 1,
+		0, 0, -(far * near) / (far - near), 0,
+	}
+}
+
+func (lit *Point) faceViewProj(face int) mat4.T {
+	view := lit.faceView(face)
+	proj := lit.faceProj()
+	return proj.Mul(&view)
 }
 
 func (lit *Point) ShadowProjection(mapIndex int) uniform.Camera {
-	panic("todo")
+	view := lit.faceView(mapIndex)
+	proj := lit.faceProj()
+	viewProj := proj.Mul(&view)
+	return uniform.Camera{
+		Proj:        proj,
+		View:        view,
+		ViewProj:    viewProj,
+		ProjInv:     proj.Invert(),
+		ViewInv:     view.Invert(),
+		ViewProjInv: viewProj.Invert(),
+		Eye:         vec4.Extend(lit.Transform().WorldPosition(), 0),
+		Forward:     vec4.Extend(cubeFaces[mapIndex].Forward, 0),
+	}
 }
@@ -0,0 +1,66 @@
+package light_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+var _ = Describe("point light shadows", func() {
+	var pool object.Pool
+	var lit *light.Point
+	BeforeEach(func() {
+		pool = object.NewPool()
+		lit = light.NewPoint(pool, light.PointArgs{
+			Color:     color.White,
+			Intensity: 1,
+			Range:     10,
+			Shadows:   true,
+		})
+	})
+
+	It("assigns a shadowmap to each cube face", func() {
+		Expect(lit.CastShadows()).To(BeTrue())
+		Expect(lit.Shadowmaps()).To(Equal(light.CubeFaces))
+
+		data := lit.LightData(&TestShadowStore{})
+		for face := 0; face < light.CubeFaces; face++ {
+			Expect(data.Shadowmap[face]).To(BeEquivalentTo(face))
+		}
+	})
+
+	It("omits shadowmaps when shadows are disabled", func() {
+		lit.Shadows.Set(false)
+		data := lit.LightData(&TestShadowStore{})
+		Expect(data.Shadowmap[1]).To(BeEquivalentTo(0))
+	})
+
+	It("projects face directions onto the center of each face", func() {
+		directions := []vec3.T{vec3.UnitX, vec3.UnitXN, vec3.UnitY, vec3.UnitYN, vec3.UnitZ, vec3.UnitZN}
+		for face, dir := range directions {
+			cam := lit.ShadowProjection(face)
+			p := cam.ViewProj.TransformPoint(dir.Scaled(5))
+			Expect(p.X).To(BeNumerically("~", 0, 1e-4))
+			Expect(p.Y).To(BeNumerically("~", 0, 1e-4))
+			Expect(p.Z).To(BeNumerically(">", 0))
+			Expect(p.Z).To(BeNumerically("<", 1))
+		}
+	})
+
+	It("ranks nearby lights as more important", func() {
+		far := light.NewPoint(pool, light.PointArgs{
+			Intensity: 1,
+			Range:     10,
+			Shadows:   true,
+		})
+		object.Builder(object.Empty(pool, "far")).
+			Attach(far).
+			Position(vec3.New(100, 0, 0)).
+			Create()
+		Expect(lit.Importance(vec3.Zero)).To(BeNumerically(">", far.Importance(vec3.Zero)))
+	})
+})
@@ -0,0 +1,98 @@
+package mesh
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/material"
+)
+
+func init() {
+	object.Register[*Instanced](object.Type{
+		Name: "Instanced Mesh",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return NewInstanced(pool, nil), nil
+		},
+	})
+}
+
+// Instance holds the properties of a single instance of an instanced mesh
+type Instance struct {
+	// Transform of the instance, relative to the instanced mesh
+	Transform mat4.T
+
+	// Color tints the vertex colors of the instance
+	Color color.T
+}
+
+// Instancer is implemented by meshes that render many copies of their vertex data.
+// All instances of a mesh are drawn with a single draw call.
+type Instancer interface {
+	Mesh
+
+	// Instances returns the instances to render
+	Instances() []Instance
+}
+
+// Instanced is a mesh that renders its vertex data once for every instance.
+// Instances are culled individually against the camera frustum.
+type Instanced struct {
+	*Static
+
+	// InstanceData holds the instances of the mesh
+	InstanceData object.Property[[]Instance]
+}
+
+var _ Instancer = (*Instanced)(nil)
+
+// NewInstanced creates a new instanced mesh without any instances
+func NewInstanced(pool object.Pool, mat *material.Def) *Instanced {
+	return object.NewComponent(pool, &Instanced{
+		Static:       New(pool, mat),
+		InstanceData: object.NewProperty[[]Instance](nil),
+	})
+}
+
+func (m *Instanced) Name() string {
+	return "InstancedMesh"
+}
+
+// Add an instance with the given transform and color. Returns the index of the new instance
+func (m *Instanced) Add(transform mat4.T, clr color.T) int {
+	instances := append(m.InstanceData.Get(), Instance{
+		Transform: transform,
+		Color:     clr,
+	})
+	m.InstanceData.Set(instances)
+	return len(instances) - 1
+}
+
+// Set replaces the instance at the given index
+func (m *Instanced) Set(index int, instance Instance) {
+	instances := m.InstanceData.Get()
+	instances[index] = instance
+	m.InstanceData.Set(instances)
+}
+
+// Remove the instance at the given index.
+// The last instance is moved into its place, changing its index.
+func (m *Instanced) Remove(index int) {
+	instances := m.InstanceData.Get()
+	last := len(instances) - 1
+	instances[index] = instances[last]
+	m.InstanceData.Set(instances[:last])
+}
+
+// Clear removes all instances
+func (m *Instanced) Clear() {
+	m.InstanceData.Set(m.InstanceData.Get()[:0])
+}
+
+// Count returns the number of instances
+func (m *Instanced) Count() int {
+	return len(m.InstanceData.Get())
+}
+
+func (m *Instanced) Instances() []Instance {
+	return m.InstanceData.Get()
+}
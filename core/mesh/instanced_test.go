@@ -0,0 +1,34 @@
+package mesh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/material"
+)
+
+var _ = Describe("instanced mesh", func() {
+	var instanced *mesh.Instanced
+	BeforeEach(func() {
+		instanced = mesh.NewInstanced(object.NewPool(), material.StandardDeferred())
+		instanced.Add(mat4.Translate(vec3.New(1, 0, 0)), color.Red)
+		instanced.Add(mat4.Translate(vec3.New(2, 0, 0)), color.Green)
+		instanced.Add(mat4.Translate(vec3.New(3, 0, 0)), color.Blue)
+	})
+
+	It("moves the last instance into removed slots", func() {
+		instanced.Remove(0)
+		Expect(instanced.Count()).To(Equal(2))
+		Expect(instanced.Instances()[0].Color).To(Equal(color.Blue))
+	})
+
+	It("preserves instances when serialized", func() {
+		kopy := object.Copy(object.NewPool(), instanced)
+		Expect(kopy.Instances()).To(Equal(instanced.Instances()))
+	})
+})
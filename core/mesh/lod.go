@@ -0,0 +1,148 @@
+package mesh
+
+import (
+	"sort"
+
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/material"
+)
+
+func init() {
+	object.Register[*LODGroup](object.Type{
+		Name: "LOD Group",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return NewLODGroup(pool, nil), nil
+		},
+	})
+}
+
+// LODLevel is a single level of detail of a mesh
+type LODLevel struct {
+	Mesh assets.Mesh
+
+	// ScreenSize is the smallest projected size at which the level is used, as a fraction of the screen height
+	ScreenSize float32
+}
+
+// LODSelection describes which levels of detail to draw
+type LODSelection struct {
+	// Level is the index of the level to draw, or -1 if the mesh is too small to be drawn
+	Level int
+
+	// Next is the index of the level that is faded in during a cross-fade, or -1.
+	Next int
+
+	// Fade is the fraction of pixels of Level that are replaced by Next
+	Fade float32
+}
+
+// LevelOfDetail is implemented by meshes with multiple levels of detail
+type LevelOfDetail interface {
+	Mesh
+
+	// Levels returns the levels of detail, from highest to lowest detail
+	Levels() []LODLevel
+
+	// Select returns the levels to draw for the given projected size
+	Select(screenSize float32) LODSelection
+}
+
+// LODGroup is a mesh with multiple levels of detail.
+// Cameras select a level using the projected size of the bounding sphere of the first level.
+// A group without levels is drawn using its vertex data.
+type LODGroup struct {
+	*Static
+
+	// LevelData holds the levels of detail, sorted from highest to lowest detail
+	LevelData object.Property[[]LODLevel]
+
+	// Bias scales the projected size before selecting a level. Values above 1 favor higher detail
+	Bias object.Property[float32]
+
+	// CrossFade is the width of the dithered transition between levels, relative to the screen size threshold.
+	// Zero disables cross-fading.
+	CrossFade object.Property[float32]
+}
+
+var _ LevelOfDetail = (*LODGroup)(nil)
+
+// NewLODGroup creates a mesh with the given levels of detail.
+// The levels are sorted by screen size, from highest to lowest detail.
+func NewLODGroup(pool object.Pool, mat *material.Def, levels ...LODLevel) *LODGroup {
+	g := object.NewComponent(pool, &LODGroup{
+		Static:    New(pool, mat),
+		LevelData: object.NewProperty[[]LODLevel](nil),
+		Bias:      object.NewProperty[float32](1),
+		CrossFade: object.NewProperty[float32](0.1),
+	})
+	g.SetLevels(levels...)
+	return g
+}
+
+func (g *LODGroup) Name() string {
+	return "LODGroup"
+}
+
+// SetLevels replaces the levels of detail. The levels are sorted by screen size, from highest to lowest detail.
+// The vertex data of the group is set to the mesh of the first level.
+func (g *LODGroup) SetLevels(levels ...LODLevel) {
+	levels = append([]LODLevel(nil), levels...)
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].ScreenSize > levels[j].ScreenSize
+	})
+	g.LevelData.Set(levels)
+	if len(levels) > 0 {
+		g.VertexData.Set(levels[0].Mesh)
+	}
+}
+
+func (g *LODGroup) Levels() []LODLevel {
+	return g.LevelData.Get()
+}
+
+func (g *LODGroup) Select(screenSize float32) LODSelection {
+	size := screenSize * g.Bias.Get()
+	band := g.CrossFade.Get()
+
+	levels := g.LevelData.Get()
+	for i, level := range levels {
+		if size < level.ScreenSize {
+			continue
+		}
+		selection := LODSelection{Level: i, Next: -1}
+
+		// fade towards the next level when approaching its threshold
+		threshold := level.ScreenSize
+		if band > 0 && threshold > 0 {
+			width := threshold * band
+			if size < threshold+width {
+				selection.Fade = 1 - (size-threshold)/width
+				if i+1 < len(levels) {
+					selection.Next = i + 1
+				}
+			}
+		}
+		return selection
+	}
+
+	// too small to be drawn
+	return LODSelection{Level: -1, Next: -1}
+}
+
+// ProjectedSize returns the height of a bounding sphere projected onto the screen, as a fraction of the screen height.
+// Works for both perspective and orthographic projections.
+func ProjectedSize(proj mat4.T, eye vec3.T, sphere shape.Sphere) float32 {
+	// the w component of a point at the distance of the sphere center
+	dist := vec3.Distance(eye, sphere.Center)
+	w := proj[11]*dist + proj[15]
+	if w <= sphere.Radius*proj[11] {
+		// the camera is inside the sphere
+		return math.InfPos
+	}
+	return sphere.Radius * math.Abs(proj[5]) / w
+}
@@ -0,0 +1,107 @@
+package mesh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/material"
+)
+
+var _ = Describe("level of detail", func() {
+	var group *mesh.LODGroup
+	BeforeEach(func() {
+		group = mesh.NewLODGroup(object.NewPool(), material.StandardDeferred(),
+			mesh.LODLevel{ScreenSize: 0.1},
+			mesh.LODLevel{ScreenSize: 0.5},
+			mesh.LODLevel{ScreenSize: 0.02},
+		)
+		group.CrossFade.Set(0.2)
+	})
+
+	It("sorts levels by screen size", func() {
+		levels := group.Levels()
+		Expect(levels[0].ScreenSize).To(Equal(float32(0.5)))
+		Expect(levels[1].ScreenSize).To(Equal(float32(0.1)))
+		Expect(levels[2].ScreenSize).To(Equal(float32(0.02)))
+	})
+
+	It("selects levels by screen size", func() {
+		Expect(group.Select(1)).To(Equal(mesh.LODSelection{Level: 0, Next: -1}))
+		Expect(group.Select(0.3)).To(Equal(mesh.LODSelection{Level: 1, Next: -1}))
+		Expect(group.Select(0.01).Level).To(Equal(-1))
+	})
+
+	It("cross-fades near thresholds", func() {
+		selection := group.Select(0.55)
+		Expect(selection.Level).To(Equal(0))
+		Expect(selection.Next).To(Equal(1))
+		Expect(selection.Fade).To(BeNumerically("~", 0.5, 0.001))
+
+		// the last level fades out
+		selection = group.Select(0.021)
+		Expect(selection.Level).To(Equal(2))
+		Expect(selection.Next).To(Equal(-1))
+		Expect(selection.Fade).To(BeNumerically(">", 0))
+	})
+
+	It("applies the bias", func() {
+		group.Bias.Set(2)
+		Expect(group.Select(0.3).Level).To(Equal(0))
+	})
+
+	It("computes projected sizes", func() {
+		proj := mat4.Perspective(45, 1, 0.1, 100)
+		sphere := shape.Sphere{Center: vec3.New(0, 0, 10), Radius: 1}
+		expected := sphere.Radius * math.Abs(proj[5]) / 10
+		Expect(mesh.ProjectedSize(proj, vec3.Zero, sphere)).To(BeNumerically("~", expected, 0.001))
+
+		// halves with twice the distance
+		far := shape.Sphere{Center: vec3.New(0, 0, 20), Radius: 1}
+		Expect(mesh.ProjectedSize(proj, vec3.Zero, far)).To(BeNumerically("~", expected/2, 0.001))
+	})
+
+	It("cross-fades within the band above each threshold", func() {
+		// the band of the first level spans 0.5 to 0.6
+		Expect(group.Select(0.6)).To(Equal(mesh.LODSelection{Level: 0, Next: -1}))
+		Expect(group.Select(0.5)).To(Equal(mesh.LODSelection{Level: 0, Next: 1, Fade: 1}))
+		Expect(group.Select(0.499).Level).To(Equal(1))
+
+		// the band scales with the threshold
+		Expect(group.Select(0.125).Fade).To(BeZero())
+		Expect(group.Select(0.11).Fade).To(BeNumerically("~", 0.5, 0.001))
+	})
+
+	It("switches without fading when the band is zero", func() {
+		group.CrossFade.Set(0)
+		Expect(group.Select(0.5)).To(Equal(mesh.LODSelection{Level: 0, Next: -1}))
+		Expect(group.Select(0.499)).To(Equal(mesh.LODSelection{Level: 1, Next: -1}))
+	})
+
+	It("preserves levels when serialized", func() {
+		kopy := object.Copy(object.NewPool(), group)
+		Expect(kopy.Levels()).To(Equal(group.Levels()))
+		Expect(kopy.Select(0.55)).To(Equal(group.Select(0.55)))
+	})
+
+	It("creates groups without levels from the editor", func() {
+		var lodType *object.Type
+		for _, t := range object.Types() {
+			if t.Name == "LOD Group" {
+				lodType = t
+			}
+		}
+		Expect(lodType).ToNot(BeNil())
+
+		created, err := lodType.Create(object.NewPool())
+		Expect(err).ToNot(HaveOccurred())
+		empty := created.(*mesh.LODGroup)
+		Expect(empty.Levels()).To(BeEmpty())
+		Expect(empty.Select(1).Level).To(Equal(-1))
+	})
+})
@@ -19,6 +19,21 @@ func init() {
 	})
 }
 
+// Layer is a bitmask that groups meshes for screen space effects.
+// Decals are only projected onto meshes in one of their layers.
+type Layer uint8
+
+const (
+	LayerStatic Layer = 1 << iota
+	LayerDynamic
+
+	// LayerReflective meshes receive screen space reflections
+	LayerReflective
+
+	LayerNone Layer = 0
+	LayerAll  Layer = 0xFF
+)
+
 type Mesh interface {
 	object.Component
 
@@ -28,6 +43,9 @@ type Mesh interface {
 
 	CastShadows() bool
 
+	// Layer returns the layers the mesh belongs to
+	Layer() Layer
+
 	//
 	// used for rendering:
 	//
@@ -53,6 +71,7 @@ type Static struct {
 	radius float32
 
 	CastsShadow object.Property[bool]
+	Layers      object.Property[Layer]
 	Mat         object.Property[*material.Def]
 	Textures    object.Dict[texture.Slot, assets.Texture]
 	VertexData  object.Property[assets.Mesh]
@@ -65,6 +84,7 @@ func New(pool object.Pool, mat *material.Def) *Static {
 	return object.NewComponent(pool, &Static{
 		Mat:         object.NewProperty(mat),
 		CastsShadow: object.NewProperty(true),
+		Layers:      object.NewProperty(LayerStatic),
 		Textures:    object.NewDict[texture.Slot, assets.Texture](),
 		VertexData:  object.NewProperty[assets.Mesh](nil),
 
@@ -105,6 +125,10 @@ func (m *Static) CastShadows() bool {
 	return false
 }
 
+func (m *Static) Layer() Layer {
+	return m.Layers.Get()
+}
+
 func (m *Static) Material() *material.Def {
 	return m.Mat.Get()
 }
@@ -113,12 +113,9 @@ type EncodedProp interface {
 	Decode([]byte) (PropValue, error)
 }
 
-var encodedPropType = reflect.TypeOf((EncodedProp)(nil))
-
 func (p *Property[T]) Serialize(enc Encoder) error {
-	if reflect.TypeOf(p.value).Implements(encodedPropType) {
+	if encoder, ok := any(p.value).(EncodedProp); ok {
 		// use the custom serialization
-		encoder := reflect.ValueOf(p.value).Interface().(EncodedProp)
 		bytes, err := encoder.Encode()
 		if err != nil {
 			return err
@@ -131,10 +128,12 @@ func (p *Property[T]) Serialize(enc Encoder) error {
 }
 
 func (p *Property[T]) Deserialize(pool Pool, dec Decoder) error {
-	if reflect.TypeOf(p.value).Implements(encodedPropType) {
+	if decoder, ok := any(p.value).(EncodedProp); ok {
 		// use the custom serialization
-		decoder := reflect.ValueOf(p.value).Interface().(EncodedProp)
 		var bytes []byte
+		if err := dec.Decode(&bytes); err != nil {
+			return err
+		}
 		value, err := decoder.Decode(bytes)
 		if err != nil {
 			return err
@@ -462,7 +462,13 @@ func (m *MemorySerializer) Decode(target any) error {
 	if m.index >= len(m.Stream) {
 		return io.EOF
 	}
-	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(m.Stream[m.index]))
+	value := reflect.ValueOf(target).Elem()
+	if data := m.Stream[m.index]; data != nil {
+		value.Set(reflect.ValueOf(data))
+	} else {
+		// nil interface values decode to the zero value
+		value.SetZero()
+	}
 	m.index++
 	return nil
 }
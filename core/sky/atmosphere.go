@@ -0,0 +1,132 @@
+package sky
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+// Atmosphere describes the physical parameters of the single scattering sky model.
+// Distances are given in meters. The model is mirrored by lib/atmosphere.glsl
+type Atmosphere struct {
+	PlanetRadius     float32
+	AtmosphereRadius float32
+
+	// Rayleigh scattering coefficients at sea level
+	Rayleigh       vec3.T
+	RayleighHeight float32
+
+	// Mie scattering coefficient at sea level
+	Mie       float32
+	MieHeight float32
+
+	// Mie preferred scattering direction
+	MieG float32
+
+	// Intensity of the sun
+	SunIntensity float32
+}
+
+// Earth returns an earth-like atmosphere
+func Earth() Atmosphere {
+	return Atmosphere{
+		PlanetRadius:     6371e3,
+		AtmosphereRadius: 6471e3,
+		Rayleigh:         vec3.New(5.5e-6, 13.0e-6, 22.4e-6),
+		RayleighHeight:   8e3,
+		Mie:              21e-6,
+		MieHeight:        1.2e3,
+		MieG:             0.758,
+		SunIntensity:     22,
+	}
+}
+
+const (
+	primarySteps   = 16
+	secondarySteps = 8
+
+	// fraction of the horizon radiance reflected by the ground
+	groundAlbedo = 0.3
+)
+
+// intersectSphere returns the near and far distances at which a ray intersects a sphere centered at the origin.
+// If the ray misses, the far distance is smaller than the near distance.
+func intersectSphere(origin, dir vec3.T, radius float32) vec2.T {
+	b := 2 * vec3.Dot(dir, origin)
+	c := vec3.Dot(origin, origin) - radius*radius
+	d := b*b - 4*c
+	if d < 0 {
+		return vec2.New(1e5, -1e5)
+	}
+	sq := math.Sqrt(d)
+	return vec2.New((-b-sq)/2, (-b+sq)/2)
+}
+
+// Radiance returns the sky radiance seen in a direction, given the normalized direction towards the sun.
+// Directions below the horizon return a dim reflection of the horizon.
+func (a *Atmosphere) Radiance(dir, sun vec3.T) vec3.T {
+	if dir.Y >= 0 {
+		return a.scatter(dir, sun)
+	}
+	horizon := vec3.New(dir.X, 0, dir.Z)
+	if horizon.LengthSqr() < 1e-8 {
+		horizon = vec3.UnitX
+	}
+	return a.scatter(horizon.Normalized(), sun).Scaled(groundAlbedo)
+}
+
+func (a *Atmosphere) scatter(dir, sun vec3.T) vec3.T {
+	// observer is placed one meter above the ground
+	origin := vec3.New(0, a.PlanetRadius+1, 0)
+
+	hit := intersectSphere(origin, dir, a.AtmosphereRadius)
+	if hit.X > hit.Y {
+		return vec3.Zero
+	}
+	stepSize := hit.Y / primarySteps
+
+	var totalRayleigh, totalMie vec3.T
+	var depthRayleigh, depthMie float32
+	for i := 0; i < primarySteps; i++ {
+		position := origin.Add(dir.Scaled((float32(i) + 0.5) * stepSize))
+		height := position.Length() - a.PlanetRadius
+
+		// optical depth of this step
+		stepRayleigh := math.Exp(-height/a.RayleighHeight) * stepSize
+		stepMie := math.Exp(-height/a.MieHeight) * stepSize
+		depthRayleigh += stepRayleigh
+		depthMie += stepMie
+
+		// points in the shadow of the planet receive no sun light
+		if shadow := intersectSphere(position, sun, a.PlanetRadius); shadow.X < shadow.Y && shadow.X > 0 {
+			continue
+		}
+
+		// optical depth towards the sun
+		sunStep := intersectSphere(position, sun, a.AtmosphereRadius).Y / secondarySteps
+		var sunRayleigh, sunMie float32
+		for j := 0; j < secondarySteps; j++ {
+			p := position.Add(sun.Scaled((float32(j) + 0.5) * sunStep))
+			h := p.Length() - a.PlanetRadius
+			sunRayleigh += math.Exp(-h/a.RayleighHeight) * sunStep
+			sunMie += math.Exp(-h/a.MieHeight) * sunStep
+		}
+
+		// attenuation along the path from the sun to the observer
+		tau := a.Rayleigh.Scaled(depthRayleigh + sunRayleigh).Add(vec3.New1(a.Mie * 1.1 * (depthMie + sunMie)))
+		attenuation := vec3.New(math.Exp(-tau.X), math.Exp(-tau.Y), math.Exp(-tau.Z))
+
+		totalRayleigh = totalRayleigh.Add(attenuation.Scaled(stepRayleigh))
+		totalMie = totalMie.Add(attenuation.Scaled(stepMie))
+	}
+
+	// phase functions
+	mu := vec3.Dot(dir, sun)
+	g := a.MieG
+	phaseRayleigh := 3 / (16 * math.Pi) * (1 + mu*mu)
+	phaseMie := 3 / (8 * math.Pi) * ((1 - g*g) * (mu*mu + 1)) / (math.Pow(1+g*g-2*mu*g, 1.5) * (2 + g*g))
+
+	rayleigh := totalRayleigh.Mul(a.Rayleigh).Scaled(phaseRayleigh)
+	mie := totalMie.Scaled(a.Mie * phaseMie)
+	return rayleigh.Add(mie).Scaled(a.SunIntensity)
+}
@@ -0,0 +1,177 @@
+package sky
+
+import (
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/quat"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/ibl"
+)
+
+type Mode int
+
+const (
+	// Procedural renders an atmospheric scattering sky lit by the sun
+	Procedural Mode = iota
+
+	// Skybox renders an HDR environment image
+	Skybox
+)
+
+// size of the cubemap used to project the procedural sky onto spherical harmonics
+const ambientCubeSize = 8
+
+type Args struct {
+	Mode      Mode
+	Path      string
+	Intensity float32
+	TimeOfDay float32
+}
+
+// Sky is drawn behind all scene geometry. Only the first sky in a scene is used.
+type Sky struct {
+	object.Component
+
+	Mode object.Property[Mode]
+
+	// Path to an equirectangular HDR image used in skybox mode
+	Path object.Property[string]
+
+	Intensity object.Property[float32]
+
+	// Hour of the day in the range [0,24). Determines the sun direction when
+	// ControlSun is enabled or when there is no directional light in the scene.
+	TimeOfDay object.Property[float32]
+
+	// DaySpeed is the number of hours that pass per second
+	DaySpeed object.Property[float32]
+
+	// Latitude in degrees. Tilts the path of the sun across the sky
+	Latitude object.Property[float32]
+
+	// ControlSun orients the first directional light in the scene according to the time of day
+	ControlSun object.Property[bool]
+
+	// Ambient enables the sky as a source of ambient lighting
+	Ambient object.Property[bool]
+
+	atmosphere Atmosphere
+	skybox     *ibl.Environment
+	sunQuery   *object.Query[*light.Directional]
+
+	ambient    ibl.SH9
+	ambientSun vec3.T
+	ambientSet bool
+}
+
+func init() {
+	object.Register[*Sky](object.Type{
+		Name: "Sky",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return New(pool, Args{
+				Mode:      Procedural,
+				Intensity: 1,
+				TimeOfDay: 12,
+			}), nil
+		},
+	})
+}
+
+func New(pool object.Pool, args Args) *Sky {
+	return object.NewComponent(pool, &Sky{
+		Mode:       object.NewProperty(args.Mode),
+		Path:       object.NewProperty(args.Path),
+		Intensity:  object.NewProperty(args.Intensity),
+		TimeOfDay:  object.NewProperty(args.TimeOfDay),
+		DaySpeed:   object.NewProperty[float32](0),
+		Latitude:   object.NewProperty[float32](30),
+		ControlSun: object.NewProperty(false),
+		Ambient:    object.NewProperty(true),
+
+		atmosphere: Earth(),
+		sunQuery:   object.NewQuery[*light.Directional](),
+	})
+}
+
+func (s *Sky) Name() string { return "Sky" }
+
+// Atmosphere returns the scattering parameters of the procedural sky
+func (s *Sky) Atmosphere() *Atmosphere {
+	return &s.atmosphere
+}
+
+func (s *Sky) Update(scene object.Component, dt float32) {
+	s.Component.Update(scene, dt)
+
+	if speed := s.DaySpeed.Get(); speed != 0 {
+		s.TimeOfDay.Set(math.Mod(s.TimeOfDay.Get()+speed*dt+24, 24))
+	}
+
+	if s.ControlSun.Get() {
+		if sun, exists := s.sunQuery.Reset().First(scene); exists {
+			// directional lights shine along their forward axis
+			towards := SunDirection(s.TimeOfDay.Get(), s.Latitude.Get())
+			sun.Transform().SetWorldRotation(quat.BetweenVectors(vec3.Forward, towards.Scaled(-1)))
+		}
+	}
+}
+
+// SunDirection returns the normalized direction towards the sun at the given hour of the day.
+// The sun rises in the east (+X) at 6, peaks at noon and sets in the west at 18.
+func SunDirection(hour, latitude float32) vec3.T {
+	angle := (hour - 6) / 24 * 2 * math.Pi
+	tilt := math.DegToRad(latitude)
+	return vec3.New(
+		math.Cos(angle),
+		math.Sin(angle)*math.Cos(tilt),
+		math.Sin(angle)*math.Sin(tilt),
+	).Normalized()
+}
+
+// Sun returns the normalized direction towards the sun. The direction of the first
+// directional light in the scene is used if one exists, otherwise it is derived from the time of day.
+func (s *Sky) Sun(scene object.Component) vec3.T {
+	if sun, exists := s.sunQuery.Reset().First(scene); exists {
+		return sun.Transform().Forward().Scaled(-1).Normalized()
+	}
+	return SunDirection(s.TimeOfDay.Get(), s.Latitude.Get())
+}
+
+// Radiance returns the sky radiance in a direction, for the given direction towards the sun
+func (s *Sky) Radiance(dir, sun vec3.T) vec3.T {
+	return s.atmosphere.Radiance(dir, sun).Scaled(s.Intensity.Get())
+}
+
+// Skybox returns the environment maps of the skybox image, or nil if the sky is procedural
+func (s *Sky) Skybox() *ibl.Environment {
+	path := s.Path.Get()
+	if s.Mode.Get() != Skybox || path == "" {
+		return nil
+	}
+	if s.skybox == nil || s.skybox.Path() != path {
+		s.skybox = ibl.Load(path)
+	}
+	return s.skybox
+}
+
+// AmbientSH returns the spherical harmonics projection of the procedural sky for the given sun direction.
+// The projection is only recomputed when the sun moves noticeably.
+func (s *Sky) AmbientSH(sun vec3.T) *ibl.SH9 {
+	if s.ambientSet && vec3.Dot(sun, s.ambientSun) > 0.9999 {
+		return &s.ambient
+	}
+
+	cube := ibl.NewCubemap(ambientCubeSize)
+	for face := range cube.Faces {
+		for y := 0; y < cube.Size; y++ {
+			for x := 0; x < cube.Size; x++ {
+				cube.Set(face, x, y, s.atmosphere.Radiance(cube.TexelDirection(face, x, y), sun))
+			}
+		}
+	}
+	s.ambient = ibl.ProjectSH(cube)
+	s.ambientSun = sun
+	s.ambientSet = true
+	return &s.ambient
+}
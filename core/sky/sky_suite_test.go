@@ -0,0 +1,13 @@
+package sky_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSky(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "core/sky")
+}
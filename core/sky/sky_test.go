@@ -0,0 +1,77 @@
+package sky_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/core/sky"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+var _ = Describe("sky", func() {
+	var pool object.Pool
+	BeforeEach(func() {
+		pool = object.NewPool()
+	})
+
+	It("moves the sun across the sky", func() {
+		sunrise := sky.SunDirection(6, 0)
+		Expect(sunrise.ApproxEqual(vec3.UnitX)).To(BeTrue())
+
+		noon := sky.SunDirection(12, 0)
+		Expect(noon.ApproxEqual(vec3.UnitY)).To(BeTrue())
+
+		midnight := sky.SunDirection(0, 30)
+		Expect(midnight.Y).To(BeNumerically("<", 0))
+	})
+
+	It("scatters blue light at noon and red light at sunset", func() {
+		atm := sky.Earth()
+
+		zenith := atm.Radiance(vec3.UnitY, sky.SunDirection(12, 30))
+		Expect(zenith.Z).To(BeNumerically(">", zenith.X))
+
+		sunset := sky.SunDirection(17.9, 0)
+		glow := atm.Radiance(vec3.New(-1, 0.05, 0).Normalized(), sunset)
+		Expect(glow.X).To(BeNumerically(">", glow.Z))
+
+		night := atm.Radiance(vec3.UnitY, sky.SunDirection(0, 0))
+		Expect(night.Length()).To(BeNumerically("<", 1e-3))
+	})
+
+	It("advances the time of day", func() {
+		s := sky.New(pool, sky.Args{TimeOfDay: 23})
+		s.DaySpeed.Set(2)
+		s.Update(s, 1)
+		Expect(s.TimeOfDay.Get()).To(BeNumerically("~", 1, 1e-4))
+	})
+
+	It("orients the directional light", func() {
+		s := sky.New(pool, sky.Args{TimeOfDay: 12})
+		s.ControlSun.Set(true)
+		sun := light.NewDirectional(pool, light.DirectionalArgs{Color: color.White, Intensity: 1})
+		scene := object.Builder(object.Empty(pool, "scene")).
+			Attach(s).
+			Create()
+		object.Builder(object.Empty(pool, "sun")).
+			Attach(sun).
+			Parent(scene).
+			Create()
+
+		s.Update(scene, 0)
+		expected := sky.SunDirection(12, s.Latitude.Get())
+		Expect(sun.Transform().Forward().Scaled(-1).ApproxEqual(expected)).To(BeTrue())
+		Expect(s.Sun(scene).ApproxEqual(expected)).To(BeTrue())
+	})
+
+	It("provides ambient light from above", func() {
+		s := sky.New(pool, sky.Args{Intensity: 1, TimeOfDay: 12})
+		sh := s.AmbientSH(sky.SunDirection(12, 30))
+		up, down := sh.Irradiance(vec3.UnitY), sh.Irradiance(vec3.UnitYN)
+		Expect(up.Z).To(BeNumerically(">", down.Z))
+		Expect(up.Z).To(BeNumerically(">", 0))
+	})
+})
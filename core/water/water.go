@@ -0,0 +1,243 @@
+package water
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/vertex"
+)
+
+// MaxWaves is the maximum number of waves of a water surface. Additional waves are ignored
+const MaxWaves = 8
+
+// Gravity is the gravitational acceleration used to derive the speed of waves from their wavelength
+const Gravity = 9.81
+
+// number of iterations used to find the surface point above a position
+const sampleIterations = 4
+
+// Wave is a single Gerstner wave.
+// Waves travel at the speed of deep water waves of their wavelength.
+type Wave struct {
+	// Direction of travel in the local XZ plane
+	Direction vec2.T
+
+	// Steepness controls the sharpness of the crests, from 0 for a sine wave to 1 for cusps.
+	// The sum of the steepness of all waves should not exceed 1, or the surface loops over itself.
+	Steepness float32
+
+	// Wavelength is the distance between crests
+	Wavelength float32
+}
+
+// Speed returns the phase speed of the wave
+func (w Wave) Speed() float32 {
+	return math.Sqrt(Gravity * w.Wavelength / (2 * math.Pi))
+}
+
+// Period returns the time it takes for the wave to travel one wavelength
+func (w Wave) Period() float32 {
+	return w.Wavelength / w.Speed()
+}
+
+// displace accumulates the displacement of a point on the undisturbed surface, along with the partial derivatives
+// of the displaced surface along the X and Z axes. Matches the wave function of the water shader.
+func (w Wave) displace(point vec2.T, time float32, offset, tangent, binormal *vec3.T) {
+	if w.Wavelength <= 0 || w.Direction.LengthSqr() == 0 {
+		return
+	}
+	k := 2 * math.Pi / w.Wavelength
+	dir := w.Direction.Normalized()
+	phase := k * (vec2.Dot(dir, point) - w.Speed()*time)
+	amplitude := w.Steepness / k
+	sin, cos := math.Sincos(phase)
+
+	*offset = offset.Add(vec3.New(dir.X*amplitude*cos, amplitude*sin, dir.Y*amplitude*cos))
+	*tangent = tangent.Add(vec3.New(-dir.X*dir.X*w.Steepness*sin, dir.X*w.Steepness*cos, -dir.X*dir.Y*w.Steepness*sin))
+	*binormal = binormal.Add(vec3.New(-dir.X*dir.Y*w.Steepness*sin, dir.Y*w.Steepness*cos, -dir.Y*dir.Y*w.Steepness*sin))
+}
+
+// DefaultWaves returns a set of waves resembling a moderate breeze over open water
+func DefaultWaves() []Wave {
+	return []Wave{
+		{Direction: vec2.New(1, 0.3), Steepness: 0.25, Wavelength: 24},
+		{Direction: vec2.New(0.7, 1), Steepness: 0.2, Wavelength: 13},
+		{Direction: vec2.New(-0.4, 1), Steepness: 0.15, Wavelength: 7},
+		{Direction: vec2.New(1, -0.8), Steepness: 0.1, Wavelength: 3.5},
+	}
+}
+
+type Args struct {
+	Size       vec2.T
+	Resolution int
+	Waves      []Wave
+}
+
+// Water is a tessellated plane displaced by Gerstner waves. It is drawn by the forward pass after opaque geometry,
+// absorbing and refracting the scene behind it and foaming where it intersects other surfaces.
+//
+// The surface lies in the local XZ plane of the object. Waves are evaluated on the CPU with the same function
+// as the shader, so that objects can follow the surface using Sample.
+type Water struct {
+	object.Component
+
+	// Size of the plane
+	Size object.Property[vec2.T]
+
+	// Resolution is the number of quads along each side of the plane
+	Resolution object.Property[int]
+
+	// Waves that displace the surface. At most MaxWaves are used
+	Waves object.Property[[]Wave]
+
+	// Absorption is the fraction of each color channel absorbed per unit of distance travelled through the water
+	Absorption object.Property[vec3.T]
+
+	// Color of the light scattered towards the eye by the water body, in linear color space
+	Color object.Property[color.T]
+
+	// Refraction is the strength of the screen space distortion of the scene behind the surface
+	Refraction object.Property[float32]
+
+	// FoamColor is the color of foam, in linear color space
+	FoamColor object.Property[color.T]
+
+	// FoamDepth is the water depth below which foam appears around intersecting geometry. Zero disables foam
+	FoamDepth object.Property[float32]
+
+	time float32
+	mesh vertex.MutableMesh[vertex.Vertex, uint32]
+}
+
+func init() {
+	object.Register[*Water](object.Type{
+		Name: "Water",
+		Create: func(pool object.Pool) (object.Component, error) {
+			return New(pool, Args{
+				Size:       vec2.New(64, 64),
+				Resolution: 128,
+				Waves:      DefaultWaves(),
+			}), nil
+		},
+	})
+}
+
+func New(pool object.Pool, args Args) *Water {
+	if args.Resolution < 1 {
+		args.Resolution = 1
+	}
+	w := object.NewComponent(pool, &Water{
+		Size:       object.NewProperty(args.Size),
+		Resolution: object.NewProperty(args.Resolution),
+		Waves:      object.NewProperty(args.Waves),
+		Absorption: object.NewProperty(vec3.New(0.45, 0.09, 0.06)),
+		Color:      object.NewProperty(color.RGB(0.01, 0.06, 0.08)),
+		Refraction: object.NewProperty[float32](0.03),
+		FoamColor:  object.NewProperty(color.RGB(0.9, 0.95, 1)),
+		FoamDepth:  object.NewProperty[float32](0.4),
+	})
+	w.mesh = vertex.NewTriangles[vertex.Vertex, uint32](object.Key("water", w), nil, nil)
+	w.Size.OnChange.Subscribe(func(vec2.T) { w.refresh() })
+	w.Resolution.OnChange.Subscribe(func(int) { w.refresh() })
+	w.refresh()
+	return w
+}
+
+func (w *Water) Name() string { return "Water" }
+
+// Mesh returns the undisplaced surface grid
+func (w *Water) Mesh() vertex.Mesh {
+	return w.mesh
+}
+
+// Time returns the time used to animate the waves, in seconds
+func (w *Water) Time() float32 {
+	return w.time
+}
+
+func (w *Water) Update(scene object.Component, dt float32) {
+	w.Component.Update(scene, dt)
+	w.time += dt
+}
+
+// ActiveWaves returns the waves used to displace the surface
+func (w *Water) ActiveWaves() []Wave {
+	waves := w.Waves.Get()
+	if len(waves) > MaxWaves {
+		return waves[:MaxWaves]
+	}
+	return waves
+}
+
+// Displace returns the displaced position and the normal of a point on the undisturbed surface, in local space
+func (w *Water) Displace(point vec2.T) (vec3.T, vec3.T) {
+	position, tangent, binormal := w.surface(point)
+	return position, vec3.Cross(binormal, tangent).Normalized()
+}
+
+// Sample returns the world space height and normal of the surface directly above or below a world space position.
+// Since waves also move the surface horizontally, the undisturbed point that ends up at the position
+// is found using Newton's method.
+func (w *Water) Sample(position vec3.T) (float32, vec3.T) {
+	target := w.Transform().Unproject(position).XZ()
+	point := target
+	for i := 0; i < sampleIterations; i++ {
+		displaced, tangent, binormal := w.surface(point)
+		residual := target.Sub(displaced.XZ())
+
+		// solve for the step using the horizontal part of the jacobian
+		det := tangent.X*binormal.Z - binormal.X*tangent.Z
+		if math.Abs(det) < 1e-6 {
+			point = point.Add(residual)
+			continue
+		}
+		point = point.Add(vec2.New(
+			(binormal.Z*residual.X-binormal.X*residual.Y)/det,
+			(tangent.X*residual.Y-tangent.Z*residual.X)/det,
+		))
+	}
+	surface, normal := w.Displace(point)
+	return w.Transform().Project(surface).Y, w.Transform().ProjectDir(normal).Normalized()
+}
+
+// surface returns the displaced position of a point on the undisturbed surface,
+// and the partial derivatives of the displaced position along the local X and Z axes
+func (w *Water) surface(point vec2.T) (position, tangent, binormal vec3.T) {
+	position = vec3.New(point.X, 0, point.Y)
+	tangent = vec3.UnitX
+	binormal = vec3.UnitZ
+	for _, wave := range w.ActiveWaves() {
+		wave.displace(point, w.time, &position, &tangent, &binormal)
+	}
+	return position, tangent, binormal
+}
+
+func (w *Water) refresh() {
+	size := w.Size.Get()
+	n := max(w.Resolution.Get(), 1)
+
+	vertices := make([]vertex.Vertex, 0, (n+1)*(n+1))
+	for z := 0; z <= n; z++ {
+		for x := 0; x <= n; x++ {
+			uv := vec2.New(float32(x)/float32(n), float32(z)/float32(n))
+			position := vec3.New((uv.X-0.5)*size.X, 0, (uv.Y-0.5)*size.Y)
+			vertices = append(vertices, vertex.New(position, vec3.UnitY, uv, color.White))
+		}
+	}
+
+	indices := make([]uint32, 0, 6*n*n)
+	row := uint32(n + 1)
+	for z := uint32(0); z < uint32(n); z++ {
+		for x := uint32(0); x < uint32(n); x++ {
+			a := z*row + x
+			b := a + 1
+			c := a + row
+			d := c + 1
+			indices = append(indices, a, c, b, b, c, d)
+		}
+	}
+
+	w.mesh.Update(vertices, indices)
+}
@@ -0,0 +1,13 @@
+package water_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWater(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "core/water")
+}
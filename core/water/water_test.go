@@ -0,0 +1,60 @@
+package water_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/core/water"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+var _ = Describe("water", func() {
+	var pool object.Pool
+	BeforeEach(func() {
+		pool = object.NewPool()
+	})
+
+	It("tessellates the plane", func() {
+		w := water.New(pool, water.Args{Size: vec2.New(10, 10), Resolution: 4})
+		Expect(w.Mesh().VertexCount()).To(Equal(25))
+		Expect(w.Mesh().IndexCount()).To(Equal(96))
+	})
+
+	It("is flat without waves", func() {
+		w := water.New(pool, water.Args{Size: vec2.New(10, 10), Resolution: 4})
+		position, normal := w.Displace(vec2.New(1, 2))
+		Expect(position.ApproxEqual(vec3.New(1, 0, 2))).To(BeTrue())
+		Expect(normal.ApproxEqual(vec3.UnitY)).To(BeTrue())
+	})
+
+	It("samples the displaced surface at a world position", func() {
+		w := water.New(pool, water.Args{Size: vec2.New(10, 10), Resolution: 4, Waves: water.DefaultWaves()})
+		w.Transform().SetPosition(vec3.New(5, 2, -3))
+		w.Update(w, 1.3)
+
+		for _, point := range []vec2.T{vec2.New(0, 0), vec2.New(1.5, -2), vec2.New(-3, 4)} {
+			surface, normal := w.Displace(point)
+			world := w.Transform().Project(surface)
+
+			height, sampled := w.Sample(vec3.New(world.X, 100, world.Z))
+			Expect(height).To(BeNumerically("~", world.Y, 1e-3))
+			Expect(vec3.Distance(sampled, normal)).To(BeNumerically("<", 1e-3))
+		}
+	})
+
+	It("repeats after the period of a wave", func() {
+		wave := water.Wave{Direction: vec2.New(1, 0), Steepness: 0.5, Wavelength: 8}
+		w := water.New(pool, water.Args{Size: vec2.New(10, 10), Resolution: 4, Waves: []water.Wave{wave}})
+
+		before, _ := w.Displace(vec2.New(1, 1))
+		w.Update(w, wave.Period()/2)
+		half, _ := w.Displace(vec2.New(1, 1))
+		w.Update(w, wave.Period()/2)
+		after, _ := w.Displace(vec2.New(1, 1))
+
+		Expect(half.Y).To(BeNumerically("~", -before.Y, 1e-3))
+		Expect(after.Y).To(BeNumerically("~", before.Y, 1e-3))
+	})
+})
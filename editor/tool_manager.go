@@ -12,6 +12,7 @@ import (
 	"github.com/johanhenriksson/goworld/math/mat4"
 	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/physics"
+	"github.com/johanhenriksson/goworld/render/color"
 )
 
 type Tool interface {
@@ -34,15 +35,26 @@ type ToolManager struct {
 	Object
 	scene    Object
 	selected []T
+	hovered  T
 	tool     Tool
 	camera   mat4.T
 	viewport draw.Viewport
 
+	// outline appearance of selected and hovered objects
+	SelectColor  color.T
+	HoverColor   color.T
+	OutlineWidth int
+
 	// built-in tools
 	Mover   *gizmo.Mover
 	Rotater *gizmo.Rotater
+
+	// Picker resolves the object under the cursor when selecting
+	Picker *draw.Picker
 }
 
+var _ draw.OutlineSource = &ToolManager{}
+
 func NewToolManager(pool Pool) *ToolManager {
 	return NewObject(pool, "Tool Manager", &ToolManager{
 		Mover: Builder(gizmo.NewMover(pool)).
@@ -53,6 +65,12 @@ func NewToolManager(pool Pool) *ToolManager {
 			Active(false).
 			Create(),
 
+		Picker: draw.NewPicker(pool),
+
+		SelectColor:  color.RGB(1, 0.6, 0.1),
+		HoverColor:   color.RGBA(1, 1, 1, 0.5),
+		OutlineWidth: 2,
+
 		selected: make([]T, 0, 16),
 	})
 }
@@ -75,6 +93,11 @@ func (m *ToolManager) MouseEvent(e mouse.Event) {
 	}
 	hit, _ := world.Raycast(near, far, 1)
 
+	m.hovered = nil
+	if hit.Shape != nil {
+		m.hovered = GetInParents[T](hit.Shape)
+	}
+
 	if m.tool != nil {
 		// pass on the mouse event
 		m.tool.ToolMouseEvent(e, hit)
@@ -83,28 +106,57 @@ func (m *ToolManager) MouseEvent(e mouse.Event) {
 		}
 	}
 
-	if hit.Shape == nil {
-		return
-	}
-
 	canReselect := m.selected == nil || m.tool == nil || m.tool.CanDeselect()
 	if !canReselect {
 		return
 	}
 
-	editor := GetInParents[T](hit.Shape)
-
 	// if nothing is selected, or CanDeselect() is true,
 	// look for something else to select.
+	// objects are picked by the renderer, so that objects without physics shapes can be selected too
 	if e.Button() == mouse.Button1 && e.Action() == mouse.Release {
-		if editor != nil {
-			// todo: pass physics hit info
-			m.setSelect(e, editor)
-		} else {
-			// deselect
-			m.setSelect(e, nil)
+		m.Picker.Pick(cursor, m.pick)
+	}
+}
+
+// pick selects the editor of a picked component. picking empty space clears the selection.
+// the result arrives a few frames after the request, so the selection state is checked again.
+func (m *ToolManager) pick(picked Component) {
+	if m.scene == nil {
+		return
+	}
+	if m.tool != nil && !m.tool.CanDeselect() {
+		return
+	}
+	if picked == nil {
+		// deselect
+		m.setSelect(mouse.NopEvent(), nil)
+		m.UseTool(nil)
+		return
+	}
+	// picked meshes without an editor, such as tool gizmos, keep the current selection
+	if editor := m.lookup(picked); editor != nil {
+		m.setSelect(mouse.NopEvent(), editor)
+	}
+}
+
+// lookup returns the editor of a component
+func (m *ToolManager) lookup(component Component) T {
+	// editor gizmos, such as light sprites, belong to their editor
+	if editor := GetInParents[T](component); editor != nil {
+		return editor
+	}
+
+	// scene objects are edited by the editor targeting them, or their closest ancestor with an editor
+	for target := component; target != nil; target = target.Parent() {
+		editor, exists := NewQuery[T]().Where(func(e T) bool {
+			return e.Target() == target
+		}).First(m.scene)
+		if exists {
+			return editor
 		}
 	}
+	return nil
 }
 
 func (m *ToolManager) Actions() []Action {
@@ -177,6 +229,27 @@ func (m *ToolManager) Selected() []T {
 	return m.selected
 }
 
+// Outlines returns the hovered and selected objects, which are outlined by the renderer
+func (m *ToolManager) Outlines() []draw.Outline {
+	outlines := make([]draw.Outline, 0, 2)
+	if m.hovered != nil {
+		outlines = append(outlines, draw.Outline{
+			Objects: []Component{m.hovered.Target()},
+			Color:   m.HoverColor,
+			Width:   m.OutlineWidth,
+		})
+	}
+	if len(m.selected) > 0 {
+		// the first editor is the object editor of the selected group
+		outlines = append(outlines, draw.Outline{
+			Objects: []Component{m.selected[0].Target()},
+			Color:   m.SelectColor,
+			Width:   m.OutlineWidth,
+		})
+	}
+	return outlines
+}
+
 func (m *ToolManager) setSelect(e mouse.Event, component T) bool {
 	// todo: detect if the object has been deleted
 	// otherwise CanDeselect() will make it impossible to select another object
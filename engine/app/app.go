@@ -6,6 +6,8 @@ import (
 
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/recorder"
+	"github.com/johanhenriksson/goworld/engine/resolution"
 	"github.com/johanhenriksson/goworld/engine/window"
 	"github.com/johanhenriksson/goworld/engine/window/glfw"
 )
@@ -17,7 +19,7 @@ func Run(args Args, scenefuncs ...object.SceneFunc) {
 	go engine.RunProfilingServer(6060)
 	interrupt := NewInterrupter()
 
-	app := engine.New("goworld", 0)
+	app := engine.New("goworld", args.Device)
 	defer app.Destroy()
 
 	// create a window
@@ -35,13 +37,23 @@ func Run(args Args, scenefuncs ...object.SceneFunc) {
 	// create renderer
 	renderer := args.Renderer(app, wnd)
 	defer renderer.Destroy()
+	renderer.Settings().AntiAliasing = args.AntiAliasing
+	if args.DynamicResolution {
+		renderer.Settings().DynamicResolution = resolution.New(resolution.DefaultArgs())
+	}
 
 	// create scene
 	pool := object.NewPool()
 	scene := object.Scene(pool, scenefuncs...)
 	wnd.SetInputHandler(scene)
 
-	object.Attach(scene, engine.NewStatsGUI(pool))
+	object.Attach(scene, engine.NewStatsGUI(pool, renderer.Stats(), renderer.Settings()))
+	object.Attach(scene, engine.NewDebugControls(pool, renderer.Settings()))
+
+	// frame recording
+	rec := recorder.New(pool, renderer, recorder.DefaultArgs())
+	object.Attach(scene, rec)
+	defer rec.Stop()
 
 	// run the render loop
 	log.Println("ready")
@@ -51,9 +63,11 @@ func Run(args Args, scenefuncs ...object.SceneFunc) {
 		// update scene
 		wnd.Poll()
 		counter.Update()
-		scene.Update(scene, counter.Delta())
+		elapsed, delta := rec.Time(counter.Elapsed(), counter.Delta())
+		scene.Update(scene, delta)
 
 		// draw
-		renderer.Draw(scene, counter.Elapsed(), counter.Delta())
+		rec.Capture()
+		renderer.Draw(scene, elapsed, delta)
 	}
 }
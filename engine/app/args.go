@@ -10,6 +10,17 @@ type Args struct {
 	Width    int
 	Height   int
 	Renderer engine.RendererFunc
+
+	// Device is the index of the physical device to render with
+	Device int
+
+	// AntiAliasing sets the initial anti-aliasing mode of the renderer.
+	// It can be changed at runtime through the renderer settings.
+	AntiAliasing engine.AntiAliasing
+
+	// DynamicResolution adjusts the resolution of the scene to keep frame times within budget.
+	// See resolution.DefaultArgs
+	DynamicResolution bool
 }
 
 func (a *Args) Defaults() *Args {
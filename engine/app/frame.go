@@ -9,14 +9,37 @@ import (
 	"github.com/johanhenriksson/goworld/render/image"
 )
 
+// FrameFunc receives each rendered frame of a headless render
+type FrameFunc func(frame int, img *osimage.RGBA) error
+
 // Render a single frame and return it as *image.RGBA
 func Frame(args Args, scenefuncs ...object.SceneFunc) *osimage.RGBA {
+	var result *osimage.RGBA
+	err := Frames(args, 1, 0, func(frame int, img *osimage.RGBA) error {
+		result = img
+		return nil
+	}, scenefuncs...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Frames renders a number of frames without a window, advancing the scene by a fixed timestep between frames.
+// Each frame is passed to the handler. Rendering stops at the first error returned by the handler.
+//
+// Asset caches are forced to load synchronously, so that every frame is rendered with all of its assets.
+func Frames(args Args, frames int, delta float32, handler FrameFunc, scenefuncs ...object.SceneFunc) error {
 	runtime.LockOSThread()
 	args.Defaults()
 
-	app := engine.New("goworld", 0)
+	app := engine.New("goworld", args.Device)
 	defer app.Destroy()
 
+	app.Meshes().SetAsync(false)
+	app.Textures().SetAsync(false)
+	app.Shaders().SetAsync(false)
+
 	buffer := engine.NewColorTarget(app.Device(), "output", image.FormatRGBA8Unorm, engine.TargetSize{
 		Width:  args.Width,
 		Height: args.Height,
@@ -28,13 +51,19 @@ func Frame(args Args, scenefuncs ...object.SceneFunc) *osimage.RGBA {
 	// create renderer
 	renderer := args.Renderer(app, buffer)
 	defer renderer.Destroy()
+	renderer.Settings().AntiAliasing = args.AntiAliasing
 
 	// create scene
 	pool := object.NewPool()
 	scene := object.Scene(pool, scenefuncs...)
 
-	scene.Update(scene, 0)
-	renderer.Draw(scene, 0, 0)
+	for frame := 0; frame < frames; frame++ {
+		scene.Update(scene, delta)
+		renderer.Draw(scene, float32(frame)*delta, delta)
 
-	return renderer.Screengrab()
+		if err := handler(frame, renderer.Screengrab()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
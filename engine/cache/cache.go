@@ -19,6 +19,10 @@ type T[K Key, V Value] interface {
 	// MaxAge returns the number of ticks until unused lines are evicted
 	MaxAge() int
 
+	// SetAsync controls whether TryFetch returns immediately while values are being instantiated.
+	// Synchronous caches always wait for the value, which makes rendering deterministic.
+	SetAsync(async bool)
+
 	// Tick increments the age of all cache lines, and evicts those
 	// that have not been accessed in maxAge ticks or more.
 	Tick()
@@ -67,8 +71,8 @@ func New[K Key, V Value](backend Backend[K, V]) T[K, V] {
 		lock:    &sync.RWMutex{},
 		maxAge:  60 * 100,
 
-		// async caches should be a setting that can be disabled for testing purposes.
-		// with async enabled, its difficult to render a single frame deterministically
+		// with async enabled, its difficult to render a single frame deterministically.
+		// async loading can be enabled with SetAsync
 		async: false,
 	}
 	return c
@@ -76,6 +80,12 @@ func New[K Key, V Value](backend Backend[K, V]) T[K, V] {
 
 func (c cache[K, V]) MaxAge() int { return c.maxAge }
 
+func (c *cache[K, V]) SetAsync(async bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.async = async
+}
+
 func (c *cache[K, V]) get(key K) (*line[V], bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -133,7 +143,10 @@ func (c *cache[K, V]) fetch(key K) *line[V] {
 }
 
 func (c *cache[K, V]) TryFetch(key K) (V, bool) {
-	if !c.async {
+	c.lock.RLock()
+	async := c.async
+	c.lock.RUnlock()
+	if !async {
 		return c.Fetch(key), true
 	}
 
@@ -9,6 +9,8 @@ import (
 	"github.com/johanhenriksson/goworld/render/shader"
 	"github.com/johanhenriksson/goworld/render/texture"
 	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
 )
 
 type Pipeline struct {
@@ -56,6 +58,11 @@ func (m *pipelineCache) Instantiate(def *material.Def, callback func(*Pipeline))
 	// fetch shader from cache
 	shader := m.shaders.Fetch(shader.Ref(def.Shader))
 
+	fillMode := core1_0.PolygonModeFill
+	if def.Wireframe {
+		fillMode = core1_0.PolygonModeLine
+	}
+
 	// create material
 	pipe := pipeline.New(
 		m.device,
@@ -71,6 +78,8 @@ func (m *pipelineCache) Instantiate(def *material.Def, callback func(*Pipeline))
 			DepthFunc:  def.DepthFunc,
 			Primitive:  def.Primitive,
 			CullMode:   def.CullMode,
+
+			PolygonFillMode: fillMode,
 		})
 
 	callback(&Pipeline{
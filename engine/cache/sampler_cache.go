@@ -72,6 +72,9 @@ func (s *samplers) MaxAge() int {
 	return s.maxAge
 }
 
+// SetAsync is a no-op. The sampler cache follows the async mode of its texture cache.
+func (s *samplers) SetAsync(bool) {}
+
 func (s *samplers) Size() int {
 	return s.size
 }
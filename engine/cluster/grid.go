@@ -0,0 +1,215 @@
+package cluster
+
+import (
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+)
+
+type Args struct {
+	// Number of clusters along each axis of the view frustum
+	X, Y, Z int
+
+	// Maximum number of lights assigned to a single cluster
+	MaxLights int
+}
+
+// DefaultArgs returns a 16x9x24 grid with up to 64 lights per cluster
+func DefaultArgs() Args {
+	return Args{
+		X:         16,
+		Y:         9,
+		Z:         24,
+		MaxLights: 64,
+	}
+}
+
+// Grid divides the view frustum into screen space tiles and exponentially distributed depth slices.
+// Lights are binned into the clusters they overlap, allowing the lighting shaders to only
+// evaluate the lights affecting the cluster a fragment belongs to.
+type Grid struct {
+	args Args
+
+	proj   mat4.T
+	near   float32
+	far    float32
+	bounds []shape.Box
+	lists  [][]uint32
+
+	clusters []uniform.Cluster
+	indices  []uniform.ClusterLights
+}
+
+func New(args Args) *Grid {
+	count := args.X * args.Y * args.Z
+	lists := make([][]uint32, count)
+	for i := range lists {
+		lists[i] = make([]uint32, 0, args.MaxLights)
+	}
+	return &Grid{
+		args:     args,
+		bounds:   make([]shape.Box, count),
+		lists:    lists,
+		clusters: make([]uniform.Cluster, count),
+		indices:  make([]uniform.ClusterLights, 0, count),
+	}
+}
+
+// Size returns the total number of clusters in the grid
+func (g *Grid) Size() int {
+	return g.args.X * g.args.Y * g.args.Z
+}
+
+// Capacity returns the number of packed light index elements required to hold a fully populated grid
+func (g *Grid) Capacity() int {
+	return g.Size() * g.args.MaxLights / uniform.ClusterLightsPerItem
+}
+
+// Index returns the linear index of the cluster at the given grid coordinates
+func (g *Grid) Index(x, y, z int) int {
+	return x + g.args.X*(y+g.args.Y*z)
+}
+
+// Slice returns the depth slice containing the given view space depth
+func (g *Grid) Slice(depth float32) int {
+	if depth <= g.near {
+		return 0
+	}
+	k := int(math.Log(depth/g.near) / math.Log(g.far/g.near) * float32(g.args.Z))
+	return math.Clamp(k, 0, g.args.Z-1)
+}
+
+// depth returns the view space depth at the near plane of the given slice
+func (g *Grid) depth(slice int) float32 {
+	return g.near * math.Pow(g.far/g.near, float32(slice)/float32(g.args.Z))
+}
+
+// Bounds returns the view space bounding box of a cluster
+func (g *Grid) Bounds(index int) shape.Box {
+	return g.bounds[index]
+}
+
+// Lights returns the light indices assigned to a cluster
+func (g *Grid) Lights(index int) []uint32 {
+	return g.lists[index]
+}
+
+// Update recomputes the cluster bounds whenever the projection changes
+func (g *Grid) Update(proj mat4.T, near, far float32) {
+	if proj == g.proj && near == g.near && far == g.far {
+		return
+	}
+	g.proj = proj
+	g.near = near
+	g.far = far
+
+	inv := proj.Invert()
+	ray := func(x, y float32) vec3.T {
+		// returns a view space point on the ray through the given ndc coordinate, with z = 1
+		p := inv.TransformPoint(vec3.New(x, y, 1))
+		return p.Scaled(1 / p.Z)
+	}
+
+	sx, sy := 2/float32(g.args.X), 2/float32(g.args.Y)
+	for y := 0; y < g.args.Y; y++ {
+		for x := 0; x < g.args.X; x++ {
+			x0, y0 := float32(x)*sx-1, float32(y)*sy-1
+			corners := [4]vec3.T{
+				ray(x0, y0),
+				ray(x0+sx, y0),
+				ray(x0, y0+sy),
+				ray(x0+sx, y0+sy),
+			}
+			for z := 0; z < g.args.Z; z++ {
+				zn, zf := g.depth(z), g.depth(z+1)
+				g.bounds[g.Index(x, y, z)] = shape.BoxFromPoints(
+					corners[0].Scaled(zn), corners[1].Scaled(zn), corners[2].Scaled(zn), corners[3].Scaled(zn),
+					corners[0].Scaled(zf), corners[1].Scaled(zf), corners[2].Scaled(zf), corners[3].Scaled(zf),
+				)
+			}
+		}
+	}
+}
+
+// Assign bins lights into the clusters they affect.
+// Light indices refer to the position of the light in the given slice.
+func (g *Grid) Assign(view mat4.T, lights []uniform.Light) {
+	for i := range g.lists {
+		g.lists[i] = g.lists[i][:0]
+	}
+
+	for index, lit := range lights {
+		if light.Type(lit.Type) != light.TypePoint {
+			// non-local lights affect every cluster
+			for i := range g.lists {
+				g.add(i, index)
+			}
+			continue
+		}
+
+		sphere := shape.Sphere{
+			Center: view.TransformPoint(lit.Position.XYZ()),
+			Radius: lit.Range,
+		}
+		if sphere.Center.Z+sphere.Radius < g.near || sphere.Center.Z-sphere.Radius > g.far {
+			continue
+		}
+
+		z0, z1 := g.Slice(sphere.Center.Z-sphere.Radius), g.Slice(sphere.Center.Z+sphere.Radius)
+		for z := z0; z <= z1; z++ {
+			for y := 0; y < g.args.Y; y++ {
+				for x := 0; x < g.args.X; x++ {
+					cluster := g.Index(x, y, z)
+					if g.bounds[cluster].IntersectsSphere(&sphere) {
+						g.add(cluster, index)
+					}
+				}
+			}
+		}
+	}
+
+	g.pack()
+}
+
+func (g *Grid) add(cluster, index int) {
+	if len(g.lists[cluster]) >= g.args.MaxLights {
+		// cluster is full, drop the light
+		return
+	}
+	g.lists[cluster] = append(g.lists[cluster], uint32(index))
+}
+
+// pack flattens the per-cluster light lists into the gpu buffer layout.
+// Each cluster list starts at an element boundary so that shaders can index it as offset + i/4.
+func (g *Grid) pack() {
+	g.indices = g.indices[:0]
+	for i, list := range g.lists {
+		g.clusters[i].Offset = uint32(len(g.indices))
+		g.clusters[i].Count = uint32(len(list))
+		for j := 0; j < len(list); j += uniform.ClusterLightsPerItem {
+			item := uniform.ClusterLights{}
+			copy(item.Index[:], list[j:])
+			g.indices = append(g.indices, item)
+		}
+	}
+}
+
+// Settings writes the grid configuration to the light settings
+func (g *Grid) Settings(settings *uniform.LightSettings) {
+	settings.ClusterX = int32(g.args.X)
+	settings.ClusterY = int32(g.args.Y)
+	settings.ClusterZ = int32(g.args.Z)
+	settings.ClusterNear = g.near
+	settings.ClusterFar = g.far
+}
+
+func (g *Grid) Flush(clusters *descriptor.Storage[uniform.Cluster], indices *descriptor.Storage[uniform.ClusterLights]) {
+	clusters.SetRange(0, g.clusters)
+	if len(g.indices) > 0 {
+		indices.SetRange(0, g.indices)
+	}
+}
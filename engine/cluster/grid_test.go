@@ -0,0 +1,105 @@
+package cluster_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/engine/cluster"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/math/vec4"
+)
+
+var _ = Describe("cluster grid", func() {
+	var grid *cluster.Grid
+	var view mat4.T
+	args := cluster.Args{X: 4, Y: 4, Z: 8, MaxLights: 8}
+	near, far := float32(0.1), float32(100)
+
+	pointLight := func(position vec3.T, radius float32) uniform.Light {
+		return uniform.Light{
+			Type:     uint32(light.TypePoint),
+			Position: vec4.Extend(position, 1),
+			Range:    radius,
+		}
+	}
+
+	BeforeEach(func() {
+		grid = cluster.New(args)
+		grid.Update(mat4.Perspective(50, 1, near, far), near, far)
+		view = mat4.Ident()
+	})
+
+	It("slices depth exponentially", func() {
+		Expect(grid.Slice(near)).To(Equal(0))
+		Expect(grid.Slice(far)).To(Equal(args.Z - 1))
+		Expect(grid.Slice(1)).To(BeNumerically("<", grid.Slice(10)))
+
+		// slice bounds should agree with the slice lookup
+		for z := 0; z < args.Z; z++ {
+			box := grid.Bounds(grid.Index(0, 0, z))
+			mid := (box.Min.Z + box.Max.Z) / 2
+			Expect(grid.Slice(mid)).To(Equal(z))
+		}
+	})
+
+	It("assigns point lights to overlapping clusters", func() {
+		grid.Assign(view, []uniform.Light{
+			pointLight(vec3.New(0, 0, 10), 0.5),
+		})
+
+		// the light is in the center of the view, which is shared by the 4 central tiles
+		z := grid.Slice(10)
+		Expect(grid.Lights(grid.Index(1, 1, z))).To(ConsistOf(uint32(0)))
+		Expect(grid.Lights(grid.Index(2, 2, z))).To(ConsistOf(uint32(0)))
+
+		// corner clusters and distant slices should be unaffected
+		Expect(grid.Lights(grid.Index(0, 0, z))).To(BeEmpty())
+		Expect(grid.Lights(grid.Index(1, 1, 0))).To(BeEmpty())
+		Expect(grid.Lights(grid.Index(1, 1, args.Z-1))).To(BeEmpty())
+	})
+
+	It("skips lights behind the camera", func() {
+		grid.Assign(view, []uniform.Light{
+			pointLight(vec3.New(0, 0, -10), 2),
+		})
+		for i := 0; i < grid.Size(); i++ {
+			Expect(grid.Lights(i)).To(BeEmpty())
+		}
+	})
+
+	It("assigns directional lights to every cluster", func() {
+		grid.Assign(view, []uniform.Light{
+			pointLight(vec3.New(0, 0, 10), 0.5),
+			{Type: uint32(light.TypeDirectional)},
+		})
+		for i := 0; i < grid.Size(); i++ {
+			Expect(grid.Lights(i)).To(ContainElement(uint32(1)))
+		}
+	})
+
+	It("limits the number of lights per cluster", func() {
+		lights := make([]uniform.Light, args.MaxLights+4)
+		for i := range lights {
+			lights[i] = pointLight(vec3.New(0, 0, 10), 1)
+		}
+		grid.Assign(view, lights)
+		Expect(grid.Lights(grid.Index(1, 1, grid.Slice(10)))).To(HaveLen(args.MaxLights))
+	})
+
+	It("writes grid settings and preserves light order", func() {
+		lights := make([]uniform.Light, 5)
+		for i := range lights {
+			lights[i] = pointLight(vec3.New(0, 0, 10), 0.5)
+		}
+		grid.Assign(view, lights)
+
+		settings := uniform.LightSettings{}
+		grid.Settings(&settings)
+		Expect(settings.ClusterZ).To(BeEquivalentTo(args.Z))
+		Expect(settings.ClusterNear).To(Equal(near))
+		Expect(grid.Lights(grid.Index(1, 1, grid.Slice(10)))).To(Equal([]uint32{0, 1, 2, 3, 4}))
+	})
+})
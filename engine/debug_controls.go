@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"github.com/johanhenriksson/goworld/core/input/keys"
+	"github.com/johanhenriksson/goworld/core/object"
+)
+
+// Key bindings of the renderer debug controls
+const (
+	// DebugModeKey cycles through the debug visualizations. Hold shift to cycle backwards
+	DebugModeKey = keys.F3
+
+	// WireframeKey toggles the wireframe overlay
+	WireframeKey = keys.F4
+)
+
+// DebugControls is a component that switches renderer debug views using key bindings
+type DebugControls struct {
+	object.Component
+	settings *RenderSettings
+}
+
+func NewDebugControls(pool object.Pool, settings *RenderSettings) *DebugControls {
+	return object.NewComponent(pool, &DebugControls{
+		settings: settings,
+	})
+}
+
+func (d *DebugControls) KeyEvent(e keys.Event) {
+	if e.Action() != keys.Press {
+		return
+	}
+	switch e.Code() {
+	case DebugModeKey:
+		if e.Modifier(keys.Shift) {
+			d.settings.Debug = d.settings.Debug.Prev()
+		} else {
+			d.settings.Debug = d.settings.Debug.Next()
+		}
+		e.Consume()
+	case WireframeKey:
+		d.settings.Wireframe = !d.settings.Wireframe
+		e.Consume()
+	}
+}
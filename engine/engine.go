@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"fmt"
+
 	"github.com/johanhenriksson/goworld/engine/cache"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
@@ -36,7 +38,11 @@ type engine struct {
 
 func New(appName string, deviceIndex int) App {
 	instance := instance.New(appName)
-	device, err := device.New(instance, instance.EnumeratePhysicalDevices()[0])
+	physicalDevices := instance.EnumeratePhysicalDevices()
+	if deviceIndex < 0 || deviceIndex >= len(physicalDevices) {
+		panic(fmt.Sprintf("invalid device index %d, found %d physical devices", deviceIndex, len(physicalDevices)))
+	}
+	device, err := device.New(instance, physicalDevices[deviceIndex])
 	if err != nil {
 		panic(err)
 	}
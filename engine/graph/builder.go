@@ -0,0 +1,372 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/engine"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// DeclareFunc declares the resources and passes of a render graph
+type DeclareFunc func(*Graph, *Builder)
+
+// Builder collects the declarations of a render graph.
+//
+// Passes declare which resources they read and write, and at which pipeline stages.
+// When the declaration is complete, the builder derives the dependencies between passes,
+// allocates transient images (sharing memory between images with disjoint lifetimes), and finally
+// constructs the passes. Since the graph is re-declared whenever it is recreated,
+// images sized relative to the output follow window resizes automatically.
+//
+// Accesses are resolved in declaration order: a read observes the most recent write declared before it.
+// Passes are therefore not reordered, the declaration order is always a valid execution order.
+type Builder struct {
+	output     engine.Target
+	resolution float32
+	resources  []*resource
+	passes     []*passDecl
+}
+
+type resourceKind int
+
+const (
+	// resourceImage is a transient image allocated by the graph, and possibly aliased
+	resourceImage resourceKind = iota
+
+	// resourceOutput is the output target of the graph
+	resourceOutput
+
+	// resourceCustom is allocated by a user supplied function, and is never aliased
+	resourceCustom
+
+	// resourceVirtual has no memory, and is only used to order passes that share data through other means
+	resourceVirtual
+
+	// resourceReadback has no memory, and marks data copied back to the host by the passes writing it
+	resourceReadback
+)
+
+func (k resourceKind) String() string {
+	switch k {
+	case resourceImage:
+		return "image"
+	case resourceOutput:
+		return "output"
+	case resourceCustom:
+		return "custom"
+	case resourceReadback:
+		return "readback"
+	default:
+		return "virtual"
+	}
+}
+
+type resource struct {
+	name    string
+	kind    resourceKind
+	image   Image
+	alloc   func(engine.TargetSize) Resource
+	resolve func(any)
+}
+
+type access struct {
+	res    *resource
+	write  bool
+	stages core1_0.PipelineStageFlags
+}
+
+type passDecl struct {
+	name     string
+	accesses []access
+	create   func() draw.Pass
+	resolve  func(draw.Pass)
+}
+
+// Image describes a transient image allocated by the render graph
+type Image struct {
+	// Format of the image. Ignored for depth images, which use the depth format of the device.
+	Format core1_0.Format
+
+	// Depth selects a depth attachment
+	Depth bool
+
+	// Scale of the image relative to the scene size. Defaults to 1
+	Scale float32
+
+	// Output sizes the image relative to the output rather than the scene.
+	// Used by overlays that are drawn after the scene is upscaled.
+	Output bool
+}
+
+func (i Image) size(scene, output engine.TargetSize) engine.TargetSize {
+	base := scene
+	if i.Output {
+		base = output
+	}
+	if i.Scale <= 0 {
+		return base
+	}
+	return base.Scaled(i.Scale)
+}
+
+// Ref is a reference to a declared resource
+type Ref interface {
+	Name() string
+	ref() *resource
+}
+
+// Handle refers to a declared resource. Its value is available once the graph has been compiled,
+// which is guaranteed inside pass constructors.
+type Handle[T any] struct {
+	res      *resource
+	value    T
+	resolved bool
+}
+
+var _ Ref = (*Handle[any])(nil)
+
+func (h *Handle[T]) Name() string   { return h.res.name }
+func (h *Handle[T]) ref() *resource { return h.res }
+
+// Get returns the allocated resource
+func (h *Handle[T]) Get() T {
+	if !h.resolved {
+		panic(fmt.Sprintf("render graph resource %s is not allocated yet", h.res.name))
+	}
+	return h.value
+}
+
+// PassHandle refers to a declared pass. The pass is available once it has been constructed,
+// which is guaranteed inside the constructors of passes that execute after it.
+type PassHandle[P draw.Pass] struct {
+	decl     *passDecl
+	pass     P
+	resolved bool
+}
+
+func (h *PassHandle[P]) Name() string { return h.decl.name }
+
+// Get returns the constructed pass
+func (h *PassHandle[P]) Get() P {
+	if !h.resolved {
+		panic(fmt.Sprintf("render graph pass %s is not constructed yet", h.decl.name))
+	}
+	return h.pass
+}
+
+// Access declares the resources accessed by a pass
+type Access struct {
+	decl *passDecl
+}
+
+// Read declares that the pass reads a resource at the given pipeline stages.
+// The pass waits for the previous writer of the resource before entering those stages.
+func (a *Access) Read(res Ref, stages core1_0.PipelineStageFlags) {
+	a.decl.accesses = append(a.decl.accesses, access{res: res.ref(), stages: stages})
+}
+
+// Write declares that the pass writes a resource at the given pipeline stages.
+// The pass waits for previous readers and writers of the resource before entering those stages.
+// Passes that modify a resource, such as blending on top of it, only need to declare the write.
+func (a *Access) Write(res Ref, stages core1_0.PipelineStageFlags) {
+	a.decl.accesses = append(a.decl.accesses, access{res: res.ref(), write: true, stages: stages})
+}
+
+// NewBuilder returns a builder for a graph presenting to the given output.
+// The output may be nil when the builder is only used for planning.
+func NewBuilder(output engine.Target) *Builder {
+	b := &Builder{
+		output:     output,
+		resolution: 1,
+	}
+	return b
+}
+
+// SetResolution sets the scale of the scene relative to the output.
+// Transient images and custom resources are sized relative to the scene.
+func (b *Builder) SetResolution(scale float32) {
+	b.resolution = scale
+}
+
+// Scaled returns true if the scene is rendered below the output resolution
+func (b *Builder) Scaled() bool {
+	return b.resolution < 1
+}
+
+// sceneSize returns the size of the output scaled by the resolution
+func (b *Builder) sceneSize() engine.TargetSize {
+	return b.output.Size().Scaled(b.resolution)
+}
+
+func (b *Builder) addResource(res *resource) {
+	for _, existing := range b.resources {
+		if existing.name == res.name {
+			panic(fmt.Sprintf("render graph resource %s is already declared", res.name))
+		}
+	}
+	b.resources = append(b.resources, res)
+}
+
+// Output declares the output target of the graph
+func (b *Builder) Output() *Handle[engine.Target] {
+	h := &Handle[engine.Target]{}
+	h.res = &resource{
+		name: "output",
+		kind: resourceOutput,
+		resolve: func(v any) {
+			h.value, h.resolved = v.(engine.Target), true
+		},
+	}
+	b.addResource(h.res)
+	return h
+}
+
+// Image declares a transient image. Images with identical descriptions and disjoint lifetimes may share memory.
+func (b *Builder) Image(name string, desc Image) *Handle[engine.Target] {
+	h := &Handle[engine.Target]{}
+	h.res = &resource{
+		name:  name,
+		kind:  resourceImage,
+		image: desc,
+		resolve: func(v any) {
+			h.value, h.resolved = v.(engine.Target), true
+		},
+	}
+	b.addResource(h.res)
+	return h
+}
+
+// Virtual declares a resource without memory. Virtual resources order passes that share data through other means,
+// for example a pass that samples a texture owned by another pass.
+func (b *Builder) Virtual(name string) *Handle[struct{}] {
+	h := &Handle[struct{}]{}
+	h.res = &resource{
+		name: name,
+		kind: resourceVirtual,
+		resolve: func(any) {
+			h.resolved = true
+		},
+	}
+	b.addResource(h.res)
+	return h
+}
+
+// Readback declares a resource without memory, representing data that passes copy back to the host.
+// Like the output, passes writing a readback are considered to contribute to the graph.
+func (b *Builder) Readback(name string) *Handle[struct{}] {
+	h := &Handle[struct{}]{}
+	h.res = &resource{
+		name: name,
+		kind: resourceReadback,
+		resolve: func(any) {
+			h.resolved = true
+		},
+	}
+	b.addResource(h.res)
+	return h
+}
+
+// Custom declares a resource allocated by the given function, using the size of the scene.
+// Custom resources are destroyed along with the graph, and never share memory.
+func Custom[T Resource](b *Builder, name string, alloc func(engine.TargetSize) T) *Handle[T] {
+	h := &Handle[T]{}
+	h.res = &resource{
+		name: name,
+		kind: resourceCustom,
+		alloc: func(size engine.TargetSize) Resource {
+			return alloc(size)
+		},
+		resolve: func(v any) {
+			h.value, h.resolved = v.(T), true
+		},
+	}
+	b.addResource(h.res)
+	return h
+}
+
+// Pass declares a render pass. The setup function declares the resources accessed by the pass,
+// and the create function constructs it once its resources have been allocated.
+func Pass[P draw.Pass](b *Builder, name string, setup func(*Access), create func() P) *PassHandle[P] {
+	h := &PassHandle[P]{}
+	h.decl = &passDecl{
+		name: name,
+		create: func() draw.Pass {
+			return create()
+		},
+		resolve: func(p draw.Pass) {
+			h.pass, h.resolved = p.(P), true
+		},
+	}
+	for _, existing := range b.passes {
+		if existing.name == name {
+			panic(fmt.Sprintf("render graph pass %s is already declared", name))
+		}
+	}
+	if setup != nil {
+		setup(&Access{decl: h.decl})
+	}
+	b.passes = append(b.passes, h.decl)
+	return h
+}
+
+// Declare returns a GraphFunc that builds a render graph from declarations
+func Declare(declare DeclareFunc) GraphFunc {
+	return func(g *Graph, output engine.Target) []Resource {
+		b := NewBuilder(output)
+		b.SetResolution(g.resolution)
+		declare(g, b)
+		return b.compile(g)
+	}
+}
+
+// compile allocates resources, constructs passes and connects them according to the plan
+func (b *Builder) compile(g *Graph) []Resource {
+	plan, err := b.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	// allocate physical resources
+	resources := make([]Resource, 0, len(b.resources))
+	scene, output := b.sceneSize(), b.output.Size()
+	physical := make([]engine.Target, len(plan.Physical))
+	for i, img := range plan.Physical {
+		var target *engine.RenderTarget
+		if img.Image.Depth {
+			target = engine.NewDepthTarget(g.app.Device(), img.Name, img.Image.size(scene, output))
+		} else {
+			target = engine.NewColorTarget(g.app.Device(), img.Name, img.Image.Format, img.Image.size(scene, output))
+		}
+		physical[i] = target
+		resources = append(resources, target)
+	}
+	for _, res := range b.resources {
+		switch res.kind {
+		case resourceOutput:
+			res.resolve(b.output)
+		case resourceImage:
+			res.resolve(physical[plan.Aliases[res.name]])
+		case resourceCustom:
+			value := res.alloc(scene)
+			resources = append(resources, value)
+			res.resolve(value)
+		case resourceVirtual, resourceReadback:
+			res.resolve(nil)
+		}
+	}
+
+	// construct passes in declaration order, so that passes may refer to passes they depend on
+	nodes := make(map[string]Node, len(plan.Order))
+	for _, decl := range plan.Order {
+		pass := decl.create()
+		decl.resolve(pass)
+		nodes[decl.name] = g.Node(pass)
+	}
+	for _, edge := range plan.Edges {
+		nodes[edge.To].After(nodes[edge.From], edge.Stages)
+	}
+
+	return resources
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"log"
+
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/device"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/upload"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+	"github.com/vkngwrapper/extensions/v2/khr_swapchain"
+)
+
+// number of captured frames that may be in flight at once
+const captureSlots = 3
+
+// frameCapture copies requested output frames into intermediate images before they are presented,
+// and downloads them to host memory in the background.
+type frameCapture struct {
+	device  *device.Device
+	pending []engine.CaptureFunc
+	images  []*image.Image
+	free    chan int
+}
+
+func newFrameCapture(dev *device.Device) *frameCapture {
+	c := &frameCapture{
+		device: dev,
+		images: make([]*image.Image, captureSlots),
+		free:   make(chan int, captureSlots),
+	}
+	for slot := range c.images {
+		c.free <- slot
+	}
+	return c
+}
+
+// Request queues a callback that receives a copy of the next drawn frame
+func (c *frameCapture) Request(fn engine.CaptureFunc) {
+	c.pending = append(c.pending, fn)
+}
+
+// Record submits a copy of the source surface if any captures are pending.
+// Must be submitted after the frame is drawn, and before it is presented.
+// Blocks until an intermediate image is available if too many captures are in flight.
+func (c *frameCapture) Record(worker command.Worker, source *image.Image) {
+	if len(c.pending) == 0 {
+		return
+	}
+	callbacks := c.pending
+	c.pending = nil
+
+	slot := <-c.free
+	dst := c.images[slot]
+	if dst == nil || dst.Width() != source.Width() || dst.Height() != source.Height() || dst.Format() != source.Format() {
+		if dst != nil {
+			dst.Destroy()
+		}
+		var err error
+		dst, err = image.New(c.device, image.Args{
+			Type:    core1_0.ImageType2D,
+			Key:     "frame-capture",
+			Width:   source.Width(),
+			Height:  source.Height(),
+			Depth:   1,
+			Layers:  1,
+			Levels:  1,
+			Format:  source.Format(),
+			Usage:   core1_0.ImageUsageTransferSrc | core1_0.ImageUsageTransferDst | core1_0.ImageUsageColorAttachment,
+			Tiling:  core1_0.ImageTilingOptimal,
+			Sharing: core1_0.SharingModeExclusive,
+			Layout:  core1_0.ImageLayoutUndefined,
+			Memory:  core1_0.MemoryPropertyDeviceLocal,
+		})
+		if err != nil {
+			panic(err)
+		}
+		c.images[slot] = dst
+	}
+
+	cmds := command.NewRecorder()
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdImageBarrier(
+			core1_0.PipelineStageColorAttachmentOutput,
+			core1_0.PipelineStageTransfer,
+			source,
+			khr_swapchain.ImageLayoutPresentSrc,
+			core1_0.ImageLayoutTransferSrcOptimal,
+			core1_0.ImageAspectColor, 0, 1)
+		cmd.CmdImageBarrier(
+			core1_0.PipelineStageTopOfPipe,
+			core1_0.PipelineStageTransfer,
+			dst,
+			core1_0.ImageLayoutUndefined,
+			core1_0.ImageLayoutTransferDstOptimal,
+			core1_0.ImageAspectColor, 0, 1)
+		cmd.CmdCopyImage(source, core1_0.ImageLayoutTransferSrcOptimal, dst, core1_0.ImageLayoutTransferDstOptimal, core1_0.ImageAspectColor)
+		cmd.CmdImageBarrier(
+			core1_0.PipelineStageTransfer,
+			core1_0.PipelineStageBottomOfPipe,
+			source,
+			core1_0.ImageLayoutTransferSrcOptimal,
+			khr_swapchain.ImageLayoutPresentSrc,
+			core1_0.ImageAspectColor, 0, 1)
+		cmd.CmdImageBarrier(
+			core1_0.PipelineStageTransfer,
+			core1_0.PipelineStageTransfer,
+			dst,
+			core1_0.ImageLayoutTransferDstOptimal,
+			core1_0.ImageLayoutTransferSrcOptimal,
+			core1_0.ImageAspectColor, 0, 1)
+	})
+
+	worker.Submit(command.SubmitInfo{
+		Marker:   "FrameCapture",
+		Commands: cmds,
+		Callback: func() {
+			// download on a separate goroutine, since the download is submitted to the same worker.
+			// the slot is released after the callbacks return, so that destroying the capture waits for them
+			go func() {
+				defer func() { c.free <- slot }()
+				img, err := upload.DownloadImage(c.device, worker, dst)
+				if err != nil {
+					log.Println("frame capture failed:", err)
+					return
+				}
+				for _, fn := range callbacks {
+					fn(img)
+				}
+			}()
+		},
+	})
+}
+
+// Destroy waits for all captures in flight to be delivered, then frees the intermediate images.
+// Pending requests are kept, and the capture remains usable.
+func (c *frameCapture) Destroy() {
+	for range captureSlots {
+		<-c.free
+	}
+	for slot, img := range c.images {
+		if img != nil {
+			img.Destroy()
+		}
+		c.images[slot] = nil
+		c.free <- slot
+	}
+}
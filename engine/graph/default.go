@@ -7,98 +7,253 @@ import (
 	"github.com/vkngwrapper/core/v2/core1_0"
 )
 
+const (
+	fragmentTests = core1_0.PipelineStageEarlyFragmentTests | core1_0.PipelineStageLateFragmentTests
+	fragment      = core1_0.PipelineStageFragmentShader
+	colorOutput   = core1_0.PipelineStageColorAttachmentOutput
+)
+
 // Instantiates the default render graph
 func Default(app engine.App, target engine.Target) engine.Renderer {
-	return New(app, target, func(g *Graph, output engine.Target) []Resource {
-		size := output.Size()
+	return New(app, target, Declare(DefaultGraph(app)))
+}
 
+// DefaultGraph declares the passes and resources of the default render graph
+func DefaultGraph(app engine.App) DeclareFunc {
+	return func(g *Graph, b *Builder) {
 		//
 		// screen buffers
 		//
 
-		// allocate main depth buffer
-		depth := engine.NewDepthTarget(app.Device(), "main-depth", size)
+		output := b.Output()
 
-		// main off-screen color buffer
-		hdrBuffer := engine.NewColorTarget(app.Device(), "main-color", core1_0.FormatR16G16B16A16SignedFloat, size)
+		// main depth buffer
+		depth := b.Image("main-depth", Image{Depth: true})
 
-		// create geometry buffer
-		gbuffer, err := pass.NewGbuffer(app.Device(), size)
-		if err != nil {
-			panic(err)
-		}
+		// main off-screen color buffer
+		hdrBuffer := b.Image("main-color", Image{Format: core1_0.FormatR16G16B16A16SignedFloat})
 
-		// allocate SSAO output buffer
-		ssaoFormat := core1_0.FormatR16SignedFloat
-		ssaoOutput := engine.NewColorTarget(app.Device(), "ssao-output", ssaoFormat, engine.TargetSize{
-			Width:  size.Width / 2,
-			Height: size.Height / 2,
-			Frames: size.Frames,
-			Scale:  size.Scale,
+		// geometry buffer
+		gbuffer := Custom(b, "gbuffer", func(size engine.TargetSize) pass.GeometryBuffer {
+			gbuffer, err := pass.NewGbuffer(app.Device(), size)
+			if err != nil {
+				panic(err)
+			}
+			return gbuffer
 		})
 
+		// half resolution ambient occlusion
+		ssaoOutput := b.Image("ssao-output", Image{Format: core1_0.FormatR16SignedFloat, Scale: 0.5})
+		blurOutput := b.Image("blur-output", Image{Format: core1_0.FormatR16SignedFloat, Scale: 0.5})
+
+		// tone mapped output
+		composition := b.Image("composition", Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized})
+		antialiased := b.Image("antialiased", Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized})
+
+		// textures owned by passes, sampled by other passes
+		shadowmaps := b.Virtual("shadowmaps")
+		pyramid := b.Virtual("depth-pyramid")
+		exposure := b.Virtual("exposure")
+		taaHistory := b.Virtual("taa-history")
+
+		// object handles under the cursor, read back by the picking pass
+		picking := b.Readback("picking")
+
 		//
 		// main render pass
 		//
 
-		shadows := pass.NewShadowPass(app, output)
-		shadowNode := g.Node(shadows)
+		shadows := Pass(b, "Shadows", func(a *Access) {
+			a.Write(shadowmaps, fragmentTests)
+		}, func() *pass.Shadowpass {
+			return pass.NewShadowPass(app, output.Get())
+		})
 
 		// depth pre-pass
-		depthPass := g.Node(pass.NewDepthPass(app, depth))
+		depthPass := Pass(b, "Depth", func(a *Access) {
+			a.Write(depth, fragmentTests)
+			a.Write(pyramid, colorOutput)
+		}, func() *pass.DepthPass {
+			return pass.NewDepthPass(app, depth.Get(), g.Stats())
+		})
 
 		// deferred geometry
-		// - wait for depth pass before fragment tests
-		deferredGeometry := g.Node(pass.NewDeferredGeometryPass(app, depth, gbuffer))
-		deferredGeometry.After(depthPass, core1_0.PipelineStageEarlyFragmentTests)
+		// - culls against the depth pyramid, and draws on top of the pre-pass depth
+		Pass(b, "DeferredGeometry", func(a *Access) {
+			a.Read(pyramid, core1_0.PipelineStageComputeShader)
+			a.Write(depth, fragmentTests)
+			a.Write(gbuffer, colorOutput)
+		}, func() *pass.DeferredGeometryPass {
+			return pass.NewDeferredGeometryPass(app, depth.Get(), gbuffer.Get(), depthPass.Get().Pyramid(), g.Stats())
+		})
+
+		// decals project onto the geometry buffer
+		Pass(b, "Decals", func(a *Access) {
+			a.Write(gbuffer, fragment)
+		}, func() *pass.DecalPass {
+			return pass.NewDecalPass(app, gbuffer.Get())
+		})
 
-		// ssao pass
-		// - wait for geometry before executing fragment shader
-		ssao := g.Node(pass.NewAmbientOcclusionPass(app, ssaoOutput, gbuffer))
-		ssao.After(deferredGeometry, core1_0.PipelineStageFragmentShader)
+		// ambient occlusion
+		Pass(b, "SSAO", func(a *Access) {
+			a.Read(gbuffer, fragment)
+			a.Write(ssaoOutput, colorOutput)
+		}, func() *pass.AmbientOcclusionPass {
+			return pass.NewAmbientOcclusionPass(app, ssaoOutput.Get(), gbuffer.Get())
+		})
 
-		// ssao blur pass
-		// - wait for ssao pass before executing fragment shader
-		blurOutput := engine.NewColorTarget(app.Device(), "blur-output", ssaoOutput.SurfaceFormat(), ssaoOutput.Size())
-		blur := g.Node(pass.NewBlurPass(app, blurOutput, ssaoOutput))
-		blur.After(ssao, core1_0.PipelineStageFragmentShader)
+		Pass(b, "SSAOBlur", func(a *Access) {
+			a.Read(ssaoOutput, fragment)
+			a.Write(blurOutput, colorOutput)
+		}, func() *pass.BlurPass {
+			return pass.NewBlurPass(app, blurOutput.Get(), ssaoOutput.Get())
+		})
 
 		// deferred lighting
-		// - wait for geometry and ssao blur before executing fragment shader
-		deferredLighting := g.Node(pass.NewDeferredLightingPass(app, hdrBuffer, gbuffer, shadows, blurOutput))
-		deferredLighting.After(shadowNode, core1_0.PipelineStageFragmentShader)
-		deferredLighting.After(blur, core1_0.PipelineStageFragmentShader)
+		Pass(b, "DeferredLighting", func(a *Access) {
+			a.Read(gbuffer, fragment)
+			a.Read(shadowmaps, fragment)
+			a.Read(blurOutput, fragment)
+			a.Write(hdrBuffer, colorOutput)
+		}, func() *pass.DeferredLightPass {
+			return pass.NewDeferredLightingPass(app, hdrBuffer.Get(), gbuffer.Get(), shadows.Get(), blurOutput.Get(), g.Settings())
+		})
+
+		// sky fills the background of the color buffer
+		Pass(b, "Sky", func(a *Access) {
+			a.Read(depth, fragmentTests)
+			a.Write(hdrBuffer, fragment)
+		}, func() *pass.SkyPass {
+			return pass.NewSkyPass(app, hdrBuffer.Get(), depth.Get())
+		})
+
+		// screen space reflections on the lit geometry buffer
+		Pass(b, "Reflections", func(a *Access) {
+			a.Read(depth, fragment)
+			a.Read(gbuffer, fragment)
+			a.Write(hdrBuffer, colorOutput)
+		}, func() *pass.ReflectionPass {
+			return pass.NewReflectionPass(app, hdrBuffer.Get(), depth.Get(), gbuffer.Get(), g.Settings())
+		})
 
 		// forward pass
-		// - wait for deferred lighting before executing fragment shader
-		forward := g.Node(pass.NewForwardPass(app, hdrBuffer, depth, shadows))
-		forward.After(deferredLighting, core1_0.PipelineStageFragmentShader)
+		Pass(b, "Forward", func(a *Access) {
+			a.Read(shadowmaps, fragment)
+			a.Write(depth, fragmentTests)
+			a.Write(hdrBuffer, fragment)
+		}, func() *pass.ForwardPass {
+			return pass.NewForwardPass(app, hdrBuffer.Get(), depth.Get(), shadows.Get(), g.Settings())
+		})
+
+		// object picking draws its own handle buffer, and only runs when picks are requested
+		Pass(b, "Picking", func(a *Access) {
+			a.Write(picking, core1_0.PipelineStageTransfer)
+		}, func() *pass.PickingPass {
+			return pass.NewPickingPass(app, output.Get().Size())
+		})
 
 		//
 		// final image composition
 		//
 
-		// post process pass
-		composition := engine.NewColorTarget(app.Device(), "composition", core1_0.FormatR8G8B8A8UnsignedNormalized, hdrBuffer.Size())
-		post := g.Node(pass.NewPostProcessPass(app, composition, hdrBuffer))
-		post.After(forward, core1_0.PipelineStageFragmentShader)
+		Pass(b, "Bloom", func(a *Access) {
+			a.Write(hdrBuffer, fragment)
+		}, func() *pass.BloomPass {
+			return pass.NewBloomPass(app, hdrBuffer.Get())
+		})
 
-		lines := g.Node(pass.NewLinePass(app, composition, depth))
-		lines.After(post, core1_0.PipelineStageFragmentShader)
+		exposurePass := Pass(b, "Exposure", func(a *Access) {
+			a.Read(hdrBuffer, fragment)
+			a.Write(exposure, colorOutput)
+		}, func() *pass.ExposurePass {
+			return pass.NewExposurePass(app, hdrBuffer.Get())
+		})
 
-		gui := g.Node(pass.NewGuiPass(app, composition))
-		gui.After(lines, core1_0.PipelineStageFragmentShader)
+		// tone mapping
+		Pass(b, "PostProcess", func(a *Access) {
+			a.Read(hdrBuffer, fragment)
+			a.Read(exposure, fragment)
+			a.Write(composition, colorOutput)
+		}, func() *pass.PostProcessPass {
+			return pass.NewPostProcessPass(app, composition.Get(), hdrBuffer.Get(), exposurePass.Get())
+		})
 
-		outputPass := g.Node(pass.NewOutputPass(app, output, composition))
-		outputPass.After(gui, core1_0.PipelineStageFragmentShader)
+		// temporal anti-aliasing resolves the composition into its own history ring, which is read by FXAA
+		taaPass := Pass(b, "TAA", func(a *Access) {
+			a.Read(depth, fragment)
+			a.Read(gbuffer, fragment)
+			a.Read(composition, fragment)
+			a.Write(taaHistory, colorOutput)
+		}, func() *pass.TemporalAAPass {
+			return pass.NewTemporalAAPass(app, composition.Get(), depth.Get(), gbuffer.Get(), g.Settings())
+		})
+
+		Pass(b, "FXAA", func(a *Access) {
+			a.Read(composition, fragment)
+			a.Read(taaHistory, fragment)
+			a.Write(antialiased, colorOutput)
+		}, func() *pass.FXAAPass {
+			return pass.NewFXAAPass(app, antialiased.Get(), composition.Get(), taaPass.Get(), g.Settings())
+		})
+
+		// debug visualizations replace the anti-aliased image
+		Pass(b, "Debug", func(a *Access) {
+			a.Read(depth, fragment)
+			a.Read(gbuffer, fragment)
+			a.Read(blurOutput, fragment)
+			a.Read(hdrBuffer, fragment)
+			a.Write(antialiased, colorOutput)
+		}, func() *pass.DebugPass {
+			return pass.NewDebugPass(app, antialiased.Get(), depth.Get(), gbuffer.Get(), blurOutput.Get(), hdrBuffer.Get(), g.Settings())
+		})
 
-		return []Resource{
-			depth,
-			hdrBuffer,
-			gbuffer,
-			ssaoOutput,
-			blurOutput,
-			composition,
+		// overlays are drawn at the output resolution, after the scene is upscaled, so that they stay sharp.
+		// lines are depth tested against an upscaled copy of the scene depth
+		overlay, overlayDepth := antialiased, depth
+		if b.Scaled() {
+			overlay = b.Image("overlay", Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized, Output: true})
+			overlayDepth = b.Image("overlay-depth", Image{Depth: true, Output: true})
+
+			Pass(b, "Upscale", func(a *Access) {
+				a.Read(antialiased, fragment)
+				a.Write(overlay, colorOutput)
+			}, func() *pass.OutputPass {
+				return pass.NewUpscalePass(app, overlay.Get(), antialiased.Get())
+			})
+
+			Pass(b, "DepthUpscale", func(a *Access) {
+				a.Read(depth, fragment)
+				a.Write(overlayDepth, fragmentTests)
+			}, func() *pass.DepthUpscalePass {
+				return pass.NewDepthUpscalePass(app, overlayDepth.Get(), depth.Get())
+			})
 		}
-	})
+
+		// editor selection outlines
+		Pass(b, "Outline", func(a *Access) {
+			a.Write(overlay, fragment)
+		}, func() *pass.OutlinePass {
+			return pass.NewOutlinePass(app, overlay.Get())
+		})
+
+		Pass(b, "Lines", func(a *Access) {
+			a.Read(overlayDepth, fragmentTests)
+			a.Write(overlay, fragment)
+		}, func() *pass.LinePass {
+			return pass.NewLinePass(app, overlay.Get(), overlayDepth.Get())
+		})
+
+		Pass(b, "GUI", func(a *Access) {
+			a.Write(overlay, fragment)
+		}, func() *pass.GuiPass {
+			return pass.NewGuiPass(app, overlay.Get())
+		})
+
+		Pass(b, "Output", func(a *Access) {
+			a.Read(overlay, fragment)
+			a.Write(output, colorOutput)
+		}, func() *pass.OutputPass {
+			return pass.NewOutputPass(app, output.Get(), overlay.Get())
+		})
+	}
 }
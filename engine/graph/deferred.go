@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/pass"
+	"github.com/johanhenriksson/goworld/render/image"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// Deferred instantiates a minimal render graph that draws everything with the deferred passes,
+// without ambient occlusion or post processing
+func Deferred(app engine.App, target engine.Target) engine.Renderer {
+	return New(app, target, func(g *Graph, output engine.Target) []Resource {
+		size := g.SceneSize()
+
+		// allocate main depth buffer
+		depth := engine.NewDepthTarget(app.Device(), "main-depth", size)
+
+		// main off-screen color buffer
+		offscreen := engine.NewColorTarget(app.Device(), "main-color", image.FormatRGBA8Unorm, size)
+
+		occlusion := engine.NewColorTarget(app.Device(), "occlusion", image.FormatRGBA8Unorm, engine.TargetSize{
+			Width: 1, Height: 1, Scale: 1, Frames: size.Frames,
+		})
+
+		// create geometry buffer
+		gbuffer, err := pass.NewGbuffer(app.Device(), size)
+		if err != nil {
+			panic(err)
+		}
+
+		shadows := pass.NewShadowPass(app, output)
+		shadowNode := g.Node(shadows)
+
+		// deferred geometry
+		deferredGeometry := g.Node(pass.NewDeferredGeometryPass(app, depth, gbuffer, nil, g.Stats()))
+
+		// deferred lighting
+		deferredLighting := g.Node(pass.NewDeferredLightingPass(app, offscreen, gbuffer, shadows, occlusion, g.Settings()))
+		deferredLighting.After(shadowNode, core1_0.PipelineStageFragmentShader)
+		deferredLighting.After(deferredGeometry, core1_0.PipelineStageFragmentShader)
+
+		outputPass := g.Node(pass.NewOutputPass(app, output, offscreen))
+		outputPass.After(deferredLighting, core1_0.PipelineStageFragmentShader)
+
+		return []Resource{
+			depth,
+			offscreen,
+			occlusion,
+			gbuffer,
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/pass"
+	"github.com/johanhenriksson/goworld/render/image"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// Forward instantiates a minimal render graph that draws everything with the forward pass
+func Forward(app engine.App, target engine.Target) engine.Renderer {
+	return New(app, target, func(g *Graph, output engine.Target) []Resource {
+		size := g.SceneSize()
+
+		// allocate main depth buffer
+		depth := engine.NewDepthTarget(app.Device(), "main-depth", size)
+
+		// main off-screen color buffer
+		offscreen := engine.NewColorTarget(app.Device(), "main-color", image.FormatRGBA8Unorm, size)
+
+		// create geometry buffer
+		gbuffer, err := pass.NewGbuffer(app.Device(), size)
+		if err != nil {
+			panic(err)
+		}
+
+		// depth pre-pass
+		depthPass := g.Node(pass.NewDepthPass(app, depth, g.Stats()))
+
+		shadows := pass.NewShadowPass(app, output)
+		shadowNode := g.Node(shadows)
+
+		forward := g.Node(pass.NewForwardPass(app, offscreen, depth, shadows, g.Settings()))
+		forward.After(depthPass, core1_0.PipelineStageEarlyFragmentTests)
+		forward.After(shadowNode, core1_0.PipelineStageFragmentShader)
+
+		outputPass := g.Node(pass.NewOutputPass(app, output, offscreen))
+		outputPass.After(forward, core1_0.PipelineStageTopOfPipe)
+
+		return []Resource{
+			depth,
+			offscreen,
+			gbuffer,
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/johanhenriksson/goworld/core/draw"
@@ -16,6 +18,10 @@ import (
 
 type GraphFunc func(*Graph, engine.Target) []Resource
 
+// ResizeInterval is the minimum time between resolution changes made by the dynamic resolution controller.
+// Changing the resolution recreates the render targets, which stalls the frame.
+const ResizeInterval = 2 * time.Second
+
 type Resource interface {
 	Destroy()
 }
@@ -29,6 +35,16 @@ type Graph struct {
 	todo      map[Node]bool
 	init      GraphFunc
 	resources []Resource
+	settings  engine.RenderSettings
+	stats     engine.RenderStats
+	capture   *frameCapture
+
+	// resolution scale of the current render targets
+	resolution float32
+
+	// time of the last resolution change, and of the previous frame
+	resized   time.Time
+	lastFrame time.Time
 }
 
 func New(app engine.App, output engine.Target, init GraphFunc) *Graph {
@@ -39,6 +55,7 @@ func New(app engine.App, output engine.Target, init GraphFunc) *Graph {
 		todo:   make(map[Node]bool, 16),
 		init:   init,
 	}
+	g.capture = newFrameCapture(app.Device())
 	g.Recreate()
 	return g
 }
@@ -47,19 +64,73 @@ func (g *Graph) Recreate() {
 	g.Destroy()
 	g.app.Pool().Recreate()
 
+	g.resolution = g.settings.Resolution()
+	g.resized = time.Now()
 	g.resources = g.init(g, g.target)
 
-	g.pre = newPreNode(g.app, g.target)
+	// validate before attaching the pre and post nodes, so that structural errors are reported
+	// when the graph is built rather than when it fails to make progress while drawing
+	if err := g.Structure().Validate(); err != nil {
+		panic(err)
+	}
+
+	g.pre = newPreNode(g.app, g.target, &g.settings, g.resolution)
 	g.post = newPostNode(g.app, g.target)
 	g.connect()
 }
 
+// Settings returns the runtime settings of the renderer.
+// Changes take effect on the next frame.
+func (g *Graph) Settings() *engine.RenderSettings {
+	return &g.settings
+}
+
+// SceneSize returns the size of the scene render targets, which is the output size scaled by the resolution
+func (g *Graph) SceneSize() engine.TargetSize {
+	return g.target.Size().Scaled(g.resolution)
+}
+
+// Stats returns the debug counters collected by the passes of the renderer
+func (g *Graph) Stats() *engine.RenderStats {
+	return &g.stats
+}
+
 func (g *Graph) Node(pass draw.Pass) Node {
 	nd := newNode(g.app, pass.Name(), pass)
 	g.nodes = append(g.nodes, nd)
 	return nd
 }
 
+// Structure returns the passes of the graph and the dependencies between them,
+// excluding the frame preparation and presentation nodes
+func (g *Graph) Structure() *Structure {
+	s := &Structure{
+		Passes: make([]PassInfo, len(g.nodes)),
+	}
+	for i, nd := range g.nodes {
+		s.Passes[i] = PassInfo{Name: nd.Name()}
+	}
+	for _, nd := range g.nodes {
+		n, ok := nd.(*node)
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(n.after))
+		for name := range n.after {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s.Edges = append(s.Edges, Edge{
+				From:   name,
+				To:     n.name,
+				Stages: n.after[name].mask,
+			})
+		}
+	}
+	return s
+}
+
 func (g *Graph) connect() {
 	// use bottom of pipe so that subsequent passes start as soon as possible
 	for _, node := range g.nodes {
@@ -74,7 +145,27 @@ func (g *Graph) connect() {
 	}
 }
 
-func (g *Graph) Draw(scene object.Object, time, delta float32) {
+func (g *Graph) Draw(scene object.Object, elapsed, delta float32) {
+	// the dynamic resolution controller is fed the measured time between frames,
+	// since the given delta is a fixed step when the simulation is decoupled from the wall clock
+	now := time.Now()
+	ctrl := g.settings.DynamicResolution
+	if ctrl != nil && !g.lastFrame.IsZero() {
+		g.settings.ResolutionScale = ctrl.Update(float32(now.Sub(g.lastFrame).Seconds()))
+	}
+	g.lastFrame = now
+
+	// the render targets are recreated whenever the resolution scale changes.
+	// changes made by the controller are rate limited, and are applied in a single step.
+	if g.settings.Resolution() != g.resolution {
+		if ctrl == nil || now.Sub(g.resized) >= ResizeInterval {
+			g.Recreate()
+
+			// the time spent recreating the targets is not part of the frame time
+			g.lastFrame = time.Now()
+		}
+	}
+
 	// put all nodes in a todo list
 	// for each node in todo list
 	//   if all Before nodes are not in todo list
@@ -94,7 +185,7 @@ func (g *Graph) Draw(scene object.Object, time, delta float32) {
 	}
 
 	// prepare
-	args, context, err := g.pre.Prepare(scene, time, delta)
+	args, context, err := g.pre.Prepare(scene, elapsed, delta)
 	if err != nil {
 		log.Println("Render preparation error:", err)
 		g.Recreate()
@@ -116,14 +207,28 @@ func (g *Graph) Draw(scene object.Object, time, delta float32) {
 			}
 		}
 		if !progress {
-			// dependency error
-			panic("unable to make progress in render graph")
+			// dependency error. should have been caught by validation
+			pending := make([]string, 0, len(g.todo))
+			for node := range g.todo {
+				pending = append(pending, node.Name())
+			}
+			sort.Strings(pending)
+			panic(fmt.Sprintf("unable to make progress in render graph, pending nodes: %s", strings.Join(pending, ", ")))
 		}
 	}
 
+	// copy the output before it is presented, if a capture was requested
+	g.capture.Record(worker, g.target.Surfaces()[context.Index])
+
 	g.post.Present(worker, context)
 }
 
+// Capture requests a copy of the next drawn frame. The callback is invoked on a background goroutine
+// once the frame has been downloaded, without stalling the render loop.
+func (g *Graph) Capture(fn engine.CaptureFunc) {
+	g.capture.Request(fn)
+}
+
 func (g *Graph) Screengrab() *image.RGBA {
 	idx := 0
 	g.app.Device().WaitIdle()
@@ -146,6 +251,7 @@ func (g *Graph) Screenshot() {
 
 func (g *Graph) Destroy() {
 	g.app.Flush()
+	g.capture.Destroy()
 	for _, resource := range g.resources {
 		resource.Destroy()
 	}
@@ -0,0 +1,263 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// Edge is a dependency between two declared passes
+type Edge struct {
+	From string
+	To   string
+
+	// Stages of the dependant pass that wait for the dependency
+	Stages core1_0.PipelineStageFlags
+
+	// Resources that caused the dependency
+	Resources []string
+}
+
+// PhysicalImage is an allocated image, shared by one or more transient images with disjoint lifetimes
+type PhysicalImage struct {
+	Name  string
+	Image Image
+
+	// Images holds the names of the transient images sharing the allocation, in order of use
+	Images []string
+}
+
+// Plan is the result of resolving the declarations of a render graph
+type Plan struct {
+	// Order holds the passes in declaration order.
+	// Every edge points from an earlier to a later pass, so this is also a valid execution order.
+	Order []*passDecl
+
+	// Edges holds the dependencies between passes
+	Edges []Edge
+
+	// Physical holds the images allocated for transient images
+	Physical []PhysicalImage
+
+	// Aliases maps transient image names to their physical image
+	Aliases map[string]int
+
+	output    string
+	readbacks []string
+}
+
+// Structure returns the passes and dependencies of the plan
+func (p *Plan) Structure() *Structure {
+	s := &Structure{
+		Passes:    make([]PassInfo, len(p.Order)),
+		Edges:     p.Edges,
+		Output:    p.output,
+		Readbacks: p.readbacks,
+	}
+	for i, decl := range p.Order {
+		info := PassInfo{Name: decl.name}
+		for _, acc := range decl.accesses {
+			if acc.write {
+				if !contains(info.Writes, acc.res.name) {
+					info.Writes = append(info.Writes, acc.res.name)
+				}
+			} else if !contains(info.Reads, acc.res.name) {
+				info.Reads = append(info.Reads, acc.res.name)
+			}
+		}
+		s.Passes[i] = info
+	}
+	return s
+}
+
+// Plan resolves the declarations into an execution plan, and validates the resulting structure.
+// No resources are allocated, so planning does not require a device.
+func (b *Builder) Plan() (*Plan, error) {
+	plan, err := b.plan()
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Structure().Validate(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (b *Builder) plan() (*Plan, error) {
+	plan := &Plan{
+		Order:   b.passes,
+		Aliases: make(map[string]int, len(b.resources)),
+	}
+	for _, res := range b.resources {
+		switch res.kind {
+		case resourceOutput:
+			plan.output = res.name
+		case resourceReadback:
+			plan.readbacks = append(plan.readbacks, res.name)
+		}
+	}
+
+	index := make(map[*passDecl]int, len(b.passes))
+	for i, decl := range b.passes {
+		index[decl] = i
+	}
+
+	type edgeKey struct{ from, to int }
+	edges := map[edgeKey]*Edge{}
+	connect := func(from, to int, stages core1_0.PipelineStageFlags, res string) {
+		if from == to {
+			return
+		}
+		key := edgeKey{from, to}
+		edge, exists := edges[key]
+		if !exists {
+			edge = &Edge{
+				From: b.passes[from].name,
+				To:   b.passes[to].name,
+			}
+			edges[key] = edge
+		}
+		edge.Stages |= stages
+		for _, existing := range edge.Resources {
+			if existing == res {
+				return
+			}
+		}
+		edge.Resources = append(edge.Resources, res)
+	}
+
+	// resolve accesses in declaration order.
+	// reads depend on the previous writer, writes depend on the previous writer and every reader since.
+	type state struct {
+		writer  int
+		readers []int
+	}
+	states := make(map[*resource]*state, len(b.resources))
+	for _, res := range b.resources {
+		states[res] = &state{writer: -1}
+	}
+	for i, decl := range b.passes {
+		for _, acc := range decl.accesses {
+			st, declared := states[acc.res]
+			if !declared {
+				return nil, fmt.Errorf("pass %s accesses undeclared resource %s", decl.name, acc.res.name)
+			}
+			if acc.write {
+				if st.writer >= 0 {
+					connect(st.writer, i, acc.stages, acc.res.name)
+				}
+				for _, reader := range st.readers {
+					connect(reader, i, acc.stages, acc.res.name)
+				}
+				st.writer = i
+				st.readers = st.readers[:0]
+			} else {
+				if st.writer < 0 {
+					return nil, fmt.Errorf("pass %s reads %s before it is written", decl.name, acc.res.name)
+				}
+				connect(st.writer, i, acc.stages, acc.res.name)
+				st.readers = append(st.readers, i)
+			}
+		}
+	}
+
+	// compute the lifetime of each transient image, as the range of passes accessing it
+	type lifetime struct {
+		res         *resource
+		first, last int
+		users       []int
+	}
+	lifetimes := make([]*lifetime, 0, len(b.resources))
+	for _, res := range b.resources {
+		if res.kind != resourceImage {
+			continue
+		}
+		life := &lifetime{res: res, first: -1, last: -1}
+		for i, decl := range b.passes {
+			for _, acc := range decl.accesses {
+				if acc.res != res {
+					continue
+				}
+				if life.first < 0 {
+					life.first = i
+				}
+				life.last = i
+				if len(life.users) == 0 || life.users[len(life.users)-1] != i {
+					life.users = append(life.users, i)
+				}
+			}
+		}
+		lifetimes = append(lifetimes, life)
+	}
+	sort.SliceStable(lifetimes, func(i, j int) bool {
+		return lifetimes[i].first < lifetimes[j].first
+	})
+
+	// assign physical images. an image may reuse the allocation of an identical image that is no longer used.
+	// unused images are always allocated separately.
+	type occupant struct {
+		last  int
+		users []int
+	}
+	occupants := []occupant{}
+	for _, life := range lifetimes {
+		reuse := -1
+		if life.first >= 0 {
+			for p, phys := range plan.Physical {
+				if occupants[p].last >= 0 && occupants[p].last < life.first && phys.Image.compatible(life.res.image) {
+					reuse = p
+					break
+				}
+			}
+		}
+		if reuse < 0 {
+			plan.Physical = append(plan.Physical, PhysicalImage{
+				Name:  life.res.name,
+				Image: life.res.image,
+			})
+			occupants = append(occupants, occupant{last: -1})
+			reuse = len(plan.Physical) - 1
+		} else {
+			// the new occupant must wait for every user of the previous occupant
+			for _, user := range occupants[reuse].users {
+				connect(user, life.first, core1_0.PipelineStageAllCommands, life.res.name)
+			}
+		}
+		plan.Physical[reuse].Images = append(plan.Physical[reuse].Images, life.res.name)
+		plan.Aliases[life.res.name] = reuse
+		occupants[reuse] = occupant{last: life.last, users: life.users}
+	}
+
+	// sort edges for a deterministic result
+	keys := make([]edgeKey, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].to != keys[j].to {
+			return keys[i].to < keys[j].to
+		}
+		return keys[i].from < keys[j].from
+	})
+	plan.Edges = make([]Edge, len(keys))
+	for i, key := range keys {
+		plan.Edges[i] = *edges[key]
+	}
+
+	return plan, nil
+}
+
+// compatible returns true if two image descriptions may share an allocation
+func (i Image) compatible(other Image) bool {
+	scale := func(s float32) float32 {
+		if s <= 0 {
+			return 1
+		}
+		return s
+	}
+	if scale(i.Scale) != scale(other.Scale) || i.Depth != other.Depth || i.Output != other.Output {
+		return false
+	}
+	return i.Depth || i.Format == other.Format
+}
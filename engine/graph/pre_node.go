@@ -25,14 +25,19 @@ type PreDrawable interface {
 type preNode struct {
 	*node
 	target       engine.Target
+	settings     *engine.RenderSettings
+	resolution   float32
+	frame        int
 	cameraQuery  *object.Query[*camera.Camera]
 	predrawQuery *object.Query[PreDrawable]
 }
 
-func newPreNode(app engine.App, target engine.Target) *preNode {
+func newPreNode(app engine.App, target engine.Target, settings *engine.RenderSettings, resolution float32) *preNode {
 	return &preNode{
 		node:         newNode(app, "Pre", nil),
 		target:       target,
+		settings:     settings,
+		resolution:   resolution,
 		cameraQuery:  object.NewQuery[*camera.Camera](),
 		predrawQuery: object.NewQuery[PreDrawable](),
 	}
@@ -43,6 +48,14 @@ func (n *preNode) Prepare(scene object.Object, time, delta float32) (*draw.Args,
 		Width:  n.target.Width(),
 		Height: n.target.Height(),
 		Scale:  n.target.Scale(),
+
+		Resolution: n.resolution,
+	}
+
+	// temporal anti-aliasing requires a different sub-pixel offset every frame
+	n.frame++
+	if n.settings.AntiAliasing == engine.AntiAliasingTAA {
+		viewport.Jitter = camera.Jitter(n.frame)
 	}
 
 	// todo: cache handling does not really belong in the render graph
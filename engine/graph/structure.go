@@ -0,0 +1,309 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Structure describes the passes of a render graph and the dependencies between them.
+// It does not refer to any GPU resources, so it can be validated and exported without a device.
+type Structure struct {
+	// Passes holds the passes of the graph, in execution order if known
+	Passes []PassInfo
+
+	// Edges holds the dependencies between passes
+	Edges []Edge
+
+	// Output is the name of the resource presented by the graph.
+	// If set, every pass must contribute to the output or to one of the readbacks.
+	Output string
+
+	// Readbacks holds the names of resources copied back to the host
+	Readbacks []string
+}
+
+// PassInfo describes the resources accessed by a pass
+type PassInfo struct {
+	Name   string
+	Reads  []string
+	Writes []string
+}
+
+// CycleError is returned when the dependencies of a graph form a cycle
+type CycleError struct {
+	// Path holds the passes of the cycle, starting and ending with the same pass
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("cycle in render graph: %s", strings.Join(e.Path, " -> "))
+}
+
+// UnreachableError is returned when passes do not contribute to the output of the graph
+type UnreachableError struct {
+	Passes []string
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("render graph passes do not contribute to the output: %s", strings.Join(e.Passes, ", "))
+}
+
+// WriterError is returned when a resource has multiple writers that are not ordered by any dependency
+type WriterError struct {
+	Resource string
+	Passes   []string
+}
+
+func (e *WriterError) Error() string {
+	return fmt.Sprintf("render graph resource %s has multiple unordered writers: %s", e.Resource, strings.Join(e.Passes, ", "))
+}
+
+// Validate checks the structure for cycles, passes that do not contribute to the output,
+// and resources with unordered writers. The first problem found is returned.
+//
+// Plans produced by a Builder can not contain cycles, since every access is resolved against earlier declarations.
+// The cycle check only guards structures built by hand, such as graphs that connect their nodes using Node.After.
+func (s *Structure) Validate() error {
+	index := make(map[string]int, len(s.Passes))
+	for i, pass := range s.Passes {
+		if _, exists := index[pass.Name]; exists {
+			return fmt.Errorf("render graph pass %s is declared twice", pass.Name)
+		}
+		index[pass.Name] = i
+	}
+	next := make([][]int, len(s.Passes))
+	for _, edge := range s.Edges {
+		from, ok := index[edge.From]
+		if !ok {
+			return fmt.Errorf("render graph edge %s -> %s refers to unknown pass %s", edge.From, edge.To, edge.From)
+		}
+		to, ok := index[edge.To]
+		if !ok {
+			return fmt.Errorf("render graph edge %s -> %s refers to unknown pass %s", edge.From, edge.To, edge.To)
+		}
+		next[from] = append(next[from], to)
+	}
+
+	if cycle := s.findCycle(next); cycle != nil {
+		return cycle
+	}
+
+	// the graph is acyclic, so reachability can be computed in reverse topological order
+	reach := s.reachability(next)
+
+	if s.Output != "" {
+		unreachable := []string{}
+		for i, pass := range s.Passes {
+			contributes := false
+			for j, other := range s.Passes {
+				if reach[i][j] && s.isSink(other) {
+					contributes = true
+					break
+				}
+			}
+			if !contributes {
+				unreachable = append(unreachable, pass.Name)
+			}
+		}
+		if len(unreachable) > 0 {
+			return &UnreachableError{Passes: unreachable}
+		}
+	}
+
+	writers := map[string][]int{}
+	resources := []string{}
+	for i, pass := range s.Passes {
+		for _, res := range pass.Writes {
+			if _, seen := writers[res]; !seen {
+				resources = append(resources, res)
+			}
+			writers[res] = append(writers[res], i)
+		}
+	}
+	for _, res := range resources {
+		passes := writers[res]
+		for a := 0; a < len(passes); a++ {
+			for b := a + 1; b < len(passes); b++ {
+				i, j := passes[a], passes[b]
+				if !reach[i][j] && !reach[j][i] {
+					return &WriterError{
+						Resource: res,
+						Passes:   []string{s.Passes[i].Name, s.Passes[j].Name},
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSink returns true if the pass writes the output or a readback
+func (s *Structure) isSink(pass PassInfo) bool {
+	if contains(pass.Writes, s.Output) {
+		return true
+	}
+	for _, readback := range s.Readbacks {
+		if contains(pass.Writes, readback) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Structure) findCycle(next [][]int) *CycleError {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(s.Passes))
+	stack := make([]int, 0, len(s.Passes))
+
+	var visit func(int) *CycleError
+	visit = func(i int) *CycleError {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, j := range next[i] {
+			switch state[j] {
+			case visiting:
+				// the cycle is the part of the stack starting at j
+				start := len(stack) - 1
+				for stack[start] != j {
+					start--
+				}
+				path := make([]string, 0, len(stack)-start+1)
+				for _, k := range stack[start:] {
+					path = append(path, s.Passes[k].Name)
+				}
+				return &CycleError{Path: append(path, s.Passes[j].Name)}
+			case unvisited:
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		return nil
+	}
+
+	for i := range s.Passes {
+		if state[i] == unvisited {
+			if err := visit(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reachability returns a matrix where reach[i][j] is true if pass j executes after pass i, or i == j.
+// The graph must be acyclic.
+func (s *Structure) reachability(next [][]int) [][]bool {
+	reach := make([][]bool, len(s.Passes))
+	var visit func(int) []bool
+	visit = func(i int) []bool {
+		if reach[i] != nil {
+			return reach[i]
+		}
+		row := make([]bool, len(s.Passes))
+		row[i] = true
+		for _, j := range next[i] {
+			for k, r := range visit(j) {
+				row[k] = row[k] || r
+			}
+		}
+		reach[i] = row
+		return row
+	}
+	for i := range s.Passes {
+		visit(i)
+	}
+	return reach
+}
+
+func contains(items []string, item string) bool {
+	for _, existing := range items {
+		if existing == item {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDOT writes the structure as a Graphviz digraph.
+// Edges are labeled with the pipeline stages that wait for the dependency, and the resources causing it.
+func (s *Structure) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph \"render graph\" {\n")
+	sb.WriteString("\tnode [shape=box];\n")
+	for _, pass := range s.Passes {
+		fmt.Fprintf(&sb, "\t%q;\n", pass.Name)
+	}
+	for _, edge := range s.Edges {
+		label := edge.Stages.String()
+		if len(edge.Resources) > 0 {
+			label += "\n" + strings.Join(edge.Resources, ", ")
+		}
+		fmt.Fprintf(&sb, "\t%q -> %q [label=%q];\n", edge.From, edge.To, label)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type jsonStructure struct {
+	Passes    []jsonPass `json:"passes"`
+	Edges     []jsonEdge `json:"edges"`
+	Output    string     `json:"output,omitempty"`
+	Readbacks []string   `json:"readbacks,omitempty"`
+}
+
+type jsonPass struct {
+	Name   string   `json:"name"`
+	Reads  []string `json:"reads,omitempty"`
+	Writes []string `json:"writes,omitempty"`
+}
+
+type jsonEdge struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	StageMask uint32   `json:"stage_mask"`
+	Stages    []string `json:"stages"`
+	Resources []string `json:"resources,omitempty"`
+}
+
+// WriteJSON writes the structure as a JSON document.
+// Edges include both the raw pipeline stage mask and the names of its stages.
+func (s *Structure) WriteJSON(w io.Writer) error {
+	doc := jsonStructure{
+		Passes:    make([]jsonPass, len(s.Passes)),
+		Edges:     make([]jsonEdge, len(s.Edges)),
+		Output:    s.Output,
+		Readbacks: s.Readbacks,
+	}
+	for i, pass := range s.Passes {
+		doc.Passes[i] = jsonPass(pass)
+	}
+	for i, edge := range s.Edges {
+		stages := []string{}
+		if edge.Stages != 0 {
+			stages = strings.Split(edge.Stages.String(), "|")
+			sort.Strings(stages)
+		}
+		doc.Edges[i] = jsonEdge{
+			From:      edge.From,
+			To:        edge.To,
+			StageMask: uint32(edge.Stages),
+			Stages:    stages,
+			Resources: edge.Resources,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
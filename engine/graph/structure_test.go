@@ -0,0 +1,176 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/graph"
+	"github.com/johanhenriksson/goworld/render/command"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+type stubPass struct {
+	name string
+}
+
+func (p *stubPass) Name() string                                         { return p.name }
+func (p *stubPass) Record(command.Recorder, draw.Args, object.Component) {}
+func (p *stubPass) Destroy()                                             {}
+
+func stub(b *graph.Builder, name string, setup func(*graph.Access)) {
+	graph.Pass(b, name, setup, func() *stubPass { return &stubPass{name: name} })
+}
+
+const fragment = core1_0.PipelineStageFragmentShader
+
+var _ = Describe("render graph structure", func() {
+	It("plans the default graph", func() {
+		b := graph.NewBuilder(nil)
+		graph.DefaultGraph(nil)(nil, b)
+		plan, err := b.Plan()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(plan.Order).To(HaveLen(21))
+	})
+
+	It("draws overlays after upscaling a scaled scene", func() {
+		b := graph.NewBuilder(nil)
+		b.SetResolution(0.5)
+		graph.DefaultGraph(nil)(nil, b)
+		plan, err := b.Plan()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(plan.Order).To(HaveLen(23))
+
+		writes := map[string][]string{}
+		for _, info := range plan.Structure().Passes {
+			writes[info.Name] = info.Writes
+		}
+		Expect(writes["Upscale"]).To(Equal([]string{"overlay"}))
+		for _, overlay := range []string{"Outline", "Lines", "GUI"} {
+			Expect(writes[overlay]).To(Equal([]string{"overlay"}), overlay)
+		}
+
+		// the overlay is sized to the output, so it may not share the scene sized composition
+		Expect(plan.Aliases["overlay"]).ToNot(Equal(plan.Aliases["composition"]))
+		Expect(plan.Physical[plan.Aliases["overlay"]].Image.Output).To(BeTrue())
+	})
+
+	It("orders accesses and aliases images with disjoint lifetimes", func() {
+		b := graph.NewBuilder(nil)
+		output := b.Output()
+		first := b.Image("first", graph.Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized})
+		second := b.Image("second", graph.Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized})
+		third := b.Image("third", graph.Image{Format: core1_0.FormatR8G8B8A8UnsignedNormalized})
+		stub(b, "A", func(a *graph.Access) { a.Write(first, fragment) })
+		stub(b, "B", func(a *graph.Access) { a.Read(first, fragment); a.Write(second, fragment) })
+		stub(b, "C", func(a *graph.Access) { a.Read(second, fragment); a.Write(third, fragment) })
+		stub(b, "D", func(a *graph.Access) { a.Read(third, fragment); a.Write(output, fragment) })
+
+		plan, err := b.Plan()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(plan.Physical).To(HaveLen(2))
+		Expect(plan.Aliases["third"]).To(Equal(plan.Aliases["first"]))
+
+		edges := []string{}
+		for _, edge := range plan.Edges {
+			edges = append(edges, edge.From+"->"+edge.To)
+		}
+		Expect(edges).To(Equal([]string{"A->B", "A->C", "B->C", "C->D"}))
+	})
+
+	It("rejects reads before writes", func() {
+		b := graph.NewBuilder(nil)
+		img := b.Image("img", graph.Image{})
+		stub(b, "A", func(a *graph.Access) { a.Read(img, fragment) })
+		_, err := b.Plan()
+		Expect(err).To(MatchError(ContainSubstring("reads img before it is written")))
+	})
+
+	It("detects passes that do not contribute to the output", func() {
+		b := graph.NewBuilder(nil)
+		output := b.Output()
+		unused := b.Image("unused", graph.Image{})
+		stub(b, "Dead", func(a *graph.Access) { a.Write(unused, fragment) })
+		stub(b, "Output", func(a *graph.Access) { a.Write(output, fragment) })
+		_, err := b.Plan()
+		var unreachable *graph.UnreachableError
+		Expect(err).To(BeAssignableToTypeOf(unreachable))
+		Expect(err.(*graph.UnreachableError).Passes).To(Equal([]string{"Dead"}))
+	})
+
+	It("considers passes writing readbacks as contributing", func() {
+		b := graph.NewBuilder(nil)
+		output := b.Output()
+		readback := b.Readback("readback")
+		stub(b, "Readback", func(a *graph.Access) { a.Write(readback, fragment) })
+		stub(b, "Output", func(a *graph.Access) { a.Write(output, fragment) })
+		plan, err := b.Plan()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(plan.Structure().Readbacks).To(Equal([]string{"readback"}))
+	})
+
+	It("detects cycles in hand-built structures", func() {
+		s := &graph.Structure{
+			Passes: []graph.PassInfo{{Name: "A"}, {Name: "B"}, {Name: "C"}},
+			Edges: []graph.Edge{
+				{From: "A", To: "B", Stages: fragment},
+				{From: "B", To: "C", Stages: fragment},
+				{From: "C", To: "B", Stages: fragment},
+			},
+		}
+		err := s.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&graph.CycleError{}))
+		Expect(err.(*graph.CycleError).Path).To(Equal([]string{"B", "C", "B"}))
+	})
+
+	It("detects unordered writers", func() {
+		s := &graph.Structure{
+			Passes: []graph.PassInfo{
+				{Name: "A", Writes: []string{"img"}},
+				{Name: "B", Writes: []string{"img"}},
+				{Name: "C", Reads: []string{"img"}},
+			},
+			Edges: []graph.Edge{
+				{From: "A", To: "C", Stages: fragment},
+				{From: "B", To: "C", Stages: fragment},
+			},
+		}
+		err := s.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&graph.WriterError{}))
+		Expect(err.(*graph.WriterError).Passes).To(Equal([]string{"A", "B"}))
+	})
+
+	It("exports stage masks", func() {
+		s := &graph.Structure{
+			Passes: []graph.PassInfo{{Name: "A"}, {Name: "B"}},
+			Edges: []graph.Edge{
+				{From: "A", To: "B", Stages: fragment | core1_0.PipelineStageEarlyFragmentTests, Resources: []string{"img"}},
+			},
+		}
+
+		dot := &bytes.Buffer{}
+		Expect(s.WriteDOT(dot)).To(Succeed())
+		Expect(dot.String()).To(ContainSubstring(`"A" -> "B"`))
+		Expect(dot.String()).To(ContainSubstring("Fragment Shader"))
+
+		out := &bytes.Buffer{}
+		Expect(s.WriteJSON(out)).To(Succeed())
+		doc := struct {
+			Edges []struct {
+				From      string   `json:"from"`
+				To        string   `json:"to"`
+				StageMask uint32   `json:"stage_mask"`
+				Stages    []string `json:"stages"`
+			} `json:"edges"`
+		}{}
+		Expect(json.Unmarshal(out.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Edges).To(HaveLen(1))
+		Expect(doc.Edges[0].StageMask).To(Equal(uint32(fragment | core1_0.PipelineStageEarlyFragmentTests)))
+		Expect(doc.Edges[0].Stages).To(ConsistOf("Fragment Shader", "Early Fragment Tests"))
+	})
+})
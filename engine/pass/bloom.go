@@ -0,0 +1,399 @@
+package pass
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/effect"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// BloomMaxLevels is the maximum length of the downsample chain
+const BloomMaxLevels = 8
+
+type BloomDescriptors struct {
+	descriptor.Set
+	Params *descriptor.Uniform[uniform.Bloom]
+	Input  *descriptor.Sampler
+}
+
+type BloomCompositeDescriptors struct {
+	descriptor.Set
+	Params *descriptor.Uniform[uniform.Bloom]
+	Input  *descriptor.Sampler
+	Dirt   *descriptor.Sampler
+}
+
+// bloomStage is a single draw in the bloom chain, reading one texture and writing to another
+type bloomStage struct {
+	pass *renderpass.Renderpass
+	fbuf framebuffer.Array
+	desc []*BloomDescriptors
+}
+
+// BloomPass extracts bright areas of the HDR buffer, blurs them through a progressive
+// downsample/upsample chain and adds the result back on top of the HDR buffer.
+// Parameters are read from the first effect.Bloom component in the scene. Without one, the pass does nothing.
+type BloomPass struct {
+	app  engine.App
+	quad vertex.Mesh
+
+	levels   []*engine.RenderTarget
+	inputTex texture.Array
+	levelTex []texture.Array
+
+	down []*bloomStage
+	up   []*bloomStage
+
+	compPass *renderpass.Renderpass
+	compFbuf framebuffer.Array
+	compDesc []*BloomCompositeDescriptors
+
+	downPipe       *pipeline.Pipeline
+	upPipe         *pipeline.Pipeline
+	compPipe       *pipeline.Pipeline
+	pipeLayout     *pipeline.Layout
+	compPipeLayout *pipeline.Layout
+	descLayout     *descriptor.Layout[*BloomDescriptors]
+	compDescLayout *descriptor.Layout[*BloomCompositeDescriptors]
+
+	bloomQuery *object.Query[*effect.Bloom]
+	dirt       assets.Texture
+	dirtPath   string
+}
+
+var _ draw.Pass = &BloomPass{}
+
+func NewBloomPass(app engine.App, target engine.Target) *BloomPass {
+	p := &BloomPass{
+		app:        app,
+		quad:       vertex.ScreenQuad("bloom-pass-quad"),
+		bloomQuery: object.NewQuery[*effect.Bloom](),
+	}
+	frames := target.Frames()
+
+	// allocate the downsample chain, starting at half resolution.
+	// the chain ends when the smallest side reaches a single pixel
+	width, height := target.Width(), target.Height()
+	levels := min(BloomMaxLevels, bloomLevels(width, height))
+	if levels == 0 {
+		// the target is too small to downsample, bloom is skipped
+		return p
+	}
+	for len(p.levels) < levels {
+		width, height = max(width/2, 1), max(height/2, 1)
+		level := engine.NewColorTarget(app.Device(), fmt.Sprintf("bloom-%d", len(p.levels)), target.SurfaceFormat(), engine.TargetSize{
+			Width:  width,
+			Height: height,
+			Frames: frames,
+			Scale:  target.Scale(),
+		})
+		p.levels = append(p.levels, level)
+	}
+
+	var err error
+	sampled := func(key string, images image.Array) texture.Array {
+		textures := make(texture.Array, len(images))
+		for i, img := range images {
+			textures[i], err = texture.FromImage(app.Device(), fmt.Sprintf("%s-%d", key, i), img, texture.Args{
+				Filter: texture.FilterLinear,
+				Wrap:   texture.WrapClamp,
+			})
+			if err != nil {
+				// todo: clean up
+				panic(err)
+			}
+		}
+		return textures
+	}
+	p.inputTex = sampled("bloom-input", target.Surfaces())
+	p.levelTex = make([]texture.Array, len(p.levels))
+	for i, level := range p.levels {
+		p.levelTex[i] = sampled(fmt.Sprintf("bloom-level-%d", i), level.Surfaces())
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "Bloom", &BloomDescriptors{
+		Params: &descriptor.Uniform[uniform.Bloom]{
+			Stages: core1_0.StageFragment,
+		},
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+
+	newStage := func(name string, output engine.Target, input texture.Array, load bool) *bloomStage {
+		stage := &bloomStage{
+			pass: newBloomRenderpass(app, name, output, load),
+			desc: p.descLayout.InstantiateMany(app.Pool(), frames),
+		}
+		stage.fbuf, err = framebuffer.NewArray(frames, app.Device(), name, output.Width(), output.Height(), stage.pass)
+		if err != nil {
+			panic(err)
+		}
+		for i, desc := range stage.desc {
+			desc.Input.Set(input[i])
+		}
+		return stage
+	}
+
+	// each downsample reads the previous level, starting with the input buffer
+	p.down = make([]*bloomStage, len(p.levels))
+	for i, level := range p.levels {
+		input := p.inputTex
+		if i > 0 {
+			input = p.levelTex[i-1]
+		}
+		p.down[i] = newStage(fmt.Sprintf("BloomDown%d", i), level, input, false)
+	}
+
+	// each upsample blends the level below into the level above it
+	p.up = make([]*bloomStage, len(p.levels)-1)
+	for i := range p.up {
+		p.up[i] = newStage(fmt.Sprintf("BloomUp%d", i), p.levels[i], p.levelTex[i+1], true)
+	}
+
+	// the first level is finally added on top of the input buffer
+	p.compPass = newBloomRenderpass(app, "BloomComposite", target, true)
+	p.compFbuf, err = framebuffer.NewArray(frames, app.Device(), "bloom-composite", target.Width(), target.Height(), p.compPass)
+	if err != nil {
+		panic(err)
+	}
+	p.compDescLayout = descriptor.NewLayout(app.Device(), "BloomComposite", &BloomCompositeDescriptors{
+		Params: &descriptor.Uniform[uniform.Bloom]{
+			Stages: core1_0.StageFragment,
+		},
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Dirt: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.compPipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.compDescLayout}, nil)
+	p.compDesc = p.compDescLayout.InstantiateMany(app.Pool(), frames)
+	for i, desc := range p.compDesc {
+		desc.Input.Set(p.levelTex[0][i])
+	}
+
+	// all downsample passes are compatible, as are all upsample passes, so the pipelines can be shared.
+	p.downPipe = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/bloom_down")),
+		Pass:     p.down[0].pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+	if len(p.up) > 0 {
+		p.upPipe = pipeline.New(app.Device(), pipeline.Args{
+			Layout:   p.pipeLayout,
+			Shader:   app.Shaders().Fetch(shader.Ref("pass/bloom_up")),
+			Pass:     p.up[0].pass,
+			Pointers: vertex.ParsePointers(vertex.Vertex{}),
+		})
+	}
+	p.compPipe = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.compPipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/bloom")),
+		Pass:     p.compPass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	return p
+}
+
+// bloomLevels returns the number of times an image can be halved before its smallest side reaches a single pixel
+func bloomLevels(width, height int) int {
+	size := min(width, height)
+	if size < 2 {
+		return 0
+	}
+	return bits.Len(uint(size)) - 1
+}
+
+// newBloomRenderpass creates a single subpass render pass drawing to the given target.
+// If load is set, the pass blends additively with the existing contents.
+func newBloomRenderpass(app engine.App, name string, target engine.Target, load bool) *renderpass.Renderpass {
+	output := attachment.Color{
+		Name:        OutputAttachment,
+		Image:       attachment.FromImageArray(target.Surfaces()),
+		LoadOp:      core1_0.AttachmentLoadOpDontCare,
+		StoreOp:     core1_0.AttachmentStoreOpStore,
+		FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+	}
+	if load {
+		output.LoadOp = core1_0.AttachmentLoadOpLoad
+		output.InitialLayout = core1_0.ImageLayoutShaderReadOnlyOptimal
+		output.Blend = attachment.BlendAdditive
+	}
+	return renderpass.New(app.Device(), renderpass.Args{
+		Name:             name,
+		ColorAttachments: []attachment.Color{output},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For color attachment operations
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+				Flags:         core1_0.DependencyByRegion,
+			},
+			{
+				// For fragment shader reads of the previous level
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+		},
+	})
+}
+
+func (p *BloomPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	if len(p.levels) == 0 {
+		return
+	}
+	bloom, exists := p.bloomQuery.Reset().First(scene)
+	if !exists {
+		return
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+
+	levels := math.Clamp(bloom.Levels.Get(), 1, len(p.levels))
+	threshold := bloom.Threshold.Get()
+	params := uniform.Bloom{
+		Threshold: threshold,
+		Knee:      threshold * bloom.Knee.Get(),
+		Radius:    bloom.Radius.Get(),
+
+		// the upsample chain accumulates every level, normalize the sum
+		Intensity: bloom.Intensity.Get() / float32(levels),
+	}
+
+	// lens dirt falls back to a white texture, leaving the bloom unchanged
+	dirt := p.app.Textures().Fetch(color.White)
+	if path := bloom.Dirt.Get(); path != "" {
+		if path != p.dirtPath {
+			p.dirt = texture.PathRef(path)
+			p.dirtPath = path
+		}
+		if tex, ready := p.app.Textures().TryFetch(p.dirt); ready {
+			dirt = tex
+			params.DirtIntensity = bloom.DirtIntensity.Get()
+		}
+	}
+
+	for i := 0; i < levels; i++ {
+		down := params
+		if i == 0 {
+			down.Prefilter = 1
+		}
+		p.down[i].desc[args.Frame].Params.Set(down)
+	}
+	for i := 0; i < levels-1; i++ {
+		p.up[i].desc[args.Frame].Params.Set(params)
+	}
+	comp := p.compDesc[args.Frame]
+	comp.Params.Set(params)
+	comp.Dirt.Set(dirt)
+
+	cmds.Record(func(cmd *command.Buffer) {
+		blit := func(stage *bloomStage, pipe *pipeline.Pipeline) {
+			cmd.CmdBeginRenderPass(stage.pass, stage.fbuf[args.Frame])
+			cmd.CmdBindGraphicsPipeline(pipe)
+			cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, stage.desc[args.Frame])
+			quad.Bind(cmd)
+			quad.Draw(cmd, 0)
+			cmd.CmdEndRenderPass()
+		}
+
+		for i := 0; i < levels; i++ {
+			blit(p.down[i], p.downPipe)
+		}
+		for i := levels - 2; i >= 0; i-- {
+			blit(p.up[i], p.upPipe)
+		}
+
+		cmd.CmdBeginRenderPass(p.compPass, p.compFbuf[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.compPipe)
+		cmd.CmdBindGraphicsDescriptor(p.compPipeLayout, 0, comp)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *BloomPass) Name() string {
+	return "Bloom"
+}
+
+func (p *BloomPass) Destroy() {
+	if len(p.levels) == 0 {
+		return
+	}
+	for _, stage := range append(p.down, p.up...) {
+		for _, desc := range stage.desc {
+			desc.Destroy()
+		}
+		stage.fbuf.Destroy()
+		stage.pass.Destroy()
+	}
+	for _, desc := range p.compDesc {
+		desc.Destroy()
+	}
+	p.compFbuf.Destroy()
+	p.compPass.Destroy()
+
+	for _, tex := range p.inputTex {
+		tex.Destroy()
+	}
+	for _, textures := range p.levelTex {
+		for _, tex := range textures {
+			tex.Destroy()
+		}
+	}
+	for _, level := range p.levels {
+		level.Destroy()
+	}
+
+	p.downPipe.Destroy()
+	if p.upPipe != nil {
+		p.upPipe.Destroy()
+	}
+	p.compPipe.Destroy()
+	p.pipeLayout.Destroy()
+	p.compPipeLayout.Destroy()
+	p.descLayout.Destroy()
+	p.compDescLayout.Destroy()
+}
@@ -0,0 +1,489 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/material"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// fragment count shown as the hottest color in the overdraw view
+const maxOverdraw = 8
+
+type DebugDescriptors struct {
+	descriptor.Set
+	Camera    *descriptor.Uniform[uniform.Camera]
+	Diffuse   *descriptor.Sampler
+	Normal    *descriptor.Sampler
+	Position  *descriptor.Sampler
+	Depth     *descriptor.Sampler
+	Occlusion *descriptor.Sampler
+	Lit       *descriptor.Sampler
+	Overdraw  *descriptor.Sampler
+}
+
+// DebugPass replaces the final image with a debug visualization selected by the render settings,
+// and optionally draws the edges of all meshes on top of it.
+//
+// Shadow cascades and light counts are computed by the lighting pass, and displayed from the lit color buffer.
+// Overdraw is measured by drawing all meshes without depth testing into an additive counter buffer.
+type DebugPass struct {
+	app      engine.App
+	settings *engine.RenderSettings
+	quad     vertex.Mesh
+
+	// full screen visualization
+	viewPass   *renderpass.Renderpass
+	viewFbufs  framebuffer.Array
+	viewPipe   *pipeline.Pipeline
+	viewLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*DebugDescriptors]
+	desc       []*DebugDescriptors
+	textures   []texture.Array
+
+	// overdraw counter
+	overdraw      *engine.RenderTarget
+	overdrawPass  *renderpass.Renderpass
+	overdrawFbufs framebuffer.Array
+	overdrawPipes cache.PipelineCache
+	overdrawPlan  *RenderPlan
+	overdrawCmds  []*command.IndirectDrawBuffer
+
+	// wireframe overlay
+	wirePass  *renderpass.Renderpass
+	wireFbufs framebuffer.Array
+	wirePipes cache.PipelineCache
+	wirePlan  *RenderPlan
+	wireCmds  []*command.IndirectDrawBuffer
+
+	// shared mesh geometry
+	layout     *pipeline.Layout
+	geomLayout *descriptor.Layout[*BasicDescriptors]
+	geometry   []*BasicDescriptors
+	objects    *uniform.ObjectBuffer
+	instances  *InstanceBuffer
+	meshes     cache.MeshCache
+	meshQuery  *object.Query[mesh.Mesh]
+}
+
+var _ draw.Pass = &DebugPass{}
+
+func NewDebugPass(
+	app engine.App,
+	target engine.Target,
+	depth engine.Target,
+	gbuffer GeometryBuffer,
+	occlusion engine.Target,
+	lit engine.Target,
+	settings *engine.RenderSettings,
+) *DebugPass {
+	var err error
+	frames := target.Frames()
+	p := &DebugPass{
+		app:      app,
+		settings: settings,
+		quad:     vertex.ScreenQuad("debug-pass-quad"),
+		meshes:   app.Meshes(),
+
+		overdrawPlan: NewRenderPlan(),
+		wirePlan:     NewRenderPlan(),
+		meshQuery:    object.NewQuery[mesh.Mesh](),
+	}
+
+	dependencies := []renderpass.SubpassDependency{
+		{
+			// For color attachment operations
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+		},
+		{
+			// For fragment shader reads
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageFragmentShader,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessShaderRead,
+		},
+	}
+
+	//
+	// mesh geometry, shared by the overdraw and wireframe passes
+	//
+
+	maxObjects := 1000
+	p.geomLayout = descriptor.NewLayout(app.Device(), "Debug", &BasicDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageAll,
+		},
+		Objects: &descriptor.Storage[uniform.Object]{
+			Stages: core1_0.StageAll,
+			Size:   maxObjects,
+		},
+	})
+	p.geometry = p.geomLayout.InstantiateMany(app.Pool(), frames)
+	p.layout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.geomLayout}, []pipeline.PushConstant{})
+	p.objects = uniform.NewObjectBuffer(maxObjects)
+	p.instances = NewInstanceBuffer(app.Device(), "debug", frames, maxInstances)
+	p.overdrawCmds = make([]*command.IndirectDrawBuffer, frames)
+	p.wireCmds = make([]*command.IndirectDrawBuffer, frames)
+	for i := 0; i < frames; i++ {
+		p.overdrawCmds[i] = command.NewIndirectDrawBuffer(app.Device(), "Overdraw", p.objects.Size())
+		p.wireCmds[i] = command.NewIndirectDrawBuffer(app.Device(), "Wireframe", p.objects.Size())
+	}
+
+	//
+	// overdraw counter
+	//
+
+	p.overdraw = engine.NewColorTarget(app.Device(), "debug-overdraw", core1_0.FormatR16SignedFloat, target.Size())
+	p.overdrawPass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Overdraw",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.overdraw.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpClear,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Clear:       color.T{},
+				Blend:       attachment.BlendAdditive,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.overdrawFbufs, err = framebuffer.NewArray(frames, app.Device(), "overdraw", target.Width(), target.Height(), p.overdrawPass)
+	if err != nil {
+		panic(err)
+	}
+	p.overdrawPipes = cache.NewPipelineCache(app.Device(), app.Shaders(), p.overdrawPass, p.layout)
+
+	//
+	// wireframe overlay
+	//
+
+	p.wirePass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Wireframe",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:          OutputAttachment,
+				Image:         attachment.FromImageArray(target.Surfaces()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Blend:         attachment.BlendMix,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.wireFbufs, err = framebuffer.NewArray(frames, app.Device(), "wireframe", target.Width(), target.Height(), p.wirePass)
+	if err != nil {
+		panic(err)
+	}
+	p.wirePipes = cache.NewPipelineCache(app.Device(), app.Shaders(), p.wirePass, p.layout)
+
+	//
+	// full screen visualization
+	//
+
+	p.viewPass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Debug",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(target.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.viewFbufs, err = framebuffer.NewArray(frames, app.Device(), "debug", target.Width(), target.Height(), p.viewPass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "Debug", &DebugDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageFragment,
+		},
+		Diffuse: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Normal: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Position: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Depth: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Occlusion: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Lit: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Overdraw: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.viewLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, []pipeline.PushConstant{
+		{
+			Stages: core1_0.StageFragment,
+			Type:   uniform.DebugView{},
+		},
+	})
+	p.viewPipe = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.viewLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/debug")),
+		Pass:     p.viewPass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), frames)
+	p.textures = make([]texture.Array, frames)
+	for i := 0; i < frames; i++ {
+		nearest := texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		}
+		depthArgs := nearest
+		depthArgs.Aspect = core1_0.ImageAspectDepth
+
+		diffuse := p.texture(fmt.Sprintf("debug-diffuse-%d", i), gbuffer.Diffuse()[i], nearest)
+		normal := p.texture(fmt.Sprintf("debug-normal-%d", i), gbuffer.Normal()[i], nearest)
+		position := p.texture(fmt.Sprintf("debug-position-%d", i), gbuffer.Position()[i], nearest)
+		depthTex := p.texture(fmt.Sprintf("debug-depth-%d", i), depth.Surfaces()[i], depthArgs)
+		occlusionTex := p.texture(fmt.Sprintf("debug-occlusion-%d", i), occlusion.Surfaces()[i], texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		})
+		litTex := p.texture(fmt.Sprintf("debug-lit-%d", i), lit.Surfaces()[i], nearest)
+		overdrawTex := p.texture(fmt.Sprintf("debug-overdraw-%d", i), p.overdraw.Surfaces()[i], nearest)
+
+		p.desc[i].Diffuse.Set(diffuse)
+		p.desc[i].Normal.Set(normal)
+		p.desc[i].Position.Set(position)
+		p.desc[i].Depth.Set(depthTex)
+		p.desc[i].Occlusion.Set(occlusionTex)
+		p.desc[i].Lit.Set(litTex)
+		p.desc[i].Overdraw.Set(overdrawTex)
+		p.textures[i] = texture.Array{diffuse, normal, position, depthTex, occlusionTex, litTex, overdrawTex}
+	}
+
+	return p
+}
+
+func (p *DebugPass) texture(key string, img *image.Image, args texture.Args) *texture.Texture {
+	tex, err := texture.FromImage(p.app.Device(), key, img, args)
+	if err != nil {
+		// todo: clean up
+		panic(err)
+	}
+	return tex
+}
+
+func (p *DebugPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	mode := p.settings.Debug
+	wireframe := p.settings.Wireframe
+	if mode == engine.DebugNone && !wireframe {
+		return
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+
+	overdraw := mode == engine.DebugOverdraw
+	if overdraw || wireframe {
+		p.prepareGeometry(args, scene, overdraw, wireframe)
+	}
+
+	desc := p.desc[args.Frame]
+	desc.Camera.Set(uniform.CameraFromArgs(args))
+	geometry := p.geometry[args.Frame]
+
+	cmds.Record(func(cmd *command.Buffer) {
+		if overdraw {
+			cmd.CmdBeginRenderPass(p.overdrawPass, p.overdrawFbufs[args.Frame])
+			cmd.CmdBindGraphicsDescriptor(p.layout, 0, geometry)
+			p.overdrawPlan.Draw(cmd, p.overdrawCmds[args.Frame])
+			cmd.CmdEndRenderPass()
+		}
+
+		if mode != engine.DebugNone {
+			cmd.CmdBeginRenderPass(p.viewPass, p.viewFbufs[args.Frame])
+			cmd.CmdBindGraphicsPipeline(p.viewPipe)
+			cmd.CmdBindGraphicsDescriptor(p.viewLayout, 0, desc)
+			cmd.CmdPushConstant(core1_0.StageFragment, 0, &uniform.DebugView{
+				Mode:        int32(mode),
+				Far:         args.Camera.Far,
+				MaxOverdraw: maxOverdraw,
+			})
+			quad.Bind(cmd)
+			quad.Draw(cmd, 0)
+			cmd.CmdEndRenderPass()
+		}
+
+		if wireframe {
+			cmd.CmdBeginRenderPass(p.wirePass, p.wireFbufs[args.Frame])
+			cmd.CmdBindGraphicsDescriptor(p.layout, 0, geometry)
+			p.wirePlan.Draw(cmd, p.wireCmds[args.Frame])
+			cmd.CmdEndRenderPass()
+		}
+	})
+}
+
+// prepareGeometry collects all visible triangle meshes into the render plans of the overdraw and wireframe passes
+func (p *DebugPass) prepareGeometry(args draw.Args, scene object.Component, overdraw, wireframe bool) {
+	descriptors := p.geometry[args.Frame]
+	descriptors.Camera.Set(uniform.CameraFromArgs(args))
+
+	p.objects.Reset()
+	p.instances.Reset(args.Frame)
+	p.overdrawPlan.Clear()
+	p.wirePlan.Clear()
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
+
+	overdrawPipe, overdrawReady := p.overdrawPipes.TryFetch(material.Overdraw())
+	wirePipe, wireReady := p.wirePipes.TryFetch(material.Wireframe())
+	overdraw = overdraw && overdrawReady
+	wireframe = wireframe && wireReady
+
+	meshes := p.meshQuery.
+		Reset().
+		Where(isDrawTriangles).
+		Collect(scene)
+
+	for _, meshObject := range meshes {
+		gpuMesh, meshReady := p.meshes.TryFetch(meshObject.Mesh())
+		if !meshReady || gpuMesh.IndexCount == 0 {
+			continue
+		}
+
+		instances, instanceCount, visible := p.instances.StoreMesh(meshObject, gpuMesh, &frustum)
+		if !visible {
+			continue
+		}
+
+		objectId := p.objects.Store(uniform.Object{
+			Model:     meshObject.Transform().Matrix(),
+			Vertices:  gpuMesh.Vertices.Address(),
+			Indices:   gpuMesh.Indices.Address(),
+			Instances: instances,
+		})
+		obj := RenderObject{
+			Handle:    objectId,
+			Indices:   gpuMesh.IndexCount,
+			Instances: instanceCount,
+		}
+		if overdraw {
+			p.overdrawPlan.Add(overdrawPipe, obj)
+		}
+		if wireframe {
+			p.wirePlan.Add(wirePipe, obj)
+		}
+	}
+
+	p.objects.Flush(descriptors.Objects)
+	p.instances.Flush()
+}
+
+func (p *DebugPass) Name() string {
+	return "Debug"
+}
+
+func (p *DebugPass) Destroy() {
+	for _, textures := range p.textures {
+		for _, tex := range textures {
+			tex.Destroy()
+		}
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	for _, desc := range p.geometry {
+		desc.Destroy()
+	}
+	for _, cmd := range p.overdrawCmds {
+		cmd.Destroy()
+	}
+	for _, cmd := range p.wireCmds {
+		cmd.Destroy()
+	}
+	p.instances.Destroy()
+
+	p.viewFbufs.Destroy()
+	p.viewPass.Destroy()
+	p.viewPipe.Destroy()
+	p.viewLayout.Destroy()
+	p.descLayout.Destroy()
+
+	p.overdrawPipes.Destroy()
+	p.overdrawFbufs.Destroy()
+	p.overdrawPass.Destroy()
+	p.overdraw.Destroy()
+
+	p.wirePipes.Destroy()
+	p.wireFbufs.Destroy()
+	p.wirePass.Destroy()
+
+	p.layout.Destroy()
+	p.geomLayout.Destroy()
+}
+
+// isDrawTriangles returns true for meshes drawn as triangles with the standard vertex format
+func isDrawTriangles(m mesh.Mesh) bool {
+	if ref := m.Mesh(); ref != nil {
+		if mat := m.Material(); mat != nil {
+			return mat.Primitive == vertex.Triangles && mat.VertexFormat == (vertex.Vertex{})
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/decal"
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+const maxDecals = 256
+
+// blends decal colors over the geometry buffer, while keeping the existing alpha channel
+var blendDecal = attachment.Blend{
+	Enabled: true,
+	Color: attachment.BlendOp{
+		Operation: core1_0.BlendOpAdd,
+		SrcFactor: core1_0.BlendFactorSrcAlpha,
+		DstFactor: core1_0.BlendFactorOneMinusSrcAlpha,
+	},
+	Alpha: attachment.BlendOp{
+		Operation: core1_0.BlendOpAdd,
+		SrcFactor: core1_0.BlendFactorZero,
+		DstFactor: core1_0.BlendFactorOne,
+	},
+}
+
+type DecalDescriptors struct {
+	descriptor.Set
+	Camera   *descriptor.Uniform[uniform.Camera]
+	Decals   *descriptor.Storage[uniform.Decal]
+	Position *descriptor.Sampler
+	Textures *descriptor.SamplerArray
+}
+
+// DecalPass projects decals onto the geometry buffer.
+// Each decal draws the back faces of its projection box, and reconstructs the surface
+// position of every covered pixel from the geometry buffer.
+type DecalPass struct {
+	app  engine.App
+	pass *renderpass.Renderpass
+	fbuf framebuffer.Array
+	box  vertex.Mesh
+
+	pipeline    *pipeline.Pipeline
+	pipeLayout  *pipeline.Layout
+	descLayout  *descriptor.Layout[*DecalDescriptors]
+	descriptors []*DecalDescriptors
+	positionTex texture.Array
+	samplers    []cache.SamplerCache
+	decals      []uniform.Decal
+	decalQuery  *object.Query[*decal.Decal]
+}
+
+var _ draw.Pass = &DecalPass{}
+
+func NewDecalPass(app engine.App, gbuffer GeometryBuffer) *DecalPass {
+	pass := renderpass.New(app.Device(), renderpass.Args{
+		Name: "Decals",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:          DiffuseAttachment,
+				Image:         attachment.FromImageArray(gbuffer.Diffuse()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Blend:         blendDecal,
+			},
+			{
+				Name:          NormalsAttachment,
+				Image:         attachment.FromImageArray(gbuffer.Normal()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Blend:         blendDecal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{DiffuseAttachment, NormalsAttachment},
+			},
+		},
+	})
+
+	fbuf, err := framebuffer.NewArray(gbuffer.Frames(), app.Device(), "decals", gbuffer.Width(), gbuffer.Height(), pass)
+	if err != nil {
+		panic(err)
+	}
+
+	maxTextures := 2 * maxDecals
+	descLayout := descriptor.NewLayout(app.Device(), "Decals", &DecalDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageAll,
+		},
+		Decals: &descriptor.Storage[uniform.Decal]{
+			Stages: core1_0.StageAll,
+			Size:   maxDecals,
+		},
+		Position: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Textures: &descriptor.SamplerArray{
+			Stages: core1_0.StageFragment,
+			Count:  maxTextures,
+		},
+	})
+	pipeLayout := pipeline.NewLayout(app.Device(), []descriptor.SetLayout{descLayout}, nil)
+	pipe := pipeline.New(app.Device(), pipeline.Args{
+		Layout:   pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/decal")),
+		Pass:     pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+
+		// draw back faces, so that decals remain visible when the camera is inside the box
+		CullMode: vertex.CullFront,
+	})
+
+	descriptors := descLayout.InstantiateMany(app.Pool(), gbuffer.Frames())
+	positionTex := make(texture.Array, gbuffer.Frames())
+	samplers := make([]cache.SamplerCache, gbuffer.Frames())
+	for i := range descriptors {
+		positionTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("decal-position-%d", i), gbuffer.Position()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		descriptors[i].Position.Set(positionTex[i])
+		samplers[i] = cache.NewSamplerCache(app.Textures(), maxTextures)
+	}
+
+	return &DecalPass{
+		app:  app,
+		pass: pass,
+		fbuf: fbuf,
+		box:  vertex.Box("decal-box"),
+
+		pipeline:    pipe,
+		pipeLayout:  pipeLayout,
+		descLayout:  descLayout,
+		descriptors: descriptors,
+		positionTex: positionTex,
+		samplers:    samplers,
+		decals:      make([]uniform.Decal, 0, maxDecals),
+		decalQuery:  object.NewQuery[*decal.Decal](),
+	}
+}
+
+func (p *DecalPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	desc := p.descriptors[args.Frame]
+	samplers := p.samplers[args.Frame]
+
+	p.decals = p.decals[:0]
+	for _, d := range p.decalQuery.Reset().Collect(scene) {
+		if len(p.decals) >= maxDecals {
+			break
+		}
+		diffuse, ready := samplers.TryFetch(d.Diffuse.Get())
+		if !ready {
+			continue
+		}
+		normal := int32(-1)
+		if ref := d.Normal.Get(); ref != nil {
+			handle, ready := samplers.TryFetch(ref)
+			if !ready {
+				continue
+			}
+			normal = int32(handle.ID)
+		}
+
+		model := d.Box()
+		p.decals = append(p.decals, uniform.Decal{
+			Model:     model,
+			ModelInv:  model.Invert(),
+			Color:     d.Color.Get(),
+			Diffuse:   uint32(diffuse.ID),
+			Normal:    normal,
+			Layers:    uint32(d.Layers.Get()),
+			AngleFade: math.Cos(math.DegToRad(d.AngleFade.Get())),
+		})
+	}
+	if len(p.decals) == 0 {
+		return
+	}
+
+	box, meshReady := p.app.Meshes().TryFetch(p.box)
+	if !meshReady {
+		return
+	}
+
+	desc.Camera.Set(uniform.CameraFromArgs(args))
+	desc.Decals.SetRange(0, p.decals)
+	samplers.Flush(desc.Textures)
+
+	count := len(p.decals)
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.fbuf[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		box.Bind(cmd)
+		box.DrawInstanced(cmd, 0, count)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *DecalPass) Name() string {
+	return "Decals"
+}
+
+func (p *DecalPass) Destroy() {
+	for _, samplers := range p.samplers {
+		samplers.Destroy()
+	}
+	for _, tex := range p.positionTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.descriptors {
+		desc.Destroy()
+	}
+	p.fbuf.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
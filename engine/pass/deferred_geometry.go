@@ -24,6 +24,7 @@ const (
 	DiffuseAttachment  attachment.Name = "diffuse"
 	NormalsAttachment  attachment.Name = "normals"
 	PositionAttachment attachment.Name = "position"
+	VelocityAttachment attachment.Name = "velocity"
 	OutputAttachment   attachment.Name = "output"
 )
 
@@ -46,8 +47,12 @@ type DeferredGeometryPass struct {
 	descriptors []*DeferredDescriptors
 	textures    cache.SamplerCache
 	objects     *uniform.ObjectBuffer
+	instances   *InstanceBuffer
+	levels      []MeshLevel
 	plan        *RenderPlan
-	commands    []*command.IndirectDrawBuffer
+	culler      *DrawCuller
+	pyramid     *DepthPyramid
+	motion      *MotionHistory
 
 	meshes    cache.MeshCache
 	pipelines cache.PipelineCache
@@ -60,6 +65,8 @@ func NewDeferredGeometryPass(
 	app engine.App,
 	depth engine.Target,
 	gbuffer GeometryBuffer,
+	pyramid *DepthPyramid,
+	stats *engine.RenderStats,
 ) *DeferredGeometryPass {
 	maxTextures := 100
 	maxObjects := 1000
@@ -88,6 +95,13 @@ func NewDeferredGeometryPass(
 				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
 				Image:       attachment.FromImageArray(gbuffer.Position()),
 			},
+			{
+				Name:        VelocityAttachment,
+				LoadOp:      core1_0.AttachmentLoadOpClear,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Image:       attachment.FromImageArray(gbuffer.Velocity()),
+			},
 		},
 		DepthAttachment: &attachment.Depth{
 			LoadOp:        core1_0.AttachmentLoadOpLoad,
@@ -102,7 +116,7 @@ func NewDeferredGeometryPass(
 				Name:  MainSubpass,
 				Depth: true,
 
-				ColorAttachments: []attachment.Name{DiffuseAttachment, NormalsAttachment, PositionAttachment},
+				ColorAttachments: []attachment.Name{DiffuseAttachment, NormalsAttachment, PositionAttachment, VelocityAttachment},
 			},
 		},
 	})
@@ -134,11 +148,6 @@ func NewDeferredGeometryPass(
 	objects := uniform.NewObjectBuffer(maxObjects)
 	pipelines := cache.NewPipelineCache(app.Device(), app.Shaders(), pass, layout)
 
-	commands := make([]*command.IndirectDrawBuffer, gbuffer.Frames())
-	for i := range commands {
-		commands[i] = command.NewIndirectDrawBuffer(app.Device(), "Deferred", objects.Size())
-	}
-
 	app.Textures().Fetch(color.White)
 
 	return &DeferredGeometryPass{
@@ -150,9 +159,12 @@ func NewDeferredGeometryPass(
 		descLayout:  descLayout,
 		descriptors: descriptors,
 		objects:     objects,
+		instances:   NewInstanceBuffer(app.Device(), "deferred", gbuffer.Frames(), maxInstances),
 		textures:    textures,
-		commands:    commands,
+		culler:      NewDrawCuller(app, "Deferred", descLayout, gbuffer.Frames(), objects.Size(), stats),
+		pyramid:     pyramid,
 		plan:        NewRenderPlan(),
+		motion:      NewMotionHistory(),
 
 		pipelines: pipelines,
 		meshes:    app.Meshes(),
@@ -176,7 +188,6 @@ func (p *DeferredGeometryPass) fetch(mesh mesh.Mesh) (*cache.GpuMesh, *cache.Pip
 
 func (p *DeferredGeometryPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
 	descriptors := p.descriptors[args.Frame]
-	indirect := p.commands[args.Frame]
 	framebuf := p.fbuf[args.Frame]
 
 	// update camera descriptor
@@ -185,6 +196,8 @@ func (p *DeferredGeometryPass) Record(cmds command.Recorder, args draw.Args, sce
 
 	// clear object buffer
 	p.objects.Reset()
+	p.instances.Reset(args.Frame)
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
 
 	// collect all objects
 	objects := p.meshQuery.
@@ -204,29 +217,61 @@ func (p *DeferredGeometryPass) Record(cmds command.Recorder, args draw.Args, sce
 		// this could happen inside the mesh cache!
 		// basically *GpuMesh could be the entire uniform object
 		// or even the entire object buffer similar to the sampler cache?
+		instances, instanceCount, visible := p.instances.StoreMesh(meshObject, mesh, &frustum)
+		if !visible {
+			continue
+		}
+
 		textureIds := AssignMeshTextures(p.textures, meshObject, pipeline.Slots)
 
-		objectId := p.objects.Store(uniform.Object{
-			Model:    meshObject.Transform().Matrix(),
-			Textures: textureIds,
-			Vertices: mesh.Vertices.Address(),
-			Indices:  mesh.Indices.Address(),
-		})
-
-		p.plan.Add(pipeline, RenderObject{
-			Handle:  objectId,
-			Indices: mesh.IndexCount,
-		})
+		model := meshObject.Transform().Matrix()
+		prevModel := p.motion.Track(meshObject.ID(), model)
+
+		p.levels = SelectLevels(p.meshes, meshObject, mesh, args.Camera, true, p.levels)
+		for _, level := range p.levels {
+			objectId := p.objects.Store(uniform.Object{
+				Model:     model,
+				Textures:  textureIds,
+				Vertices:  level.Mesh.Vertices.Address(),
+				Indices:   level.Mesh.Indices.Address(),
+				PrevModel: prevModel,
+				Layer:     uint32(meshObject.Layer()),
+				Instances: instances,
+				Dither:    level.Dither,
+			})
+
+			p.plan.Add(pipeline, RenderObject{
+				Handle:    objectId,
+				Indices:   level.Mesh.IndexCount,
+				Instances: instanceCount,
+				Bounds:    cullBounds(level.Mesh, instanceCount),
+			})
+		}
 	}
 
+	p.motion.Swap()
+
 	// flush descriptors
 	p.objects.Flush(descriptors.Objects)
+	p.instances.Flush()
 	p.textures.Flush(descriptors.Textures)
 
+	// culling & draw compaction happens on the gpu.
+	// objects are occlusion culled against the depth pre-pass of the current frame, if there is one
+	occlusion := Occlusion{}
+	if p.pyramid != nil {
+		occlusion = Occlusion{
+			Pyramid: p.pyramid,
+			Frame:   args.Frame,
+		}
+	}
+	p.culler.Prepare(args.Frame, p.plan, args.Camera.ViewProj, occlusion)
+
 	cmds.Record(func(cmd *command.Buffer) {
+		p.culler.Cull(cmd, args.Frame, descriptors)
 		cmd.CmdBeginRenderPass(p.pass, framebuf)
 		cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
-		p.plan.Draw(cmd, indirect)
+		p.culler.Draw(cmd, args.Frame)
 		cmd.CmdEndRenderPass()
 	})
 }
@@ -237,14 +282,13 @@ func (p *DeferredGeometryPass) Name() string {
 
 func (p *DeferredGeometryPass) Destroy() {
 	p.textures.Destroy()
+	p.instances.Destroy()
 	p.fbuf.Destroy()
 	p.pass.Destroy()
 	for _, desc := range p.descriptors {
 		desc.Destroy()
 	}
-	for _, commands := range p.commands {
-		commands.Destroy()
-	}
+	p.culler.Destroy()
 	p.layout.Destroy()
 	p.descLayout.Destroy()
 	p.pipelines.Destroy()
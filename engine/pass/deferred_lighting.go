@@ -6,6 +6,7 @@ import (
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/engine"
 	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/cluster"
 	"github.com/johanhenriksson/goworld/engine/uniform"
 	"github.com/johanhenriksson/goworld/render/color"
 	"github.com/johanhenriksson/goworld/render/command"
@@ -20,18 +21,22 @@ import (
 const LightingSubpass renderpass.Name = "lighting"
 
 type DeferredLightPass struct {
-	app        engine.App
-	target     engine.Target
-	gbuffer    GeometryBuffer
-	ssao       engine.Target
-	quad       vertex.Mesh
-	pass       *renderpass.Renderpass
-	light      *LightShader
-	fbuf       framebuffer.Array
-	samplers   []cache.SamplerCache
-	shadows    []*ShadowCache
-	lightbufs  []*uniform.LightBuffer
-	lightQuery *object.Query[light.T]
+	app         engine.App
+	target      engine.Target
+	gbuffer     GeometryBuffer
+	ssao        engine.Target
+	quad        vertex.Mesh
+	pass        *renderpass.Renderpass
+	light       *LightShader
+	fbuf        framebuffer.Array
+	samplers    []cache.SamplerCache
+	shadows     []*ShadowCache
+	lightbufs   []*uniform.LightBuffer
+	clusters    *cluster.Grid
+	lightQuery  *object.Query[light.T]
+	environment *EnvironmentLighting
+	fog         *SceneFog
+	settings    *engine.RenderSettings
 }
 
 func NewDeferredLightingPass(
@@ -40,6 +45,7 @@ func NewDeferredLightingPass(
 	gbuffer GeometryBuffer,
 	shadows *Shadowpass,
 	occlusion engine.Target,
+	settings *engine.RenderSettings,
 ) *DeferredLightPass {
 	pass := renderpass.New(app.Device(), renderpass.Args{
 		Name: "Deferred Lighting",
@@ -94,7 +100,8 @@ func NewDeferredLightingPass(
 
 	quad := vertex.ScreenQuad("geometry-pass-quad")
 
-	lightsh := NewLightShader(app, pass, gbuffer, occlusion)
+	clusters := cluster.New(cluster.DefaultArgs())
+	lightsh := NewLightShader(app, pass, gbuffer, occlusion, clusters)
 
 	maxLights := 256
 	maxShadowTextures := maxLights
@@ -108,16 +115,21 @@ func NewDeferredLightingPass(
 	}
 
 	return &DeferredLightPass{
-		target:     target,
-		gbuffer:    gbuffer,
-		app:        app,
-		quad:       quad,
-		light:      lightsh,
-		pass:       pass,
-		fbuf:       fbuf,
-		shadows:    shadowmaps,
-		lightbufs:  lightbufs,
-		lightQuery: object.NewQuery[light.T](),
+		target:      target,
+		gbuffer:     gbuffer,
+		app:         app,
+		quad:        quad,
+		light:       lightsh,
+		pass:        pass,
+		fbuf:        fbuf,
+		shadows:     shadowmaps,
+		lightbufs:   lightbufs,
+		samplers:    samplers,
+		clusters:    clusters,
+		lightQuery:  object.NewQuery[light.T](),
+		environment: NewEnvironmentLighting(),
+		fog:         NewSceneFog(),
+		settings:    settings,
 	}
 }
 
@@ -131,13 +143,28 @@ func (p *DeferredLightPass) Record(cmds command.Recorder, args draw.Args, scene
 	shadows := p.shadows[args.Frame]
 	lightbuf.Reset()
 
-	// todo: perform frustum culling on light volumes
 	lights := p.lightQuery.Reset().Collect(scene)
 	for _, lit := range lights {
 		lightbuf.Store(lit.LightData(shadows))
 	}
 
+	// bin lights into view space clusters
+	p.clusters.Update(args.Camera.Proj, args.Camera.Near, args.Camera.Far)
+	p.clusters.Assign(args.Camera.View, lightbuf.Lights())
+	p.clusters.Settings(lightbuf.Settings())
+
+	// environment & sky ambient lighting
+	p.environment.Apply(lightbuf.Settings(), p.samplers[args.Frame], scene)
+
+	// atmospheric fog
+	p.fog.Apply(lightbuf.Settings(), scene)
+
+	// debug views implemented by the lighting shader
+	lightbuf.Settings().DebugMode = int32(p.settings.Debug)
+	lightbuf.Settings().ShadowFilter = int32(p.settings.Shadows)
+
 	lightbuf.Flush(desc.Lights)
+	p.clusters.Flush(desc.Clusters, desc.ClusterLights)
 	shadows.Flush(desc.Shadow)
 
 	quad := p.app.Meshes().Fetch(p.quad)
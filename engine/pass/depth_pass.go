@@ -7,6 +7,7 @@ import (
 	"github.com/johanhenriksson/goworld/engine"
 	"github.com/johanhenriksson/goworld/engine/cache"
 	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/shape"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
 	"github.com/johanhenriksson/goworld/render/framebuffer"
@@ -18,18 +19,30 @@ import (
 	"github.com/vkngwrapper/core/v2/core1_0"
 )
 
+// DepthPass renders the depth of opaque deferred geometry ahead of the geometry pass,
+// and reduces it into a depth pyramid used for occlusion culling.
+//
+// Objects are occlusion culled against the pyramid of the previous frame. Occluded objects are then retested
+// against a pyramid built from the depth of the visible objects, and drawn in a second pass if they became visible.
 type DepthPass struct {
 	app   engine.App
 	depth engine.Target
 	pass  *renderpass.Renderpass
 	fbuf  framebuffer.Array
 
+	retestPass *renderpass.Renderpass
+	retestFbuf framebuffer.Array
+	pyramid    *DepthPyramid
+	last       int
+
 	layout      *pipeline.Layout
 	descLayout  *descriptor.Layout[*BasicDescriptors]
 	descriptors []*BasicDescriptors
 	objects     *uniform.ObjectBuffer
+	instances   *InstanceBuffer
+	levels      []MeshLevel
 	plan        *RenderPlan
-	commands    []*command.IndirectDrawBuffer
+	culler      *DrawCuller
 
 	meshes    cache.MeshCache
 	pipelines cache.PipelineCache
@@ -41,6 +54,7 @@ var _ draw.Pass = &ForwardPass{}
 func NewDepthPass(
 	app engine.App,
 	depth engine.Target,
+	stats *engine.RenderStats,
 ) *DepthPass {
 	pass := renderpass.New(app.Device(), renderpass.Args{
 		Name: "Depth",
@@ -66,6 +80,41 @@ func NewDepthPass(
 		panic(err)
 	}
 
+	// the retest pass draws objects that became visible on top of the first pass
+	retestPass := renderpass.New(app.Device(), renderpass.Args{
+		Name: "DepthRetest",
+		DepthAttachment: &attachment.Depth{
+			LoadOp:        core1_0.AttachmentLoadOpLoad,
+			StencilLoadOp: core1_0.AttachmentLoadOpLoad,
+			StoreOp:       core1_0.AttachmentStoreOpStore,
+			InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+
+			Image: attachment.FromImageArray(depth.Surfaces()),
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:  MainSubpass,
+				Depth: true,
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// wait for the depth pyramid to finish reading the depth buffer
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageFragmentShader,
+				DstStageMask:  core1_0.PipelineStageEarlyFragmentTests | core1_0.PipelineStageLateFragmentTests,
+				SrcAccessMask: core1_0.AccessShaderRead,
+				DstAccessMask: core1_0.AccessDepthStencilAttachmentRead | core1_0.AccessDepthStencilAttachmentWrite,
+			},
+		},
+	})
+	retestFbuf, err := framebuffer.NewArray(depth.Frames(), app.Device(), "depth-retest", depth.Width(), depth.Height(), retestPass)
+	if err != nil {
+		panic(err)
+	}
+
 	maxObjects := 1000
 	descLayout := descriptor.NewLayout(app.Device(), "Depth", &BasicDescriptors{
 		Camera: &descriptor.Uniform[uniform.Camera]{
@@ -82,22 +131,23 @@ func NewDepthPass(
 	objects := uniform.NewObjectBuffer(maxObjects)
 	pipelines := cache.NewPipelineCache(app.Device(), app.Shaders(), pass, layout)
 
-	commands := make([]*command.IndirectDrawBuffer, depth.Frames())
-	for i := range commands {
-		commands[i] = command.NewIndirectDrawBuffer(app.Device(), "Depth", objects.Size())
-	}
-
 	return &DepthPass{
 		app:   app,
 		depth: depth,
 		pass:  pass,
 		fbuf:  fbuf,
 
+		retestPass: retestPass,
+		retestFbuf: retestFbuf,
+		pyramid:    NewDepthPyramid(app, depth),
+		last:       -1,
+
 		layout:      layout,
 		descriptors: descriptors,
 		descLayout:  descLayout,
 		objects:     objects,
-		commands:    commands,
+		instances:   NewInstanceBuffer(app.Device(), "depth", depth.Frames(), maxInstances),
+		culler:      NewDrawCuller(app, "Depth", descLayout, depth.Frames(), objects.Size(), stats),
 		plan:        NewRenderPlan(),
 
 		pipelines: pipelines,
@@ -135,14 +185,15 @@ func (p *DepthPass) fetch(mesh mesh.Mesh) (*cache.GpuMesh, *cache.Pipeline, bool
 
 func (p *DepthPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
 	descriptors := p.descriptors[args.Frame]
-	indirect := p.commands[args.Frame]
 	framebuf := p.fbuf[args.Frame]
 
 	cam := uniform.CameraFromArgs(args)
 	descriptors.Camera.Set(cam)
 
 	p.objects.Reset()
+	p.instances.Reset(args.Frame)
 	p.plan.Clear()
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
 
 	// todo: better strategy for picking occluders
 	occluders := p.meshQuery.
@@ -157,39 +208,89 @@ func (p *DepthPass) Record(cmds command.Recorder, args draw.Args, scene object.C
 			continue
 		}
 
-		objectId := p.objects.Store(uniform.Object{
-			Model:    meshObject.Transform().Matrix(),
-			Vertices: mesh.Vertices.Address(),
-			Indices:  mesh.Indices.Address(),
-		})
+		instances, instanceCount, visible := p.instances.StoreMesh(meshObject, mesh, &frustum)
+		if !visible {
+			continue
+		}
 
-		p.plan.Add(pipeline, RenderObject{
-			Handle:  objectId,
-			Indices: mesh.IndexCount,
-		})
+		p.levels = SelectLevels(p.meshes, meshObject, mesh, args.Camera, true, p.levels)
+		for _, level := range p.levels {
+			objectId := p.objects.Store(uniform.Object{
+				Model:     meshObject.Transform().Matrix(),
+				Vertices:  level.Mesh.Vertices.Address(),
+				Indices:   level.Mesh.Indices.Address(),
+				Instances: instances,
+				Dither:    level.Dither,
+			})
+
+			p.plan.Add(pipeline, RenderObject{
+				Handle:    objectId,
+				Indices:   level.Mesh.IndexCount,
+				Instances: instanceCount,
+				Bounds:    cullBounds(level.Mesh, instanceCount),
+			})
+		}
 	}
 
 	p.objects.Flush(descriptors.Objects)
+	p.instances.Flush()
+
+	// culling & draw compaction happens on the gpu.
+	// objects are occlusion culled against the pyramid of the most recent frame, if there is one
+	occlusion := Occlusion{}
+	if p.last >= 0 {
+		occlusion = Occlusion{
+			Pyramid: p.pyramid,
+			Frame:   p.last,
+			Retest:  true,
+		}
+	}
+	retest := occlusion.Pyramid != nil && p.pyramid.Valid(occlusion.Frame)
+	p.culler.Prepare(args.Frame, p.plan, args.Camera.ViewProj, occlusion)
+	p.pyramid.Prepare(args.Frame, args.Camera.ViewProj)
+	p.last = args.Frame
 
 	cmds.Record(func(cmd *command.Buffer) {
+		// draw objects that were visible in the previous frame
+		p.culler.Cull(cmd, args.Frame, descriptors)
 		cmd.CmdBeginRenderPass(p.pass, framebuf)
 		cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
-		p.plan.Draw(cmd, indirect)
+		p.culler.Draw(cmd, args.Frame)
 		cmd.CmdEndRenderPass()
+
+		// retest occluded objects against the depth of the visible objects
+		if retest {
+			p.pyramid.Build(cmd, args.Frame)
+			p.culler.Retest(cmd, args.Frame, descriptors)
+			cmd.CmdBeginRenderPass(p.retestPass, p.retestFbuf[args.Frame])
+			cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
+			p.culler.DrawRetested(cmd, args.Frame)
+			cmd.CmdEndRenderPass()
+		}
+
+		// build the final pyramid, used by later passes and the next frame
+		p.pyramid.Build(cmd, args.Frame)
 	})
 }
 
+// Pyramid returns the depth pyramid built from the depth buffer
+func (p *DepthPass) Pyramid() *DepthPyramid {
+	return p.pyramid
+}
+
 func (p *DepthPass) Name() string {
 	return "Depth"
 }
 
 func (p *DepthPass) Destroy() {
-	for _, command := range p.commands {
-		command.Destroy()
-	}
+	p.culler.Destroy()
 	for _, desc := range p.descriptors {
 		desc.Destroy()
 	}
+	p.instances.Destroy()
+	p.pyramid.Destroy()
+	p.retestFbuf.Destroy()
+	p.retestPass.Destroy()
 	p.fbuf.Destroy()
 	p.pass.Destroy()
 	p.layout.Destroy()
@@ -0,0 +1,261 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// PyramidMaxLevels is the maximum number of levels in a depth pyramid
+const PyramidMaxLevels = 16
+
+type DepthPyramidDescriptors struct {
+	descriptor.Set
+	Input *descriptor.Sampler
+}
+
+// pyramidLevel is a single level of the depth pyramid, along with the draw that reduces the level above it
+type pyramidLevel struct {
+	target *engine.RenderTarget
+	tex    texture.Array
+	pass   *renderpass.Renderpass
+	fbuf   framebuffer.Array
+	desc   []*DepthPyramidDescriptors
+}
+
+// DepthPyramid is a hierarchical depth buffer (Hi-Z) used for occlusion culling.
+// Each level holds the farthest depth of a 2x2 texel footprint of the level above it,
+// starting at half the resolution of the depth buffer.
+//
+// The pyramid is built once per frame and remembers the view projection it was built with,
+// so that later frames can reproject their objects into it.
+type DepthPyramid struct {
+	app      engine.App
+	quad     vertex.Mesh
+	quadMesh *cache.GpuMesh
+	depthTex texture.Array
+	levels   []*pyramidLevel
+	frameTex []texture.Array
+
+	viewProj []mat4.T
+	valid    []bool
+
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*DepthPyramidDescriptors]
+}
+
+func NewDepthPyramid(app engine.App, depth engine.Target) *DepthPyramid {
+	frames := depth.Frames()
+	p := &DepthPyramid{
+		app:      app,
+		quad:     vertex.ScreenQuad("depth-pyramid-quad"),
+		viewProj: make([]mat4.T, frames),
+		valid:    make([]bool, frames),
+	}
+
+	var err error
+	p.depthTex = make(texture.Array, frames)
+	for i := range p.depthTex {
+		p.depthTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("depth-pyramid-input-%d", i), depth.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+			Aspect: core1_0.ImageAspectDepth,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "DepthPyramid", &DepthPyramidDescriptors{
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+
+	// each level reduces the level above it, starting with the depth buffer
+	width, height := depth.Width(), depth.Height()
+	input := p.depthTex
+	for len(p.levels) < PyramidMaxLevels {
+		width, height = max(width/2, 1), max(height/2, 1)
+		index := len(p.levels)
+		level := &pyramidLevel{
+			target: engine.NewColorTarget(app.Device(), fmt.Sprintf("depth-pyramid-%d", index), core1_0.FormatR32SignedFloat, engine.TargetSize{
+				Width:  width,
+				Height: height,
+				Frames: frames,
+				Scale:  depth.Scale(),
+			}),
+			tex:  make(texture.Array, frames),
+			desc: p.descLayout.InstantiateMany(app.Pool(), frames),
+		}
+		for i, img := range level.target.Surfaces() {
+			level.tex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("depth-pyramid-%d-%d", index, i), img, texture.Args{
+				Filter: texture.FilterNearest,
+				Wrap:   texture.WrapClamp,
+			})
+			if err != nil {
+				// todo: clean up
+				panic(err)
+			}
+			level.desc[i].Input.Set(input[i])
+		}
+
+		level.pass = newDepthPyramidRenderpass(app, fmt.Sprintf("DepthPyramid%d", index), level.target)
+		level.fbuf, err = framebuffer.NewArray(frames, app.Device(), fmt.Sprintf("depth-pyramid-%d", index), width, height, level.pass)
+		if err != nil {
+			panic(err)
+		}
+
+		p.levels = append(p.levels, level)
+		input = level.tex
+		if width == 1 && height == 1 {
+			break
+		}
+	}
+
+	p.frameTex = make([]texture.Array, frames)
+	for i := range p.frameTex {
+		for _, level := range p.levels {
+			p.frameTex[i] = append(p.frameTex[i], level.tex[i])
+		}
+	}
+
+	// all level passes are compatible, so the pipeline can be shared.
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/depth_pyramid")),
+		Pass:     p.levels[0].pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	return p
+}
+
+func newDepthPyramidRenderpass(app engine.App, name string, target engine.Target) *renderpass.Renderpass {
+	return renderpass.New(app.Device(), renderpass.Args{
+		Name: name,
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(target.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For color attachment operations, after culling has read the previous contents
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput | core1_0.PipelineStageComputeShader,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite,
+			},
+			{
+				// For fragment shader reads of the level above, or the depth buffer
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput | core1_0.PipelineStageLateFragmentTests,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessDepthStencilAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+		},
+	})
+}
+
+// Prepare marks the pyramid of the given frame as built using the given view projection.
+// Must be called before recording Build.
+func (p *DepthPyramid) Prepare(frame int, viewProj mat4.T) {
+	if p.quadMesh == nil {
+		if quad, ready := p.app.Meshes().TryFetch(p.quad); ready {
+			p.quadMesh = quad
+		}
+	}
+	p.viewProj[frame] = viewProj
+
+	// the pyramid contents are undefined until the quad is available
+	p.valid[frame] = p.quadMesh != nil
+}
+
+// Build records the reduction of the depth buffer into the pyramid.
+// Must be recorded outside of a render pass, after the depth buffer has been written.
+func (p *DepthPyramid) Build(cmd *command.Buffer, frame int) {
+	if p.quadMesh == nil {
+		return
+	}
+	for _, level := range p.levels {
+		cmd.CmdBeginRenderPass(level.pass, level.fbuf[frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, level.desc[frame])
+		p.quadMesh.Bind(cmd)
+		p.quadMesh.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	}
+}
+
+// Valid returns true if the pyramid of the given frame has been built
+func (p *DepthPyramid) Valid(frame int) bool {
+	return p.valid[frame]
+}
+
+// ViewProj returns the view projection the pyramid of the given frame was built with
+func (p *DepthPyramid) ViewProj(frame int) mat4.T {
+	return p.viewProj[frame]
+}
+
+// Levels returns the level textures of the given frame, from highest to lowest resolution
+func (p *DepthPyramid) Levels(frame int) texture.Array {
+	return p.frameTex[frame]
+}
+
+// Size returns the size of the first level, in texels
+func (p *DepthPyramid) Size() vec2.T {
+	first := p.levels[0].target
+	return vec2.NewI(first.Width(), first.Height())
+}
+
+func (p *DepthPyramid) Destroy() {
+	for _, level := range p.levels {
+		for _, desc := range level.desc {
+			desc.Destroy()
+		}
+		for _, tex := range level.tex {
+			tex.Destroy()
+		}
+		level.fbuf.Destroy()
+		level.pass.Destroy()
+		level.target.Destroy()
+	}
+	for _, tex := range p.depthTex {
+		tex.Destroy()
+	}
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
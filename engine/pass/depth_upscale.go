@@ -0,0 +1,166 @@
+package pass
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+type DepthUpscaleDescriptors struct {
+	descriptor.Set
+	Depth *descriptor.Sampler
+}
+
+// DepthUpscalePass copies the scene depth buffer into a larger depth buffer,
+// so that overlays drawn at the output resolution are occluded by the scene.
+type DepthUpscalePass struct {
+	app  engine.App
+	quad vertex.Mesh
+
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*DepthUpscaleDescriptors]
+
+	desc  []*DepthUpscaleDescriptors
+	tex   texture.Array
+	fbufs framebuffer.Array
+	pass  *renderpass.Renderpass
+}
+
+var _ draw.Pass = &DepthUpscalePass{}
+
+func NewDepthUpscalePass(app engine.App, target engine.Target, source engine.Target) *DepthUpscalePass {
+	log.Println("create depth upscale pass")
+	p := &DepthUpscalePass{
+		app:  app,
+		quad: vertex.ScreenQuad("depth-upscale-pass-quad"),
+	}
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "DepthUpscale",
+		DepthAttachment: &attachment.Depth{
+			Image:         attachment.FromImageArray(target.Surfaces()),
+			LoadOp:        core1_0.AttachmentLoadOpDontCare,
+			StencilLoadOp: core1_0.AttachmentLoadOpDontCare,
+			StoreOp:       core1_0.AttachmentStoreOpStore,
+			FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:  MainSubpass,
+				Depth: true,
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For fragment shader reads of the scene depth
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageEarlyFragmentTests | core1_0.PipelineStageLateFragmentTests,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessDepthStencilAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+			{
+				// For depth attachment writes
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageEarlyFragmentTests | core1_0.PipelineStageLateFragmentTests,
+				DstStageMask:  core1_0.PipelineStageEarlyFragmentTests | core1_0.PipelineStageLateFragmentTests,
+				SrcAccessMask: core1_0.AccessDepthStencilAttachmentWrite,
+				DstAccessMask: core1_0.AccessDepthStencilAttachmentWrite,
+				Flags:         core1_0.DependencyByRegion,
+			},
+		},
+	})
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "DepthUpscale", &DepthUpscaleDescriptors{
+		Depth: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+
+	// every fragment is written, regardless of the previous contents
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:     p.pipeLayout,
+		Shader:     app.Shaders().Fetch(shader.Ref("pass/depth_upscale")),
+		Pass:       p.pass,
+		Pointers:   vertex.ParsePointers(vertex.Vertex{}),
+		DepthTest:  true,
+		DepthWrite: true,
+		DepthFunc:  core1_0.CompareOpAlways,
+	})
+
+	frames := target.Frames()
+	var err error
+	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), "depth-upscale", target.Width(), target.Height(), p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	// depth is not filtered, since interpolated depths do not belong to any surface
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), frames)
+	p.tex = make(texture.Array, frames)
+	for i := range p.tex {
+		key := fmt.Sprintf("depth-upscale-source-%d", i)
+		p.tex[i], err = texture.FromImage(app.Device(), key, source.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+			Aspect: core1_0.ImageAspectDepth,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Depth.Set(p.tex[i])
+	}
+
+	return p
+}
+
+func (p *DepthUpscalePass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	// the quad is always drawn, since later passes expect the target to be in shader read layout
+	quad := p.app.Meshes().Fetch(p.quad)
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, p.desc[args.Frame])
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *DepthUpscalePass) Name() string {
+	return "DepthUpscale"
+}
+
+func (p *DepthUpscalePass) Destroy() {
+	for _, tex := range p.tex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	p.fbufs.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
@@ -0,0 +1,374 @@
+package pass
+
+import (
+	"unsafe"
+
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/shader"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// maximum number of render groups drawn by a culler, each group has its own draw counter
+const maxCullGroups = 256
+
+// size of a single indirect draw command, as written by the culling shader
+const drawStride = int(unsafe.Sizeof(command.Draw{}))
+
+// number of threads in a culling shader work group
+const cullGroupSize = 64
+
+// number of culling phases. each phase has its own region of the command & counter buffers
+const cullPhases = 2
+
+type CullDescriptors struct {
+	descriptor.Set
+	Params   *descriptor.Uniform[uniform.Cull]
+	Draws    *descriptor.Storage[uniform.CullDraw]
+	Commands *descriptor.Storage[command.Draw]
+	Counts   *descriptor.Storage[uint32]
+	Stats    *descriptor.Storage[uniform.CullStats]
+	Pyramid  *descriptor.SamplerArray
+}
+
+type cullGroup struct {
+	Pipeline *cache.Pipeline
+	Offset   int
+	Size     int
+}
+
+// cullFrame holds the culling state of a single frame, read when the frame commands are recorded
+type cullFrame struct {
+	draws     int
+	groups    []cullGroup
+	occlusion bool
+	retest    bool
+	submitted bool
+
+	// overflow holds groups that did not fit in the culling buffers.
+	// their draw commands are kept in spill, and are drawn without culling.
+	overflow []cullGroup
+	spill    []command.Draw
+}
+
+// Occlusion selects the depth pyramids tested by a culler. The zero value disables occlusion culling.
+type Occlusion struct {
+	Pyramid *DepthPyramid
+
+	// Frame is the frame slot of the pyramid tested in the first phase.
+	// The pyramid is tested using the view projection it was built with.
+	Frame int
+
+	// Retest enables the second phase, which retests occluded draws against the pyramid of the current frame.
+	// The current pyramid must be built between Cull and Retest.
+	Retest bool
+}
+
+// DrawCuller frustum & occlusion culls the objects of a render plan in a compute shader.
+// Visible objects are written as compacted indirect draw commands, with one draw counter per render group.
+// The commands are drawn using draw-indirect-count, so that the CPU never needs to know which objects were visible.
+// Objects that do not fit in the culling buffers are drawn directly, without culling.
+//
+// The compute shader reads object transforms directly from the object buffer of the geometry pass,
+// which is bound as the first descriptor set.
+//
+// Occlusion culling tests objects against a depth pyramid of a previous frame. Since the previous frame may
+// hide objects that have become visible, occluded objects can be retested against the depth pyramid of the
+// current frame in a second phase, once the visible objects of the first phase have been drawn.
+type DrawCuller struct {
+	name        string
+	pipeline    *pipeline.Compute
+	layout      *pipeline.Layout
+	descLayout  *descriptor.Layout[*CullDescriptors]
+	descriptors []*CullDescriptors
+	draws       []uniform.CullDraw
+	frames      []cullFrame
+	maxDraws    int
+	stats       *engine.RenderStats
+}
+
+// NewDrawCuller creates a draw culler for a geometry pass. Culling counters are written to stats, if provided.
+func NewDrawCuller(app engine.App, name string, passLayout descriptor.SetLayout, frames, maxDraws int, stats *engine.RenderStats) *DrawCuller {
+	descLayout := descriptor.NewLayout(app.Device(), name+"Cull", &CullDescriptors{
+		Params: &descriptor.Uniform[uniform.Cull]{
+			Stages: core1_0.StageCompute,
+		},
+		Draws: &descriptor.Storage[uniform.CullDraw]{
+			Stages: core1_0.StageCompute,
+			Size:   maxDraws,
+		},
+		Commands: &descriptor.Storage[command.Draw]{
+			Stages: core1_0.StageCompute,
+			Size:   cullPhases * maxDraws,
+			Usage:  core1_0.BufferUsageIndirectBuffer,
+		},
+		Counts: &descriptor.Storage[uint32]{
+			Stages: core1_0.StageCompute,
+			Size:   cullPhases * maxCullGroups,
+			Usage:  core1_0.BufferUsageIndirectBuffer | core1_0.BufferUsageTransferDst,
+		},
+		Stats: &descriptor.Storage[uniform.CullStats]{
+			Stages: core1_0.StageCompute,
+			Size:   1,
+			Usage:  core1_0.BufferUsageTransferDst,
+		},
+		Pyramid: &descriptor.SamplerArray{
+			Stages: core1_0.StageCompute,
+			Count:  cullPhases * PyramidMaxLevels,
+		},
+	})
+	layout := pipeline.NewLayout(app.Device(), []descriptor.SetLayout{passLayout, descLayout}, []pipeline.PushConstant{
+		{
+			Stages: core1_0.StageCompute,
+			Type:   uniform.CullPhase{},
+		},
+	})
+	pipe := pipeline.NewCompute(app.Device(), pipeline.ComputeArgs{
+		Layout: layout,
+		Shader: app.Shaders().Fetch(shader.ComputeRef("pass/cull")),
+	})
+
+	cullFrames := make([]cullFrame, frames)
+	for i := range cullFrames {
+		cullFrames[i].groups = make([]cullGroup, 0, maxCullGroups)
+	}
+
+	return &DrawCuller{
+		name:        name,
+		pipeline:    pipe,
+		layout:      layout,
+		descLayout:  descLayout,
+		descriptors: descLayout.InstantiateMany(app.Pool(), frames),
+		draws:       make([]uniform.CullDraw, 0, maxDraws),
+		frames:      cullFrames,
+		maxDraws:    maxDraws,
+		stats:       stats,
+	}
+}
+
+// Prepare uploads the objects of a render plan for culling against the given view projection matrix.
+func (c *DrawCuller) Prepare(frame int, plan *RenderPlan, viewProj mat4.T, occlusion Occlusion) {
+	desc := c.descriptors[frame]
+	state := &c.frames[frame]
+
+	// the previous commands of this frame have completed, read back its counters
+	if state.submitted && c.stats != nil {
+		var counters uniform.CullStats
+		desc.Stats.Buffer().Read(0, &counters)
+		c.stats.SetCulling(c.name, engine.CullStats{
+			Objects:  int(counters.Objects),
+			Frustum:  int(counters.Frustum),
+			Occluded: int(counters.Occluded - counters.Recovered),
+			Overflow: len(state.spill),
+		})
+	}
+
+	c.batch(state, plan)
+
+	frustum := shape.FrustumFromMatrix(viewProj)
+	planes := [6]vec4.T{}
+	for i, plane := range []shape.Plane{frustum.Left, frustum.Right, frustum.Top, frustum.Bottom, frustum.Front, frustum.Back} {
+		planes[i] = vec4.Extend(plane.Normal, plane.Distance)
+	}
+
+	params := uniform.Cull{
+		Planes:   planes,
+		ViewProj: viewProj,
+		Count:    uint32(len(c.draws)),
+		MaxDraws: uint32(c.maxDraws),
+	}
+
+	state.draws = len(c.draws)
+	state.occlusion = occlusion.Pyramid != nil && occlusion.Pyramid.Valid(occlusion.Frame)
+	state.retest = state.occlusion && occlusion.Retest
+	if state.occlusion {
+		pyramid := occlusion.Pyramid
+		levels := pyramid.Levels(occlusion.Frame)
+		params.Occlusion = 1
+		params.PrevViewProj = pyramid.ViewProj(occlusion.Frame)
+		params.PyramidSize = pyramid.Size()
+		params.Levels = uint32(len(levels))
+		desc.Pyramid.SetRange(0, levels)
+		if state.retest {
+			desc.Pyramid.SetRange(PyramidMaxLevels, pyramid.Levels(frame))
+		}
+	}
+
+	desc.Params.Set(params)
+	desc.Draws.SetRange(0, c.draws)
+	state.submitted = true
+}
+
+// batch splits the groups of a render plan into culled draws and overflow draws.
+// Groups are culled until the draw or group capacity is exhausted, the remaining objects overflow.
+func (c *DrawCuller) batch(state *cullFrame, plan *RenderPlan) {
+	c.draws = c.draws[:0]
+	state.groups = state.groups[:0]
+	state.overflow = state.overflow[:0]
+	state.spill = state.spill[:0]
+
+	offset := 0
+	for _, group := range plan.groups {
+		if len(group.Objects) == 0 {
+			continue
+		}
+
+		culled := 0
+		if len(state.groups) < maxCullGroups {
+			culled = min(len(group.Objects), c.maxDraws-offset)
+		}
+		if culled > 0 {
+			index := uint32(len(state.groups))
+			state.groups = append(state.groups, cullGroup{
+				Pipeline: group.Pipeline,
+				Offset:   offset,
+				Size:     culled,
+			})
+			for _, obj := range group.Objects[:culled] {
+				draw := obj.DrawIndirect()
+				c.draws = append(c.draws, uniform.CullDraw{
+					Bounds:    vec4.Extend(obj.Bounds.Center, obj.Bounds.Radius),
+					Object:    draw.InstanceOffset,
+					Vertices:  draw.VertexCount,
+					Instances: draw.InstanceCount,
+					Offset:    uint32(offset),
+					Group:     index,
+				})
+			}
+			offset += culled
+		}
+
+		if culled < len(group.Objects) {
+			state.overflow = append(state.overflow, cullGroup{
+				Pipeline: group.Pipeline,
+				Offset:   len(state.spill),
+				Size:     len(group.Objects) - culled,
+			})
+			for _, obj := range group.Objects[culled:] {
+				state.spill = append(state.spill, obj.DrawIndirect())
+			}
+		}
+	}
+}
+
+// Cull records the first culling phase. passDesc is the descriptor set of the geometry pass, containing the object buffer.
+// Must be recorded outside of a render pass, before Draw.
+func (c *DrawCuller) Cull(cmd *command.Buffer, frame int, passDesc descriptor.Set) {
+	desc := c.descriptors[frame]
+	state := &c.frames[frame]
+
+	// reset draw counters & statistics
+	cmd.CmdFillBuffer(desc.Counts.Buffer(), 0, desc.Counts.Buffer().Size(), 0)
+	cmd.CmdFillBuffer(desc.Stats.Buffer(), 0, desc.Stats.Buffer().Size(), 0)
+	cmd.CmdMemoryBarrier(
+		core1_0.PipelineStageTransfer, core1_0.PipelineStageComputeShader,
+		core1_0.AccessTransferWrite, core1_0.AccessShaderRead|core1_0.AccessShaderWrite)
+
+	if state.occlusion {
+		// the tested pyramid may have been built by a previous submission
+		cmd.CmdMemoryBarrier(
+			core1_0.PipelineStageColorAttachmentOutput, core1_0.PipelineStageComputeShader,
+			core1_0.AccessColorAttachmentWrite, core1_0.AccessShaderRead)
+	}
+
+	c.dispatch(cmd, frame, passDesc, 0)
+}
+
+// Retest records the second culling phase, which draws objects that were occluded in the first phase
+// but are visible in the depth pyramid of the current frame. Does nothing unless retesting was enabled in Prepare.
+// Must be recorded outside of a render pass, after the current depth pyramid has been built.
+func (c *DrawCuller) Retest(cmd *command.Buffer, frame int, passDesc descriptor.Set) {
+	if !c.frames[frame].retest {
+		return
+	}
+
+	// wait for the pyramid of the current frame
+	cmd.CmdMemoryBarrier(
+		core1_0.PipelineStageColorAttachmentOutput, core1_0.PipelineStageComputeShader,
+		core1_0.AccessColorAttachmentWrite, core1_0.AccessShaderRead)
+
+	c.dispatch(cmd, frame, passDesc, 1)
+}
+
+func (c *DrawCuller) dispatch(cmd *command.Buffer, frame int, passDesc descriptor.Set, phase int) {
+	desc := c.descriptors[frame]
+	state := &c.frames[frame]
+	if state.draws == 0 {
+		return
+	}
+
+	cmd.CmdBindComputePipeline(c.pipeline)
+	cmd.CmdBindComputeDescriptor(c.layout, 0, passDesc)
+	cmd.CmdBindComputeDescriptor(c.layout, 1, desc)
+	cmd.CmdPushComputeConstant(c.pipeline, 0, &uniform.CullPhase{
+		Phase: uint32(phase),
+	})
+	cmd.CmdDispatch((state.draws+cullGroupSize-1)/cullGroupSize, 1, 1)
+
+	// make the commands and counts available to indirect draws
+	cmd.CmdMemoryBarrier(
+		core1_0.PipelineStageComputeShader, core1_0.PipelineStageDrawIndirect,
+		core1_0.AccessShaderWrite, core1_0.AccessIndirectCommandRead)
+}
+
+// Draw records the compacted draw commands of each render group from the first culling phase,
+// followed by the objects that overflowed the culling buffers.
+// Must be recorded inside the render pass of the geometry pass, after its descriptors are bound.
+func (c *DrawCuller) Draw(cmd *command.Buffer, frame int) {
+	c.draw(cmd, frame, 0)
+
+	state := &c.frames[frame]
+	for _, group := range state.overflow {
+		group.Pipeline.Bind(cmd)
+		for _, draw := range state.spill[group.Offset : group.Offset+group.Size] {
+			cmd.CmdDraw(draw)
+		}
+	}
+}
+
+// DrawRetested records the draw commands of objects found visible by Retest.
+// Does nothing unless retesting was enabled in Prepare.
+func (c *DrawCuller) DrawRetested(cmd *command.Buffer, frame int) {
+	if !c.frames[frame].retest {
+		return
+	}
+	c.draw(cmd, frame, 1)
+}
+
+func (c *DrawCuller) draw(cmd *command.Buffer, frame, phase int) {
+	desc := c.descriptors[frame]
+	commands := desc.Commands.Buffer()
+	counts := desc.Counts.Buffer()
+	commandOffset := phase * c.maxDraws * drawStride
+	countOffset := phase * maxCullGroups * 4
+	for i, group := range c.frames[frame].groups {
+		group.Pipeline.Bind(cmd)
+		cmd.CmdDrawIndirectCount(commands, commandOffset+group.Offset*drawStride, counts, countOffset+i*4, group.Size, drawStride)
+	}
+}
+
+func (c *DrawCuller) Destroy() {
+	for _, desc := range c.descriptors {
+		desc.Destroy()
+	}
+	c.pipeline.Destroy()
+	c.layout.Destroy()
+	c.descLayout.Destroy()
+}
+
+// cullBounds returns the bounds used to cull an object on the GPU.
+// Instanced objects are never culled as a whole, since their instances are culled individually.
+func cullBounds(gpuMesh *cache.GpuMesh, instances int) shape.Sphere {
+	if instances > 0 {
+		return shape.Sphere{}
+	}
+	return gpuMesh.Bounds()
+}
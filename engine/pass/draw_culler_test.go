@@ -0,0 +1,59 @@
+package pass
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/render/material"
+)
+
+var _ = Describe("draw culler", func() {
+	var culler *DrawCuller
+	var state *cullFrame
+	var plan *RenderPlan
+
+	BeforeEach(func() {
+		culler = &DrawCuller{maxDraws: 4}
+		state = &cullFrame{}
+		plan = NewRenderPlan()
+	})
+
+	It("draws objects exceeding the culling capacity without culling", func() {
+		first := &cache.Pipeline{ID: 1}
+		second := &cache.Pipeline{ID: 2}
+		for i := 0; i < 3; i++ {
+			plan.Add(first, RenderObject{Handle: i, Indices: 3})
+		}
+		for i := 3; i < 6; i++ {
+			plan.Add(second, RenderObject{Handle: i, Indices: 3})
+		}
+
+		culler.batch(state, plan)
+		Expect(culler.draws).To(HaveLen(4))
+		Expect(state.groups).To(Equal([]cullGroup{
+			{Pipeline: first, Offset: 0, Size: 3},
+			{Pipeline: second, Offset: 3, Size: 1},
+		}))
+
+		Expect(state.overflow).To(Equal([]cullGroup{
+			{Pipeline: second, Offset: 0, Size: 2},
+		}))
+		Expect(state.spill).To(HaveLen(2))
+		Expect(state.spill[0].InstanceOffset).To(BeEquivalentTo(4))
+		Expect(state.spill[1].InstanceOffset).To(BeEquivalentTo(5))
+	})
+
+	It("overflows groups beyond the group capacity", func() {
+		culler.maxDraws = 2 * maxCullGroups
+		for i := 0; i <= maxCullGroups; i++ {
+			pipe := &cache.Pipeline{ID: material.ID(i)}
+			plan.Add(pipe, RenderObject{Handle: i, Indices: 3})
+		}
+
+		culler.batch(state, plan)
+		Expect(state.groups).To(HaveLen(maxCullGroups))
+		Expect(state.overflow).To(HaveLen(1))
+		Expect(state.spill).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,86 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/core/sky"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/ibl"
+)
+
+// EnvironmentLighting collects the ambient lighting sources of a scene.
+// An environment component takes precedence over the sky. Skyboxes provide image based lighting,
+// while the procedural sky contributes diffuse ambient light through spherical harmonics.
+type EnvironmentLighting struct {
+	envQuery *object.Query[*light.Environment]
+	skyQuery *object.Query[*sky.Sky]
+}
+
+func NewEnvironmentLighting() *EnvironmentLighting {
+	return &EnvironmentLighting{
+		envQuery: object.NewQuery[*light.Environment](),
+		skyQuery: object.NewQuery[*sky.Sky](),
+	}
+}
+
+// Apply writes the ambient lighting of the scene to the light settings.
+// Until all maps are loaded, or if they fail to load, the flat ambient light is used instead.
+func (e *EnvironmentLighting) Apply(settings *uniform.LightSettings, samplers cache.SamplerCache, scene object.Component) {
+	settings.EnvIrradiance = 0
+	settings.SkyAmbient = 0
+
+	if env, exists := e.envQuery.Reset().First(scene); exists {
+		if maps := env.Maps(); maps != nil && !maps.Failed() {
+			applyMaps(settings, samplers, maps, env.Intensity.Get())
+			return
+		}
+	}
+
+	skyComponent, exists := e.skyQuery.Reset().First(scene)
+	if !exists || !skyComponent.Ambient.Get() {
+		return
+	}
+	if maps := skyComponent.Skybox(); maps != nil && !maps.Failed() {
+		applyMaps(settings, samplers, maps, skyComponent.Intensity.Get())
+		return
+	}
+	if skyComponent.Mode.Get() == sky.Procedural {
+		sh := skyComponent.AmbientSH(skyComponent.Sun(scene))
+		intensity := skyComponent.Intensity.Get()
+		for i, coef := range sh {
+			settings.SkySH[i] = vec4.Extend(coef.Scaled(intensity), 0)
+		}
+		settings.SkyAmbient = 1
+	}
+}
+
+func applyMaps(settings *uniform.LightSettings, samplers cache.SamplerCache, maps *ibl.Environment, intensity float32) {
+	// fetch every map, so that all of them start loading
+	ready := true
+	fetch := func(ref assets.Texture) int32 {
+		handle, exists := samplers.TryFetch(ref)
+		if !exists {
+			ready = false
+			return 0
+		}
+		return int32(handle.ID)
+	}
+
+	irradiance := fetch(maps.Irradiance())
+	brdf := fetch(ibl.BRDF)
+	var specular [uniform.EnvironmentLevels]int32
+	for level := range specular {
+		specular[level] = fetch(maps.Specular(level))
+	}
+	if !ready {
+		return
+	}
+
+	settings.EnvIntensity = intensity
+	settings.EnvIrradiance = irradiance
+	settings.EnvBRDF = brdf
+	settings.EnvSpecular = specular
+}
@@ -0,0 +1,248 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/tonemap"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+type ExposureDescriptors struct {
+	descriptor.Set
+	Params   *descriptor.Uniform[uniform.Exposure]
+	Input    *descriptor.Sampler
+	Previous *descriptor.Sampler
+}
+
+// ExposurePass measures the average luminance of the HDR buffer using a histogram,
+// and adapts it over time towards the current frame. The result is a single texel holding
+// the adapted log2 luminance, which is used by the post process pass for automatic exposure.
+type ExposurePass struct {
+	app  engine.App
+	quad vertex.Mesh
+
+	// history is a ring of adapted values, one more than the number of frames.
+	// each frame reads the value written by the previous frame, and writes to the next slot.
+	history    *engine.RenderTarget
+	historyTex texture.Array
+	inputTex   texture.Array
+	current    int
+	valid      bool
+	reset      bool
+
+	pass  *renderpass.Renderpass
+	fbufs framebuffer.Array
+	desc  []*ExposureDescriptors
+
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*ExposureDescriptors]
+}
+
+var _ draw.Pass = &ExposurePass{}
+
+func NewExposurePass(app engine.App, input engine.Target) *ExposurePass {
+	var err error
+	p := &ExposurePass{
+		app:   app,
+		quad:  vertex.ScreenQuad("exposure-pass-quad"),
+		reset: true,
+	}
+
+	slots := input.Frames() + 1
+	p.history = engine.NewColorTarget(app.Device(), "exposure", core1_0.FormatR32SignedFloat, engine.TargetSize{
+		Width:  1,
+		Height: 1,
+		Frames: slots,
+		Scale:  1,
+	})
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Exposure",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.history.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For color attachment operations
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+			},
+			{
+				// For fragment shader reads of the previous value
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+		},
+	})
+
+	p.fbufs, err = framebuffer.NewArray(slots, app.Device(), "exposure", 1, 1, p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "Exposure", &ExposureDescriptors{
+		Params: &descriptor.Uniform[uniform.Exposure]{
+			Stages: core1_0.StageFragment,
+		},
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Previous: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/exposure")),
+		Pass:     p.pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), input.Frames())
+	p.inputTex = make(texture.Array, input.Frames())
+	for i := range p.inputTex {
+		p.inputTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("exposure-input-%d", i), input.Surfaces()[i], texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Input.Set(p.inputTex[i])
+	}
+	p.historyTex = make(texture.Array, slots)
+	for i := range p.historyTex {
+		p.historyTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("exposure-%d", i), p.history.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+	}
+
+	return p
+}
+
+// Output returns the texture holding the most recently adapted log2 luminance.
+// Until the first value has been computed, a blank texture is returned.
+func (p *ExposurePass) Output() *texture.Texture {
+	if !p.valid {
+		return p.app.Textures().Fetch(color.White)
+	}
+	return p.historyTex[p.current]
+}
+
+func (p *ExposurePass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	settings := args.Camera.Tonemap
+	if !settings.Auto {
+		// adapt instantly once auto exposure is enabled again
+		p.reset = true
+		return
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+
+	desc := p.desc[args.Frame]
+	previous := p.historyTex[p.current]
+	if p.reset {
+		// the previous slot has never been written.
+		// bind any valid texture, its value is ignored when resetting
+		previous = p.app.Textures().Fetch(color.White)
+	}
+	desc.Previous.Set(previous)
+	desc.Params.Set(uniform.Exposure{
+		MinEV: settings.MinEV,
+		MaxEV: settings.MaxEV,
+		Speed: settings.Speed,
+		Delta: args.Delta,
+		Low:   tonemap.HistogramLow,
+		High:  tonemap.HistogramHigh,
+		Reset: boolToInt(p.reset),
+	})
+
+	p.current = (p.current + 1) % len(p.fbufs)
+	p.reset = false
+	p.valid = true
+	fbuf := p.fbufs[p.current]
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, fbuf)
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *ExposurePass) Name() string {
+	return "Exposure"
+}
+
+func (p *ExposurePass) Destroy() {
+	for _, tex := range p.inputTex {
+		tex.Destroy()
+	}
+	for _, tex := range p.historyTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	p.fbufs.Destroy()
+	p.history.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
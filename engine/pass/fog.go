@@ -0,0 +1,52 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/core/effect"
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// SceneFog collects the fog settings of a scene.
+// The first directional light is used as the sun for in-scattering.
+type SceneFog struct {
+	fogQuery *object.Query[*effect.Fog]
+	sunQuery *object.Query[*light.Directional]
+}
+
+func NewSceneFog() *SceneFog {
+	return &SceneFog{
+		fogQuery: object.NewQuery[*effect.Fog](),
+		sunQuery: object.NewQuery[*light.Directional](),
+	}
+}
+
+// Apply writes the fog of the scene to the light settings. Fog is disabled if the scene has none.
+func (f *SceneFog) Apply(settings *uniform.LightSettings, scene object.Component) {
+	settings.FogEnabled = 0
+	settings.FogSun = vec4.Zero
+
+	fog, exists := f.fogQuery.Reset().First(scene)
+	if !exists {
+		return
+	}
+
+	settings.FogEnabled = 1
+	settings.FogColor = fog.Color.Get()
+	settings.FogDensity = fog.Density.Get()
+	settings.FogHeight = fog.Height.Get()
+	settings.FogFalloff = fog.HeightFalloff.Get()
+	settings.FogStart = fog.Start.Get()
+
+	scattering := fog.Scattering.Get()
+	if scattering <= 0 {
+		return
+	}
+	if sun, exists := f.sunQuery.Reset().First(scene); exists {
+		towards := sun.Transform().Forward().Scaled(-1).Normalized()
+		settings.FogSun = vec4.Extend(towards, scattering)
+		settings.FogSunColor = color.FromVec3(sun.Color.Get().Vec3().Scaled(sun.Intensity.Get()))
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/engine"
 	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/cluster"
 	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/shape"
 	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
@@ -24,10 +26,12 @@ import (
 
 type ForwardDescriptors struct {
 	descriptor.Set
-	Camera   *descriptor.Uniform[uniform.Camera]
-	Objects  *descriptor.Storage[uniform.Object]
-	Lights   *descriptor.Storage[uniform.Light]
-	Textures *descriptor.SamplerArray
+	Camera        *descriptor.Uniform[uniform.Camera]
+	Objects       *descriptor.Storage[uniform.Object]
+	Lights        *descriptor.Storage[uniform.Light]
+	Clusters      *descriptor.Storage[uniform.Cluster]
+	ClusterLights *descriptor.Storage[uniform.ClusterLights]
+	Textures      *descriptor.SamplerArray
 }
 
 type ForwardPass struct {
@@ -40,15 +44,24 @@ type ForwardPass struct {
 	descriptors []*ForwardDescriptors
 	textures    cache.SamplerCache
 	objects     *uniform.ObjectBuffer
+	instances   *InstanceBuffer
+	levels      []MeshLevel
 	lights      *uniform.LightBuffer
+	clusters    *cluster.Grid
 	shadows     *ShadowCache
 	plan        *RenderPlan
+	transparent *RenderPlan
+	culler      *DrawCuller
 	commands    []*command.IndirectDrawBuffer
 
-	meshes     cache.MeshCache
-	pipelines  cache.PipelineCache
-	meshQuery  *object.Query[mesh.Mesh]
-	lightQuery *object.Query[light.T]
+	meshes      cache.MeshCache
+	pipelines   cache.PipelineCache
+	meshQuery   *object.Query[mesh.Mesh]
+	lightQuery  *object.Query[light.T]
+	environment *EnvironmentLighting
+	fog         *SceneFog
+	water       *WaterRenderer
+	settings    *engine.RenderSettings
 }
 
 var _ draw.Pass = &ForwardPass{}
@@ -58,6 +71,7 @@ func NewForwardPass(
 	target engine.Target,
 	depth engine.Target,
 	shadowPass *Shadowpass,
+	settings *engine.RenderSettings,
 ) *ForwardPass {
 	// todo: arguments/settings
 	maxLights := 256
@@ -102,6 +116,8 @@ func NewForwardPass(
 		panic(err)
 	}
 
+	clusters := cluster.New(cluster.DefaultArgs())
+
 	// todo: these could probably be global descriptors
 	// pass descriptor layout
 	descLayout := descriptor.NewLayout(app.Device(), "Forward", &ForwardDescriptors{
@@ -116,6 +132,14 @@ func NewForwardPass(
 			Stages: core1_0.StageAll,
 			Size:   maxLights,
 		},
+		Clusters: &descriptor.Storage[uniform.Cluster]{
+			Stages: core1_0.StageFragment,
+			Size:   clusters.Size(),
+		},
+		ClusterLights: &descriptor.Storage[uniform.ClusterLights]{
+			Stages: core1_0.StageFragment,
+			Size:   clusters.Capacity(),
+		},
 		Textures: &descriptor.SamplerArray{
 			Stages: core1_0.StageFragment,
 			Count:  maxTextures,
@@ -144,16 +168,24 @@ func NewForwardPass(
 		descLayout:  descLayout,
 		descriptors: descriptors,
 		objects:     objects,
+		instances:   NewInstanceBuffer(app.Device(), "forward", target.Frames(), maxInstances),
 		lights:      lights,
+		clusters:    clusters,
 		textures:    textures,
 		shadows:     shadows,
+		settings:    settings,
 		commands:    commands,
+		culler:      NewDrawCuller(app, "Forward", descLayout, target.Frames(), objects.Size(), nil),
 		plan:        NewRenderPlan(),
-
-		pipelines:  pipelines,
-		meshes:     app.Meshes(),
-		meshQuery:  object.NewQuery[mesh.Mesh](),
-		lightQuery: object.NewQuery[light.T](),
+		transparent: NewRenderPlan(),
+
+		pipelines:   pipelines,
+		meshes:      app.Meshes(),
+		meshQuery:   object.NewQuery[mesh.Mesh](),
+		lightQuery:  object.NewQuery[light.T](),
+		environment: NewEnvironmentLighting(),
+		fog:         NewSceneFog(),
+		water:       NewWaterRenderer(app, pass, descLayout, target, depth),
 	}
 }
 
@@ -218,8 +250,23 @@ func (p *ForwardPass) Record(cmds command.Recorder, args draw.Args, scene object
 		p.lights.Store(lit.LightData(p.shadows))
 	}
 
+	// bin lights into view space clusters
+	p.clusters.Update(args.Camera.Proj, args.Camera.Near, args.Camera.Far)
+	p.clusters.Assign(args.Camera.View, p.lights.Lights())
+	p.clusters.Settings(p.lights.Settings())
+
+	// environment & sky ambient lighting
+	p.environment.Apply(p.lights.Settings(), p.textures, scene)
+
+	// atmospheric fog
+	p.fog.Apply(p.lights.Settings(), scene)
+
+	p.lights.Settings().ShadowFilter = int32(p.settings.Shadows)
+
 	// clear object buffer
 	p.objects.Reset()
+	p.instances.Reset(args.Frame)
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
 
 	// opaque pass
 	opaqueQuery := p.meshQuery.
@@ -228,8 +275,9 @@ func (p *ForwardPass) Record(cmds command.Recorder, args draw.Args, scene object
 		Where(isTransparent(false)).
 		Collect(scene)
 
-	// clear render plan
+	// clear render plans
 	p.plan.Clear()
+	p.transparent.Clear()
 
 	for _, meshObject := range opaqueQuery {
 		mesh, pipeline, ready := p.fetch(meshObject)
@@ -240,19 +288,31 @@ func (p *ForwardPass) Record(cmds command.Recorder, args draw.Args, scene object
 		// this could happen inside the mesh cache!
 		// basically *GpuMesh could be the entire uniform object
 		// or even the entire object buffer similar to the sampler cache?
-		textureIds := AssignMeshTextures(p.textures, meshObject, pipeline.Slots)
+		instances, instanceCount, visible := p.instances.StoreMesh(meshObject, mesh, &frustum)
+		if !visible {
+			continue
+		}
 
-		objectId := p.objects.Store(uniform.Object{
-			Model:    meshObject.Transform().Matrix(),
-			Textures: textureIds,
-			Vertices: mesh.Vertices.Address(),
-			Indices:  mesh.Indices.Address(),
-		})
+		textureIds := AssignMeshTextures(p.textures, meshObject, pipeline.Slots)
 
-		p.plan.Add(pipeline, RenderObject{
-			Handle:  objectId,
-			Indices: mesh.IndexCount,
-		})
+		p.levels = SelectLevels(p.meshes, meshObject, mesh, args.Camera, true, p.levels)
+		for _, level := range p.levels {
+			objectId := p.objects.Store(uniform.Object{
+				Model:     meshObject.Transform().Matrix(),
+				Textures:  textureIds,
+				Vertices:  level.Mesh.Vertices.Address(),
+				Indices:   level.Mesh.Indices.Address(),
+				Instances: instances,
+				Dither:    level.Dither,
+			})
+
+			p.plan.Add(pipeline, RenderObject{
+				Handle:    objectId,
+				Indices:   level.Mesh.IndexCount,
+				Instances: instanceCount,
+				Bounds:    cullBounds(level.Mesh, instanceCount),
+			})
+		}
 	}
 
 	// transparent pass
@@ -266,35 +326,65 @@ func (p *ForwardPass) Record(cmds command.Recorder, args draw.Args, scene object
 	transparentObjects := p.depthSort(transparentQuery, args.Camera.Position)
 
 	for _, t := range transparentObjects {
-		textureIds := AssignMeshTextures(p.textures, t.Mesh, t.Pipeline.Slots)
+		// instances of transparent meshes are drawn in the order they were added
+		instances, instanceCount, visible := p.instances.StoreMesh(t.Mesh, t.GpuMesh, &frustum)
+		if !visible {
+			continue
+		}
 
-		objectId := p.objects.Store(uniform.Object{
-			Model:    t.Mesh.Transform().Matrix(),
-			Textures: textureIds,
-			Vertices: t.GpuMesh.Vertices.Address(),
-			Indices:  t.GpuMesh.Indices.Address(),
-		})
+		textureIds := AssignMeshTextures(p.textures, t.Mesh, t.Pipeline.Slots)
 
-		p.plan.AddOrdered(t.Pipeline, RenderObject{
-			Handle:  objectId,
-			Indices: t.GpuMesh.IndexCount,
-		})
+		p.levels = SelectLevels(p.meshes, t.Mesh, t.GpuMesh, args.Camera, true, p.levels)
+		for _, level := range p.levels {
+			objectId := p.objects.Store(uniform.Object{
+				Model:     t.Mesh.Transform().Matrix(),
+				Textures:  textureIds,
+				Vertices:  level.Mesh.Vertices.Address(),
+				Indices:   level.Mesh.Indices.Address(),
+				Instances: instances,
+				Dither:    level.Dither,
+			})
+
+			p.transparent.AddOrdered(t.Pipeline, RenderObject{
+				Handle:    objectId,
+				Indices:   level.Mesh.IndexCount,
+				Instances: instanceCount,
+			})
+		}
 	}
 
+	// water surfaces
+	water := p.water.Prepare(args, scene, &frustum)
+
 	// flush descriptors
 	p.lights.Flush(descriptors.Lights)
+	p.clusters.Flush(descriptors.Clusters, descriptors.ClusterLights)
 	p.objects.Flush(descriptors.Objects)
+	p.instances.Flush()
 	p.textures.Flush(descriptors.Textures)
 
 	//
 	// phase 2: record commands
 	//
 
+	// opaque objects are culled on the gpu.
+	// transparent objects must be drawn in depth order, and are drawn as-is
+	p.culler.Prepare(args.Frame, p.plan, args.Camera.ViewProj, Occlusion{})
+
 	cmds.Record(func(cmd *command.Buffer) {
-		indirect.Reset()
+		p.culler.Cull(cmd, args.Frame, descriptors)
 		cmd.CmdBeginRenderPass(p.pass, framebuf)
 		cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
-		p.plan.Draw(cmd, indirect)
+		p.culler.Draw(cmd, args.Frame)
+		if water {
+			// water samples the opaque scene behind it, which is copied outside of the render pass
+			cmd.CmdEndRenderPass()
+			p.water.CopyScene(cmd, args.Frame)
+			cmd.CmdBeginRenderPass(p.pass, framebuf)
+			p.water.Draw(cmd, args.Frame, descriptors)
+			cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
+		}
+		p.transparent.Draw(cmd, indirect)
 		cmd.CmdEndRenderPass()
 	})
 }
@@ -304,7 +394,9 @@ func (p *ForwardPass) Name() string {
 }
 
 func (p *ForwardPass) Destroy() {
+	p.water.Destroy()
 	p.textures.Destroy()
+	p.instances.Destroy()
 	p.fbuf.Destroy()
 	p.pass.Destroy()
 	for _, desc := range p.descriptors {
@@ -313,6 +405,7 @@ func (p *ForwardPass) Destroy() {
 	for _, commands := range p.commands {
 		commands.Destroy()
 	}
+	p.culler.Destroy()
 	p.layout.Destroy()
 	p.descLayout.Destroy()
 	p.pipelines.Destroy()
@@ -0,0 +1,177 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+type FXAADescriptors struct {
+	descriptor.Set
+	Params *descriptor.Uniform[uniform.FXAA]
+	Input  *descriptor.Sampler
+}
+
+// FXAAPass writes the final anti-aliased image to its target.
+// Applies fast approximate anti-aliasing to the input when enabled in the render settings.
+// When temporal anti-aliasing is active, the resolved TAA output is used as input instead.
+type FXAAPass struct {
+	app      engine.App
+	settings *engine.RenderSettings
+	temporal *TemporalAAPass
+	quad     vertex.Mesh
+
+	inputTex texture.Array
+	pass     *renderpass.Renderpass
+	fbufs    framebuffer.Array
+	desc     []*FXAADescriptors
+
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*FXAADescriptors]
+}
+
+var _ draw.Pass = &FXAAPass{}
+
+func NewFXAAPass(app engine.App, target engine.Target, input engine.Target, temporal *TemporalAAPass, settings *engine.RenderSettings) *FXAAPass {
+	var err error
+	p := &FXAAPass{
+		app:      app,
+		settings: settings,
+		temporal: temporal,
+		quad:     vertex.ScreenQuad("fxaa-pass-quad"),
+	}
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "FXAA",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(target.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For color attachment operations
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+			},
+			{
+				// For fragment shader reads
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+		},
+	})
+
+	p.fbufs, err = framebuffer.NewArray(target.Frames(), app.Device(), "fxaa", target.Width(), target.Height(), p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "FXAA", &FXAADescriptors{
+		Params: &descriptor.Uniform[uniform.FXAA]{
+			Stages: core1_0.StageFragment,
+		},
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/fxaa")),
+		Pass:     p.pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), input.Frames())
+	p.inputTex = make(texture.Array, input.Frames())
+	for i := range p.inputTex {
+		p.inputTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("fxaa-input-%d", i), input.Surfaces()[i], texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+	}
+
+	return p
+}
+
+func (p *FXAAPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+
+	desc := p.desc[args.Frame]
+	input := p.inputTex[args.Frame]
+	if p.temporal != nil && p.temporal.Active() {
+		input = p.temporal.Output()
+	}
+	desc.Input.Set(input)
+	desc.Params.Set(uniform.FXAA{
+		Enabled: boolToInt(p.settings.AntiAliasing == engine.AntiAliasingFXAA),
+	})
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *FXAAPass) Name() string {
+	return "FXAA"
+}
+
+func (p *FXAAPass) Destroy() {
+	for _, tex := range p.inputTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	p.fbufs.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
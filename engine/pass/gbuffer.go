@@ -16,6 +16,10 @@ type GeometryBuffer interface {
 	Diffuse() image.Array
 	Normal() image.Array
 	Position() image.Array
+
+	// Velocity holds screen space motion vectors caused by object movement
+	Velocity() image.Array
+
 	Destroy()
 }
 
@@ -23,6 +27,7 @@ type gbuffer struct {
 	diffuse  image.Array
 	normal   image.Array
 	position image.Array
+	velocity image.Array
 	width    int
 	height   int
 }
@@ -32,12 +37,14 @@ func NewGbuffer(device *device.Device, size engine.TargetSize) (GeometryBuffer,
 	diffuseFmt := core1_0.FormatR8G8B8A8UnsignedNormalized
 	normalFmt := core1_0.FormatR8G8B8A8UnsignedNormalized
 	positionFmt := core1_0.FormatR32G32B32A32SignedFloat
+	velocityFmt := core1_0.FormatR16G16SignedFloat
 	usage := core1_0.ImageUsageSampled | core1_0.ImageUsageColorAttachment | core1_0.ImageUsageInputAttachment
 
 	var err error
 	diffuses := make(image.Array, frames)
 	normals := make(image.Array, frames)
 	positions := make(image.Array, frames)
+	velocities := make(image.Array, frames)
 
 	for i := 0; i < frames; i++ {
 		diffuses[i], err = image.New2D(device, "diffuse", width, height, diffuseFmt, false, usage)
@@ -54,12 +61,18 @@ func NewGbuffer(device *device.Device, size engine.TargetSize) (GeometryBuffer,
 		if err != nil {
 			return nil, err
 		}
+
+		velocities[i], err = image.New2D(device, "velocity", width, height, velocityFmt, false, usage)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &gbuffer{
 		diffuse:  diffuses,
 		normal:   normals,
 		position: positions,
+		velocity: velocities,
 		width:    width,
 		height:   height,
 	}, nil
@@ -71,6 +84,7 @@ func (b *gbuffer) Frames() int           { return len(b.diffuse) }
 func (b *gbuffer) Diffuse() image.Array  { return b.diffuse }
 func (b *gbuffer) Normal() image.Array   { return b.normal }
 func (b *gbuffer) Position() image.Array { return b.position }
+func (b *gbuffer) Velocity() image.Array { return b.velocity }
 
 func (b *gbuffer) pixelOffset(pos vec2.T, img *image.Image, size int) int {
 	denormPos := pos.Mul(img.Size().XY())
@@ -92,4 +106,9 @@ func (p *gbuffer) Destroy() {
 		img.Destroy()
 	}
 	p.position = nil
+
+	for _, img := range p.velocity {
+		img.Destroy()
+	}
+	p.velocity = nil
 }
@@ -0,0 +1,121 @@
+package pass
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/render/buffer"
+	"github.com/johanhenriksson/goworld/render/device"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// maximum number of instances drawn by a pass each frame
+const maxInstances = 32768
+
+// instances are tightly packed, matching the buffer reference layout in the shaders
+const instanceStride = int(unsafe.Sizeof(uniform.Instance{}))
+
+// InstanceBuffer collects the per-instance data of instanced meshes.
+// Each frame has its own buffer, which is referenced by address from the object buffer.
+type InstanceBuffer struct {
+	buffers  []buffer.T
+	data     []uniform.Instance
+	capacity int
+	frame    int
+}
+
+func NewInstanceBuffer(dev *device.Device, key string, frames, capacity int) *InstanceBuffer {
+	buffers := make([]buffer.T, frames)
+	for i := range buffers {
+		buffers[i] = buffer.New(dev, buffer.Args{
+			Key:    fmt.Sprintf("%s-instances-%d", key, i),
+			Size:   capacity * instanceStride,
+			Usage:  core1_0.BufferUsageStorageBuffer,
+			Memory: device.MemoryTypeShared,
+		})
+	}
+	return &InstanceBuffer{
+		buffers:  buffers,
+		data:     make([]uniform.Instance, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// Reset clears the instance data, and selects the buffer of the given frame
+func (b *InstanceBuffer) Reset(frame int) {
+	b.frame = frame
+	b.data = b.data[:0]
+}
+
+// Store the instances of a mesh with the given model matrix.
+// If a frustum is provided, instances with bounds outside of it are culled.
+// Returns the address of the first stored instance and the number of visible instances.
+func (b *InstanceBuffer) Store(model mat4.T, instances []mesh.Instance, bounds shape.Sphere, frustum *shape.Frustum) (device.Address, int) {
+	first := len(b.data)
+	for _, instance := range instances {
+		if len(b.data) >= b.capacity {
+			break
+		}
+		if frustum != nil && !instanceVisible(model, instance.Transform, bounds, frustum) {
+			continue
+		}
+		b.data = append(b.data, uniform.Instance{
+			Model: instance.Transform,
+			Color: instance.Color,
+		})
+	}
+	count := len(b.data) - first
+	address := b.buffers[b.frame].Address() + device.Address(first*instanceStride)
+	return address, count
+}
+
+// Flush writes the instance data to the buffer of the current frame
+func (b *InstanceBuffer) Flush() {
+	buf := b.buffers[b.frame]
+	for i := range b.data {
+		buf.Write(i*instanceStride, &b.data[i])
+	}
+	buf.Flush()
+}
+
+func (b *InstanceBuffer) Destroy() {
+	for _, buf := range b.buffers {
+		buf.Destroy()
+	}
+	b.buffers = nil
+}
+
+// instanceVisible tests the bounding sphere of an instance against a frustum
+func instanceVisible(model, transform mat4.T, bounds shape.Sphere, frustum *shape.Frustum) bool {
+	world := model.Mul(&transform)
+	sphere := shape.Sphere{
+		Center: world.TransformPoint(bounds.Center),
+		Radius: bounds.Radius * maxScale(world),
+	}
+	return frustum.IntersectsSphere(&sphere)
+}
+
+// maxScale returns the largest scale factor along the axes of a transform
+func maxScale(m mat4.T) float32 {
+	x, y, z, _ := m.Cols()
+	return math.Sqrt(math.Max(x.XYZ().LengthSqr(), math.Max(y.XYZ().LengthSqr(), z.XYZ().LengthSqr())))
+}
+
+// StoreMesh stores the instances of a mesh, if it is instanced.
+// Returns the address and number of visible instances, or zero for meshes that are not instanced.
+// Returns false if every instance of an instanced mesh was culled, or if it has no instances.
+func (b *InstanceBuffer) StoreMesh(m mesh.Mesh, gpuMesh *cache.GpuMesh, frustum *shape.Frustum) (device.Address, int, bool) {
+	instanced, isInstanced := m.(mesh.Instancer)
+	if !isInstanced {
+		return device.InvalidAddress, 0, true
+	}
+	address, count := b.Store(m.Transform().Matrix(), instanced.Instances(), gpuMesh.Bounds(), frustum)
+	return address, count, count > 0
+}
@@ -0,0 +1,65 @@
+package pass
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/buffer"
+	"github.com/johanhenriksson/goworld/render/device"
+)
+
+// hostBuffer is a buffer without device memory, for tests that only compute addresses
+type hostBuffer struct {
+	buffer.T
+}
+
+func (hostBuffer) Address() device.Address { return 0 }
+
+var _ = Describe("instance buffer", func() {
+	var instances *InstanceBuffer
+	var frustum shape.Frustum
+	bounds := shape.Sphere{Radius: 1}
+
+	BeforeEach(func() {
+		instances = &InstanceBuffer{
+			buffers:  []buffer.T{hostBuffer{}},
+			capacity: 16,
+		}
+		frustum = shape.FrustumFromMatrix(mat4.OrthographicRZ(-5, 5, -5, 5, -5, 5))
+	})
+
+	at := func(x float32) mesh.Instance {
+		return mesh.Instance{Transform: mat4.Translate(vec3.New(x, 0, 0))}
+	}
+
+	It("culls instances individually", func() {
+		_, count := instances.Store(mat4.Ident(), []mesh.Instance{at(0), at(20), at(5.5), at(-20)}, bounds, &frustum)
+		Expect(count).To(Equal(2))
+		Expect(instances.data[0].Model).To(Equal(at(0).Transform))
+		Expect(instances.data[1].Model).To(Equal(at(5.5).Transform))
+	})
+
+	It("culls instances in world space", func() {
+		model := mat4.Translate(vec3.New(20, 0, 0))
+		_, count := instances.Store(model, []mesh.Instance{at(0), at(-20)}, bounds, &frustum)
+		Expect(count).To(Equal(1))
+		Expect(instances.data[0].Model).To(Equal(at(-20).Transform))
+	})
+
+	It("culls instances using their scale", func() {
+		translation, scale := mat4.Translate(vec3.New(8, 0, 0)), mat4.Scale(vec3.New(4, 4, 4))
+		large := translation.Mul(&scale)
+		_, count := instances.Store(mat4.Ident(), []mesh.Instance{{Transform: large}, at(8)}, bounds, &frustum)
+		Expect(count).To(Equal(1))
+		Expect(instances.data[0].Model).To(Equal(large))
+	})
+
+	It("keeps every instance without a frustum", func() {
+		_, count := instances.Store(mat4.Ident(), []mesh.Instance{at(0), at(20)}, bounds, nil)
+		Expect(count).To(Equal(2))
+	})
+})
@@ -2,6 +2,7 @@ package pass
 
 import (
 	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cluster"
 	"github.com/johanhenriksson/goworld/engine/uniform"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
@@ -16,13 +17,15 @@ import (
 
 type LightDescriptors struct {
 	descriptor.Set
-	Camera    *descriptor.Uniform[uniform.Camera]
-	Lights    *descriptor.Storage[uniform.Light]
-	Diffuse   *descriptor.Sampler
-	Normal    *descriptor.Sampler
-	Position  *descriptor.Sampler
-	Occlusion *descriptor.Sampler
-	Shadow    *descriptor.SamplerArray
+	Camera        *descriptor.Uniform[uniform.Camera]
+	Lights        *descriptor.Storage[uniform.Light]
+	Diffuse       *descriptor.Sampler
+	Normal        *descriptor.Sampler
+	Position      *descriptor.Sampler
+	Occlusion     *descriptor.Sampler
+	Clusters      *descriptor.Storage[uniform.Cluster]
+	ClusterLights *descriptor.Storage[uniform.ClusterLights]
+	Shadow        *descriptor.SamplerArray
 }
 
 type LightShader struct {
@@ -37,7 +40,7 @@ type LightShader struct {
 	occlusionTex texture.Array
 }
 
-func NewLightShader(app engine.App, pass *renderpass.Renderpass, gbuffer GeometryBuffer, occlusion engine.Target) *LightShader {
+func NewLightShader(app engine.App, pass *renderpass.Renderpass, gbuffer GeometryBuffer, occlusion engine.Target, clusters *cluster.Grid) *LightShader {
 	dlayout := descriptor.NewLayout(app.Device(), "Lighting", &LightDescriptors{
 		Camera: &descriptor.Uniform[uniform.Camera]{
 			Stages: core1_0.StageFragment,
@@ -58,6 +61,14 @@ func NewLightShader(app engine.App, pass *renderpass.Renderpass, gbuffer Geometr
 		Occlusion: &descriptor.Sampler{
 			Stages: core1_0.StageFragment,
 		},
+		Clusters: &descriptor.Storage[uniform.Cluster]{
+			Stages: core1_0.StageFragment,
+			Size:   clusters.Size(),
+		},
+		ClusterLights: &descriptor.Storage[uniform.ClusterLights]{
+			Stages: core1_0.StageFragment,
+			Size:   clusters.Capacity(),
+		},
 		Shadow: &descriptor.SamplerArray{
 			Stages: core1_0.StageFragment,
 			Count:  256,
@@ -0,0 +1,74 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/engine/cache"
+)
+
+// MeshLevel is a level of detail of a mesh selected for drawing
+type MeshLevel struct {
+	Mesh *cache.GpuMesh
+
+	// Dither is the cross-fade factor of the level.
+	// Positive values discard a fraction of the pixels, negative values keep only the complementary fraction.
+	Dither float32
+}
+
+// SelectLevels returns the levels of detail to draw for a mesh, as seen by the given camera.
+// Meshes without levels of detail are always drawn using their base mesh.
+// During a cross-fade, two levels with complementary dither patterns are returned, unless crossFade is false.
+// Levels that are not yet loaded fall back to the base mesh, levels without a mesh are not drawn.
+func SelectLevels(meshes cache.MeshCache, m mesh.Mesh, base *cache.GpuMesh, camera draw.Camera, crossFade bool, out []MeshLevel) []MeshLevel {
+	out = out[:0]
+	lod, isLod := m.(mesh.LevelOfDetail)
+	if !isLod || len(lod.Levels()) == 0 {
+		return append(out, MeshLevel{Mesh: base})
+	}
+
+	model := m.Transform().Matrix()
+	bounds := base.Bounds()
+	bounds.Center = model.TransformPoint(bounds.Center)
+	bounds.Radius *= maxScale(model)
+	size := mesh.ProjectedSize(camera.Proj, camera.Position, bounds)
+
+	selection := lod.Select(size)
+	if selection.Level < 0 {
+		return out
+	}
+
+	levels := lod.Levels()
+	add := func(index int, dither float32) {
+		if levels[index].Mesh == nil {
+			return
+		}
+		gpuMesh, ready := meshes.TryFetch(levels[index].Mesh)
+		if !ready {
+			gpuMesh = base
+		}
+		out = append(out, MeshLevel{
+			Mesh:   gpuMesh,
+			Dither: dither,
+		})
+	}
+
+	if selection.Fade <= 0 {
+		add(selection.Level, 0)
+		return out
+	}
+	if !crossFade {
+		// switch at the middle of the transition instead
+		if selection.Fade > 0.5 && selection.Next >= 0 {
+			add(selection.Next, 0)
+		} else {
+			add(selection.Level, 0)
+		}
+		return out
+	}
+
+	add(selection.Level, selection.Fade)
+	if selection.Next >= 0 {
+		add(selection.Next, -selection.Fade)
+	}
+	return out
+}
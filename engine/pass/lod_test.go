@@ -0,0 +1,62 @@
+package pass
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/vertex"
+)
+
+// loadedMeshes is a mesh cache where every mesh is ready
+type loadedMeshes struct {
+	cache.MeshCache
+	meshes map[assets.Mesh]*cache.GpuMesh
+}
+
+func (c loadedMeshes) TryFetch(m assets.Mesh) (*cache.GpuMesh, bool) {
+	return c.meshes[m], true
+}
+
+var _ = Describe("level of detail selection", func() {
+	var meshes loadedMeshes
+	var base, detailed *cache.GpuMesh
+	var detailedMesh assets.Mesh
+	camera := draw.Camera{
+		Proj:     mat4.Perspective(45, 1, 0.1, 100),
+		Position: vec3.New(0, 0, 10),
+	}
+
+	BeforeEach(func() {
+		base = &cache.GpuMesh{}
+		detailed = &cache.GpuMesh{}
+		detailedMesh = vertex.ScreenQuad("lod-detailed")
+		meshes = loadedMeshes{meshes: map[assets.Mesh]*cache.GpuMesh{detailedMesh: detailed}}
+	})
+
+	It("draws groups without levels using the base mesh", func() {
+		group := mesh.NewLODGroup(object.NewPool(), nil)
+		levels := SelectLevels(meshes, group, base, camera, true, nil)
+		Expect(levels).To(Equal([]MeshLevel{{Mesh: base}}))
+	})
+
+	It("skips levels without a mesh", func() {
+		group := mesh.NewLODGroup(object.NewPool(), nil, mesh.LODLevel{})
+		levels := SelectLevels(meshes, group, base, camera, true, nil)
+		Expect(levels).To(BeEmpty())
+	})
+
+	It("draws the selected level", func() {
+		group := mesh.NewLODGroup(object.NewPool(), nil,
+			mesh.LODLevel{Mesh: detailedMesh, ScreenSize: 0},
+		)
+		levels := SelectLevels(meshes, group, base, camera, true, nil)
+		Expect(levels).To(Equal([]MeshLevel{{Mesh: detailed}}))
+	})
+})
@@ -0,0 +1,36 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/math/mat4"
+)
+
+// MotionHistory remembers the model matrices of the previous frame, used to compute per-object motion vectors.
+type MotionHistory struct {
+	prev map[object.Handle]mat4.T
+	next map[object.Handle]mat4.T
+}
+
+func NewMotionHistory() *MotionHistory {
+	return &MotionHistory{
+		prev: make(map[object.Handle]mat4.T),
+		next: make(map[object.Handle]mat4.T),
+	}
+}
+
+// Track records the current model matrix of an object and returns its model matrix from the previous frame.
+// Objects that were not drawn in the previous frame are assumed to be stationary.
+func (h *MotionHistory) Track(id object.Handle, model mat4.T) mat4.T {
+	h.next[id] = model
+	if prev, exists := h.prev[id]; exists {
+		return prev
+	}
+	return model
+}
+
+// Swap should be called once per frame after all objects have been tracked.
+// Objects that were not tracked during the frame are forgotten.
+func (h *MotionHistory) Swap() {
+	h.prev, h.next = h.next, h.prev
+	clear(h.next)
+}
@@ -0,0 +1,373 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/material"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// widest outline supported by the composite shader, in pixels
+const maxOutlineWidth = 8
+
+type OutlineDescriptors struct {
+	descriptor.Set
+	Mask *descriptor.Sampler
+}
+
+// OutlinePass draws colored outlines around groups of objects requested by a draw.OutlineSource in the scene,
+// such as the editor selection and hover highlight.
+//
+// The meshes of each group are drawn into a mask, storing the index of the group.
+// A full screen pass then draws the outline color on pixels outside of the mask that are close to a masked pixel.
+// Outlines are drawn without depth testing, so occluded parts of the objects are outlined as well.
+type OutlinePass struct {
+	app    engine.App
+	target engine.Target
+	quad   vertex.Mesh
+
+	// outline group mask
+	mask       *engine.RenderTarget
+	maskPass   *renderpass.Renderpass
+	maskFbufs  framebuffer.Array
+	maskLayout *pipeline.Layout
+	maskPipes  cache.PipelineCache
+	geomLayout *descriptor.Layout[*BasicDescriptors]
+	geometry   []*BasicDescriptors
+	objects    *uniform.ObjectBuffer
+	instances  *InstanceBuffer
+	plans      [draw.MaxOutlines]*RenderPlan
+	commands   [draw.MaxOutlines][]*command.IndirectDrawBuffer
+
+	// outline composition
+	pass       *renderpass.Renderpass
+	fbufs      framebuffer.Array
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*OutlineDescriptors]
+	desc       []*OutlineDescriptors
+	maskTex    texture.Array
+
+	meshes      cache.MeshCache
+	sourceQuery *object.Query[draw.OutlineSource]
+	meshQuery   *object.Query[mesh.Mesh]
+	drawn       map[mesh.Mesh]bool
+}
+
+var _ draw.Pass = &OutlinePass{}
+
+func NewOutlinePass(app engine.App, target engine.Target) *OutlinePass {
+	var err error
+	frames := target.Frames()
+	p := &OutlinePass{
+		app:    app,
+		target: target,
+		quad:   vertex.ScreenQuad("outline-pass-quad"),
+
+		meshes:      app.Meshes(),
+		sourceQuery: object.NewQuery[draw.OutlineSource](),
+		meshQuery:   object.NewQuery[mesh.Mesh](),
+		drawn:       make(map[mesh.Mesh]bool, 64),
+	}
+
+	dependencies := []renderpass.SubpassDependency{
+		{
+			// For color attachment operations
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+		},
+		{
+			// For fragment shader reads
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageFragmentShader,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessShaderRead,
+		},
+	}
+
+	//
+	// outline group mask
+	//
+
+	p.mask = engine.NewColorTarget(app.Device(), "outline-mask", core1_0.FormatR8UnsignedNormalized, target.Size())
+	p.maskPass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "OutlineMask",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.mask.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpClear,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Clear:       color.T{},
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.maskFbufs, err = framebuffer.NewArray(frames, app.Device(), "outline-mask", target.Width(), target.Height(), p.maskPass)
+	if err != nil {
+		panic(err)
+	}
+
+	maxObjects := 1000
+	p.geomLayout = descriptor.NewLayout(app.Device(), "OutlineMask", &BasicDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageAll,
+		},
+		Objects: &descriptor.Storage[uniform.Object]{
+			Stages: core1_0.StageAll,
+			Size:   maxObjects,
+		},
+	})
+	p.geometry = p.geomLayout.InstantiateMany(app.Pool(), frames)
+	p.maskLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.geomLayout}, []pipeline.PushConstant{
+		{
+			Stages: core1_0.StageFragment,
+			Type:   uniform.OutlineMask{},
+		},
+	})
+	p.maskPipes = cache.NewPipelineCache(app.Device(), app.Shaders(), p.maskPass, p.maskLayout)
+	p.objects = uniform.NewObjectBuffer(maxObjects)
+	p.instances = NewInstanceBuffer(app.Device(), "outline", frames, maxInstances)
+	for group := range p.plans {
+		p.plans[group] = NewRenderPlan()
+		p.commands[group] = make([]*command.IndirectDrawBuffer, frames)
+		for i := range p.commands[group] {
+			p.commands[group][i] = command.NewIndirectDrawBuffer(app.Device(), fmt.Sprintf("Outline%d", group), p.objects.Size())
+		}
+	}
+
+	//
+	// outline composition
+	//
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Outline",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:          OutputAttachment,
+				Image:         attachment.FromImageArray(target.Surfaces()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Blend:         attachment.BlendMix,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), "outline", target.Width(), target.Height(), p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "Outline", &OutlineDescriptors{
+		Mask: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, []pipeline.PushConstant{
+		{
+			Stages: core1_0.StageFragment,
+			Type:   uniform.Outline{},
+		},
+	})
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/outline")),
+		Pass:     p.pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), frames)
+	p.maskTex = make(texture.Array, frames)
+	for i := range p.maskTex {
+		p.maskTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("outline-mask-%d", i), p.mask.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Mask.Set(p.maskTex[i])
+	}
+
+	return p
+}
+
+func (p *OutlinePass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	source, exists := p.sourceQuery.Reset().First(scene)
+	if !exists {
+		return
+	}
+	outlines := source.Outlines()
+	if len(outlines) > draw.MaxOutlines {
+		outlines = outlines[:draw.MaxOutlines]
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+	pipe, pipeReady := p.maskPipes.TryFetch(material.OutlineMask())
+	if !pipeReady {
+		return
+	}
+
+	geometry := p.geometry[args.Frame]
+	geometry.Camera.Set(uniform.CameraFromArgs(args))
+
+	p.objects.Reset()
+	p.instances.Reset(args.Frame)
+	clear(p.drawn)
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
+
+	params := uniform.Outline{}
+	visible := false
+	// meshes in several groups are only drawn in the last one, which has the highest priority
+	for group := len(outlines) - 1; group >= 0; group-- {
+		outline := outlines[group]
+		plan := p.plans[group]
+		plan.Clear()
+
+		params.Colors[group] = outline.Color
+		params.Widths[group] = int32(min(max(outline.Width, 0), maxOutlineWidth))
+
+		meshes := p.meshQuery.
+			Reset().
+			Where(isDrawTriangles).
+			Collect(outline.Objects...)
+
+		for _, meshObject := range meshes {
+			if p.drawn[meshObject] {
+				continue
+			}
+			p.drawn[meshObject] = true
+
+			gpuMesh, meshReady := p.meshes.TryFetch(meshObject.Mesh())
+			if !meshReady || gpuMesh.IndexCount == 0 {
+				continue
+			}
+
+			instances, instanceCount, inView := p.instances.StoreMesh(meshObject, gpuMesh, &frustum)
+			if !inView {
+				continue
+			}
+
+			objectId := p.objects.Store(uniform.Object{
+				Model:     meshObject.Transform().Matrix(),
+				Vertices:  gpuMesh.Vertices.Address(),
+				Indices:   gpuMesh.Indices.Address(),
+				Instances: instances,
+			})
+			plan.Add(pipe, RenderObject{
+				Handle:    objectId,
+				Indices:   gpuMesh.IndexCount,
+				Instances: instanceCount,
+			})
+			visible = true
+		}
+	}
+	if !visible {
+		return
+	}
+
+	p.objects.Flush(geometry.Objects)
+	p.instances.Flush()
+
+	desc := p.desc[args.Frame]
+	groups := len(outlines)
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.maskPass, p.maskFbufs[args.Frame])
+		cmd.CmdBindGraphicsDescriptor(p.maskLayout, 0, geometry)
+		for group := 0; group < groups; group++ {
+			// push constants are kept when rebinding pipelines with the same layout
+			pipe.Bind(cmd)
+			cmd.CmdPushConstant(core1_0.StageFragment, 0, &uniform.OutlineMask{
+				Group: float32(group + 1),
+			})
+			p.plans[group].Draw(cmd, p.commands[group][args.Frame])
+		}
+		cmd.CmdEndRenderPass()
+
+		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		cmd.CmdPushConstant(core1_0.StageFragment, 0, &params)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *OutlinePass) Name() string {
+	return "Outline"
+}
+
+func (p *OutlinePass) Destroy() {
+	for _, tex := range p.maskTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	for _, desc := range p.geometry {
+		desc.Destroy()
+	}
+	for _, cmds := range p.commands {
+		for _, cmd := range cmds {
+			cmd.Destroy()
+		}
+	}
+	p.instances.Destroy()
+
+	p.maskPipes.Destroy()
+	p.maskFbufs.Destroy()
+	p.maskPass.Destroy()
+	p.maskLayout.Destroy()
+	p.geomLayout.Destroy()
+	p.mask.Destroy()
+
+	p.fbufs.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
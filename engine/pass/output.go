@@ -3,6 +3,7 @@ package pass
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/johanhenriksson/goworld/core/draw"
 	"github.com/johanhenriksson/goworld/core/object"
@@ -23,6 +24,7 @@ import (
 
 type OutputPass struct {
 	app    engine.App
+	name   string
 	source engine.Target
 
 	pipeline   *pipeline.Pipeline
@@ -43,23 +45,36 @@ type OutputDescriptors struct {
 	Output *descriptor.Sampler
 }
 
+// NewOutputPass copies the source image to the output surfaces, ready for presentation
 func NewOutputPass(app engine.App, target engine.Target, source engine.Target) *OutputPass {
-	log.Println("create output pass")
+	// clearing avoids displaying garbage on the very first frame
+	return newOutputPass(app, "Output", target, source, core1_0.AttachmentLoadOpClear, khr_swapchain.ImageLayoutPresentSrc)
+}
+
+// NewUpscalePass copies the source image to a larger target, which can be sampled by later passes
+func NewUpscalePass(app engine.App, target engine.Target, source engine.Target) *OutputPass {
+	return newOutputPass(app, "Upscale", target, source, core1_0.AttachmentLoadOpDontCare, core1_0.ImageLayoutShaderReadOnlyOptimal)
+}
+
+func newOutputPass(app engine.App, name string, target engine.Target, source engine.Target, load core1_0.AttachmentLoadOp, layout core1_0.ImageLayout) *OutputPass {
+	log.Println("create", strings.ToLower(name), "pass")
 	p := &OutputPass{
 		app:    app,
+		name:   name,
 		source: source,
 	}
 
 	p.quad = vertex.ScreenQuad("output-pass-quad")
 
 	p.pass = renderpass.New(app.Device(), renderpass.Args{
-		Name: "Output",
+		Name: name,
 		ColorAttachments: []attachment.Color{
 			{
 				Name:        OutputAttachment,
 				Image:       attachment.FromImageArray(target.Surfaces()),
-				LoadOp:      core1_0.AttachmentLoadOpClear, // clearing avoids displaying garbage on the very first frame
-				FinalLayout: khr_swapchain.ImageLayoutPresentSrc,
+				LoadOp:      load,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: layout,
 			},
 		},
 		Subpasses: []renderpass.Subpass{
@@ -111,17 +126,23 @@ func NewOutputPass(app engine.App, target engine.Target, source engine.Target) *
 
 	frames := target.Frames()
 	var err error
-	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), "output", target.Width(), target.Height(), p.pass)
+	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), strings.ToLower(name), target.Width(), target.Height(), p.pass)
 	if err != nil {
 		panic(err)
 	}
 
+	// the source is upscaled when the scene is rendered below the output resolution
+	filter := texture.FilterNearest
+	if source.Width() != target.Width() || source.Height() != target.Height() {
+		filter = texture.FilterLinear
+	}
+
 	p.desc = p.descLayout.InstantiateMany(app.Pool(), frames)
 	p.tex = make(texture.Array, frames)
 	for i := range p.tex {
-		key := fmt.Sprintf("gbuffer-output-%d", i)
+		key := fmt.Sprintf("%s-source-%d", strings.ToLower(name), i)
 		p.tex[i], err = texture.FromImage(app.Device(), key, p.source.Surfaces()[i], texture.Args{
-			Filter: texture.FilterNearest,
+			Filter: filter,
 			Wrap:   texture.WrapClamp,
 		})
 		if err != nil {
@@ -148,7 +169,7 @@ func (p *OutputPass) Record(cmds command.Recorder, args draw.Args, scene object.
 }
 
 func (p *OutputPass) Name() string {
-	return "Output"
+	return p.name
 }
 
 func (p *OutputPass) Destroy() {
@@ -0,0 +1,13 @@
+package pass
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPass(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "engine/pass")
+}
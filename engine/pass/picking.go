@@ -0,0 +1,289 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/render/buffer"
+	"github.com/johanhenriksson/goworld/render/color"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/material"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// maximum number of pick requests resolved each frame
+const maxPickRequests = 16
+
+// size of a picking buffer pixel, in bytes
+const pickPixelSize = 4
+
+type pickFrame struct {
+	requests   []draw.PickRequest
+	components []object.Component
+	results    *buffer.Buffer
+	submitted  bool
+}
+
+// PickingPass resolves pick requests queued by draw.Picker components in the scene.
+//
+// Meshes are drawn into an integer image, storing their object handle plus one for each pixel. Zero means empty.
+// The pixels under the requested positions are copied to a host visible buffer, which is read back the next
+// time the frame is recorded, once the GPU has finished with it. Nothing is drawn in frames without requests.
+type PickingPass struct {
+	app       engine.App
+	target    *engine.RenderTarget
+	depth     *engine.RenderTarget
+	pass      *renderpass.Renderpass
+	fbufs     framebuffer.Array
+	layout    *pipeline.Layout
+	pipelines cache.PipelineCache
+
+	descLayout  *descriptor.Layout[*BasicDescriptors]
+	descriptors []*BasicDescriptors
+	objects     *uniform.ObjectBuffer
+	instances   *InstanceBuffer
+	plan        *RenderPlan
+	commands    []*command.IndirectDrawBuffer
+	frames      []*pickFrame
+
+	meshes      cache.MeshCache
+	meshQuery   *object.Query[mesh.Mesh]
+	pickerQuery *object.Query[*draw.Picker]
+}
+
+var _ draw.Pass = &PickingPass{}
+
+func NewPickingPass(app engine.App, size engine.TargetSize) *PickingPass {
+	var err error
+	p := &PickingPass{
+		app:    app,
+		target: engine.NewColorTarget(app.Device(), "picking", core1_0.FormatR32UnsignedInt, size),
+		depth:  engine.NewDepthTarget(app.Device(), "picking-depth", size),
+
+		meshes:      app.Meshes(),
+		meshQuery:   object.NewQuery[mesh.Mesh](),
+		pickerQuery: object.NewQuery[*draw.Picker](),
+	}
+	frames := p.target.Frames()
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Picking",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.target.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpClear,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutTransferSrcOptimal,
+				Clear:       color.T{},
+			},
+		},
+		DepthAttachment: &attachment.Depth{
+			LoadOp:        core1_0.AttachmentLoadOpClear,
+			StencilLoadOp: core1_0.AttachmentLoadOpClear,
+			StoreOp:       core1_0.AttachmentStoreOpDontCare,
+			FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+			ClearDepth:    1,
+
+			Image: attachment.FromImageArray(p.depth.Surfaces()),
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:  MainSubpass,
+				Depth: true,
+
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// The previous copy must finish reading the picking image
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageTransfer,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessTransferRead,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite,
+			},
+			{
+				// Handles must be written before they are copied to the readback buffer
+				Src:           MainSubpass,
+				Dst:           renderpass.ExternalSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageTransfer,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessTransferRead,
+			},
+		},
+	})
+	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), "picking", size.Width, size.Height, p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	maxObjects := 1000
+	p.descLayout = descriptor.NewLayout(app.Device(), "Picking", &BasicDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageAll,
+		},
+		Objects: &descriptor.Storage[uniform.Object]{
+			Stages: core1_0.StageAll,
+			Size:   maxObjects,
+		},
+	})
+	p.descriptors = p.descLayout.InstantiateMany(app.Pool(), frames)
+	p.layout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, []pipeline.PushConstant{})
+	p.pipelines = cache.NewPipelineCache(app.Device(), app.Shaders(), p.pass, p.layout)
+	p.objects = uniform.NewObjectBuffer(maxObjects)
+	p.instances = NewInstanceBuffer(app.Device(), "picking", frames, maxInstances)
+	p.plan = NewRenderPlan()
+
+	p.commands = make([]*command.IndirectDrawBuffer, frames)
+	p.frames = make([]*pickFrame, frames)
+	for i := range p.frames {
+		p.commands[i] = command.NewIndirectDrawBuffer(app.Device(), "Picking", p.objects.Size())
+		p.frames[i] = &pickFrame{
+			requests:   make([]draw.PickRequest, 0, maxPickRequests),
+			components: make([]object.Component, 0, maxObjects),
+			results:    buffer.NewShared(app.Device(), fmt.Sprintf("picking-results-%d", i), maxPickRequests*pickPixelSize),
+		}
+	}
+
+	return p
+}
+
+func (p *PickingPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	frame := p.frames[args.Frame]
+
+	// the previous commands of this frame have completed, resolve its requests
+	if frame.submitted {
+		p.resolve(frame)
+	}
+
+	// requests are left in the queue until the pipeline is ready
+	pipe, pipeReady := p.pipelines.TryFetch(material.Picking())
+	if !pipeReady {
+		return
+	}
+
+	for _, picker := range p.pickerQuery.Reset().Collect(scene) {
+		frame.requests = append(frame.requests, picker.Take(maxPickRequests-len(frame.requests))...)
+	}
+	if len(frame.requests) == 0 {
+		return
+	}
+
+	descriptors := p.descriptors[args.Frame]
+	descriptors.Camera.Set(uniform.CameraFromArgs(args))
+
+	p.objects.Reset()
+	p.instances.Reset(args.Frame)
+	p.plan.Clear()
+	frame.components = frame.components[:0]
+	frustum := shape.FrustumFromMatrix(args.Camera.ViewProj)
+
+	meshes := p.meshQuery.
+		Reset().
+		Where(isDrawTriangles).
+		Collect(scene)
+	for _, meshObject := range meshes {
+		if len(frame.components) >= p.objects.Size() {
+			break
+		}
+
+		gpuMesh, meshReady := p.meshes.TryFetch(meshObject.Mesh())
+		if !meshReady || gpuMesh.IndexCount == 0 {
+			continue
+		}
+
+		instances, instanceCount, inView := p.instances.StoreMesh(meshObject, gpuMesh, &frustum)
+		if !inView {
+			continue
+		}
+
+		objectId := p.objects.Store(uniform.Object{
+			Model:     meshObject.Transform().Matrix(),
+			Vertices:  gpuMesh.Vertices.Address(),
+			Indices:   gpuMesh.Indices.Address(),
+			Instances: instances,
+		})
+		frame.components = append(frame.components, meshObject)
+		p.plan.Add(pipe, RenderObject{
+			Handle:    objectId,
+			Indices:   gpuMesh.IndexCount,
+			Instances: instanceCount,
+		})
+	}
+
+	p.objects.Flush(descriptors.Objects)
+	p.instances.Flush()
+
+	image := p.target.Surfaces()[args.Frame]
+	width, height := p.target.Width(), p.target.Height()
+	frame.submitted = true
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
+		cmd.CmdBindGraphicsDescriptor(p.layout, 0, descriptors)
+		p.plan.Draw(cmd, p.commands[args.Frame])
+		cmd.CmdEndRenderPass()
+
+		for i, req := range frame.requests {
+			x := min(max(int((req.Position.X*0.5+0.5)*float32(width)), 0), width-1)
+			y := min(max(int((req.Position.Y*0.5+0.5)*float32(height)), 0), height-1)
+			cmd.CmdCopyImageRegionToBuffer(image, core1_0.ImageLayoutTransferSrcOptimal, core1_0.ImageAspectColor,
+				x, y, 1, 1, frame.results, i*pickPixelSize)
+		}
+	})
+}
+
+// resolve reads back the handles of a completed frame and invokes the request callbacks
+func (p *PickingPass) resolve(frame *pickFrame) {
+	handles := make([]uint32, len(frame.requests))
+	frame.results.Read(0, handles)
+	for i, req := range frame.requests {
+		var picked object.Component
+		if handle := int(handles[i]); handle > 0 && handle <= len(frame.components) {
+			picked = frame.components[handle-1]
+		}
+		req.Callback(picked)
+	}
+	frame.requests = frame.requests[:0]
+	frame.submitted = false
+}
+
+func (p *PickingPass) Name() string {
+	return "Picking"
+}
+
+func (p *PickingPass) Destroy() {
+	for _, frame := range p.frames {
+		frame.results.Destroy()
+	}
+	for _, cmd := range p.commands {
+		cmd.Destroy()
+	}
+	for _, desc := range p.descriptors {
+		desc.Destroy()
+	}
+	p.instances.Destroy()
+	p.pipelines.Destroy()
+	p.fbufs.Destroy()
+	p.pass.Destroy()
+	p.layout.Destroy()
+	p.descLayout.Destroy()
+	p.depth.Destroy()
+	p.target.Destroy()
+}
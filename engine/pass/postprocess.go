@@ -7,6 +7,7 @@ import (
 	"github.com/johanhenriksson/goworld/core/draw"
 	"github.com/johanhenriksson/goworld/core/object"
 	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
 	"github.com/johanhenriksson/goworld/render/framebuffer"
@@ -22,8 +23,9 @@ import (
 type PostProcessPass struct {
 	LUT assets.Texture
 
-	app   engine.App
-	input engine.Target
+	app      engine.App
+	input    engine.Target
+	exposure *ExposurePass
 
 	pipeline   *pipeline.Pipeline
 	pipeLayout *pipeline.Layout
@@ -40,17 +42,20 @@ var _ draw.Pass = &PostProcessPass{}
 
 type PostProcessDescriptors struct {
 	descriptor.Set
-	Input *descriptor.Sampler
-	LUT   *descriptor.Sampler
+	Input    *descriptor.Sampler
+	LUT      *descriptor.Sampler
+	Settings *descriptor.Uniform[uniform.Tonemap]
+	Exposure *descriptor.Sampler
 }
 
-func NewPostProcessPass(app engine.App, target engine.Target, input engine.Target) *PostProcessPass {
+func NewPostProcessPass(app engine.App, target engine.Target, input engine.Target, exposure *ExposurePass) *PostProcessPass {
 	var err error
 	p := &PostProcessPass{
 		LUT: texture.PathRef("textures/color_grading/none.png"),
 
-		app:   app,
-		input: input,
+		app:      app,
+		input:    input,
+		exposure: exposure,
 	}
 
 	p.quad = vertex.ScreenQuad("blur-pass-quad")
@@ -102,6 +107,12 @@ func NewPostProcessPass(app engine.App, target engine.Target, input engine.Targe
 		LUT: &descriptor.Sampler{
 			Stages: core1_0.StageFragment,
 		},
+		Settings: &descriptor.Uniform[uniform.Tonemap]{
+			Stages: core1_0.StageFragment,
+		},
+		Exposure: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
 	})
 	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
 	p.pipeline = pipeline.New(
@@ -147,6 +158,15 @@ func (p *PostProcessPass) Record(cmds command.Recorder, args draw.Args, scene ob
 	desc := p.desc[args.Frame]
 	desc.LUT.Set(lutTex)
 
+	// tone mapping settings of the current camera
+	tonemap := args.Camera.Tonemap
+	desc.Settings.Set(uniform.Tonemap{
+		Operator:     int32(tonemap.Operator),
+		Auto:         boolToInt(tonemap.Auto),
+		Compensation: tonemap.Compensation,
+	})
+	desc.Exposure.Set(p.exposure.Output())
+
 	// todo: theres not much point recording this every frame
 	cmds.Record(func(cmd *command.Buffer) {
 		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
@@ -0,0 +1,380 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/mesh"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// HistoryAttachment is the name of the attachment capturing the lit color for the next frame
+const HistoryAttachment attachment.Name = "history"
+
+// reflection parameters of each quality preset
+var reflectionPresets = map[engine.ReflectionQuality]uniform.Reflections{
+	engine.ReflectionsLow: {
+		Steps:       16,
+		Refinements: 4,
+		MaxDistance: 15,
+		Thickness:   0.5,
+	},
+	engine.ReflectionsMedium: {
+		Steps:       32,
+		Refinements: 6,
+		MaxDistance: 30,
+		Thickness:   0.3,
+	},
+	engine.ReflectionsHigh: {
+		Steps:       64,
+		Refinements: 8,
+		MaxDistance: 60,
+		Thickness:   0.2,
+	},
+}
+
+// fraction of the screen over which reflections fade out towards the edges
+const reflectionEdgeFade = 0.1
+
+type ReflectionDescriptors struct {
+	descriptor.Set
+	Camera   *descriptor.Uniform[uniform.Camera]
+	Params   *descriptor.Uniform[uniform.Reflections]
+	Position *descriptor.Sampler
+	Normal   *descriptor.Sampler
+	Depth    *descriptor.Sampler
+	Color    *descriptor.Sampler
+	History  *descriptor.Sampler
+}
+
+type ReflectionCompositeDescriptors struct {
+	descriptor.Set
+	Reflection *descriptor.Sampler
+}
+
+// ReflectionPass adds screen space reflections to meshes in the reflective layer.
+//
+// Reflected rays are marched through the depth buffer, starting from the view space position and normal
+// of the geometry buffer. Hits are reprojected into the previous frame, and resolved against its lit color.
+// Reflections fade out towards the screen edges, with distance, and for rays facing the camera.
+// The result is mixed into the lit color by a fresnel weight, before post processing.
+//
+// The lit color of the current frame is captured into a history ring while tracing,
+// one image more than the number of frames, like the temporal anti-aliasing history.
+type ReflectionPass struct {
+	app      engine.App
+	settings *engine.RenderSettings
+	quad     vertex.Mesh
+
+	// reflection tracing
+	reflection  *engine.RenderTarget
+	history     *engine.RenderTarget
+	tracePass   *renderpass.Renderpass
+	traceFbufs  framebuffer.Array
+	tracePipe   *pipeline.Pipeline
+	traceLayout *pipeline.Layout
+	traceDesc   *descriptor.Layout[*ReflectionDescriptors]
+	desc        []*ReflectionDescriptors
+	textures    texture.Array
+	colorTex    texture.Array
+	historyTex  texture.Array
+	current     int
+	valid       bool
+
+	// composition
+	pass       *renderpass.Renderpass
+	fbufs      framebuffer.Array
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*ReflectionCompositeDescriptors]
+	composite  []*ReflectionCompositeDescriptors
+}
+
+var _ draw.Pass = &ReflectionPass{}
+
+func NewReflectionPass(app engine.App, target engine.Target, depth engine.Target, gbuffer GeometryBuffer, settings *engine.RenderSettings) *ReflectionPass {
+	var err error
+	p := &ReflectionPass{
+		app:      app,
+		settings: settings,
+		quad:     vertex.ScreenQuad("reflection-pass-quad"),
+	}
+	frames := target.Frames()
+
+	dependencies := []renderpass.SubpassDependency{
+		{
+			// For color attachment operations
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+		},
+		{
+			// For fragment shader reads
+			Src:           renderpass.ExternalSubpass,
+			Dst:           MainSubpass,
+			SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+			DstStageMask:  core1_0.PipelineStageFragmentShader,
+			SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+			DstAccessMask: core1_0.AccessShaderRead,
+		},
+	}
+
+	//
+	// reflection tracing
+	//
+
+	// reflections and history share the ring index, each slot is written by one framebuffer
+	size := target.Size()
+	size.Frames = frames + 1
+	p.reflection = engine.NewColorTarget(app.Device(), "reflection", core1_0.FormatR16G16B16A16SignedFloat, size)
+	p.history = engine.NewColorTarget(app.Device(), "reflection-history", target.SurfaceFormat(), size)
+
+	p.tracePass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "ReflectionTrace",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.reflection.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+			{
+				Name:        HistoryAttachment,
+				Image:       attachment.FromImageArray(p.history.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment, HistoryAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.traceFbufs, err = framebuffer.NewArray(size.Frames, app.Device(), "reflection-trace", size.Width, size.Height, p.tracePass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.traceDesc = descriptor.NewLayout(app.Device(), "Reflections", &ReflectionDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageFragment,
+		},
+		Params: &descriptor.Uniform[uniform.Reflections]{
+			Stages: core1_0.StageFragment,
+		},
+		Position: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Normal: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Depth: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Color: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		History: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.traceLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.traceDesc}, nil)
+	p.tracePipe = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.traceLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/ssr")),
+		Pass:     p.tracePass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.desc = p.traceDesc.InstantiateMany(app.Pool(), frames)
+	p.colorTex = make(texture.Array, frames)
+	for i := 0; i < frames; i++ {
+		p.desc[i].Position.Set(p.texture(fmt.Sprintf("reflection-position-%d", i), gbuffer.Position()[i], texture.Args{}))
+		p.desc[i].Normal.Set(p.texture(fmt.Sprintf("reflection-normal-%d", i), gbuffer.Normal()[i], texture.Args{}))
+		p.desc[i].Depth.Set(p.texture(fmt.Sprintf("reflection-depth-%d", i), depth.Surfaces()[i], texture.Args{
+			Aspect: core1_0.ImageAspectDepth,
+		}))
+		p.colorTex[i] = p.texture(fmt.Sprintf("reflection-color-%d", i), target.Surfaces()[i], texture.Args{})
+		p.desc[i].Color.Set(p.colorTex[i])
+	}
+
+	p.historyTex = make(texture.Array, size.Frames)
+	for i := range p.historyTex {
+		p.historyTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("reflection-history-%d", i), p.history.Surfaces()[i], texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+	}
+
+	//
+	// composition
+	//
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "Reflections",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:          OutputAttachment,
+				Image:         attachment.FromImageArray(target.Surfaces()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+				Blend:         attachment.BlendMix,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: dependencies,
+	})
+	p.fbufs, err = framebuffer.NewArray(frames, app.Device(), "reflections", target.Width(), target.Height(), p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "ReflectionComposite", &ReflectionCompositeDescriptors{
+		Reflection: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/ssr_composite")),
+		Pass:     p.pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	p.composite = p.descLayout.InstantiateMany(app.Pool(), size.Frames)
+	for i := range p.composite {
+		p.composite[i].Reflection.Set(p.texture(fmt.Sprintf("reflection-%d", i), p.reflection.Surfaces()[i], texture.Args{}))
+	}
+
+	return p
+}
+
+// texture creates a clamped, nearest filtered texture view of an image, owned by the pass
+func (p *ReflectionPass) texture(key string, img *image.Image, args texture.Args) *texture.Texture {
+	args.Filter = texture.FilterNearest
+	args.Wrap = texture.WrapClamp
+	tex, err := texture.FromImage(p.app.Device(), key, img, args)
+	if err != nil {
+		panic(err)
+	}
+	p.textures = append(p.textures, tex)
+	return tex
+}
+
+func (p *ReflectionPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	params, enabled := reflectionPresets[p.settings.Reflections]
+	if !enabled {
+		// the history is stale once reflections are enabled again
+		p.valid = false
+		return
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		p.valid = false
+		return
+	}
+
+	desc := p.desc[args.Frame]
+	reset := !p.valid
+	previous := p.historyTex[p.current]
+	if reset {
+		// the previous slot has not been written.
+		// bind the current color instead, its value is ignored when resetting
+		previous = p.colorTex[args.Frame]
+	}
+
+	params.EdgeFade = reflectionEdgeFade
+	params.Layers = uint32(mesh.LayerReflective)
+	params.Reset = boolToInt(reset)
+	desc.History.Set(previous)
+	desc.Camera.Set(uniform.CameraFromArgs(args))
+	desc.Params.Set(params)
+
+	p.current = (p.current + 1) % len(p.historyTex)
+	p.valid = true
+	traceFbuf := p.traceFbufs[p.current]
+	composite := p.composite[p.current]
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.tracePass, traceFbuf)
+		cmd.CmdBindGraphicsPipeline(p.tracePipe)
+		cmd.CmdBindGraphicsDescriptor(p.traceLayout, 0, desc)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+
+		cmd.CmdBeginRenderPass(p.pass, p.fbufs[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, composite)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *ReflectionPass) Name() string {
+	return "Reflections"
+}
+
+func (p *ReflectionPass) Destroy() {
+	for _, tex := range p.textures {
+		tex.Destroy()
+	}
+	for _, tex := range p.historyTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	for _, desc := range p.composite {
+		desc.Destroy()
+	}
+	p.traceFbufs.Destroy()
+	p.tracePass.Destroy()
+	p.tracePipe.Destroy()
+	p.traceLayout.Destroy()
+	p.traceDesc.Destroy()
+	p.reflection.Destroy()
+	p.history.Destroy()
+
+	p.fbufs.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
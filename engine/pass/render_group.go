@@ -2,6 +2,7 @@ package pass
 
 import (
 	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/math/shape"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/material"
 )
@@ -12,14 +13,23 @@ type RenderObject struct {
 
 	// Indices is the number of indices to render
 	Indices int
+
+	// Instances is the number of instances to render.
+	// Zero draws a single instance of an object that is not instanced.
+	Instances int
+
+	// Bounds is the bounding sphere of the mesh in model space, used for GPU culling.
+	// Objects with a zero radius are never culled.
+	Bounds shape.Sphere
 }
 
 // DrawIndirect returns a command.Draw object that can be used to render the object
 func (r RenderObject) DrawIndirect() command.Draw {
 	return command.Draw{
-		InstanceCount: 1,
+		InstanceCount: uint32(max(r.Instances, 1)),
 
-		// InstanceOffset is the index of the object properties in the object buffer
+		// InstanceOffset is the index of the object properties in the object buffer.
+		// All instances of an instanced object share the same object properties.
 		InstanceOffset: uint32(r.Handle),
 
 		// Vertex count is actually the number of indices, since indexing is implemented in the shader
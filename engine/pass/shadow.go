@@ -1,8 +1,8 @@
 package pass
 
 import (
-	"fmt"
 	"log"
+	"sort"
 
 	"github.com/johanhenriksson/goworld/core/draw"
 	"github.com/johanhenriksson/goworld/core/light"
@@ -11,6 +11,7 @@ import (
 	"github.com/johanhenriksson/goworld/engine"
 	"github.com/johanhenriksson/goworld/engine/cache"
 	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/render/command"
 	"github.com/johanhenriksson/goworld/render/descriptor"
 	"github.com/johanhenriksson/goworld/render/framebuffer"
@@ -23,17 +24,34 @@ import (
 	"github.com/vkngwrapper/core/v2/core1_0"
 )
 
-type ShadowmapLookupFn func(light.T, int) *texture.Texture
+// ShadowmapLookupFn returns the shadow atlas texture and the location of a shadow map of a light within it.
+// Returns false if the light did not have its shadows rendered this frame.
+type ShadowmapLookupFn func(light.T, int) (*texture.Texture, light.ShadowMap, bool)
 
+// Shadowpass renders the shadow maps of all shadow casting lights into a shared shadow atlas.
+//
+// Directional lights are allocated first, followed by the most important point lights.
+// Each light requests a resolution for its shadow maps, which is reduced if the atlas is full.
 type Shadowpass struct {
-	app    engine.App
-	target engine.Target
-	pass   *renderpass.Renderpass
-	size   int
+	// PointLightBudget is the maximum number of point lights that may cast shadows each frame.
+	// Only the most important shadow casting point lights are selected.
+	PointLightBudget int
+
+	app       engine.App
+	target    engine.Target
+	pass      *renderpass.Renderpass
+	size      int
+	pointSize int
+
+	atlas   *ShadowAtlas
+	frame   *framebuffer.Framebuffer
+	texture *texture.Texture
 
 	layout     *pipeline.Layout
 	descLayout *descriptor.Layout[*BasicDescriptors]
 	objects    *uniform.ObjectBuffer
+	instances  *InstanceBuffer
+	levels     []MeshLevel
 	plan       *RenderPlan
 	commands   []*command.IndirectDrawBuffer
 
@@ -57,19 +75,21 @@ func (s *Shadowmap) Destroy() {
 }
 
 type Cascade struct {
-	Texture     *texture.Texture
-	Frame       *framebuffer.Framebuffer
 	Descriptors []*BasicDescriptors
 }
 
 func (c *Cascade) Destroy() {
-	c.Texture.Destroy()
-	c.Frame.Destroy()
 	for _, desc := range c.Descriptors {
 		desc.Destroy()
 	}
 }
 
+// shadowDraw renders the shadow casters into a tile of the atlas
+type shadowDraw struct {
+	tile ShadowTile
+	desc *BasicDescriptors
+}
+
 func NewShadowPass(app engine.App, target engine.Target) *Shadowpass {
 	pass := renderpass.New(app.Device(), renderpass.Args{
 		Name: "Shadow",
@@ -137,16 +157,38 @@ func NewShadowPass(app engine.App, target engine.Target) *Shadowpass {
 		commands[i] = command.NewIndirectDrawBuffer(app.Device(), "Shadows", objects.Size())
 	}
 
+	// the frame buffer object will allocate the atlas depth image for us
+	atlas := NewShadowAtlas(8192)
+	frame, err := framebuffer.New(app.Device(), "shadow-atlas", atlas.Size(), atlas.Size(), pass)
+	if err != nil {
+		panic(err)
+	}
+	tex, err := texture.FromView(app.Device(), "shadow-atlas", frame.Attachment(attachment.DepthName), texture.Args{
+		Aspect: core1_0.ImageAspectDepth,
+		Wrap:   texture.WrapClamp,
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	return &Shadowpass{
+		PointLightBudget: 4,
+
 		app:        app,
 		target:     target,
 		pass:       pass,
 		shadowmaps: make(map[light.T]Shadowmap),
 		size:       2048,
+		pointSize:  512,
+
+		atlas:   atlas,
+		frame:   frame,
+		texture: tex,
 
 		layout:     layout,
 		descLayout: descLayout,
 		objects:    objects,
+		instances:  NewInstanceBuffer(app.Device(), "shadows", target.Frames(), maxInstances),
 		commands:   commands,
 		plan:       NewRenderPlan(),
 
@@ -161,38 +203,20 @@ func (p *Shadowpass) Name() string {
 	return "Shadow"
 }
 
-func (p *Shadowpass) createShadowmap(light light.T) Shadowmap {
-	log.Println("creating shadowmap for", light.Name())
+func (p *Shadowpass) createShadowmap(lit light.T) Shadowmap {
+	log.Println("creating shadowmap for", lit.Name())
 
-	cascades := make([]Cascade, light.Shadowmaps())
+	// each light cascade needs its own descriptors projection
+	// todo: share object descriptors between cascades
+	cascades := make([]Cascade, lit.Shadowmaps())
 	for i := range cascades {
-		key := fmt.Sprintf("%s-%d", object.Key("light", light), i)
-		fbuf, err := framebuffer.New(p.app.Device(), key, p.size, p.size, p.pass)
-		if err != nil {
-			panic(err)
-		}
-
-		// the frame buffer object will allocate a new depth image for us
-		view := fbuf.Attachment(attachment.DepthName)
-		tex, err := texture.FromView(p.app.Device(), key, view, texture.Args{
-			Aspect: core1_0.ImageAspectDepth,
-		})
-		if err != nil {
-			panic(err)
-		}
-
-		cascades[i].Texture = tex
-		cascades[i].Frame = fbuf
-
-		// each light cascade needs its own descriptors projection
-		// todo: share object descriptors between cascades
 		cascades[i].Descriptors = p.descLayout.InstantiateMany(p.app.Pool(), p.target.Frames())
 	}
 
 	shadowmap := Shadowmap{
 		Cascades: cascades,
 	}
-	p.shadowmaps[light] = shadowmap
+	p.shadowmaps[lit] = shadowmap
 	return shadowmap
 }
 
@@ -225,6 +249,13 @@ func (p *Shadowpass) Record(cmds command.Recorder, args draw.Args, scene object.
 		Where(func(lit light.T) bool { return lit.Type() == light.TypeDirectional && lit.CastShadows() }).
 		Collect(scene)
 
+	// point light shadows are expensive, only render the most important ones
+	points := p.lightQuery.
+		Reset().
+		Where(func(lit light.T) bool { return lit.Type() == light.TypePoint && lit.CastShadows() }).
+		Collect(scene)
+	lights = append(lights, selectPointShadows(points, args.Camera.Position, p.PointLightBudget)...)
+
 	meshes := p.meshQuery.
 		Reset().
 		Where(castsShadows).
@@ -232,6 +263,7 @@ func (p *Shadowpass) Record(cmds command.Recorder, args draw.Args, scene object.
 
 	p.plan.Clear()
 	p.objects.Reset()
+	p.instances.Reset(args.Frame)
 
 	// record render plan with all shadow casters
 	for _, meshObject := range meshes {
@@ -240,54 +272,118 @@ func (p *Shadowpass) Record(cmds command.Recorder, args draw.Args, scene object.
 			continue
 		}
 
-		objectId := p.objects.Store(uniform.Object{
-			Model:    meshObject.Transform().Matrix(),
-			Vertices: mesh.Vertices.Address(),
-			Indices:  mesh.Indices.Address(),
-		})
+		// instances are not culled, since they may cast shadows into the view from outside of it
+		instances, instanceCount, visible := p.instances.StoreMesh(meshObject, mesh, nil)
+		if !visible {
+			continue
+		}
+
+		// levels of detail are selected by the main camera, so that shadows match the visible geometry.
+		// the shadow shader does not dither, so a single level is drawn during cross-fades
+		p.levels = SelectLevels(p.meshes, meshObject, mesh, args.Camera, false, p.levels)
+		for _, level := range p.levels {
+			objectId := p.objects.Store(uniform.Object{
+				Model:     meshObject.Transform().Matrix(),
+				Vertices:  level.Mesh.Vertices.Address(),
+				Indices:   level.Mesh.Indices.Address(),
+				Instances: instances,
+			})
 
-		p.plan.Add(pipeline, RenderObject{
-			Handle:  objectId,
-			Indices: mesh.IndexCount,
-		})
+			p.plan.Add(pipeline, RenderObject{
+				Handle:    objectId,
+				Indices:   level.Mesh.IndexCount,
+				Instances: instanceCount,
+			})
+		}
 	}
+	p.instances.Flush()
 
 	// todo: frustum cull meshes using light frustum
 
-	for _, light := range lights {
-		shadowmap, mapExists := p.shadowmaps[light]
+	p.atlas.Reset()
+	draws := make([]shadowDraw, 0, len(lights))
+	for _, lit := range lights {
+		size := p.size
+		if lit.Type() == light.TypePoint {
+			size = p.pointSize
+		}
+		if _, allocated := p.atlas.Allocate(lit, lit.Shadowmaps(), size); !allocated {
+			// the atlas is full, the light is rendered without shadows
+			continue
+		}
+
+		shadowmap, mapExists := p.shadowmaps[lit]
+		if mapExists && len(shadowmap.Cascades) != lit.Shadowmaps() {
+			// the number of cascades has changed
+			shadowmap.Destroy()
+			mapExists = false
+		}
 		if !mapExists {
-			shadowmap = p.createShadowmap(light)
+			shadowmap = p.createShadowmap(lit)
 		}
 
 		for index, cascade := range shadowmap.Cascades {
-			camera := light.ShadowProjection(index)
-			frame := cascade.Frame
+			tile, _ := p.atlas.Tile(lit, index)
 
 			// update descriptors
 			desc := cascade.Descriptors[args.Frame]
-			desc.Camera.Set(camera)
+			desc.Camera.Set(lit.ShadowProjection(index))
 			p.objects.Flush(desc.Objects)
 
-			cmds.Record(func(cmd *command.Buffer) {
-				cmd.CmdBeginRenderPass(p.pass, frame)
-				cmd.CmdBindGraphicsDescriptor(p.layout, 0, desc)
-				p.plan.Draw(cmd, indirect)
-				cmd.CmdEndRenderPass()
+			draws = append(draws, shadowDraw{
+				tile: tile,
+				desc: desc,
 			})
 		}
 	}
+	if len(draws) == 0 {
+		return
+	}
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.frame)
+		for _, shadow := range draws {
+			cmd.CmdSetViewport(shadow.tile.X, shadow.tile.Y, shadow.tile.Size, shadow.tile.Size)
+			cmd.CmdSetScissor(shadow.tile.X, shadow.tile.Y, shadow.tile.Size, shadow.tile.Size)
+			cmd.CmdBindGraphicsDescriptor(p.layout, 0, shadow.desc)
+			p.plan.Draw(cmd, indirect)
+		}
+		cmd.CmdEndRenderPass()
+	})
 }
 
 func castsShadows(m mesh.Mesh) bool {
 	return m.CastShadows()
 }
 
-func (p *Shadowpass) Shadowmap(light light.T, cascade int) *texture.Texture {
-	if shadowmap, exists := p.shadowmaps[light]; exists {
-		return shadowmap.Cascades[cascade].Texture
+// selectPointShadows returns the budget most important point lights
+func selectPointShadows(points []light.T, eye vec3.T, budget int) []light.T {
+	importance := func(lit light.T) float32 {
+		if point, ok := lit.(*light.Point); ok {
+			return point.Importance(eye)
+		}
+		return 0
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return importance(points[i]) > importance(points[j])
+	})
+	if len(points) > budget {
+		points = points[:max(budget, 0)]
+	}
+	return points
+}
+
+// Shadowmap returns the shadow atlas texture, and the location of the shadow map of the given light and cascade index.
+// Returns false if the light did not have its shadows rendered this frame.
+func (p *Shadowpass) Shadowmap(lit light.T, cascade int) (*texture.Texture, light.ShadowMap, bool) {
+	tile, exists := p.atlas.Tile(lit, cascade)
+	if !exists {
+		return nil, light.ShadowMap{}, false
 	}
-	return nil
+	return p.texture, light.ShadowMap{
+		Region: tile.Region(p.atlas.Size()),
+		Size:   tile.Size,
+	}, true
 }
 
 func (p *Shadowpass) Destroy() {
@@ -296,6 +392,12 @@ func (p *Shadowpass) Destroy() {
 	}
 	p.shadowmaps = nil
 
+	p.texture.Destroy()
+	p.texture = nil
+
+	p.frame.Destroy()
+	p.frame = nil
+
 	p.pass.Destroy()
 	p.pass = nil
 
@@ -304,6 +406,9 @@ func (p *Shadowpass) Destroy() {
 	}
 	p.commands = nil
 
+	p.instances.Destroy()
+	p.instances = nil
+
 	p.layout.Destroy()
 	p.layout = nil
 
@@ -0,0 +1,146 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/core/light"
+	"github.com/johanhenriksson/goworld/math/vec4"
+)
+
+// ShadowTile is a square region of the shadow atlas, in texels
+type ShadowTile struct {
+	X, Y, Size int
+}
+
+// Region returns the offset of the tile in xy, and its scale in zw, in texture coordinates of an atlas of the given size
+func (t ShadowTile) Region(atlasSize int) vec4.T {
+	size := float32(atlasSize)
+	return vec4.New(float32(t.X)/size, float32(t.Y)/size, float32(t.Size)/size, float32(t.Size)/size)
+}
+
+// ShadowAtlas packs the shadow maps of all lights into a single square texture.
+//
+// Tiles are allocated each frame using a buddy allocator, splitting larger free tiles into quarters until a tile
+// of the requested size is available. All tiles are power of two sized, and at least light.MinShadowResolution.
+// Lights are allocated in order of importance. If the maps of a light do not fit at the requested resolution,
+// the resolution is halved until they do, so that less important lights get less of the budget.
+type ShadowAtlas struct {
+	size  int
+	free  [][]ShadowTile
+	saved [][]ShadowTile
+	tiles map[light.T][]ShadowTile
+}
+
+func NewShadowAtlas(size int) *ShadowAtlas {
+	if size < light.MinShadowResolution || size&(size-1) != 0 {
+		panic("shadow atlas size must be a power of two, and at least the minimum shadow resolution")
+	}
+	levels := 1
+	for tile := size; tile > light.MinShadowResolution; tile /= 2 {
+		levels++
+	}
+	atlas := &ShadowAtlas{
+		size:  size,
+		free:  make([][]ShadowTile, levels),
+		saved: make([][]ShadowTile, levels),
+		tiles: make(map[light.T][]ShadowTile),
+	}
+	atlas.Reset()
+	return atlas
+}
+
+// Size returns the width and height of the atlas, in texels
+func (a *ShadowAtlas) Size() int {
+	return a.size
+}
+
+// Reset frees all tiles
+func (a *ShadowAtlas) Reset() {
+	for level := range a.free {
+		a.free[level] = a.free[level][:0]
+	}
+	a.free[0] = append(a.free[0], ShadowTile{Size: a.size})
+	clear(a.tiles)
+}
+
+// Allocate reserves a tile for each of the shadow maps of a light, at the largest resolution up to the requested
+// size at which all of them fit. Returns the resolution of the tiles, or false if the atlas is full.
+func (a *ShadowAtlas) Allocate(lit light.T, maps, size int) (int, bool) {
+	size = min(max(size, light.MinShadowResolution), a.size)
+	for size&(size-1) != 0 {
+		// round down to a power of two
+		size &= size - 1
+	}
+	for ; size >= light.MinShadowResolution; size /= 2 {
+		a.save()
+		tiles := make([]ShadowTile, 0, maps)
+		for len(tiles) < maps {
+			tile, ok := a.take(a.level(size))
+			if !ok {
+				break
+			}
+			tiles = append(tiles, tile)
+		}
+		if len(tiles) == maps {
+			a.tiles[lit] = tiles
+			return size, true
+		}
+		a.restore()
+	}
+	return 0, false
+}
+
+// Tile returns the tile allocated for a shadow map of a light
+func (a *ShadowAtlas) Tile(lit light.T, index int) (ShadowTile, bool) {
+	tiles, exists := a.tiles[lit]
+	if !exists || index < 0 || index >= len(tiles) {
+		return ShadowTile{}, false
+	}
+	return tiles[index], true
+}
+
+// level returns the allocator level of a power of two tile size
+func (a *ShadowAtlas) level(size int) int {
+	level := 0
+	for tile := a.size; tile > size; tile /= 2 {
+		level++
+	}
+	return level
+}
+
+// take returns a free tile at the given level, splitting a larger tile if necessary
+func (a *ShadowAtlas) take(level int) (ShadowTile, bool) {
+	if n := len(a.free[level]); n > 0 {
+		tile := a.free[level][n-1]
+		a.free[level] = a.free[level][:n-1]
+		return tile, true
+	}
+	if level == 0 {
+		return ShadowTile{}, false
+	}
+	parent, ok := a.take(level - 1)
+	if !ok {
+		return ShadowTile{}, false
+	}
+
+	// keep three quarters, in reverse order so that tiles are handed out row by row
+	half := parent.Size / 2
+	a.free[level] = append(a.free[level],
+		ShadowTile{X: parent.X + half, Y: parent.Y + half, Size: half},
+		ShadowTile{X: parent.X, Y: parent.Y + half, Size: half},
+		ShadowTile{X: parent.X + half, Y: parent.Y, Size: half},
+	)
+	return ShadowTile{X: parent.X, Y: parent.Y, Size: half}, true
+}
+
+// save stores a copy of the free lists, so that a failed allocation can be undone
+func (a *ShadowAtlas) save() {
+	for level, tiles := range a.free {
+		a.saved[level] = append(a.saved[level][:0], tiles...)
+	}
+}
+
+// restore resets the free lists to the last saved state
+func (a *ShadowAtlas) restore() {
+	for level, tiles := range a.saved {
+		a.free[level] = append(a.free[level][:0], tiles...)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/johanhenriksson/goworld/render/descriptor"
 )
 
+// ShadowCache resolves shadow map lookups into regions of the shadow atlas,
+// and assigns the atlas texture a handle in the sampler array of a pass.
 type ShadowCache struct {
 	samplers cache.SamplerCache
 	lookup   ShadowmapLookupFn
@@ -20,13 +22,13 @@ func NewShadowCache(samplers cache.SamplerCache, lookup ShadowmapLookupFn) *Shad
 	}
 }
 
-func (s *ShadowCache) Lookup(lit light.T, cascade int) (int, bool) {
-	if shadowtex := s.lookup(lit, cascade); shadowtex != nil {
-		handle := s.samplers.Assign(shadowtex)
-		return handle.ID, true
+func (s *ShadowCache) Lookup(lit light.T, cascade int) (light.ShadowMap, bool) {
+	if atlas, shadowmap, exists := s.lookup(lit, cascade); exists {
+		shadowmap.Handle = s.samplers.Assign(atlas).ID
+		return shadowmap, true
 	}
 	// no shadowmap available
-	return 0, false
+	return light.ShadowMap{}, false
 }
 
 // Flush the underlying sampler cache
@@ -0,0 +1,198 @@
+package pass
+
+import (
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/core/sky"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+type SkyDescriptors struct {
+	descriptor.Set
+	Camera   *descriptor.Uniform[uniform.Camera]
+	Sky      *descriptor.Uniform[uniform.Sky]
+	Textures *descriptor.SamplerArray
+}
+
+// SkyPass draws the sky behind all geometry, by testing a far plane quad against the depth buffer.
+type SkyPass struct {
+	app  engine.App
+	pass *renderpass.Renderpass
+	fbuf framebuffer.Array
+	quad vertex.Mesh
+
+	pipeline    *pipeline.Pipeline
+	pipeLayout  *pipeline.Layout
+	descLayout  *descriptor.Layout[*SkyDescriptors]
+	descriptors []*SkyDescriptors
+	samplers    []cache.SamplerCache
+	skyQuery    *object.Query[*sky.Sky]
+}
+
+var _ draw.Pass = &SkyPass{}
+
+func NewSkyPass(app engine.App, target engine.Target, depth engine.Target) *SkyPass {
+	pass := renderpass.New(app.Device(), renderpass.Args{
+		Name: "Sky",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:          OutputAttachment,
+				Image:         attachment.FromImageArray(target.Surfaces()),
+				LoadOp:        core1_0.AttachmentLoadOpLoad,
+				StoreOp:       core1_0.AttachmentStoreOpStore,
+				InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+				FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		DepthAttachment: &attachment.Depth{
+			Image:         attachment.FromImageArray(depth.Surfaces()),
+			LoadOp:        core1_0.AttachmentLoadOpLoad,
+			StencilLoadOp: core1_0.AttachmentLoadOpLoad,
+			StoreOp:       core1_0.AttachmentStoreOpStore,
+			InitialLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			FinalLayout:   core1_0.ImageLayoutShaderReadOnlyOptimal,
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:  MainSubpass,
+				Depth: true,
+
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+	})
+
+	fbuf, err := framebuffer.NewArray(target.Frames(), app.Device(), "sky", target.Width(), target.Height(), pass)
+	if err != nil {
+		panic(err)
+	}
+
+	maxTextures := 4
+	descLayout := descriptor.NewLayout(app.Device(), "Sky", &SkyDescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageFragment,
+		},
+		Sky: &descriptor.Uniform[uniform.Sky]{
+			Stages: core1_0.StageFragment,
+		},
+		Textures: &descriptor.SamplerArray{
+			Stages: core1_0.StageFragment,
+			Count:  maxTextures,
+		},
+	})
+	pipeLayout := pipeline.NewLayout(app.Device(), []descriptor.SetLayout{descLayout}, nil)
+	pipe := pipeline.New(app.Device(), pipeline.Args{
+		Layout:     pipeLayout,
+		Shader:     app.Shaders().Fetch(shader.Ref("pass/sky")),
+		Pass:       pass,
+		Pointers:   vertex.ParsePointers(vertex.Vertex{}),
+		DepthTest:  true,
+		DepthWrite: false,
+		DepthFunc:  core1_0.CompareOpLessOrEqual,
+	})
+
+	samplers := make([]cache.SamplerCache, target.Frames())
+	for i := range samplers {
+		samplers[i] = cache.NewSamplerCache(app.Textures(), maxTextures)
+	}
+
+	return &SkyPass{
+		app:  app,
+		pass: pass,
+		fbuf: fbuf,
+		quad: vertex.ScreenQuad("sky-pass-quad"),
+
+		pipeline:    pipe,
+		pipeLayout:  pipeLayout,
+		descLayout:  descLayout,
+		descriptors: descLayout.InstantiateMany(app.Pool(), target.Frames()),
+		samplers:    samplers,
+		skyQuery:    object.NewQuery[*sky.Sky](),
+	}
+}
+
+func (p *SkyPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	skyComponent, exists := p.skyQuery.Reset().First(scene)
+	if !exists {
+		return
+	}
+
+	desc := p.descriptors[args.Frame]
+	samplers := p.samplers[args.Frame]
+	desc.Camera.Set(uniform.CameraFromArgs(args))
+
+	atm := skyComponent.Atmosphere()
+	data := uniform.Sky{
+		SunDirection:     vec4.Extend(skyComponent.Sun(scene), 0),
+		Rayleigh:         vec4.Extend(atm.Rayleigh, 0),
+		Mode:             uniform.SkyModeProcedural,
+		Intensity:        skyComponent.Intensity.Get(),
+		SunIntensity:     atm.SunIntensity,
+		PlanetRadius:     atm.PlanetRadius,
+		AtmosphereRadius: atm.AtmosphereRadius,
+		RayleighHeight:   atm.RayleighHeight,
+		MieHeight:        atm.MieHeight,
+		Mie:              atm.Mie,
+		MieG:             atm.MieG,
+	}
+	if skyComponent.Mode.Get() == sky.Skybox {
+		maps := skyComponent.Skybox()
+		if maps == nil {
+			return
+		}
+		// the first specular level is the unfiltered environment cubemap
+		handle, ready := samplers.TryFetch(maps.Specular(0))
+		if !ready {
+			return
+		}
+		data.Mode = uniform.SkyModeSkybox
+		data.Skybox = int32(handle.ID)
+	}
+	desc.Sky.Set(data)
+	samplers.Flush(desc.Textures)
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		return
+	}
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, p.fbuf[args.Frame])
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *SkyPass) Name() string {
+	return "Sky"
+}
+
+func (p *SkyPass) Destroy() {
+	for _, samplers := range p.samplers {
+		samplers.Destroy()
+	}
+	for _, desc := range p.descriptors {
+		desc.Destroy()
+	}
+	p.fbuf.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
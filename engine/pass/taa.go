@@ -0,0 +1,280 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/framebuffer"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/renderpass/attachment"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+// weight of the history buffer in the resolved color
+const taaFeedback = 0.9
+
+type TemporalAADescriptors struct {
+	descriptor.Set
+	Camera   *descriptor.Uniform[uniform.Camera]
+	Params   *descriptor.Uniform[uniform.TemporalAA]
+	Input    *descriptor.Sampler
+	Depth    *descriptor.Sampler
+	Velocity *descriptor.Sampler
+	History  *descriptor.Sampler
+}
+
+// TemporalAAPass resolves the jittered input image against an accumulated history buffer.
+// The history is reprojected using the depth buffer and the object motion vectors of the
+// geometry buffer, and clamped to the color neighborhood of the current frame to reject stale samples.
+type TemporalAAPass struct {
+	app      engine.App
+	settings *engine.RenderSettings
+	quad     vertex.Mesh
+
+	// history is a ring of resolved images, one more than the number of frames.
+	// each frame reads the image resolved by the previous frame, and writes to the next slot.
+	history    *engine.RenderTarget
+	historyTex texture.Array
+	inputTex   texture.Array
+	depthTex   texture.Array
+	velocity   texture.Array
+	current    int
+	valid      bool
+
+	pass  *renderpass.Renderpass
+	fbufs framebuffer.Array
+	desc  []*TemporalAADescriptors
+
+	pipeline   *pipeline.Pipeline
+	pipeLayout *pipeline.Layout
+	descLayout *descriptor.Layout[*TemporalAADescriptors]
+}
+
+var _ draw.Pass = &TemporalAAPass{}
+
+func NewTemporalAAPass(app engine.App, input engine.Target, depth engine.Target, gbuffer GeometryBuffer, settings *engine.RenderSettings) *TemporalAAPass {
+	var err error
+	p := &TemporalAAPass{
+		app:      app,
+		settings: settings,
+		quad:     vertex.ScreenQuad("taa-pass-quad"),
+	}
+
+	size := input.Size()
+	size.Frames = input.Frames() + 1
+	p.history = engine.NewColorTarget(app.Device(), "taa-history", input.SurfaceFormat(), size)
+
+	p.pass = renderpass.New(app.Device(), renderpass.Args{
+		Name: "TemporalAA",
+		ColorAttachments: []attachment.Color{
+			{
+				Name:        OutputAttachment,
+				Image:       attachment.FromImageArray(p.history.Surfaces()),
+				LoadOp:      core1_0.AttachmentLoadOpDontCare,
+				StoreOp:     core1_0.AttachmentStoreOpStore,
+				FinalLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
+			},
+		},
+		Subpasses: []renderpass.Subpass{
+			{
+				Name:             MainSubpass,
+				ColorAttachments: []attachment.Name{OutputAttachment},
+			},
+		},
+		Dependencies: []renderpass.SubpassDependency{
+			{
+				// For color attachment operations
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessColorAttachmentWrite | core1_0.AccessColorAttachmentRead,
+			},
+			{
+				// For fragment shader reads of the history
+				Src:           renderpass.ExternalSubpass,
+				Dst:           MainSubpass,
+				SrcStageMask:  core1_0.PipelineStageColorAttachmentOutput,
+				DstStageMask:  core1_0.PipelineStageFragmentShader,
+				SrcAccessMask: core1_0.AccessColorAttachmentWrite,
+				DstAccessMask: core1_0.AccessShaderRead,
+			},
+		},
+	})
+
+	p.fbufs, err = framebuffer.NewArray(size.Frames, app.Device(), "taa", size.Width, size.Height, p.pass)
+	if err != nil {
+		panic(err)
+	}
+
+	p.descLayout = descriptor.NewLayout(app.Device(), "TemporalAA", &TemporalAADescriptors{
+		Camera: &descriptor.Uniform[uniform.Camera]{
+			Stages: core1_0.StageFragment,
+		},
+		Params: &descriptor.Uniform[uniform.TemporalAA]{
+			Stages: core1_0.StageFragment,
+		},
+		Input: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Depth: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		Velocity: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		History: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	p.pipeLayout = pipeline.NewLayout(app.Device(), []descriptor.SetLayout{p.descLayout}, nil)
+	p.pipeline = pipeline.New(app.Device(), pipeline.Args{
+		Layout:   p.pipeLayout,
+		Shader:   app.Shaders().Fetch(shader.Ref("pass/taa")),
+		Pass:     p.pass,
+		Pointers: vertex.ParsePointers(vertex.Vertex{}),
+	})
+
+	frames := input.Frames()
+	p.desc = p.descLayout.InstantiateMany(app.Pool(), frames)
+	p.inputTex = make(texture.Array, frames)
+	p.depthTex = make(texture.Array, frames)
+	p.velocity = make(texture.Array, frames)
+	for i := 0; i < frames; i++ {
+		p.inputTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("taa-input-%d", i), input.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Input.Set(p.inputTex[i])
+
+		p.depthTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("taa-depth-%d", i), depth.Surfaces()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+			Aspect: core1_0.ImageAspectDepth,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Depth.Set(p.depthTex[i])
+
+		p.velocity[i], err = texture.FromImage(app.Device(), fmt.Sprintf("taa-velocity-%d", i), gbuffer.Velocity()[i], texture.Args{
+			Filter: texture.FilterNearest,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+		p.desc[i].Velocity.Set(p.velocity[i])
+	}
+
+	p.historyTex = make(texture.Array, size.Frames)
+	for i := range p.historyTex {
+		p.historyTex[i], err = texture.FromImage(app.Device(), fmt.Sprintf("taa-history-%d", i), p.history.Surfaces()[i], texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		})
+		if err != nil {
+			// todo: clean up
+			panic(err)
+		}
+	}
+
+	return p
+}
+
+// Active returns true if the pass resolved an image during the most recent frame.
+func (p *TemporalAAPass) Active() bool {
+	return p.valid
+}
+
+// Output returns the most recently resolved image.
+func (p *TemporalAAPass) Output() *texture.Texture {
+	return p.historyTex[p.current]
+}
+
+func (p *TemporalAAPass) Record(cmds command.Recorder, args draw.Args, scene object.Component) {
+	if p.settings.AntiAliasing != engine.AntiAliasingTAA {
+		// the history is stale once temporal anti-aliasing is enabled again
+		p.valid = false
+		return
+	}
+
+	quad, meshReady := p.app.Meshes().TryFetch(p.quad)
+	if !meshReady {
+		p.valid = false
+		return
+	}
+
+	desc := p.desc[args.Frame]
+	reset := !p.valid
+	previous := p.historyTex[p.current]
+	if reset {
+		// the previous slot has not been written.
+		// bind the current input instead, its value is ignored when resetting
+		previous = p.inputTex[args.Frame]
+	}
+	desc.History.Set(previous)
+	desc.Camera.Set(uniform.CameraFromArgs(args))
+	desc.Params.Set(uniform.TemporalAA{
+		Feedback: taaFeedback,
+		Reset:    boolToInt(reset),
+	})
+
+	p.current = (p.current + 1) % len(p.fbufs)
+	p.valid = true
+	fbuf := p.fbufs[p.current]
+
+	cmds.Record(func(cmd *command.Buffer) {
+		cmd.CmdBeginRenderPass(p.pass, fbuf)
+		cmd.CmdBindGraphicsPipeline(p.pipeline)
+		cmd.CmdBindGraphicsDescriptor(p.pipeLayout, 0, desc)
+		quad.Bind(cmd)
+		quad.Draw(cmd, 0)
+		cmd.CmdEndRenderPass()
+	})
+}
+
+func (p *TemporalAAPass) Name() string {
+	return "TemporalAA"
+}
+
+func (p *TemporalAAPass) Destroy() {
+	for _, tex := range p.inputTex {
+		tex.Destroy()
+	}
+	for _, tex := range p.depthTex {
+		tex.Destroy()
+	}
+	for _, tex := range p.velocity {
+		tex.Destroy()
+	}
+	for _, tex := range p.historyTex {
+		tex.Destroy()
+	}
+	for _, desc := range p.desc {
+		desc.Destroy()
+	}
+	p.fbufs.Destroy()
+	p.history.Destroy()
+	p.pass.Destroy()
+	p.pipeline.Destroy()
+	p.pipeLayout.Destroy()
+	p.descLayout.Destroy()
+}
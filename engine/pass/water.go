@@ -0,0 +1,264 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/core/water"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/cache"
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/shape"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/command"
+	"github.com/johanhenriksson/goworld/render/descriptor"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/pipeline"
+	"github.com/johanhenriksson/goworld/render/renderpass"
+	"github.com/johanhenriksson/goworld/render/shader"
+	"github.com/johanhenriksson/goworld/render/texture"
+	"github.com/johanhenriksson/goworld/render/vertex"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+)
+
+const maxWaters = 16
+
+type WaterDescriptors struct {
+	descriptor.Set
+	Waters     *descriptor.Storage[uniform.Water]
+	SceneColor *descriptor.Sampler
+	SceneDepth *descriptor.Sampler
+}
+
+type waterDraw struct {
+	mesh  *cache.GpuMesh
+	index int
+}
+
+// WaterRenderer draws water surfaces as part of the forward pass.
+//
+// Water refracts and absorbs the scene behind it, so the color and depth buffers are copied after the opaque
+// geometry is drawn, and sampled by the water shader. The water descriptors are bound as the second descriptor set,
+// so that the lights and samplers of the forward pass are shared.
+type WaterRenderer struct {
+	app         engine.App
+	target      engine.Target
+	depth       engine.Target
+	pipeline    *pipeline.Pipeline
+	pipeLayout  *pipeline.Layout
+	descLayout  *descriptor.Layout[*WaterDescriptors]
+	descriptors []*WaterDescriptors
+	sceneColor  []*texture.Texture
+	sceneDepth  []*texture.Texture
+	waterQuery  *object.Query[*water.Water]
+	waters      []uniform.Water
+	draws       [][]waterDraw
+}
+
+func NewWaterRenderer(app engine.App, pass *renderpass.Renderpass, forward descriptor.SetLayout, target, depth engine.Target) *WaterRenderer {
+	descLayout := descriptor.NewLayout(app.Device(), "Water", &WaterDescriptors{
+		Waters: &descriptor.Storage[uniform.Water]{
+			Stages: core1_0.StageAll,
+			Size:   maxWaters,
+		},
+		SceneColor: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+		SceneDepth: &descriptor.Sampler{
+			Stages: core1_0.StageFragment,
+		},
+	})
+	pipeLayout := pipeline.NewLayout(app.Device(), []descriptor.SetLayout{forward, descLayout}, nil)
+	pipe := pipeline.New(app.Device(), pipeline.Args{
+		Layout:     pipeLayout,
+		Shader:     app.Shaders().Fetch(shader.Ref("forward/water")),
+		Pass:       pass,
+		Pointers:   vertex.ParsePointers(vertex.Vertex{}),
+		CullMode:   vertex.CullNone,
+		DepthTest:  true,
+		DepthWrite: true,
+		DepthFunc:  core1_0.CompareOpLessOrEqual,
+	})
+
+	frames := target.Frames()
+	descriptors := descLayout.InstantiateMany(app.Pool(), frames)
+	sceneColor := make([]*texture.Texture, frames)
+	sceneDepth := make([]*texture.Texture, frames)
+	for i := range descriptors {
+		var err error
+		sceneColor[i], err = texture.New(app.Device(), fmt.Sprintf("water-scene-color-%d", i),
+			target.Width(), target.Height(), target.Surfaces()[i].Format(), texture.Args{
+				Filter: texture.FilterLinear,
+				Wrap:   texture.WrapClamp,
+			})
+		if err != nil {
+			panic(err)
+		}
+		sceneDepth[i], err = texture.New(app.Device(), fmt.Sprintf("water-scene-depth-%d", i),
+			depth.Width(), depth.Height(), depth.Surfaces()[i].Format(), texture.Args{
+				Filter: texture.FilterNearest,
+				Wrap:   texture.WrapClamp,
+				Aspect: core1_0.ImageAspectDepth,
+			})
+		if err != nil {
+			panic(err)
+		}
+		descriptors[i].SceneColor.Set(sceneColor[i])
+		descriptors[i].SceneDepth.Set(sceneDepth[i])
+	}
+
+	return &WaterRenderer{
+		app:         app,
+		target:      target,
+		depth:       depth,
+		pipeline:    pipe,
+		pipeLayout:  pipeLayout,
+		descLayout:  descLayout,
+		descriptors: descriptors,
+		sceneColor:  sceneColor,
+		sceneDepth:  sceneDepth,
+		waterQuery:  object.NewQuery[*water.Water](),
+		waters:      make([]uniform.Water, 0, maxWaters),
+		draws:       make([][]waterDraw, frames),
+	}
+}
+
+// Prepare collects the visible water surfaces of the scene. Returns false if there is nothing to draw
+func (w *WaterRenderer) Prepare(args draw.Args, scene object.Component, frustum *shape.Frustum) bool {
+	w.waters = w.waters[:0]
+	draws := w.draws[args.Frame][:0]
+
+	for _, surface := range w.waterQuery.Reset().Collect(scene) {
+		if len(w.waters) >= maxWaters {
+			break
+		}
+		mesh, ready := w.app.Meshes().TryFetch(surface.Mesh())
+		if !ready {
+			continue
+		}
+
+		data := uniform.Water{
+			Model:      surface.Transform().Matrix(),
+			Absorption: vec4.Extend(surface.Absorption.Get(), 0),
+			Color:      surface.Color.Get(),
+			FoamColor:  surface.FoamColor.Get().WithAlpha(surface.FoamDepth.Get()),
+			Time:       surface.Time(),
+			Refraction: surface.Refraction.Get(),
+		}
+
+		// waves move the surface by at most the sum of their amplitudes
+		amplitude := float32(0)
+		for i, wave := range surface.ActiveWaves() {
+			data.Waves[i] = vec4.New(wave.Direction.X, wave.Direction.Y, wave.Steepness, wave.Wavelength)
+			data.WaveCount++
+			if wave.Wavelength > 0 {
+				amplitude += wave.Steepness * wave.Wavelength / (2 * math.Pi)
+			}
+		}
+
+		bounds := mesh.Bounds()
+		scale := surface.Transform().WorldScale()
+		sphere := shape.Sphere{
+			Center: surface.Transform().Project(bounds.Center),
+			Radius: bounds.Radius*max(scale.X, scale.Y, scale.Z) + amplitude,
+		}
+		if !frustum.IntersectsSphere(&sphere) {
+			continue
+		}
+
+		draws = append(draws, waterDraw{mesh: mesh, index: len(w.waters)})
+		w.waters = append(w.waters, data)
+	}
+	w.draws[args.Frame] = draws
+	if len(draws) == 0 {
+		return false
+	}
+
+	w.descriptors[args.Frame].Waters.SetRange(0, w.waters)
+	return true
+}
+
+// CopyScene copies the color and depth buffers into the scene textures sampled by the water shader.
+// Must be recorded outside of the render pass.
+func (w *WaterRenderer) CopyScene(cmd *command.Buffer, frame int) {
+	copyScene(cmd, w.target.Surfaces()[frame], w.sceneColor[frame].Image(), core1_0.ImageAspectColor,
+		core1_0.PipelineStageColorAttachmentOutput)
+	copyScene(cmd, w.depth.Surfaces()[frame], w.sceneDepth[frame].Image(), depthAspects(w.depth.Surfaces()[frame].Format()),
+		core1_0.PipelineStageEarlyFragmentTests|core1_0.PipelineStageLateFragmentTests)
+}
+
+// Draw the water surfaces prepared for the frame. Must be recorded inside the forward render pass.
+// The forward descriptors are bound as the first set of the water pipeline layout.
+func (w *WaterRenderer) Draw(cmd *command.Buffer, frame int, forward descriptor.Set) {
+	cmd.CmdBindGraphicsPipeline(w.pipeline)
+	cmd.CmdBindGraphicsDescriptor(w.pipeLayout, 0, forward)
+	cmd.CmdBindGraphicsDescriptor(w.pipeLayout, 1, w.descriptors[frame])
+	for _, d := range w.draws[frame] {
+		d.mesh.Bind(cmd)
+		d.mesh.Draw(cmd, d.index)
+	}
+}
+
+func (w *WaterRenderer) Destroy() {
+	for _, desc := range w.descriptors {
+		desc.Destroy()
+	}
+	for i := range w.sceneColor {
+		w.sceneColor[i].Destroy()
+		w.sceneDepth[i].Destroy()
+	}
+	w.pipeline.Destroy()
+	w.pipeLayout.Destroy()
+	w.descLayout.Destroy()
+}
+
+// copyScene copies an attachment in shader read layout into a sampled texture of the same size and format.
+// Both images are left in shader read layout.
+func copyScene(cmd *command.Buffer, src, dst *image.Image, aspects core1_0.ImageAspectFlags, stages core1_0.PipelineStageFlags) {
+	cmd.CmdImageBarrier(
+		stages,
+		core1_0.PipelineStageTransfer,
+		src,
+		core1_0.ImageLayoutShaderReadOnlyOptimal,
+		core1_0.ImageLayoutTransferSrcOptimal,
+		aspects, 0, 1)
+	cmd.CmdImageBarrier(
+		core1_0.PipelineStageFragmentShader,
+		core1_0.PipelineStageTransfer,
+		dst,
+		core1_0.ImageLayoutUndefined,
+		core1_0.ImageLayoutTransferDstOptimal,
+		aspects, 0, 1)
+
+	// only the depth aspect of depth stencil images is copied
+	copyAspects := aspects &^ core1_0.ImageAspectStencil
+	cmd.CmdCopyImage(src, core1_0.ImageLayoutTransferSrcOptimal, dst, core1_0.ImageLayoutTransferDstOptimal, copyAspects)
+
+	cmd.CmdImageBarrier(
+		core1_0.PipelineStageTransfer,
+		stages,
+		src,
+		core1_0.ImageLayoutTransferSrcOptimal,
+		core1_0.ImageLayoutShaderReadOnlyOptimal,
+		aspects, 0, 1)
+	cmd.CmdImageBarrier(
+		core1_0.PipelineStageTransfer,
+		core1_0.PipelineStageFragmentShader,
+		dst,
+		core1_0.ImageLayoutTransferDstOptimal,
+		core1_0.ImageLayoutShaderReadOnlyOptimal,
+		aspects, 0, 1)
+}
+
+// depthAspects returns the aspects of a depth format. Layout transitions of depth stencil images must include both
+func depthAspects(format core1_0.Format) core1_0.ImageAspectFlags {
+	switch format {
+	case core1_0.FormatD32SignedFloatS8UnsignedInt, core1_0.FormatD24UnsignedNormalizedS8UnsignedInt, core1_0.FormatD16UnsignedNormalizedS8UnsignedInt:
+		return core1_0.ImageAspectDepth | core1_0.ImageAspectStencil
+	default:
+		return core1_0.ImageAspectDepth
+	}
+}
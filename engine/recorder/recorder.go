@@ -0,0 +1,172 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/johanhenriksson/goworld/core/input/keys"
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/render/upload"
+)
+
+// RecordKey toggles recording
+const RecordKey = keys.F9
+
+// Args configures a frame recorder
+type Args struct {
+	// Directory that frames are written to. If empty, a new timestamped directory
+	// is created in the working directory each time recording starts.
+	Directory string
+
+	// FrameRate is the number of simulated frames per second while recording
+	FrameRate float32
+
+	// Interval captures every Nth simulated frame
+	Interval int
+}
+
+func DefaultArgs() Args {
+	return Args{
+		FrameRate: 60,
+		Interval:  1,
+	}
+}
+
+// Source produces captured frames, usually the renderer
+type Source interface {
+	Capture(engine.CaptureFunc)
+}
+
+// Recorder is a component that captures rendered frames to a numbered PNG image sequence.
+//
+// While recording, the simulation advances by a fixed time step each frame rather than by wall clock time,
+// so that the recording plays back smoothly at the configured frame rate regardless of how long
+// each frame takes to render and write to disk.
+// Frames are downloaded and encoded in the background. If too many frames are in flight the render loop
+// is stalled until they are written, which is fine since simulation time is decoupled from the wall clock.
+type Recorder struct {
+	object.Component
+	source Source
+	args   Args
+
+	recording bool
+	directory string
+	elapsed   float32
+	offset    float32
+	frame     int
+	images    int
+}
+
+func New(pool object.Pool, source Source, args Args) *Recorder {
+	if args.FrameRate <= 0 {
+		panic("recorder frame rate must be positive")
+	}
+	if args.Interval < 1 {
+		panic("recorder interval must be at least 1")
+	}
+	return object.NewComponent(pool, &Recorder{
+		source: source,
+		args:   args,
+	})
+}
+
+// Recording returns true while frames are being recorded
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Directory returns the output directory of the current or most recent recording
+func (r *Recorder) Directory() string {
+	return r.directory
+}
+
+// Start begins recording frames, creating the output directory if required
+func (r *Recorder) Start() error {
+	if r.recording {
+		return nil
+	}
+	dir := r.args.Directory
+	if dir == "" {
+		dir = fmt.Sprintf("Recording-%s", time.Now().Format("2006-01-02_15-04-05"))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	r.directory = dir
+	r.recording = true
+	r.frame = 0
+	r.images = 0
+	log.Println("recording frames to", dir)
+	return nil
+}
+
+// Stop ends the recording. Frames that are already captured are still written
+func (r *Recorder) Stop() {
+	if !r.recording {
+		return
+	}
+	r.recording = false
+	log.Println("recorded", r.images, "frames to", r.directory)
+}
+
+// Toggle starts or stops recording
+func (r *Recorder) Toggle() {
+	if r.recording {
+		r.Stop()
+		return
+	}
+	if err := r.Start(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Time returns the elapsed time and time step to use for the next frame.
+// While recording, the time advances by a fixed step. Otherwise, the given wall clock times are returned,
+// shifted by the difference between simulated and wall clock time accumulated while recording,
+// so that the elapsed time never jumps when a recording stops.
+func (r *Recorder) Time(elapsed, delta float32) (float32, float32) {
+	if !r.recording {
+		r.elapsed = elapsed + r.offset
+		return r.elapsed, delta
+	}
+	step := 1 / r.args.FrameRate
+	r.elapsed += step
+	r.offset = r.elapsed - elapsed
+	return r.elapsed, step
+}
+
+// Capture requests the next drawn frame from the source, if it should be recorded.
+// Should be called once per frame, before drawing.
+func (r *Recorder) Capture() {
+	if !r.recording {
+		return
+	}
+	frame := r.frame
+	r.frame++
+	if frame%r.args.Interval != 0 {
+		return
+	}
+
+	filename := filepath.Join(r.directory, fmt.Sprintf("frame-%06d.png", r.images))
+	r.images++
+	r.source.Capture(func(img *image.RGBA) {
+		if err := upload.SavePng(img, filename); err != nil {
+			log.Println("failed to write recorded frame:", err)
+		}
+	})
+}
+
+func (r *Recorder) KeyEvent(e keys.Event) {
+	if e.Action() != keys.Press {
+		return
+	}
+	if e.Code() == RecordKey {
+		r.Toggle()
+		e.Consume()
+	}
+}
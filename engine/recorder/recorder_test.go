@@ -0,0 +1,102 @@
+package recorder_test
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine"
+	"github.com/johanhenriksson/goworld/engine/recorder"
+)
+
+// source delivers a blank frame immediately for each capture request
+type source struct {
+	captures int
+}
+
+func (s *source) Capture(fn engine.CaptureFunc) {
+	s.captures++
+	fn(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+}
+
+var _ = Describe("frame recorder", func() {
+	var src *source
+	var args recorder.Args
+
+	BeforeEach(func() {
+		src = &source{}
+		args = recorder.DefaultArgs()
+		args.Directory = GinkgoT().TempDir()
+	})
+
+	It("passes wall clock time through when not recording", func() {
+		r := recorder.New(object.NewPool(), src, args)
+		elapsed, delta := r.Time(10, 0.1)
+		Expect(elapsed).To(Equal(float32(10)))
+		Expect(delta).To(Equal(float32(0.1)))
+
+		r.Capture()
+		Expect(src.captures).To(Equal(0))
+	})
+
+	It("advances by a fixed step while recording", func() {
+		args.FrameRate = 50
+		r := recorder.New(object.NewPool(), src, args)
+		r.Time(10, 0.1)
+		Expect(r.Start()).To(Succeed())
+
+		elapsed, delta := r.Time(10.5, 0.5)
+		Expect(delta).To(BeNumerically("~", 0.02, 1e-4))
+		Expect(elapsed).To(BeNumerically("~", 10.02, 1e-4))
+
+		elapsed, _ = r.Time(11, 0.5)
+		Expect(elapsed).To(BeNumerically("~", 10.04, 1e-4))
+
+		r.Stop()
+		elapsed, delta = r.Time(11.5, 0.5)
+		Expect(elapsed).To(BeNumerically("~", 10.54, 1e-4))
+		Expect(delta).To(Equal(float32(0.5)))
+	})
+
+	It("keeps time continuous across recordings", func() {
+		args.FrameRate = 10
+		r := recorder.New(object.NewPool(), src, args)
+		r.Time(1, 0.1)
+		Expect(r.Start()).To(Succeed())
+		r.Time(3, 2)
+		r.Stop()
+
+		elapsed, _ := r.Time(4, 1)
+		Expect(elapsed).To(BeNumerically("~", 2.1, 1e-4))
+
+		Expect(r.Start()).To(Succeed())
+		elapsed, _ = r.Time(9, 5)
+		Expect(elapsed).To(BeNumerically("~", 2.2, 1e-4))
+		r.Stop()
+
+		elapsed, _ = r.Time(10, 1)
+		Expect(elapsed).To(BeNumerically("~", 3.2, 1e-4))
+	})
+
+	It("writes every nth frame to a numbered sequence", func() {
+		args.Interval = 2
+		r := recorder.New(object.NewPool(), src, args)
+		Expect(r.Start()).To(Succeed())
+		for i := 0; i < 5; i++ {
+			r.Time(0, 0)
+			r.Capture()
+		}
+		r.Stop()
+
+		Expect(src.captures).To(Equal(3))
+		for _, name := range []string{"frame-000000.png", "frame-000001.png", "frame-000002.png"} {
+			Expect(filepath.Join(args.Directory, name)).To(BeAnExistingFile())
+		}
+		_, err := os.Stat(filepath.Join(args.Directory, "frame-000003.png"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
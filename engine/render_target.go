@@ -19,6 +19,16 @@ type TargetSize struct {
 	Scale  float32
 }
 
+// Scaled returns the size scaled by a factor, keeping the frame count and display scale
+func (s TargetSize) Scaled(scale float32) TargetSize {
+	return TargetSize{
+		Width:  max(int(float32(s.Width)*scale), 1),
+		Height: max(int(float32(s.Height)*scale), 1),
+		Frames: s.Frames,
+		Scale:  s.Scale,
+	}
+}
+
 type Target interface {
 	Size() TargetSize
 	Scale() float32
@@ -47,7 +57,7 @@ var _ Target = (*RenderTarget)(nil)
 
 func NewDepthTarget(device *device.Device, key string, size TargetSize) *RenderTarget {
 	format := device.GetDepthFormat()
-	usage := core1_0.ImageUsageSampled | core1_0.ImageUsageDepthStencilAttachment | core1_0.ImageUsageInputAttachment
+	usage := core1_0.ImageUsageSampled | core1_0.ImageUsageDepthStencilAttachment | core1_0.ImageUsageInputAttachment | core1_0.ImageUsageTransferSrc
 	target, err := NewRenderTarget(device, key, format, usage, size)
 	if err != nil {
 		panic(err)
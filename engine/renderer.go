@@ -4,13 +4,243 @@ import (
 	"image"
 
 	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/resolution"
 )
 
 type RendererFunc func(App, Target) Renderer
 
+// CaptureFunc receives a captured frame
+type CaptureFunc func(*image.RGBA)
+
 type Renderer interface {
 	Draw(scene object.Object, time, delta float32)
 	Recreate()
 	Screengrab() *image.RGBA
+
+	// Capture requests a copy of the next drawn frame, which is passed to the callback on a background goroutine
+	Capture(CaptureFunc)
+
+	Settings() *RenderSettings
+	Stats() *RenderStats
 	Destroy()
 }
+
+// AntiAliasing selects the anti-aliasing technique used by the renderer
+type AntiAliasing int
+
+const (
+	AntiAliasingNone AntiAliasing = iota
+
+	// AntiAliasingFXAA applies fast approximate anti-aliasing to the final image
+	AntiAliasingFXAA
+
+	// AntiAliasingTAA accumulates jittered frames over time
+	AntiAliasingTAA
+)
+
+func (a AntiAliasing) String() string {
+	switch a {
+	case AntiAliasingFXAA:
+		return "FXAA"
+	case AntiAliasingTAA:
+		return "TAA"
+	default:
+		return "None"
+	}
+}
+
+// DebugMode selects a debug visualization that replaces the final image
+type DebugMode int
+
+const (
+	DebugNone DebugMode = iota
+
+	// DebugAlbedo shows the diffuse color of the geometry buffer
+	DebugAlbedo
+
+	// DebugNormals shows the view space normals of the geometry buffer
+	DebugNormals
+
+	// DebugPosition shows the view space positions of the geometry buffer
+	DebugPosition
+
+	// DebugDepth shows the linearized depth buffer
+	DebugDepth
+
+	// DebugOcclusion shows the blurred ambient occlusion term
+	DebugOcclusion
+
+	// DebugCascades tints directional light by the shadow cascade it samples
+	DebugCascades
+
+	// DebugLightCount shows a heat map of the number of lights affecting each light cluster
+	DebugLightCount
+
+	// DebugOverdraw shows a heat map of the number of fragments drawn to each pixel
+	DebugOverdraw
+
+	debugModeCount
+)
+
+func (m DebugMode) String() string {
+	switch m {
+	case DebugAlbedo:
+		return "Albedo"
+	case DebugNormals:
+		return "Normals"
+	case DebugPosition:
+		return "Position"
+	case DebugDepth:
+		return "Depth"
+	case DebugOcclusion:
+		return "Occlusion"
+	case DebugCascades:
+		return "Cascades"
+	case DebugLightCount:
+		return "Light Count"
+	case DebugOverdraw:
+		return "Overdraw"
+	default:
+		return "None"
+	}
+}
+
+// Next returns the debug mode following m, wrapping around to DebugNone
+func (m DebugMode) Next() DebugMode {
+	return (m + 1) % debugModeCount
+}
+
+// Prev returns the debug mode preceding m, wrapping around to the last mode
+func (m DebugMode) Prev() DebugMode {
+	return (m + debugModeCount - 1) % debugModeCount
+}
+
+// ShadowFilter selects how shadow maps are filtered. Must match the filters in lighting.glsl
+type ShadowFilter int
+
+const (
+	// ShadowFilterPCF averages a fixed radius Poisson disk of samples
+	ShadowFilterPCF ShadowFilter = iota
+
+	// ShadowFilterPCSS varies the filter radius with the distance between the blocker and the receiver,
+	// softening shadows far from their casters. Requires an additional blocker search.
+	ShadowFilterPCSS
+
+	// ShadowFilterHard takes a single sample
+	ShadowFilterHard
+
+	shadowFilterCount
+)
+
+func (f ShadowFilter) String() string {
+	switch f {
+	case ShadowFilterPCSS:
+		return "PCSS"
+	case ShadowFilterHard:
+		return "Hard"
+	default:
+		return "PCF"
+	}
+}
+
+// Next returns the shadow filter following f, wrapping around to ShadowFilterPCF
+func (f ShadowFilter) Next() ShadowFilter {
+	return (f + 1) % shadowFilterCount
+}
+
+// ReflectionQuality selects a quality preset for screen space reflections
+type ReflectionQuality int
+
+const (
+	ReflectionsOff ReflectionQuality = iota
+	ReflectionsLow
+	ReflectionsMedium
+	ReflectionsHigh
+)
+
+func (q ReflectionQuality) String() string {
+	switch q {
+	case ReflectionsLow:
+		return "Low"
+	case ReflectionsMedium:
+		return "Medium"
+	case ReflectionsHigh:
+		return "High"
+	default:
+		return "Off"
+	}
+}
+
+// Next returns the quality preset following q, wrapping around to ReflectionsOff
+func (q ReflectionQuality) Next() ReflectionQuality {
+	return (q + 1) % (ReflectionsHigh + 1)
+}
+
+// RenderSettings holds renderer options that can be changed between frames
+type RenderSettings struct {
+	AntiAliasing AntiAliasing
+
+	// Debug selects a debug visualization of the scene
+	Debug DebugMode
+
+	// Wireframe draws the edges of all meshes on top of the final image
+	Wireframe bool
+
+	// Shadows selects the filtering of shadow maps
+	Shadows ShadowFilter
+
+	// Reflections selects the quality of screen space reflections on reflective meshes
+	Reflections ReflectionQuality
+
+	// ResolutionScale is the scale of the scene render targets relative to the output,
+	// which is upscaled before overlays such as the GUI are drawn. Zero renders at full resolution.
+	// Changing the scale recreates the render targets.
+	ResolutionScale float32
+
+	// DynamicResolution adjusts ResolutionScale to keep the measured frame time within a budget.
+	// The render targets are recreated at most once per graph.ResizeInterval. Optional
+	DynamicResolution *resolution.Controller
+}
+
+// Resolution returns the effective resolution scale, in the range (0, 1]
+func (s *RenderSettings) Resolution() float32 {
+	if s.ResolutionScale <= 0 {
+		return 1
+	}
+	return min(s.ResolutionScale, 1)
+}
+
+// CullStats holds the results of GPU culling in a single pass
+type CullStats struct {
+	// Objects is the number of objects submitted for culling
+	Objects int
+
+	// Frustum is the number of objects outside the view frustum
+	Frustum int
+
+	// Occluded is the number of objects hidden behind other geometry
+	Occluded int
+
+	// Overflow is the number of objects that did not fit in the culling buffers, and were drawn without culling
+	Overflow int
+}
+
+// Visible returns the number of objects that were drawn
+func (s CullStats) Visible() int {
+	return s.Objects + s.Overflow - s.Frustum - s.Occluded
+}
+
+// RenderStats holds debug counters collected by the renderer.
+// Counters read back from the GPU lag a few frames behind.
+type RenderStats struct {
+	// Culling holds culling counters by pass name
+	Culling map[string]CullStats
+}
+
+// SetCulling stores the culling counters of a pass
+func (s *RenderStats) SetCulling(pass string, stats CullStats) {
+	if s.Culling == nil {
+		s.Culling = make(map[string]CullStats)
+	}
+	s.Culling[pass] = stats
+}
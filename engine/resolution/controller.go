@@ -0,0 +1,114 @@
+package resolution
+
+// Args configures a dynamic resolution controller
+type Args struct {
+	// Budget is the target frame time, in seconds
+	Budget float32
+
+	// Min and Max bound the resolution scale
+	Min float32
+	Max float32
+
+	// Step is the change in scale made by each adjustment
+	Step float32
+
+	// Hysteresis is the fraction of the budget that the average frame time must deviate by before the scale
+	// is adjusted. The scale is lowered above Budget * (1 + Hysteresis), and raised below Budget * (1 - Hysteresis).
+	Hysteresis float32
+
+	// Window is the number of frames averaged before each decision
+	Window int
+
+	// Cooldown is the number of frames ignored after an adjustment,
+	// since the first frames after changing the resolution include the cost of recreating the render targets.
+	Cooldown int
+}
+
+// DefaultArgs targets 60 Hz displays. The budget is set above the refresh interval,
+// so that frames presented at the refresh rate allow the scale to increase.
+func DefaultArgs() Args {
+	return Args{
+		Budget:     1.0 / 50,
+		Min:        0.5,
+		Max:        1,
+		Step:       0.1,
+		Hysteresis: 0.1,
+		Window:     30,
+		Cooldown:   10,
+	}
+}
+
+// Controller adjusts the resolution scale of the renderer towards a frame time budget.
+//
+// Frame times are averaged over a window of frames. When the average is outside of the hysteresis band
+// around the budget, the scale is moved one step in the appropriate direction, and the following frames
+// are ignored until the cost of the change has settled.
+//
+// Frame times are usually measured on the CPU between frames, which includes waiting for the GPU.
+// When presentation is synchronized to the display, frames never complete faster than the refresh interval,
+// so the budget should leave some room above it for the controller to raise the scale again.
+type Controller struct {
+	args  Args
+	scale float32
+	sum   float32
+	count int
+	skip  int
+}
+
+// New returns a controller starting at the maximum scale
+func New(args Args) *Controller {
+	if args.Budget <= 0 {
+		panic("resolution budget must be positive")
+	}
+	if args.Min <= 0 || args.Max < args.Min {
+		panic("resolution bounds must be positive and ordered")
+	}
+	if args.Window < 1 {
+		args.Window = 1
+	}
+	return &Controller{
+		args:  args,
+		scale: args.Max,
+	}
+}
+
+// Scale returns the current resolution scale
+func (c *Controller) Scale() float32 {
+	return c.scale
+}
+
+// Update records the time of a frame, in seconds, and returns the resolution scale to use for the next frame
+func (c *Controller) Update(frameTime float32) float32 {
+	if c.skip > 0 {
+		c.skip--
+		return c.scale
+	}
+
+	c.sum += frameTime
+	c.count++
+	if c.count < c.args.Window {
+		return c.scale
+	}
+
+	average := c.sum / float32(c.count)
+	c.sum, c.count = 0, 0
+
+	scale := c.scale
+	switch {
+	case average > c.args.Budget*(1+c.args.Hysteresis):
+		scale = max(c.scale-c.args.Step, c.args.Min)
+	case average < c.args.Budget*(1-c.args.Hysteresis):
+		scale = min(c.scale+c.args.Step, c.args.Max)
+	}
+	if scale != c.scale {
+		c.scale = scale
+		c.skip = c.args.Cooldown
+	}
+	return c.scale
+}
+
+// Reset discards the recorded frame times and returns the scale to the maximum
+func (c *Controller) Reset() {
+	c.scale = c.args.Max
+	c.sum, c.count, c.skip = 0, 0, 0
+}
@@ -0,0 +1,60 @@
+package resolution_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/engine/resolution"
+)
+
+var _ = Describe("resolution controller", func() {
+	args := resolution.Args{
+		Budget:     0.01,
+		Min:        0.5,
+		Max:        1,
+		Step:       0.25,
+		Hysteresis: 0.2,
+		Window:     4,
+		Cooldown:   2,
+	}
+
+	run := func(c *resolution.Controller, frameTime float32, frames int) float32 {
+		scale := c.Scale()
+		for i := 0; i < frames; i++ {
+			scale = c.Update(frameTime)
+		}
+		return scale
+	}
+
+	It("lowers the scale when over budget, down to the minimum", func() {
+		c := resolution.New(args)
+		Expect(run(c, 0.02, 3)).To(Equal(float32(1)))
+		Expect(run(c, 0.02, 1)).To(Equal(float32(0.75)))
+
+		// cooldown frames and a full window are required before the next step
+		Expect(run(c, 0.02, 5)).To(Equal(float32(0.75)))
+		Expect(run(c, 0.02, 1)).To(Equal(float32(0.5)))
+		Expect(run(c, 0.02, 100)).To(Equal(float32(0.5)))
+	})
+
+	It("raises the scale when under budget, up to the maximum", func() {
+		c := resolution.New(args)
+		run(c, 0.02, 10)
+		Expect(c.Scale()).To(Equal(float32(0.5)))
+		Expect(run(c, 0.005, 100)).To(Equal(float32(1)))
+	})
+
+	It("keeps the scale within the hysteresis band", func() {
+		c := resolution.New(args)
+		run(c, 0.02, 4)
+		Expect(c.Scale()).To(Equal(float32(0.75)))
+		Expect(run(c, 0.0085, 100)).To(Equal(float32(0.75)))
+		Expect(run(c, 0.0115, 100)).To(Equal(float32(0.75)))
+	})
+
+	It("averages frame times over the window", func() {
+		c := resolution.New(args)
+		run(c, 0.005, 2)
+		Expect(run(c, 0.015, 2)).To(Equal(float32(1)))
+	})
+})
@@ -0,0 +1,13 @@
+package resolution_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestResolution(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "engine/resolution")
+}
@@ -2,18 +2,25 @@ package engine
 
 import (
 	"fmt"
+	"maps"
 	"runtime"
+	"slices"
 
+	"github.com/johanhenriksson/goworld/core/input/mouse"
 	"github.com/johanhenriksson/goworld/core/object"
+	"github.com/johanhenriksson/goworld/engine/resolution"
 	"github.com/johanhenriksson/goworld/gui"
 	"github.com/johanhenriksson/goworld/gui/node"
 	"github.com/johanhenriksson/goworld/gui/style"
+	"github.com/johanhenriksson/goworld/gui/widget/button"
 	"github.com/johanhenriksson/goworld/gui/widget/label"
 	"github.com/johanhenriksson/goworld/gui/widget/rect"
 	"github.com/johanhenriksson/goworld/render/color"
 )
 
-func NewStatsGUI(pool object.Pool) gui.Fragment {
+// NewStatsGUI creates a GUI fragment displaying frame timings, memory usage and renderer counters.
+// Renderer counters are optional. If render settings are given, buttons to toggle the debug views are shown.
+func NewStatsGUI(pool object.Pool, stats *RenderStats, settings *RenderSettings) gui.Fragment {
 	lastAlloc := uint64(0)
 	timer := NewFrameCounter(100)
 
@@ -30,6 +37,61 @@ func NewStatsGUI(pool object.Pool) gui.Fragment {
 			frameAlloc := (m.TotalAlloc - lastAlloc) / 1024
 			lastAlloc = m.TotalAlloc
 
+			children := []node.T{
+				label.New("fps", label.Props{
+					Text: fmt.Sprintf("fps=%.1f", avgFps),
+					Style: label.Style{
+						Color: color.White,
+					},
+				}),
+				label.New("mem", label.Props{
+					Text: fmt.Sprintf("heap=%dmb alloc=%dkb gc=%d", heapAlloc, frameAlloc, m.NumGC),
+					Style: label.Style{
+						Color: color.White,
+					},
+				}),
+			}
+			if stats != nil {
+				for _, pass := range slices.Sorted(maps.Keys(stats.Culling)) {
+					cull := stats.Culling[pass]
+					children = append(children, label.New("cull-"+pass, label.Props{
+						Text: fmt.Sprintf("%s: drawn=%d/%d frustum=%d occluded=%d overflow=%d", pass, cull.Visible(), cull.Objects+cull.Overflow, cull.Frustum, cull.Occluded, cull.Overflow),
+						Style: label.Style{
+							Color: color.White,
+						},
+					}))
+				}
+			}
+			if settings != nil {
+				children = append(children, rect.New("debug", rect.Props{
+					Style: rect.Style{
+						Layout: style.Row{},
+					},
+					Children: []node.T{
+						debugButton("debug-mode", fmt.Sprintf("view: %s", settings.Debug), func() {
+							settings.Debug = settings.Debug.Next()
+						}),
+						debugButton("debug-wireframe", fmt.Sprintf("wireframe: %s", onOff(settings.Wireframe)), func() {
+							settings.Wireframe = !settings.Wireframe
+						}),
+						debugButton("debug-shadows", fmt.Sprintf("shadows: %s", settings.Shadows), func() {
+							settings.Shadows = settings.Shadows.Next()
+						}),
+						debugButton("debug-reflections", fmt.Sprintf("reflections: %s", settings.Reflections), func() {
+							settings.Reflections = settings.Reflections.Next()
+						}),
+						debugButton("debug-resolution", resolutionText(settings), func() {
+							if settings.DynamicResolution == nil {
+								settings.DynamicResolution = resolution.New(resolution.DefaultArgs())
+							} else {
+								settings.DynamicResolution = nil
+								settings.ResolutionScale = 0
+							}
+						}),
+					},
+				}))
+			}
+
 			return rect.New("stats", rect.Props{
 				Style: rect.Style{
 					Position: style.Absolute{
@@ -39,21 +101,42 @@ func NewStatsGUI(pool object.Pool) gui.Fragment {
 					Layout:     style.Column{},
 					AlignItems: style.AlignEnd,
 				},
-				Children: []node.T{
-					label.New("fps", label.Props{
-						Text: fmt.Sprintf("fps=%.1f", avgFps),
-						Style: label.Style{
-							Color: color.White,
-						},
-					}),
-					label.New("mem", label.Props{
-						Text: fmt.Sprintf("heap=%dmb alloc=%dkb gc=%d", heapAlloc, frameAlloc, m.NumGC),
-						Style: label.Style{
-							Color: color.White,
-						},
-					}),
-				},
+				Children: children,
 			})
 		},
 	})
 }
+
+func debugButton(key, text string, onClick func()) node.T {
+	return button.New(key, button.Props{
+		Text: text,
+		Style: button.Style{
+			TextColor: color.White,
+			BgColor:   color.RGBA(0, 0, 0, 0.5),
+			Padding:   style.RectXY(6, 2),
+			Margin:    style.Px(2),
+			Radius:    style.Px(4),
+			Hover: button.Hover{
+				BgColor: color.RGBA(0.2, 0.2, 0.2, 0.7),
+			},
+		},
+		OnClick: func(e mouse.Event) {
+			onClick()
+		},
+	})
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+func resolutionText(settings *RenderSettings) string {
+	text := fmt.Sprintf("resolution: %.0f%%", 100*settings.Resolution())
+	if settings.DynamicResolution != nil {
+		text += " (auto)"
+	}
+	return text
+}
@@ -0,0 +1,22 @@
+package uniform
+
+import "structs"
+
+type TemporalAA struct {
+	_ structs.HostLayout
+
+	// Feedback is the weight of the history buffer in the resolved color
+	Feedback float32
+
+	// Reset discards the history buffer
+	Reset int32
+	_     [2]float32
+}
+
+type FXAA struct {
+	_ structs.HostLayout
+
+	// Enabled applies FXAA, otherwise the input is copied as is
+	Enabled int32
+	_       [3]float32
+}
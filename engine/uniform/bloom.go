@@ -0,0 +1,17 @@
+package uniform
+
+import "structs"
+
+type Bloom struct {
+	_ structs.HostLayout
+
+	Threshold     float32
+	Knee          float32
+	Radius        float32
+	Intensity     float32
+	DirtIntensity float32
+
+	// Prefilter is set on the first downsample, which applies the threshold
+	Prefilter int32
+	_         [2]float32
+}
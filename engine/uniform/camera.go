@@ -23,9 +23,17 @@ type Camera struct {
 	Viewport    vec2.T
 	Delta       float32
 	Time        float32
+
+	// unjittered view projection of the previous frame
+	PrevViewProj mat4.T
+
+	// sub-pixel projection offset in normalized device coordinates
+	Jitter vec2.T
+	_      [2]float32
 }
 
 func CameraFromArgs(args draw.Args) Camera {
+	width, height := args.Camera.Viewport.RenderSize()
 	return Camera{
 		Proj:        args.Camera.Proj,
 		View:        args.Camera.View,
@@ -35,11 +43,14 @@ func CameraFromArgs(args draw.Args) Camera {
 		ViewProjInv: args.Camera.ViewProjInv,
 		Eye:         vec4.Extend(args.Camera.Position, 0),
 		Forward:     vec4.Extend(args.Camera.Forward, 0),
-		Viewport:    vec2.NewI(args.Camera.Viewport.Width, args.Camera.Viewport.Height),
+		Viewport:    vec2.NewI(width, height),
 
 		// todo: timing values should not be part of the camera
 
 		Delta: args.Delta,
 		Time:  args.Time,
+
+		PrevViewProj: args.Camera.PrevViewProj,
+		Jitter:       args.Camera.Jitter,
 	}
 }
@@ -0,0 +1,24 @@
+package uniform
+
+import (
+	"structs"
+)
+
+// ClusterLightsPerItem is the number of light indices packed into each ClusterLights element
+const ClusterLightsPerItem = 4
+
+// Cluster holds the range of light indices affecting a single cluster
+type Cluster struct {
+	_ structs.HostLayout
+
+	Offset uint32
+	Count  uint32
+	_      [2]uint32
+}
+
+// ClusterLights packs light indices into 16 byte elements (uvec4 in glsl)
+type ClusterLights struct {
+	_ structs.HostLayout
+
+	Index [ClusterLightsPerItem]uint32
+}
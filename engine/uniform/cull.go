@@ -0,0 +1,93 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec4"
+)
+
+// Cull holds the parameters of the draw culling compute shader
+type Cull struct {
+	_ structs.HostLayout
+
+	// Planes of the view frustum. Points with a positive distance to all planes are inside
+	Planes [6]vec4.T
+
+	// ViewProj is the view projection of the current frame, used to test against the current depth pyramid
+	ViewProj mat4.T
+
+	// PrevViewProj is the view projection of the frame the tested depth pyramid was built in
+	PrevViewProj mat4.T
+
+	// PyramidSize is the size of the first depth pyramid level, in texels
+	PyramidSize vec2.T
+
+	// Count is the number of draws to cull
+	Count uint32
+
+	// MaxDraws is the number of draw commands reserved for each culling phase
+	MaxDraws uint32
+
+	// Levels is the number of depth pyramid levels
+	Levels uint32
+
+	// Occlusion is non-zero if draws should be tested against the depth pyramid
+	Occlusion uint32
+	_         [2]uint32
+}
+
+// CullPhase selects the culling phase using a push constant
+type CullPhase struct {
+	_ structs.HostLayout
+
+	// Phase is zero for the first phase, which tests against the depth pyramid of a previous frame.
+	// The second phase retests draws that were occluded in the first phase against the depth pyramid of the current frame.
+	Phase uint32
+}
+
+// CullDraw is a single draw submitted to the culling compute shader
+type CullDraw struct {
+	_ structs.HostLayout
+
+	// Bounds is the bounding sphere of the mesh in model space.
+	// Draws with a non-positive radius are never culled.
+	Bounds vec4.T
+
+	// Object is the handle of the object in the object buffer
+	Object uint32
+
+	// Vertices is the number of vertices to draw
+	Vertices uint32
+
+	// Instances is the number of instances to draw
+	Instances uint32
+
+	// Offset is the index of the first draw command of the render group in the command buffer
+	Offset uint32
+
+	// Group is the index of the render group, used to select the draw counter
+	Group uint32
+
+	// Occluded is set by the first culling phase if the draw should be retested in the second phase
+	Occluded uint32
+	_        [2]uint32
+}
+
+// CullStats holds debug counters written by the culling compute shader
+type CullStats struct {
+	_ structs.HostLayout
+
+	// Objects is the number of draws tested
+	Objects uint32
+
+	// Frustum is the number of draws outside the view frustum
+	Frustum uint32
+
+	// Occluded is the number of draws hidden by the depth pyramid in the first phase
+	Occluded uint32
+
+	// Recovered is the number of occluded draws found visible in the second phase
+	Recovered uint32
+}
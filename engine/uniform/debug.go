@@ -0,0 +1,18 @@
+package uniform
+
+import "structs"
+
+// DebugView holds the parameters of the debug visualization pass
+type DebugView struct {
+	_ structs.HostLayout
+
+	// Mode is the engine.DebugMode to display
+	Mode int32
+
+	// Far is the depth mapped to white in the depth view
+	Far float32
+
+	// MaxOverdraw is the fragment count mapped to the hottest color in the overdraw view
+	MaxOverdraw float32
+	_           float32
+}
@@ -0,0 +1,30 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+type Decal struct {
+	_ structs.HostLayout
+
+	// Model maps a unit cube onto the projection box
+	Model mat4.T
+
+	// ModelInv maps world space into the unit cube
+	ModelInv mat4.T
+
+	Color   color.T
+	Diffuse uint32
+
+	// Normal is the sampler index of the normal map, or -1 if the decal has none
+	Normal int32
+
+	// Layers is the mask of mesh layers that receive the decal
+	Layers uint32
+
+	// AngleFade is the cosine of the angle at which the decal starts fading out
+	AngleFade float32
+}
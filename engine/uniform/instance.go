@@ -0,0 +1,20 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// Instance holds the per-instance properties of an instanced mesh.
+// Instances are referenced by address from the object buffer.
+type Instance struct {
+	_ structs.HostLayout
+
+	// Model is the transform of the instance, relative to the object
+	Model mat4.T
+
+	// Color is multiplied with the vertex color
+	Color color.T
+}
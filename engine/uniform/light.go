@@ -11,14 +11,26 @@ import (
 )
 
 const ShadowCascades = 4
-const LightPadding = 74
+const ShadowMaps = 6
+const LightPadding = 85
+
+// EnvironmentLevels is the number of prefiltered specular environment maps. Must match ibl.SpecularLevels
+const EnvironmentLevels = 5
 
 type Light struct {
 	_ structs.HostLayout
 
-	ViewProj  [ShadowCascades]mat4.T
-	Shadowmap [ShadowCascades]uint32
-	Distance  [ShadowCascades]float32
+	ViewProj [ShadowMaps]mat4.T
+
+	// ShadowRegion holds the offset of each shadow map within the shadow atlas in xy, and its scale in zw
+	ShadowRegion [ShadowMaps]vec4.T
+
+	// ShadowParams holds the depth bias of each shadow map in x, the normal offset in y,
+	// and the penumbra scale used by soft shadows in z
+	ShadowParams [ShadowMaps]vec4.T
+
+	Shadowmap [ShadowMaps]uint32
+	Distance  [ShadowMaps]float32
 	Color     color.T
 	Position  vec4.T
 	Type      uint32
@@ -37,6 +49,28 @@ type LightSettings struct {
 	ShadowSampleRadius float32
 	ShadowBias         float32
 	NormalOffset       float32
+	ShadowFilter       int32
+	ClusterX           int32
+	ClusterY           int32
+	ClusterZ           int32
+	ClusterNear        float32
+	ClusterFar         float32
+	EnvIntensity       float32
+	EnvIrradiance      int32
+	EnvBRDF            int32
+	EnvSpecular        [EnvironmentLevels]int32
+	_                  [4]int32 // std430 aligns vec4 arrays to 16 bytes
+	SkySH              [9]vec4.T
+	SkyAmbient         int32
+	FogDensity         float32
+	FogHeight          float32
+	FogFalloff         float32
+	FogColor           color.T
+	FogSun             vec4.T // direction towards the sun, scattering strength in w
+	FogSunColor        color.T
+	FogStart           float32
+	FogEnabled         int32
+	DebugMode          int32
 	_padding           [LightPadding]uint32
 }
 
@@ -22,8 +22,8 @@ func NewLightBuffer(capacity int) *LightBuffer {
 			AmbientIntensity: 0.4,
 
 			ShadowBias:         0.005,
-			ShadowSampleRadius: 1,
-			ShadowSamples:      1,
+			ShadowSampleRadius: 1.5,
+			ShadowSamples:      16,
 			NormalOffset:       0.1,
 		},
 	}
@@ -48,3 +48,13 @@ func (b *LightBuffer) Reset() {
 func (b *LightBuffer) Store(light Light) {
 	b.buffer = append(b.buffer, light)
 }
+
+// Lights returns the lights currently stored in the buffer
+func (b *LightBuffer) Lights() []Light {
+	return b.buffer[1:]
+}
+
+// Settings returns a pointer to the light settings written on the next flush
+func (b *LightBuffer) Settings() *LightSettings {
+	return &b.settings
+}
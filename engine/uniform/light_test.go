@@ -0,0 +1,47 @@
+package uniform_test
+
+import (
+	"reflect"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/engine/uniform"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+var _ = Describe("light settings", func() {
+	settings := uniform.LightSettings{}
+
+	It("matches the std430 layout of the shader", func() {
+		Expect(unsafe.Offsetof(settings.EnvSpecular)).To(Equal(uintptr(76)))
+		Expect(unsafe.Offsetof(settings.SkySH)).To(Equal(uintptr(112)))
+		Expect(unsafe.Offsetof(settings.SkyAmbient)).To(Equal(uintptr(256)))
+		Expect(unsafe.Offsetof(settings.FogColor)).To(Equal(uintptr(272)))
+		Expect(unsafe.Offsetof(settings.FogSun)).To(Equal(uintptr(288)))
+		Expect(unsafe.Offsetof(settings.FogSunColor)).To(Equal(uintptr(304)))
+		Expect(unsafe.Offsetof(settings.DebugMode)).To(Equal(uintptr(328)))
+	})
+
+	It("aligns vector fields to 16 bytes", func() {
+		vector := reflect.TypeOf(vec4.T{})
+		clr := reflect.TypeOf(color.T{})
+		t := reflect.TypeOf(settings)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			kind := field.Type
+			if kind.Kind() == reflect.Array {
+				kind = kind.Elem()
+			}
+			if kind == vector || kind == clr {
+				Expect(field.Offset%16).To(BeZero(), "field %s", field.Name)
+			}
+		}
+	})
+
+	It("has the same size as a light", func() {
+		Expect(unsafe.Sizeof(uniform.LightSettings{})).To(Equal(unsafe.Sizeof(uniform.Light{})))
+	})
+})
@@ -20,4 +20,16 @@ type Object struct {
 
 	Vertices device.Address
 	Indices  device.Address
+
+	// PrevModel is the model matrix of the previous frame, used to compute motion vectors
+	PrevModel mat4.T
+
+	// Layer is the mesh layer bitmask, used to mask screen space effects
+	Layer uint32
+
+	// Dither is the level of detail cross-fade factor. See lib/dither.glsl
+	Dither float32
+
+	// Instances is the address of the per-instance data of instanced meshes, or zero
+	Instances device.Address
 }
@@ -0,0 +1,25 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/core/draw"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// OutlineMask selects the outline group written to the outline mask
+type OutlineMask struct {
+	_ structs.HostLayout
+
+	// Group is the one-based index of the outline group
+	Group float32
+	_     [3]float32
+}
+
+// Outline holds the colors and pixel widths of each outline group
+type Outline struct {
+	_ structs.HostLayout
+
+	Colors [draw.MaxOutlines]color.T
+	Widths [draw.MaxOutlines]int32
+}
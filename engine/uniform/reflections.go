@@ -0,0 +1,29 @@
+package uniform
+
+import "structs"
+
+type Reflections struct {
+	_ structs.HostLayout
+
+	// Steps is the number of ray march steps
+	Steps int32
+
+	// Refinements is the number of binary search steps used to refine a hit
+	Refinements int32
+
+	// MaxDistance is the length of reflected rays, in view space units
+	MaxDistance float32
+
+	// Thickness is the depth of surfaces, used to reject rays passing behind them
+	Thickness float32
+
+	// EdgeFade is the fraction of the screen over which reflections fade out towards the edges
+	EdgeFade float32
+
+	// Layers is the mesh layer mask of surfaces receiving reflections
+	Layers uint32
+
+	// Reset is set when the history does not contain a previous frame
+	Reset int32
+	_     float32
+}
@@ -0,0 +1,30 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/math/vec4"
+)
+
+const (
+	SkyModeProcedural = 0
+	SkyModeSkybox     = 1
+)
+
+type Sky struct {
+	_ structs.HostLayout
+
+	SunDirection     vec4.T
+	Rayleigh         vec4.T
+	Mode             int32
+	Skybox           int32
+	Intensity        float32
+	SunIntensity     float32
+	PlanetRadius     float32
+	AtmosphereRadius float32
+	RayleighHeight   float32
+	MieHeight        float32
+	Mie              float32
+	MieG             float32
+	_                [2]float32
+}
@@ -0,0 +1,27 @@
+package uniform
+
+import "structs"
+
+type Exposure struct {
+	_ structs.HostLayout
+
+	MinEV float32
+	MaxEV float32
+	Speed float32
+	Delta float32
+	Low   float32
+	High  float32
+
+	// Reset discards the previous adapted value
+	Reset int32
+	_     float32
+}
+
+type Tonemap struct {
+	_ structs.HostLayout
+
+	Operator     int32
+	Auto         int32
+	Compensation float32
+	_            float32
+}
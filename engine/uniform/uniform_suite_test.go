@@ -0,0 +1,13 @@
+package uniform_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestUniform(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "engine/uniform")
+}
@@ -0,0 +1,35 @@
+package uniform
+
+import (
+	"structs"
+
+	"github.com/johanhenriksson/goworld/math/mat4"
+	"github.com/johanhenriksson/goworld/math/vec4"
+	"github.com/johanhenriksson/goworld/render/color"
+)
+
+// MaxWaves is the number of waves per water surface. See lib/water.glsl
+const MaxWaves = 8
+
+type Water struct {
+	_ structs.HostLayout
+
+	Model mat4.T
+
+	// Waves holds the direction of each wave in xy, its steepness in z and its wavelength in w
+	Waves [MaxWaves]vec4.T
+
+	// Absorption is the extinction coefficient of each color channel, per unit of distance
+	Absorption vec4.T
+
+	// Color is the color of light scattered by the water body
+	Color color.T
+
+	// FoamColor is the color of foam. Its alpha channel holds the depth below which foam appears
+	FoamColor color.T
+
+	Time       float32
+	WaveCount  int32
+	Refraction float32
+	_          float32
+}
@@ -175,3 +175,19 @@ func Snap(f, multiple float32) float32 {
 func Pow(f, x float32) float32 {
 	return float32(math.Pow(float64(f), float64(x)))
 }
+
+func Log(f float32) float32 {
+	return float32(math.Log(float64(f)))
+}
+
+func Exp(f float32) float32 {
+	return float32(math.Exp(float64(f)))
+}
+
+func Log2(f float32) float32 {
+	return float32(math.Log2(float64(f)))
+}
+
+func Exp2(f float32) float32 {
+	return float32(math.Exp2(float64(f)))
+}
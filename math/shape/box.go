@@ -0,0 +1,35 @@
+package shape
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+// Box is an axis-aligned bounding box
+type Box struct {
+	Min vec3.T
+	Max vec3.T
+}
+
+// BoxFromPoints returns the smallest box containing all the given points
+func BoxFromPoints(points ...vec3.T) Box {
+	box := Box{
+		Min: vec3.New(math.InfPos, math.InfPos, math.InfPos),
+		Max: vec3.New(math.InfNeg, math.InfNeg, math.InfNeg),
+	}
+	for _, p := range points {
+		box.Min = vec3.Min(box.Min, p)
+		box.Max = vec3.Max(box.Max, p)
+	}
+	return box
+}
+
+// ClosestPoint returns the point inside the box that is closest to the given point
+func (b *Box) ClosestPoint(point vec3.T) vec3.T {
+	return vec3.Max(b.Min, vec3.Min(b.Max, point))
+}
+
+func (b *Box) IntersectsSphere(s *Sphere) bool {
+	closest := b.ClosestPoint(s.Center)
+	return closest.Sub(s.Center).LengthSqr() <= s.Radius*s.Radius
+}
@@ -10,8 +10,10 @@ type Plane struct {
 	Distance float32
 }
 
+// normalize scales the plane equation so that its normal has unit length,
+// making DistanceToPoint return the actual distance to the plane
 func (p *Plane) normalize() {
-	length := p.Normal.LengthSqr() + p.Distance*p.Distance
+	length := p.Normal.Length()
 	p.Normal = p.Normal.Scaled(1 / length)
 	p.Distance /= length
 }
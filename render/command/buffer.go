@@ -13,6 +13,7 @@ import (
 	"github.com/johanhenriksson/goworld/render/renderpass"
 
 	"github.com/vkngwrapper/core/v2/core1_0"
+	"github.com/vkngwrapper/core/v2/core1_2"
 )
 
 type Buffer struct {
@@ -90,6 +91,46 @@ func (b *Buffer) CmdBindGraphicsDescriptor(layout *pipeline.Layout, index int, s
 	b.ptr.CmdBindDescriptorSets(core1_0.PipelineBindPointGraphics, layout.Ptr(), index, []core1_0.DescriptorSet{set.Ptr()}, nil)
 }
 
+func (b *Buffer) CmdBindComputePipeline(pipe *pipeline.Compute) {
+	b.ptr.CmdBindPipeline(core1_0.PipelineBindPointCompute, pipe.Ptr())
+}
+
+func (b *Buffer) CmdBindComputeDescriptor(layout *pipeline.Layout, index int, set descriptor.Set) {
+	b.ptr.CmdBindDescriptorSets(core1_0.PipelineBindPointCompute, layout.Ptr(), index, []core1_0.DescriptorSet{set.Ptr()}, nil)
+}
+
+func (b *Buffer) CmdDispatch(groupsX, groupsY, groupsZ int) {
+	b.ptr.CmdDispatch(groupsX, groupsY, groupsZ)
+}
+
+// CmdFillBuffer fills a range of a buffer with a repeated 32-bit value
+func (b *Buffer) CmdFillBuffer(dst buffer.T, offset, size int, value uint32) {
+	b.ptr.CmdFillBuffer(dst.Ptr(), offset, size, value)
+}
+
+// CmdBufferBarrier makes writes to a buffer available to subsequent commands
+func (b *Buffer) CmdBufferBarrier(srcStage, dstStage core1_0.PipelineStageFlags, srcAccess, dstAccess core1_0.AccessFlags, buf buffer.T) {
+	b.ptr.CmdPipelineBarrier(srcStage, dstStage, core1_0.DependencyFlags(0), nil, []core1_0.BufferMemoryBarrier{
+		{
+			SrcAccessMask: srcAccess,
+			DstAccessMask: dstAccess,
+			Buffer:        buf.Ptr(),
+			Offset:        0,
+			Size:          buf.Size(),
+		},
+	}, nil)
+}
+
+// CmdMemoryBarrier makes all memory writes of the source stages available to the destination stages
+func (b *Buffer) CmdMemoryBarrier(srcStage, dstStage core1_0.PipelineStageFlags, srcAccess, dstAccess core1_0.AccessFlags) {
+	b.ptr.CmdPipelineBarrier(srcStage, dstStage, core1_0.DependencyFlags(0), []core1_0.MemoryBarrier{
+		{
+			SrcAccessMask: srcAccess,
+			DstAccessMask: dstAccess,
+		},
+	}, nil, nil)
+}
+
 func (b *Buffer) CmdBindVertexBuffer(vtx buffer.T, offset int) {
 	binding := bufferBinding{buffer: vtx.Ptr(), offset: offset}
 	if b.vertex == binding {
@@ -120,6 +161,12 @@ func (b *Buffer) CmdDrawIndirect(buffer buffer.T, offset, count, stride int) {
 	b.ptr.CmdDrawIndirect(buffer.Ptr(), offset, count, stride)
 }
 
+// CmdDrawIndirectCount draws a number of indirect draw commands, read from the count buffer at the given offset.
+// At most maxDraws commands are drawn.
+func (b *Buffer) CmdDrawIndirectCount(buffer buffer.T, offset int, count buffer.T, countOffset, maxDraws, stride int) {
+	core1_2.PromoteCommandBuffer(b.ptr).CmdDrawIndirectCount(buffer.Ptr(), uint64(offset), count.Ptr(), uint64(countOffset), maxDraws, stride)
+}
+
 func (b *Buffer) CmdDrawIndexed(cmd DrawIndexed) {
 	b.ptr.CmdDrawIndexed(
 		int(cmd.IndexCount),
@@ -200,14 +247,22 @@ func (b *Buffer) CmdPushConstant(stages core1_0.ShaderStageFlags, offset int, va
 	if b.pipeline == nil {
 		panic("bind graphics pipeline first")
 	}
+	b.ptr.CmdPushConstants(b.pipeline.Layout().Ptr(), stages, offset, pushConstantBytes(value))
+}
+
+// CmdPushComputeConstant pushes a constant value to the compute stage of a compute pipeline
+func (b *Buffer) CmdPushComputeConstant(pipe *pipeline.Compute, offset int, value any) {
+	b.ptr.CmdPushConstants(pipe.Layout().Ptr(), core1_0.StageCompute, offset, pushConstantBytes(value))
+}
+
+func pushConstantBytes(value any) []byte {
 	// this is awkward
-	// ptr := reflect.ValueOf(value).UnsafePointer()
 	size := reflect.ValueOf(value).Elem().Type().Size()
 	ptr := reflect.ValueOf(value).UnsafePointer()
 	valueBytes := make([]byte, size)
 
 	device.Memcpy(unsafe.Pointer(&valueBytes[0]), ptr, int(size))
-	b.ptr.CmdPushConstants(b.pipeline.Layout().Ptr(), stages, offset, valueBytes)
+	return valueBytes
 }
 
 func (b *Buffer) CmdImageBarrier(srcMask, dstMask core1_0.PipelineStageFlags, image *image.Image, oldLayout, newLayout core1_0.ImageLayout, aspects core1_0.ImageAspectFlags, mipLevel, levels int) {
@@ -260,6 +315,28 @@ func (b *Buffer) CmdCopyImageToBuffer(src *image.Image, srcLayout core1_0.ImageL
 	})
 }
 
+// CmdCopyImageRegionToBuffer copies a rectangle of an image into a buffer, starting at the given byte offset
+func (b *Buffer) CmdCopyImageRegionToBuffer(src *image.Image, srcLayout core1_0.ImageLayout, aspects core1_0.ImageAspectFlags, x, y, width, height int, dst buffer.T, dstOffset int) {
+	b.ptr.CmdCopyImageToBuffer(src.Ptr(), srcLayout, dst.Ptr(), []core1_0.BufferImageCopy{
+		{
+			BufferOffset: dstOffset,
+			ImageSubresource: core1_0.ImageSubresourceLayers{
+				AspectMask: core1_0.ImageAspectFlags(aspects),
+				LayerCount: 1,
+			},
+			ImageOffset: core1_0.Offset3D{
+				X: x,
+				Y: y,
+			},
+			ImageExtent: core1_0.Extent3D{
+				Width:  width,
+				Height: height,
+				Depth:  1,
+			},
+		},
+	})
+}
+
 func (b *Buffer) CmdConvertImage(src *image.Image, srcLayout core1_0.ImageLayout, dst *image.Image, dstLayout core1_0.ImageLayout, aspects core1_0.ImageAspectFlags) {
 	b.ptr.CmdBlitImage(src.Ptr(), srcLayout, dst.Ptr(), dstLayout, []core1_0.ImageBlit{
 		{
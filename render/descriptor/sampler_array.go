@@ -85,7 +85,7 @@ func (d *SamplerArray) SetRange(offset int, textures texture.Array) {
 		if tex == nil {
 			panic(fmt.Sprintf("texture[%d] is null", i))
 		}
-		d.info[i] = core1_0.DescriptorImageInfo{
+		d.info[offset+i] = core1_0.DescriptorImageInfo{
 			Sampler:     tex.Ptr(),
 			ImageView:   tex.View().Ptr(),
 			ImageLayout: core1_0.ImageLayoutShaderReadOnlyOptimal,
@@ -15,6 +15,10 @@ type Storage[K comparable] struct {
 	Stages core1_0.ShaderStageFlags
 	Size   int
 
+	// Usage adds buffer usage flags, allowing the storage buffer to be used for other purposes,
+	// such as indirect draw commands written by compute shaders.
+	Usage core1_0.BufferUsageFlags
+
 	binding int
 	buffer  *buffer.Array[K]
 	set     Set
@@ -33,7 +37,7 @@ func (d *Storage[K]) Initialize(dev *device.Device, set Set, binding int) {
 	d.buffer = buffer.NewArray[K](dev, buffer.Args{
 		Key:    d.String(),
 		Size:   d.Size,
-		Usage:  core1_0.BufferUsageStorageBuffer,
+		Usage:  core1_0.BufferUsageStorageBuffer | d.Usage,
 		Memory: device.MemoryTypeShared,
 	})
 	d.write()
@@ -52,6 +56,11 @@ func (d *Storage[K]) Destroy() {
 	}
 }
 
+// Buffer returns the underlying storage buffer
+func (d *Storage[K]) Buffer() buffer.T {
+	return d.buffer
+}
+
 func (d *Storage[K]) Set(index int, data K) {
 	d.buffer.Set(index, data)
 }
@@ -71,11 +71,19 @@ func New(instance *instance.Instance, physDevice core1_0.PhysicalDevice) (*Devic
 	queue := mostSpecificQueue(core1_0.QueueGraphics | core1_0.QueueTransfer)
 	log.Println("worker queue:", queue)
 
+	core11Features := core1_2.PhysicalDeviceVulkan11Features{
+		// required for gl_BaseInstance, used to look up object data of instanced draws
+		ShaderDrawParameters: true,
+	}
+
 	core12Features := core1_2.PhysicalDeviceVulkan12Features{
 		BufferDeviceAddress: true,
 
 		ScalarBlockLayout: true,
 
+		// gpu-driven rendering
+		DrawIndirectCount: true,
+
 		ShaderInt8:                        true,
 		StorageBuffer8BitAccess:           true,
 		UniformAndStorageBuffer8BitAccess: true,
@@ -91,6 +99,8 @@ func New(instance *instance.Instance, physDevice core1_0.PhysicalDevice) (*Devic
 		DescriptorBindingSampledImageUpdateAfterBind:       true,
 		DescriptorBindingStorageBufferUpdateAfterBind:      true,
 		DescriptorBindingStorageTexelBufferUpdateAfterBind: true,
+
+		NextOptions: common.NextOptions{Next: core11Features},
 	}
 
 	dev, _, err := physDevice.CreateDevice(nil, core1_0.DeviceCreateInfo{
@@ -104,6 +114,7 @@ func New(instance *instance.Instance, physDevice core1_0.PhysicalDevice) (*Devic
 		EnabledFeatures: &core1_0.PhysicalDeviceFeatures{
 			IndependentBlend: true,
 			DepthClamp:       true,
+			FillModeNonSolid: true,
 
 			ShaderInt16: true,
 			ShaderInt64: true,
@@ -0,0 +1,188 @@
+package ibl
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/image"
+)
+
+// Faces is the number of faces in a cubemap.
+// Face order: +X, -X, +Y, -Y, +Z, -Z
+const Faces = 6
+
+// Cubemap holds linear RGB radiance for each face of a cube
+type Cubemap struct {
+	Size  int
+	Faces [Faces][]vec3.T
+}
+
+func NewCubemap(size int) *Cubemap {
+	cube := &Cubemap{Size: size}
+	for face := range cube.Faces {
+		cube.Faces[face] = make([]vec3.T, size*size)
+	}
+	return cube
+}
+
+// Direction returns the normalized direction through the face coordinates u, v in [0,1].
+// Matches the face selection of sampleCubeStrip in lighting.glsl
+func Direction(face int, u, v float32) vec3.T {
+	s, t := 2*u-1, 2*v-1
+	var dir vec3.T
+	switch face {
+	case 0:
+		dir = vec3.New(1, -t, -s)
+	case 1:
+		dir = vec3.New(-1, -t, s)
+	case 2:
+		dir = vec3.New(s, 1, t)
+	case 3:
+		dir = vec3.New(s, -1, -t)
+	case 4:
+		dir = vec3.New(s, -t, 1)
+	default:
+		dir = vec3.New(-s, -t, -1)
+	}
+	return dir.Normalized()
+}
+
+// FaceUV returns the face and face coordinates in [0,1] hit by a direction.
+// It is the inverse of Direction.
+func FaceUV(dir vec3.T) (int, float32, float32) {
+	a := dir.Abs()
+	var face int
+	var ma, s, t float32
+	switch {
+	case a.X >= a.Y && a.X >= a.Z:
+		ma = a.X
+		if dir.X > 0 {
+			face, s, t = 0, -dir.Z, -dir.Y
+		} else {
+			face, s, t = 1, dir.Z, -dir.Y
+		}
+	case a.Y >= a.Z:
+		ma = a.Y
+		if dir.Y > 0 {
+			face, s, t = 2, dir.X, dir.Z
+		} else {
+			face, s, t = 3, dir.X, -dir.Z
+		}
+	default:
+		ma = a.Z
+		if dir.Z > 0 {
+			face, s, t = 4, dir.X, -dir.Y
+		} else {
+			face, s, t = 5, -dir.X, -dir.Y
+		}
+	}
+	return face, (s/ma + 1) / 2, (t/ma + 1) / 2
+}
+
+// TexelDirection returns the direction through the center of a texel
+func (c *Cubemap) TexelDirection(face, x, y int) vec3.T {
+	size := float32(c.Size)
+	return Direction(face, (float32(x)+0.5)/size, (float32(y)+0.5)/size)
+}
+
+// TexelSolidAngle returns the solid angle covered by a texel
+func (c *Cubemap) TexelSolidAngle(x, y int) float32 {
+	size := float32(c.Size)
+	s := 2*(float32(x)+0.5)/size - 1
+	t := 2*(float32(y)+0.5)/size - 1
+	texel := 2 / size
+	return texel * texel / math.Pow(1+s*s+t*t, 1.5)
+}
+
+// At returns the texel at the given coordinates, clamped to the face edges
+func (c *Cubemap) At(face, x, y int) vec3.T {
+	x = math.Clamp(x, 0, c.Size-1)
+	y = math.Clamp(y, 0, c.Size-1)
+	return c.Faces[face][y*c.Size+x]
+}
+
+// Set the value of a texel
+func (c *Cubemap) Set(face, x, y int, value vec3.T) {
+	c.Faces[face][y*c.Size+x] = value
+}
+
+// Sample returns the bilinearly filtered radiance in the given direction.
+// Filtering does not cross face edges.
+func (c *Cubemap) Sample(dir vec3.T) vec3.T {
+	face, u, v := FaceUV(dir)
+	fx := u*float32(c.Size) - 0.5
+	fy := v*float32(c.Size) - 0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0, fy-y0
+	x, y := int(x0), int(y0)
+
+	top := vec3.Lerp(c.At(face, x, y), c.At(face, x+1, y), tx)
+	bottom := vec3.Lerp(c.At(face, x, y+1), c.At(face, x+1, y+1), tx)
+	return vec3.Lerp(top, bottom, ty)
+}
+
+// Resample returns a copy of the cubemap with a different face size
+func (c *Cubemap) Resample(size int) *Cubemap {
+	if size == c.Size {
+		out := NewCubemap(size)
+		for face := range c.Faces {
+			copy(out.Faces[face], c.Faces[face])
+		}
+		return out
+	}
+	out := NewCubemap(size)
+	out.each(func(face, x, y int, dir vec3.T) vec3.T {
+		return c.Sample(dir)
+	})
+	return out
+}
+
+// Downsample returns a cubemap of half the size using a 2x2 box filter
+func (c *Cubemap) Downsample() *Cubemap {
+	out := NewCubemap(max(c.Size/2, 1))
+	for face := range out.Faces {
+		for y := 0; y < out.Size; y++ {
+			for x := 0; x < out.Size; x++ {
+				sum := c.At(face, 2*x, 2*y).
+					Add(c.At(face, 2*x+1, 2*y)).
+					Add(c.At(face, 2*x, 2*y+1)).
+					Add(c.At(face, 2*x+1, 2*y+1))
+				out.Set(face, x, y, sum.Scaled(0.25))
+			}
+		}
+	}
+	return out
+}
+
+// MipChain returns the cubemap followed by successively downsampled copies, down to 1x1 faces
+func (c *Cubemap) MipChain() []*Cubemap {
+	chain := []*Cubemap{c}
+	for level := c; level.Size > 1; {
+		level = level.Downsample()
+		chain = append(chain, level)
+	}
+	return chain
+}
+
+// each computes the value of every texel in the cubemap
+func (c *Cubemap) each(texel func(face, x, y int, dir vec3.T) vec3.T) {
+	for face := range c.Faces {
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				c.Set(face, x, y, texel(face, x, y, c.TexelDirection(face, x, y)))
+			}
+		}
+	}
+}
+
+// Strip returns the cubemap as a half float image with all faces placed side by side horizontally.
+// This is the layout sampled by sampleCubeStrip in lighting.glsl
+func (c *Cubemap) Strip() *image.Data {
+	width := Faces * c.Size
+	pixels := make([]vec3.T, width*c.Size)
+	for face := range c.Faces {
+		for y := 0; y < c.Size; y++ {
+			copy(pixels[y*width+face*c.Size:], c.Faces[face][y*c.Size:(y+1)*c.Size])
+		}
+	}
+	return encodeHalf(width, c.Size, pixels)
+}
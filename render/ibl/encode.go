@@ -0,0 +1,57 @@
+package ibl
+
+import (
+	"encoding/binary"
+	gomath "math"
+
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/image"
+)
+
+// encodeHalf packs RGB pixels into an RGBA half float image with alpha set to 1
+func encodeHalf(width, height int, pixels []vec3.T) *image.Data {
+	one := halfFloat(1)
+	buffer := make([]byte, 8*len(pixels))
+	for i, p := range pixels {
+		binary.LittleEndian.PutUint16(buffer[8*i+0:], halfFloat(p.X))
+		binary.LittleEndian.PutUint16(buffer[8*i+2:], halfFloat(p.Y))
+		binary.LittleEndian.PutUint16(buffer[8*i+4:], halfFloat(p.Z))
+		binary.LittleEndian.PutUint16(buffer[8*i+6:], one)
+	}
+	return &image.Data{
+		Width:  width,
+		Height: height,
+		Format: image.FormatRGBA16Float,
+		Buffer: buffer,
+	}
+}
+
+// halfFloat converts a float32 to IEEE 754 half precision.
+// Values out of range are clamped to the largest finite half, and denormals are flushed to zero.
+func halfFloat(f float32) uint16 {
+	bits := gomath.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	if f != f {
+		// nan
+		return 0x7e00
+	}
+
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mantissa := bits & 0x7fffff
+	switch {
+	case exp <= 0:
+		return sign
+	case exp >= 31:
+		return sign | 0x7bff
+	}
+
+	// round to nearest
+	half := uint32(exp)<<10 | mantissa>>13
+	if mantissa&0x1000 != 0 {
+		half++
+	}
+	if half >= 0x7c00 {
+		half = 0x7bff
+	}
+	return sign | uint16(half)
+}
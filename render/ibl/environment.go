@@ -0,0 +1,178 @@
+package ibl
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/johanhenriksson/goworld/assets"
+	"github.com/johanhenriksson/goworld/assets/fs"
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/render/image"
+	"github.com/johanhenriksson/goworld/render/texture"
+)
+
+// SpecularLevels is the number of prefiltered specular maps, ranging from roughness 0 to 1
+const SpecularLevels = 5
+
+type Args struct {
+	// Face size of the environment cubemap and the first specular level
+	Size int
+
+	// Face size of the irradiance cubemap
+	IrradianceSize int
+
+	// Number of importance samples used when prefiltering specular levels
+	Samples int
+}
+
+func DefaultArgs() Args {
+	return Args{
+		Size:           128,
+		IrradianceSize: 32,
+		Samples:        64,
+	}
+}
+
+// Maps holds the precomputed image based lighting maps of an environment
+type Maps struct {
+	Environment *Cubemap
+	Irradiance  *Cubemap
+	Specular    [SpecularLevels]*Cubemap
+}
+
+// Roughness returns the roughness of a prefiltered specular level
+func Roughness(level int) float32 {
+	return float32(level) / float32(SpecularLevels-1)
+}
+
+// Compute converts an equirectangular environment to a cubemap and precomputes its lighting maps
+func Compute(src *Equirect, args Args) *Maps {
+	env := src.Cubemap(args.Size)
+	chain := env.MipChain()
+
+	maps := &Maps{
+		Environment: env,
+		Irradiance:  Irradiance(env, args.IrradianceSize),
+	}
+	for level := range maps.Specular {
+		size := math.Max(args.Size>>level, 8)
+		maps.Specular[level] = Prefilter(chain, size, Roughness(level), args.Samples)
+	}
+	return maps
+}
+
+// Environment lazily loads an equirectangular HDR image and precomputes its lighting maps.
+// Computation happens the first time one of its textures is loaded by the texture cache.
+// If the image fails to load, the environment is marked as failed and its textures are left black.
+type Environment struct {
+	path string
+	args Args
+
+	once   sync.Once
+	maps   *Maps
+	failed atomic.Bool
+
+	irradiance *mapRef
+	specular   [SpecularLevels]*mapRef
+}
+
+func Load(path string) *Environment {
+	return New(path, DefaultArgs())
+}
+
+func New(path string, args Args) *Environment {
+	env := &Environment{
+		path: path,
+		args: args,
+	}
+	env.irradiance = &mapRef{env: env, name: "irradiance"}
+	for level := range env.specular {
+		env.specular[level] = &mapRef{env: env, name: "specular", level: level}
+	}
+	return env
+}
+
+func (e *Environment) Path() string { return e.path }
+
+// Maps returns the precomputed lighting maps, loading the environment if required.
+// Returns nil if the environment image could not be loaded.
+func (e *Environment) Maps(filesystem fs.Filesystem) *Maps {
+	e.once.Do(func() {
+		img, err := image.LoadHDR(filesystem, e.path)
+		if err != nil {
+			log.Println("failed to load environment", e.path, ":", err)
+			e.failed.Store(true)
+			return
+		}
+		e.maps = Compute(EquirectFromHDR(img), e.args)
+	})
+	return e.maps
+}
+
+// Failed returns true if the environment image could not be loaded.
+// Failed environments should not be used for lighting.
+func (e *Environment) Failed() bool {
+	return e.failed.Load()
+}
+
+// Irradiance returns a texture reference to the diffuse irradiance map
+func (e *Environment) Irradiance() assets.Texture { return e.irradiance }
+
+// Specular returns a texture reference to a prefiltered specular level
+func (e *Environment) Specular(level int) assets.Texture { return e.specular[level] }
+
+type mapRef struct {
+	env   *Environment
+	name  string
+	level int
+}
+
+func (r *mapRef) Key() string  { return fmt.Sprintf("ibl:%s:%s:%d", r.env.path, r.name, r.level) }
+func (r *mapRef) Version() int { return 1 }
+
+func (r *mapRef) LoadTexture(filesystem fs.Filesystem) *texture.Data {
+	// failed environments produce a black placeholder, since the texture cache requires image data
+	cube := NewCubemap(1)
+	if maps := r.env.Maps(filesystem); maps != nil {
+		cube = maps.Irradiance
+		if r.name == "specular" {
+			cube = maps.Specular[r.level]
+		}
+	}
+	return &texture.Data{
+		Image: cube.Strip(),
+		Args: texture.Args{
+			Filter: texture.FilterLinear,
+			Wrap:   texture.WrapClamp,
+		},
+	}
+}
+
+// BRDF is the split sum lookup table shared by all environments
+var BRDF assets.Texture = &brdfRef{size: 32, samples: 128}
+
+type brdfRef struct {
+	size    int
+	samples int
+
+	once sync.Once
+	data *texture.Data
+}
+
+func (r *brdfRef) Key() string  { return "ibl:brdf" }
+func (r *brdfRef) Version() int { return 1 }
+
+func (r *brdfRef) LoadTexture(fs.Filesystem) *texture.Data {
+	r.once.Do(func() {
+		r.data = &texture.Data{
+			Image: BRDFImage(r.size, BRDFTable(r.size, r.samples)),
+			Args: texture.Args{
+				Filter: texture.FilterLinear,
+				Wrap:   texture.WrapClamp,
+			},
+		}
+	})
+	return r.data
+}
@@ -0,0 +1,86 @@
+package ibl
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/image"
+)
+
+// Equirect is an equirectangular (latitude/longitude) environment image.
+// The top row corresponds to +Y.
+type Equirect struct {
+	Width  int
+	Height int
+	Pixels []vec3.T
+}
+
+func NewEquirect(width, height int) *Equirect {
+	return &Equirect{
+		Width:  width,
+		Height: height,
+		Pixels: make([]vec3.T, width*height),
+	}
+}
+
+// EquirectFromHDR converts a decoded HDR image to an equirectangular environment
+func EquirectFromHDR(img *image.HDR) *Equirect {
+	eq := NewEquirect(img.Width, img.Height)
+	for i := range eq.Pixels {
+		eq.Pixels[i] = vec3.FromSlice(img.Pixels[3*i : 3*i+3])
+	}
+	return eq
+}
+
+// EquirectUV returns the image coordinates in [0,1] for a direction
+func EquirectUV(dir vec3.T) (float32, float32) {
+	dir = dir.Normalized()
+	u := 0.5 + math.Atan2(dir.X, dir.Z)/(2*math.Pi)
+	v := math.Acos(math.Clamp(dir.Y, -1, 1)) / math.Pi
+	return u, v
+}
+
+// At returns the pixel at the given coordinates.
+// Coordinates wrap horizontally and are clamped vertically.
+func (e *Equirect) At(x, y int) vec3.T {
+	x = ((x % e.Width) + e.Width) % e.Width
+	y = math.Clamp(y, 0, e.Height-1)
+	return e.Pixels[y*e.Width+x]
+}
+
+// Sample returns the bilinearly filtered radiance in the given direction
+func (e *Equirect) Sample(dir vec3.T) vec3.T {
+	u, v := EquirectUV(dir)
+	fx := u*float32(e.Width) - 0.5
+	fy := v*float32(e.Height) - 0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0, fy-y0
+	x, y := int(x0), int(y0)
+
+	top := vec3.Lerp(e.At(x, y), e.At(x+1, y), tx)
+	bottom := vec3.Lerp(e.At(x, y+1), e.At(x+1, y+1), tx)
+	return vec3.Lerp(top, bottom, ty)
+}
+
+// Cubemap projects the equirectangular image onto a cubemap.
+// Each texel is supersampled to reduce aliasing when the source is much larger than the cubemap.
+func (e *Equirect) Cubemap(size int) *Cubemap {
+	const subsamples = 2
+	cube := NewCubemap(size)
+	weight := 1 / float32(subsamples*subsamples)
+	for face := range cube.Faces {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				sum := vec3.Zero
+				for sy := 0; sy < subsamples; sy++ {
+					for sx := 0; sx < subsamples; sx++ {
+						u := (float32(x) + (float32(sx)+0.5)/subsamples) / float32(size)
+						v := (float32(y) + (float32(sy)+0.5)/subsamples) / float32(size)
+						sum = sum.Add(e.Sample(Direction(face, u, v)))
+					}
+				}
+				cube.Set(face, x, y, sum.Scaled(weight))
+			}
+		}
+	}
+	return cube
+}
@@ -0,0 +1,13 @@
+package ibl_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestIBL(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "render/ibl")
+}
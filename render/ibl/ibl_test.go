@@ -0,0 +1,169 @@
+package ibl_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/assets/fs"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/ibl"
+	"github.com/johanhenriksson/goworld/render/image"
+)
+
+func constantEnvironment(radiance vec3.T) *ibl.Equirect {
+	eq := ibl.NewEquirect(16, 8)
+	for i := range eq.Pixels {
+		eq.Pixels[i] = radiance
+	}
+	return eq
+}
+
+func expectColor(actual, expected vec3.T, tolerance float64) {
+	ExpectWithOffset(1, actual.X).To(BeNumerically("~", expected.X, tolerance))
+	ExpectWithOffset(1, actual.Y).To(BeNumerically("~", expected.Y, tolerance))
+	ExpectWithOffset(1, actual.Z).To(BeNumerically("~", expected.Z, tolerance))
+}
+
+var _ = Describe("cubemap", func() {
+	It("maps face coordinates back to the same face", func() {
+		for face := 0; face < ibl.Faces; face++ {
+			for _, uv := range [][2]float32{{0.5, 0.5}, {0.1, 0.8}, {0.9, 0.25}} {
+				f, u, v := ibl.FaceUV(ibl.Direction(face, uv[0], uv[1]))
+				Expect(f).To(Equal(face))
+				Expect(u).To(BeNumerically("~", uv[0], 1e-5))
+				Expect(v).To(BeNumerically("~", uv[1], 1e-5))
+			}
+		}
+	})
+
+	It("covers the full sphere with texel solid angles", func() {
+		cube := ibl.NewCubemap(16)
+		total := float32(0)
+		for y := 0; y < cube.Size; y++ {
+			for x := 0; x < cube.Size; x++ {
+				total += cube.TexelSolidAngle(x, y)
+			}
+		}
+		Expect(total * ibl.Faces).To(BeNumerically("~", 4*3.14159, 0.05))
+	})
+
+	It("projects equirectangular images onto cube faces", func() {
+		eq := ibl.NewEquirect(32, 16)
+		for x := 0; x < eq.Width; x++ {
+			// bright top half, dark bottom half
+			for y := 0; y < eq.Height/2; y++ {
+				eq.Pixels[y*eq.Width+x] = vec3.One
+			}
+		}
+		cube := eq.Cubemap(8)
+		expectColor(cube.Sample(vec3.UnitY), vec3.One, 1e-4)
+		expectColor(cube.Sample(vec3.UnitYN), vec3.Zero, 1e-4)
+	})
+})
+
+var _ = Describe("precomputation", func() {
+	radiance := vec3.New(0.5, 1, 2)
+
+	It("computes irradiance equal to a constant environment", func() {
+		env := constantEnvironment(radiance).Cubemap(16)
+		irradiance := ibl.Irradiance(env, 4)
+		for _, dir := range []vec3.T{vec3.UnitX, vec3.UnitYN, vec3.New(1, 1, -1)} {
+			expectColor(irradiance.Sample(dir), radiance, 0.02)
+		}
+	})
+
+	It("computes directional irradiance from a hemisphere", func() {
+		eq := ibl.NewEquirect(64, 32)
+		for x := 0; x < eq.Width; x++ {
+			for y := 0; y < eq.Height/2; y++ {
+				eq.Pixels[y*eq.Width+x] = vec3.One
+			}
+		}
+		sh := ibl.ProjectSH(eq.Cubemap(16))
+
+		// an upward facing surface sees the entire bright hemisphere
+		up, down, side := sh.Irradiance(vec3.UnitY), sh.Irradiance(vec3.UnitYN), sh.Irradiance(vec3.UnitX)
+		Expect(up.X).To(BeNumerically("~", 1, 0.1))
+		Expect(down.X).To(BeNumerically("~", 0, 0.1))
+		Expect(side.X).To(BeNumerically("~", 0.5, 0.05))
+	})
+
+	It("preserves a constant environment when prefiltering", func() {
+		maps := ibl.Compute(constantEnvironment(radiance), ibl.Args{
+			Size:           16,
+			IrradianceSize: 4,
+			Samples:        16,
+		})
+		for level := range maps.Specular {
+			expectColor(maps.Specular[level].Sample(vec3.UnitZ), radiance, 1e-3)
+		}
+	})
+
+	It("keeps mirror reflections sharp", func() {
+		env := constantEnvironment(vec3.Zero).Cubemap(16)
+		env.Set(4, 8, 8, vec3.One)
+		sharp := ibl.Prefilter(env.MipChain(), 16, 0, 16)
+		rough := ibl.Prefilter(env.MipChain(), 16, 1, 64)
+		Expect(sharp.At(4, 8, 8)).To(Equal(vec3.One))
+		Expect(rough.At(4, 8, 8).X).To(BeNumerically("<", 0.5))
+	})
+
+	It("integrates the split sum brdf", func() {
+		// smooth surfaces viewed head on reflect F0 exactly
+		smooth := ibl.IntegrateBRDF(1, 0.01, 128)
+		Expect(smooth.X).To(BeNumerically("~", 1, 0.02))
+		Expect(smooth.Y).To(BeNumerically("~", 0, 0.02))
+
+		// scale and bias never exceed one
+		table := ibl.BRDFTable(8, 64)
+		for _, v := range table {
+			Expect(v.X + v.Y).To(BeNumerically("<=", 1.001))
+		}
+
+		img := ibl.BRDFImage(8, table)
+		Expect(img.Format).To(Equal(image.FormatRGBA16Float))
+		Expect(img.Buffer).To(HaveLen(8 * 8 * 8))
+	})
+})
+
+var _ = Describe("hdr loading", func() {
+	It("decodes flat rgbe scanlines", func() {
+		data := []byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 2\n")
+		data = append(data, 128, 64, 0, 129, 0, 0, 0, 0)
+
+		img, err := image.DecodeHDR(bytes.NewReader(data))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(img.Width).To(Equal(2))
+		Expect(img.Height).To(Equal(1))
+		Expect(img.Pixels).To(Equal([]float32{1, 0.5, 0, 0, 0, 0}))
+	})
+
+	It("decodes run length encoded scanlines", func() {
+		data := []byte("#?RADIANCE\n\n-Y 1 +X 8\n")
+		data = append(data, 2, 2, 0, 8)
+		data = append(data, 136, 128)                  // r: run of 8
+		data = append(data, 136, 0)                    // g: run of 8
+		data = append(data, 8, 1, 2, 3, 4, 5, 6, 7, 8) // b: literals
+		data = append(data, 136, 129)                  // e: run of 8
+
+		img, err := image.DecodeHDR(bytes.NewReader(data))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(img.Pixels[0:3]).To(Equal([]float32{1, 0, 1.0 / 128}))
+		Expect(img.Pixels[21:24]).To(Equal([]float32{1, 0, 8.0 / 128}))
+	})
+})
+
+var _ = Describe("environment", func() {
+	It("falls back to black placeholders if the image fails to load", func() {
+		filesystem := fs.NewLocal(GinkgoT().TempDir())
+		env := ibl.Load("missing.hdr")
+		Expect(env.Maps(filesystem)).To(BeNil())
+		Expect(env.Failed()).To(BeTrue())
+
+		data := env.Specular(0).LoadTexture(filesystem)
+		Expect(data.Image.Width).To(Equal(ibl.Faces))
+		Expect(data.Image.Height).To(Equal(1))
+	})
+})
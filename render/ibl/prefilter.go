@@ -0,0 +1,142 @@
+package ibl
+
+import (
+	"math/bits"
+
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/image"
+)
+
+// Hammersley returns the i-th point of an n point low discrepancy sequence in [0,1)^2
+func Hammersley(i, n int) vec2.T {
+	return vec2.New(float32(i)/float32(n), float32(bits.Reverse32(uint32(i)))/float32(1<<32))
+}
+
+// ImportanceSampleGGX returns a half vector around the normal distributed according to the GGX distribution
+func ImportanceSampleGGX(xi vec2.T, normal vec3.T, roughness float32) vec3.T {
+	a := roughness * roughness
+	phi := 2 * math.Pi * xi.X
+	cosTheta := math.Sqrt((1 - xi.Y) / (1 + (a*a-1)*xi.Y))
+	sinTheta := math.Sqrt(1 - cosTheta*cosTheta)
+
+	// tangent space to world space
+	up := vec3.UnitZ
+	if math.Abs(normal.Z) >= 0.999 {
+		up = vec3.UnitX
+	}
+	tangent := vec3.Cross(up, normal).Normalized()
+	bitangent := vec3.Cross(normal, tangent)
+
+	return tangent.Scaled(math.Cos(phi) * sinTheta).
+		Add(bitangent.Scaled(math.Sin(phi) * sinTheta)).
+		Add(normal.Scaled(cosTheta)).
+		Normalized()
+}
+
+// distributionGGX evaluates the GGX normal distribution function
+func distributionGGX(NdotH, roughness float32) float32 {
+	a := roughness * roughness
+	a2 := a * a
+	d := NdotH*NdotH*(a2-1) + 1
+	return a2 / (math.Pi * d * d)
+}
+
+// geometrySmith evaluates the Smith-Schlick geometry term using the IBL remapping of k
+func geometrySmith(NdotV, NdotL, roughness float32) float32 {
+	k := roughness * roughness / 2
+	gv := NdotV / (NdotV*(1-k) + k)
+	gl := NdotL / (NdotL*(1-k) + k)
+	return gv * gl
+}
+
+// Prefilter convolves the environment with the GGX lobe of the given roughness, assuming that the
+// view direction equals the surface normal. The environment is given as a mip chain which is used
+// to reduce aliasing for wide lobes.
+func Prefilter(chain []*Cubemap, size int, roughness float32, samples int) *Cubemap {
+	env := chain[0]
+	if roughness <= 0 {
+		return env.Resample(size)
+	}
+
+	texelAngle := 4 * math.Pi / float32(Faces*env.Size*env.Size)
+	out := NewCubemap(size)
+	out.each(func(face, x, y int, normal vec3.T) vec3.T {
+		sum := vec3.Zero
+		weight := float32(0)
+		for i := 0; i < samples; i++ {
+			h := ImportanceSampleGGX(Hammersley(i, samples), normal, roughness)
+			NdotH := vec3.Dot(normal, h)
+			l := h.Scaled(2 * NdotH).Sub(normal)
+			NdotL := vec3.Dot(normal, l)
+			if NdotL <= 0 {
+				continue
+			}
+
+			// pick a source mip level matching the solid angle of the sample
+			pdf := distributionGGX(NdotH, roughness)/4 + 1e-4
+			sampleAngle := 1 / (float32(samples) * pdf)
+			level := 0.5*math.Log(sampleAngle/texelAngle)/math.Log(2) + 1
+			source := chain[math.Clamp(int(math.Round(level)), 0, len(chain)-1)]
+
+			sum = sum.Add(source.Sample(l).Scaled(NdotL))
+			weight += NdotL
+		}
+		if weight <= 0 {
+			return env.Sample(normal)
+		}
+		return sum.Scaled(1 / weight)
+	})
+	return out
+}
+
+// IntegrateBRDF computes the split sum scale and bias applied to F0 for a given view angle and roughness
+func IntegrateBRDF(NdotV, roughness float32, samples int) vec2.T {
+	NdotV = math.Max(NdotV, 1e-4)
+	view := vec3.New(math.Sqrt(1-NdotV*NdotV), 0, NdotV)
+	normal := vec3.UnitZ
+
+	var a, b float32
+	for i := 0; i < samples; i++ {
+		h := ImportanceSampleGGX(Hammersley(i, samples), normal, roughness)
+		VdotH := vec3.Dot(view, h)
+		l := h.Scaled(2 * VdotH).Sub(view)
+
+		NdotL := math.Max(l.Z, 0)
+		NdotH := math.Max(h.Z, 0)
+		VdotH = math.Max(VdotH, 0)
+		if NdotL <= 0 {
+			continue
+		}
+
+		g := geometrySmith(NdotV, NdotL, roughness)
+		visibility := g * VdotH / (NdotH * NdotV)
+		fresnel := math.Pow(1-VdotH, 5)
+		a += (1 - fresnel) * visibility
+		b += fresnel * visibility
+	}
+	return vec2.New(a, b).Scaled(1 / float32(samples))
+}
+
+// BRDFTable computes the split sum lookup table. The horizontal axis is NdotV and the vertical axis is roughness.
+func BRDFTable(size, samples int) []vec2.T {
+	table := make([]vec2.T, size*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			NdotV := (float32(x) + 0.5) / float32(size)
+			roughness := (float32(y) + 0.5) / float32(size)
+			table[y*size+x] = IntegrateBRDF(NdotV, roughness, samples)
+		}
+	}
+	return table
+}
+
+// BRDFImage encodes a split sum lookup table as a half float image
+func BRDFImage(size int, table []vec2.T) *image.Data {
+	pixels := make([]vec3.T, len(table))
+	for i, v := range table {
+		pixels[i] = vec3.Extend(v, 0)
+	}
+	return encodeHalf(size, size, pixels)
+}
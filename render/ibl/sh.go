@@ -0,0 +1,64 @@
+package ibl
+
+import (
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+// SH9 holds third order spherical harmonics coefficients for RGB radiance
+type SH9 [9]vec3.T
+
+// shBasis evaluates the real spherical harmonics basis functions for a normalized direction
+func shBasis(d vec3.T) [9]float32 {
+	return [9]float32{
+		0.282095,
+		0.488603 * d.Y,
+		0.488603 * d.Z,
+		0.488603 * d.X,
+		1.092548 * d.X * d.Y,
+		1.092548 * d.Y * d.Z,
+		0.315392 * (3*d.Z*d.Z - 1),
+		1.092548 * d.X * d.Z,
+		0.546274 * (d.X*d.X - d.Y*d.Y),
+	}
+}
+
+// ProjectSH projects the radiance of a cubemap onto spherical harmonics
+func ProjectSH(env *Cubemap) SH9 {
+	var sh SH9
+	for face := range env.Faces {
+		for y := 0; y < env.Size; y++ {
+			for x := 0; x < env.Size; x++ {
+				weight := env.TexelSolidAngle(x, y)
+				radiance := env.At(face, x, y).Scaled(weight)
+				basis := shBasis(env.TexelDirection(face, x, y))
+				for i := range sh {
+					sh[i] = sh[i].Add(radiance.Scaled(basis[i]))
+				}
+			}
+		}
+	}
+	return sh
+}
+
+// Irradiance returns the cosine weighted radiance arriving at a surface with the given normal.
+// The result is divided by pi, so that a constant environment yields its own radiance.
+func (sh *SH9) Irradiance(normal vec3.T) vec3.T {
+	// cosine lobe convolution factors per band (pi, 2pi/3, pi/4), divided by pi
+	bands := [9]float32{1, 2.0 / 3, 2.0 / 3, 2.0 / 3, 0.25, 0.25, 0.25, 0.25, 0.25}
+	basis := shBasis(normal.Normalized())
+	result := vec3.Zero
+	for i := range sh {
+		result = result.Add(sh[i].Scaled(bands[i] * basis[i]))
+	}
+	return vec3.Max(result, vec3.Zero)
+}
+
+// Irradiance computes a diffuse irradiance cubemap from an environment cubemap
+func Irradiance(env *Cubemap, size int) *Cubemap {
+	sh := ProjectSH(env)
+	out := NewCubemap(size)
+	out.each(func(face, x, y int, dir vec3.T) vec3.T {
+		return sh.Irradiance(dir)
+	})
+	return out
+}
@@ -3,3 +3,4 @@ package image
 import "github.com/vkngwrapper/core/v2/core1_0"
 
 const FormatRGBA8Unorm = core1_0.FormatR8G8B8A8UnsignedNormalized
+const FormatRGBA16Float = core1_0.FormatR16G16B16A16SignedFloat
@@ -0,0 +1,149 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/johanhenriksson/goworld/assets/fs"
+)
+
+var ErrInvalidHDR = errors.New("invalid radiance hdr image")
+
+// HDR holds linear floating point RGB pixel data, stored row by row starting at the top left corner.
+type HDR struct {
+	Width  int
+	Height int
+	Pixels []float32
+}
+
+// LoadHDR loads a Radiance RGBE (.hdr) image file
+func LoadHDR(assets fs.Filesystem, file string) (*HDR, error) {
+	data, err := assets.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeHDR(bytes.NewReader(data))
+}
+
+// DecodeHDR decodes a Radiance RGBE image. Only the standard -Y +X orientation is supported.
+func DecodeHDR(r io.Reader) (*HDR, error) {
+	reader := bufio.NewReader(r)
+
+	// parse header
+	magic, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(magic, "#?") {
+		return nil, ErrInvalidHDR
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidHDR, line)
+		}
+	}
+
+	// parse resolution string
+	var width, height int
+	resolution, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width); err != nil {
+		return nil, fmt.Errorf("%w: unsupported resolution %s", ErrInvalidHDR, strings.TrimSpace(resolution))
+	}
+
+	img := &HDR{
+		Width:  width,
+		Height: height,
+		Pixels: make([]float32, 3*width*height),
+	}
+	scanline := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		if err := readScanline(reader, scanline, width); err != nil {
+			return nil, err
+		}
+		for x := 0; x < width; x++ {
+			rgbe := scanline[4*x : 4*x+4]
+			i := 3 * (y*width + x)
+			if rgbe[3] == 0 {
+				continue
+			}
+			f := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+			img.Pixels[i+0] = float32(rgbe[0]) * f
+			img.Pixels[i+1] = float32(rgbe[1]) * f
+			img.Pixels[i+2] = float32(rgbe[2]) * f
+		}
+	}
+
+	return img, nil
+}
+
+// readScanline reads a single scanline into an interleaved rgbe buffer
+func readScanline(reader *bufio.Reader, scanline []byte, width int) error {
+	if _, err := io.ReadFull(reader, scanline[:4]); err != nil {
+		return err
+	}
+
+	// flat scanlines do not start with the run length marker
+	rle := width >= 8 && width < 0x8000 && scanline[0] == 2 && scanline[1] == 2 && scanline[2]&0x80 == 0
+	if !rle {
+		_, err := io.ReadFull(reader, scanline[4:])
+		return err
+	}
+	if int(scanline[2])<<8|int(scanline[3]) != width {
+		return fmt.Errorf("%w: scanline width mismatch", ErrInvalidHDR)
+	}
+
+	// run length encoded scanlines store each channel separately
+	for channel := 0; channel < 4; channel++ {
+		for x := 0; x < width; {
+			count, err := reader.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				// run of a single value
+				count -= 128
+				value, err := reader.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+int(count) > width {
+					return fmt.Errorf("%w: run exceeds scanline", ErrInvalidHDR)
+				}
+				for i := 0; i < int(count); i++ {
+					scanline[4*x+channel] = value
+					x++
+				}
+			} else {
+				// literal values
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("%w: invalid literal run", ErrInvalidHDR)
+				}
+				for i := 0; i < int(count); i++ {
+					value, err := reader.ReadByte()
+					if err != nil {
+						return err
+					}
+					scanline[4*x+channel] = value
+					x++
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -31,6 +31,7 @@ type Def struct {
 	Primitive    vertex.Primitive
 	CullMode     vertex.CullMode
 	Transparent  bool
+	Wireframe    bool
 
 	id ID
 }
@@ -61,3 +61,50 @@ func Lines() *Def {
 		CullMode:     vertex.CullNone,
 	}
 }
+
+// Wireframe draws the edges of triangle meshes as lines
+func Wireframe() *Def {
+	return &Def{
+		Shader:       "pass/wireframe",
+		Pass:         "debug",
+		VertexFormat: vertex.Vertex{},
+		Primitive:    vertex.Triangles,
+		CullMode:     vertex.CullNone,
+		Wireframe:    true,
+	}
+}
+
+// Overdraw accumulates the number of fragments drawn to each pixel
+func Overdraw() *Def {
+	return &Def{
+		Shader:       "pass/overdraw",
+		Pass:         "debug",
+		VertexFormat: vertex.Vertex{},
+		Primitive:    vertex.Triangles,
+		CullMode:     vertex.CullBack,
+	}
+}
+
+// OutlineMask writes the outline group of meshes to the outline mask
+func OutlineMask() *Def {
+	return &Def{
+		Shader:       "pass/outline_mask",
+		Pass:         "outline",
+		VertexFormat: vertex.Vertex{},
+		Primitive:    vertex.Triangles,
+		CullMode:     vertex.CullNone,
+	}
+}
+
+// Picking writes the object handles of meshes to the picking buffer
+func Picking() *Def {
+	return &Def{
+		Shader:       "pass/picking",
+		Pass:         "picking",
+		VertexFormat: vertex.Vertex{},
+		Primitive:    vertex.Triangles,
+		CullMode:     vertex.CullNone,
+		DepthTest:    true,
+		DepthWrite:   true,
+	}
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"log"
+
+	"github.com/johanhenriksson/goworld/render/device"
+	"github.com/johanhenriksson/goworld/render/shader"
+
+	"github.com/vkngwrapper/core/v2/core1_0"
+	"github.com/vkngwrapper/core/v2/driver"
+)
+
+type ComputeArgs struct {
+	Shader *shader.Shader
+	Layout *Layout
+}
+
+// Compute is a pipeline that runs a single compute shader
+type Compute struct {
+	ptr    core1_0.Pipeline
+	device *device.Device
+	args   ComputeArgs
+}
+
+func NewCompute(device *device.Device, args ComputeArgs) *Compute {
+	if device == nil {
+		panic("device is nil")
+	}
+	if args.Shader == nil {
+		panic("shader is nil")
+	}
+	modules := args.Shader.Modules()
+	if len(modules) != 1 || modules[0].Stage() != shader.StageCompute {
+		panic("compute pipelines require a single compute shader module")
+	}
+
+	key := args.Shader.Name()
+	log.Println("creating compute pipeline", key)
+
+	info := core1_0.ComputePipelineCreateInfo{
+		Layout: args.Layout.Ptr(),
+		Stage: core1_0.PipelineShaderStageCreateInfo{
+			Module: modules[0].Ptr(),
+			Name:   modules[0].Entrypoint(),
+			Stage:  core1_0.StageCompute,
+		},
+	}
+
+	ptrs, result, err := device.Ptr().CreateComputePipelines(nil, nil, []core1_0.ComputePipelineCreateInfo{info})
+	if err != nil {
+		panic(err)
+	}
+	if result != core1_0.VKSuccess {
+		panic("failed to create compute pipeline")
+	}
+	device.SetDebugObjectName(driver.VulkanHandle(ptrs[0].Handle()), core1_0.ObjectTypePipeline, key)
+
+	return &Compute{
+		ptr:    ptrs[0],
+		device: device,
+		args:   args,
+	}
+}
+
+func (p *Compute) Ptr() core1_0.Pipeline {
+	return p.ptr
+}
+
+func (p *Compute) Shader() *shader.Shader {
+	return p.args.Shader
+}
+
+func (p *Compute) Layout() *Layout {
+	return p.args.Layout
+}
+
+func (p *Compute) Destroy() {
+	if p.ptr != nil {
+		p.ptr.Destroy(nil)
+		p.ptr = nil
+	}
+}
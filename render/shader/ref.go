@@ -24,3 +24,16 @@ func (r *ref) Version() int {
 func (r *ref) LoadShader(assets fs.Filesystem, dev *device.Device) *Shader {
 	return New(dev, assets, r.name)
 }
+
+type computeRef struct {
+	ref
+}
+
+// ComputeRef returns a reference to a compute shader
+func ComputeRef(name string) *computeRef {
+	return &computeRef{ref{name: name}}
+}
+
+func (r *computeRef) LoadShader(assets fs.Filesystem, dev *device.Device) *Shader {
+	return NewCompute(dev, assets, r.name)
+}
@@ -65,6 +65,26 @@ func New(device *device.Device, assets fs.Filesystem, path string) *Shader {
 	}
 }
 
+// NewCompute loads a compute shader consisting of a single compute module
+func NewCompute(device *device.Device, assets fs.Filesystem, path string) *Shader {
+	detailsPath := fmt.Sprintf("shaders/%s.json", path)
+	details, err := ReadDetails(assets, detailsPath)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load shader details %s: %s", detailsPath, err))
+	}
+
+	modules := []Module{
+		NewModule(device, assets, fmt.Sprintf("shaders/%s.cs.glsl", path), StageCompute),
+	}
+
+	return &Shader{
+		name:     path,
+		modules:  modules,
+		inputs:   Inputs{},
+		bindings: details.Bindings,
+	}
+}
+
 // Name returns the file name of the shader
 func (s *Shader) Name() string {
 	return s.name
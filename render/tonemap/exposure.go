@@ -0,0 +1,131 @@
+package tonemap
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+const (
+	// HistogramBins is the number of luminance buckets in the exposure histogram
+	HistogramBins = 64
+
+	// HistogramLow is the fraction of the darkest samples ignored when averaging
+	HistogramLow = 0.1
+
+	// HistogramHigh is the fraction of samples, counted from the darkest, above which samples are ignored
+	HistogramHigh = 0.9
+
+	// Key is the middle grey value that the average scene luminance is mapped to
+	Key = 0.18
+
+	// luminance below this value is treated as empty space and excluded from the histogram
+	minLuminance = 1.0 / 65536
+)
+
+// Settings holds the tone mapping configuration of a camera
+type Settings struct {
+	Operator Operator
+
+	// Compensation offsets the exposure in stops
+	Compensation float32
+
+	// Auto enables eye adaptation. If disabled, only the compensation applies
+	Auto bool
+
+	// Speed is the adaptation rate. Higher values adapt faster
+	Speed float32
+
+	// MinEV and MaxEV limit the adapted average log2 luminance
+	MinEV float32
+	MaxEV float32
+}
+
+// DefaultSettings matches the fixed exposure and curve used before tone mapping was configurable
+func DefaultSettings() Settings {
+	return Settings{
+		Operator: Exponential,
+		Auto:     false,
+		Speed:    1.5,
+		MinEV:    -6,
+		MaxEV:    10,
+	}
+}
+
+// Exposure returns the linear exposure multiplier for an average log2 scene luminance
+func (s Settings) Exposure(ev float32) float32 {
+	if !s.Auto {
+		return math.Exp2(s.Compensation)
+	}
+	return Key * math.Exp2(s.Compensation-ev)
+}
+
+// Luminance returns the relative luminance of a linear color
+func Luminance(color vec3.T) float32 {
+	return 0.2126*color.X + 0.7152*color.Y + 0.0722*color.Z
+}
+
+// Histogram counts samples by log2 luminance within a fixed EV range.
+// Samples outside the range are clamped to the first or last bin.
+type Histogram struct {
+	Bins  [HistogramBins]float32
+	MinEV float32
+	MaxEV float32
+}
+
+func NewHistogram(minEV, maxEV float32) *Histogram {
+	return &Histogram{
+		MinEV: minEV,
+		MaxEV: maxEV,
+	}
+}
+
+// Add a luminance sample to the histogram. Samples that are close to black are ignored.
+func (h *Histogram) Add(luminance float32) {
+	if luminance < minLuminance {
+		return
+	}
+	t := (math.Log2(luminance) - h.MinEV) / (h.MaxEV - h.MinEV)
+	bin := math.Clamp(int(t*HistogramBins), 0, HistogramBins-1)
+	h.Bins[bin]++
+}
+
+// Center returns the log2 luminance at the center of a bin
+func (h *Histogram) Center(bin int) float32 {
+	return h.MinEV + (float32(bin)+0.5)/HistogramBins*(h.MaxEV-h.MinEV)
+}
+
+// Average returns the mean log2 luminance of the samples between the low and high fractions,
+// discarding the darkest and brightest outliers. An empty histogram returns MinEV.
+func (h *Histogram) Average(low, high float32) float32 {
+	total := float32(0)
+	for _, count := range h.Bins {
+		total += count
+	}
+	lo, hi := total*low, total*high
+
+	sum, weight, cumulative := float32(0), float32(0), float32(0)
+	for bin, count := range h.Bins {
+		start, end := cumulative, cumulative+count
+		cumulative = end
+
+		// the part of this bin that falls within the percentile window
+		included := math.Min(end, hi) - math.Max(start, lo)
+		if included > 0 {
+			sum += included * h.Center(bin)
+			weight += included
+		}
+	}
+	if weight == 0 {
+		return h.MinEV
+	}
+	return sum / weight
+}
+
+// Adapt moves the current adapted luminance towards the target, framerate independently.
+// A speed of zero or less adapts instantly.
+func Adapt(current, target, speed, dt float32) float32 {
+	if speed <= 0 {
+		return target
+	}
+	return current + (target-current)*(1-math.Exp(-speed*dt))
+}
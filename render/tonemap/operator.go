@@ -0,0 +1,127 @@
+package tonemap
+
+import (
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+// Operator is a curve mapping linear HDR color to the displayable [0,1] range.
+// The CPU implementations mirror lib/tonemap.glsl
+type Operator int
+
+const (
+	// Exponential maps color using 1 - exp(-x)
+	Exponential Operator = iota
+
+	// Reinhard maps color using x / (1 + x)
+	Reinhard
+
+	// ACES uses the Narkowicz fit of the ACES filmic reference curve
+	ACES
+
+	// AgX uses the minimal AgX approximation, which desaturates bright colors towards white
+	AgX
+
+	// Filmic uses the Uncharted 2 curve by John Hable
+	Filmic
+)
+
+func (op Operator) String() string {
+	switch op {
+	case Exponential:
+		return "Exponential"
+	case Reinhard:
+		return "Reinhard"
+	case ACES:
+		return "ACES"
+	case AgX:
+		return "AgX"
+	case Filmic:
+		return "Filmic"
+	default:
+		return "Unknown"
+	}
+}
+
+// Apply maps an exposed linear color using the given operator.
+// The result is linear, gamma correction is applied separately.
+func Apply(op Operator, color vec3.T) vec3.T {
+	switch op {
+	case Reinhard:
+		return perChannel(color, func(x float32) float32 { return x / (1 + x) })
+	case ACES:
+		return perChannel(color, aces)
+	case AgX:
+		return agx(color)
+	case Filmic:
+		white := hable(filmicWhite)
+		return perChannel(color, func(x float32) float32 { return math.Clamp(hable(x*filmicBias)/white, 0, 1) })
+	default:
+		return perChannel(color, func(x float32) float32 { return 1 - math.Exp(-x) })
+	}
+}
+
+func perChannel(c vec3.T, f func(float32) float32) vec3.T {
+	return vec3.New(f(c.X), f(c.Y), f(c.Z))
+}
+
+func aces(x float32) float32 {
+	const a, b, c, d, e = 2.51, 0.03, 2.43, 0.59, 0.14
+	return math.Clamp((x*(a*x+b))/(x*(c*x+d)+e), 0, 1)
+}
+
+const (
+	filmicWhite = 11.2
+	filmicBias  = 2
+)
+
+func hable(x float32) float32 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return ((x*(a*x+c*b) + d*e) / (x*(a*x+b) + d*f)) - e/f
+}
+
+// agx matrices are stored column major, like their glsl counterparts
+var (
+	agxInset = [9]float32{
+		0.842479062253094, 0.0423282422610123, 0.0423756549057051,
+		0.0784335999999992, 0.878468636469772, 0.0784336,
+		0.0792237451477643, 0.0791661274605434, 0.879142973793104,
+	}
+	agxOutset = [9]float32{
+		1.19687900512017, -0.0528968517574562, -0.0529716355144438,
+		-0.0980208811401368, 1.15190312990417, -0.0980434501171241,
+		-0.0990297440797205, -0.0989611768448433, 1.15107367264116,
+	}
+)
+
+const (
+	agxMinEV = -12.47393
+	agxMaxEV = 4.026069
+)
+
+func mul3(m *[9]float32, v vec3.T) vec3.T {
+	return vec3.New(
+		m[0]*v.X+m[3]*v.Y+m[6]*v.Z,
+		m[1]*v.X+m[4]*v.Y+m[7]*v.Z,
+		m[2]*v.X+m[5]*v.Y+m[8]*v.Z,
+	)
+}
+
+// agxContrast is a polynomial approximation of the default agx contrast curve
+func agxContrast(x float32) float32 {
+	x2 := x * x
+	x4 := x2 * x2
+	return 15.5*x4*x2 - 40.14*x4*x + 31.96*x4 - 6.868*x2*x + 0.4298*x2 + 0.1191*x - 0.00232
+}
+
+func agx(color vec3.T) vec3.T {
+	c := mul3(&agxInset, color)
+	c = perChannel(c, func(x float32) float32 {
+		ev := math.Clamp(math.Log2(math.Max(x, 1e-10)), agxMinEV, agxMaxEV)
+		return agxContrast((ev - agxMinEV) / (agxMaxEV - agxMinEV))
+	})
+	c = mul3(&agxOutset, c)
+
+	// the curve produces display encoded values, linearize them
+	return perChannel(c, func(x float32) float32 { return math.Pow(math.Clamp(x, 0, 1), 2.2) })
+}
@@ -0,0 +1,13 @@
+package tonemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTonemap(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "render/tonemap")
+}
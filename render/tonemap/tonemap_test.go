@@ -0,0 +1,93 @@
+package tonemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+	"github.com/johanhenriksson/goworld/render/tonemap"
+)
+
+var _ = Describe("operators", func() {
+	operators := []tonemap.Operator{tonemap.Exponential, tonemap.Reinhard, tonemap.ACES, tonemap.AgX, tonemap.Filmic}
+
+	It("maps black to black", func() {
+		for _, op := range operators {
+			c := tonemap.Apply(op, vec3.Zero)
+			Expect(c.X).To(BeNumerically("~", 0, 0.01), op.String())
+		}
+	})
+
+	It("is monotonic and bounded", func() {
+		for _, op := range operators {
+			prev := float32(-1)
+			for x := float32(0.01); x < 100; x *= 1.5 {
+				y := tonemap.Apply(op, vec3.New(x, x, x)).X
+				Expect(y).To(BeNumerically(">=", prev), op.String())
+				Expect(y).To(BeNumerically("<=", 1.001), op.String())
+				prev = y
+			}
+		}
+	})
+})
+
+var _ = Describe("histogram", func() {
+	It("averages uniform luminance", func() {
+		h := tonemap.NewHistogram(-8, 8)
+		for i := 0; i < 100; i++ {
+			h.Add(4)
+		}
+		Expect(h.Average(tonemap.HistogramLow, tonemap.HistogramHigh)).To(BeNumerically("~", 2, 0.25))
+	})
+
+	It("ignores outliers", func() {
+		h := tonemap.NewHistogram(-8, 8)
+		for i := 0; i < 95; i++ {
+			h.Add(1)
+		}
+		for i := 0; i < 5; i++ {
+			h.Add(200)
+		}
+		Expect(h.Average(tonemap.HistogramLow, tonemap.HistogramHigh)).To(BeNumerically("~", 0, 0.25))
+	})
+
+	It("ignores black samples", func() {
+		h := tonemap.NewHistogram(-8, 8)
+		h.Add(0)
+		Expect(h.Average(0, 1)).To(Equal(float32(-8)))
+	})
+
+	It("clamps to the ev range", func() {
+		h := tonemap.NewHistogram(-2, 2)
+		h.Add(1000)
+		Expect(h.Average(0, 1)).To(BeNumerically("<=", 2))
+	})
+})
+
+var _ = Describe("adaptation", func() {
+	It("converges towards the target", func() {
+		ev := float32(0)
+		for i := 0; i < 600; i++ {
+			ev = tonemap.Adapt(ev, 4, 1.5, 1.0/60)
+			Expect(ev).To(BeNumerically("<=", 4))
+		}
+		Expect(ev).To(BeNumerically("~", 4, 0.01))
+	})
+
+	It("is framerate independent", func() {
+		slow := tonemap.Adapt(0, 4, 1, 0.5)
+		fast := float32(0)
+		for i := 0; i < 50; i++ {
+			fast = tonemap.Adapt(fast, 4, 1, 0.01)
+		}
+		Expect(fast).To(BeNumerically("~", slow, 0.001))
+	})
+
+	It("maps the average luminance to middle grey", func() {
+		settings := tonemap.DefaultSettings()
+		settings.Auto = true
+		ev := float32(3)
+		Expect(settings.Exposure(ev) * math.Exp2(ev)).To(BeNumerically("~", tonemap.Key, 0.0001))
+	})
+})
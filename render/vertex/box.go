@@ -0,0 +1,34 @@
+package vertex
+
+import (
+	"github.com/johanhenriksson/goworld/math/vec2"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+// Unit box helper. Centered on the origin with a side length of 1, faces wound the same way as cube meshes.
+func Box(key string) Mesh {
+	v := func(x, y, z float32) Vertex {
+		return T(vec3.New(x*0.5, y*0.5, z*0.5), vec3.Zero, vec2.Zero)
+	}
+	return NewTriangles(key, []Vertex{
+		// X+
+		v(1, -1, 1), v(1, -1, -1), v(1, 1, -1), v(1, 1, 1),
+		// X-
+		v(-1, -1, -1), v(-1, -1, 1), v(-1, 1, 1), v(-1, 1, -1),
+		// Y+
+		v(1, 1, -1), v(-1, 1, -1), v(-1, 1, 1), v(1, 1, 1),
+		// Y-
+		v(-1, -1, -1), v(1, -1, -1), v(1, -1, 1), v(-1, -1, 1),
+		// Z+
+		v(-1, -1, 1), v(1, -1, 1), v(1, 1, 1), v(-1, 1, 1),
+		// Z-
+		v(1, -1, -1), v(-1, -1, -1), v(-1, 1, -1), v(1, 1, -1),
+	}, []uint32{
+		0, 1, 2, 0, 2, 3,
+		4, 5, 6, 4, 6, 7,
+		8, 9, 10, 8, 10, 11,
+		12, 13, 14, 12, 14, 15,
+		16, 17, 18, 16, 18, 19,
+		20, 21, 22, 20, 22, 23,
+	})
+}
@@ -0,0 +1,132 @@
+package vertex
+
+import (
+	"fmt"
+
+	"github.com/johanhenriksson/goworld/math"
+	"github.com/johanhenriksson/goworld/math/vec3"
+)
+
+type cell struct {
+	X, Y, Z int
+}
+
+// Simplify generates a lower detail version of a triangle mesh by clustering its vertices on a uniform grid.
+// The longest side of the mesh bounds is divided into the given number of cells.
+// Each cluster is replaced by the vertex closest to the centroid of the cluster, keeping its attributes.
+// Triangles that collapse into lines or points are removed.
+func Simplify[V VertexFormat, I IndexFormat](key string, mesh MutableMesh[V, I], resolution int) MutableMesh[V, I] {
+	if mesh.Primitive() != Triangles {
+		panic("only triangle meshes can be simplified")
+	}
+	resolution = max(resolution, 1)
+
+	vertices := mesh.Vertices()
+	indices := mesh.Indices()
+
+	origin := mesh.Min()
+	extent := mesh.Max().Sub(origin)
+	size := math.Max(extent.X, math.Max(extent.Y, extent.Z)) / float32(resolution)
+	if size <= 0 {
+		size = 1
+	}
+
+	cellOf := func(p vec3.T) cell {
+		q := p.Sub(origin).Scaled(1 / size)
+		return cell{
+			X: math.Min(int(q.X), resolution-1),
+			Y: math.Min(int(q.Y), resolution-1),
+			Z: math.Min(int(q.Z), resolution-1),
+		}
+	}
+
+	// compute the centroid of each cluster
+	type cluster struct {
+		sum   vec3.T
+		count int
+		best  int
+		dist  float32
+	}
+	clusters := map[cell]*cluster{}
+	cells := make([]cell, len(vertices))
+	for i, v := range vertices {
+		c := cellOf(v.Position())
+		cells[i] = c
+		cl, exists := clusters[c]
+		if !exists {
+			cl = &cluster{best: -1}
+			clusters[c] = cl
+		}
+		cl.sum = cl.sum.Add(v.Position())
+		cl.count++
+	}
+
+	// pick the representative vertex of each cluster
+	for i, v := range vertices {
+		cl := clusters[cells[i]]
+		centroid := cl.sum.Scaled(1 / float32(cl.count))
+		dist := vec3.Distance(v.Position(), centroid)
+		if cl.best < 0 || dist < cl.dist {
+			cl.best = i
+			cl.dist = dist
+		}
+	}
+
+	// remap triangles to cluster representatives
+	remap := make(map[int]I, len(clusters))
+	outVertices := make([]V, 0, len(clusters))
+	outIndex := func(index I) I {
+		best := clusters[cells[index]].best
+		if mapped, exists := remap[best]; exists {
+			return mapped
+		}
+		mapped := I(len(outVertices))
+		outVertices = append(outVertices, vertices[best])
+		remap[best] = mapped
+		return mapped
+	}
+
+	type triangle struct {
+		A, B, C I
+	}
+	seen := map[triangle]bool{}
+	outIndices := make([]I, 0, len(indices))
+	for i := 0; i+2 < len(indices); i += 3 {
+		a := clusters[cells[indices[i+0]]].best
+		b := clusters[cells[indices[i+1]]].best
+		c := clusters[cells[indices[i+2]]].best
+		if a == b || b == c || a == c {
+			continue
+		}
+
+		ia, ib, ic := outIndex(indices[i+0]), outIndex(indices[i+1]), outIndex(indices[i+2])
+
+		// rotate the triangle so that duplicates with the same winding are detected
+		key := triangle{ia, ib, ic}
+		if ib < ia && ib < ic {
+			key = triangle{ib, ic, ia}
+		} else if ic < ia && ic < ib {
+			key = triangle{ic, ia, ib}
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		outIndices = append(outIndices, ia, ib, ic)
+	}
+
+	return NewTriangles(key, outVertices, outIndices)
+}
+
+// SimplifyLevels generates a number of increasingly simplified versions of a triangle mesh.
+// The grid resolution is halved for each level, starting from the given resolution.
+func SimplifyLevels[V VertexFormat, I IndexFormat](mesh MutableMesh[V, I], resolution, levels int) []MutableMesh[V, I] {
+	result := make([]MutableMesh[V, I], 0, levels)
+	for i := 0; i < levels && resolution > 0; i++ {
+		key := fmt.Sprintf("%s-lod%d", mesh.Key(), i+1)
+		result = append(result, Simplify(key, mesh, resolution))
+		resolution /= 2
+	}
+	return result
+}
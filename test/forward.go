@@ -8,60 +8,18 @@ import (
 
 	"github.com/johanhenriksson/goworld/core/camera"
 	"github.com/johanhenriksson/goworld/core/light"
-	"github.com/johanhenriksson/goworld/engine"
 	"github.com/johanhenriksson/goworld/engine/app"
 	"github.com/johanhenriksson/goworld/engine/graph"
-	"github.com/johanhenriksson/goworld/engine/pass"
 	"github.com/johanhenriksson/goworld/geometry/cube"
 	"github.com/johanhenriksson/goworld/geometry/plane"
 	"github.com/johanhenriksson/goworld/math/quat"
 	"github.com/johanhenriksson/goworld/math/vec2"
 	"github.com/johanhenriksson/goworld/math/vec3"
 	"github.com/johanhenriksson/goworld/render/color"
-	"github.com/johanhenriksson/goworld/render/image"
 	"github.com/johanhenriksson/goworld/render/material"
 	"github.com/johanhenriksson/goworld/render/texture"
-
-	"github.com/vkngwrapper/core/v2/core1_0"
 )
 
-func ForwardGraph(app engine.App, target engine.Target) engine.Renderer {
-	return graph.New(app, target, func(g *graph.Graph, output engine.Target) []graph.Resource {
-		size := output.Size()
-
-		// allocate main depth buffer
-		depth := engine.NewDepthTarget(app.Device(), "main-depth", size)
-
-		// main off-screen color buffer
-		offscreen := engine.NewColorTarget(app.Device(), "main-color", image.FormatRGBA8Unorm, size)
-
-		// create geometry buffer
-		gbuffer, err := pass.NewGbuffer(app.Device(), size)
-		if err != nil {
-			panic(err)
-		}
-
-		// depth pre-pass
-		depthPass := g.Node(pass.NewDepthPass(app, depth))
-
-		shadows := pass.NewShadowPass(app, output)
-		shadowNode := g.Node(shadows)
-
-		forward := g.Node(pass.NewForwardPass(app, offscreen, depth, shadows))
-		forward.After(depthPass, core1_0.PipelineStageEarlyFragmentTests)
-		forward.After(shadowNode, core1_0.PipelineStageFragmentShader)
-
-		outputPass := g.Node(pass.NewOutputPass(app, output, offscreen))
-		outputPass.After(forward, core1_0.PipelineStageTopOfPipe)
-
-		return []graph.Resource{
-			depth,
-			offscreen,
-			gbuffer,
-		}
-	})
-}
-
 var _ = Describe("forward renderer", Label("e2e"), func() {
 	It("renders correctly", func() {
 		img := app.Frame(
@@ -69,7 +27,7 @@ var _ = Describe("forward renderer", Label("e2e"), func() {
 				Width:    512,
 				Height:   512,
 				Title:    "goworld",
-				Renderer: ForwardGraph,
+				Renderer: graph.Forward,
 			},
 			func(pool Pool, scene Object) {
 				Builder(Empty(pool, "Camera")).
@@ -0,0 +1,407 @@
+package util
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/johanhenriksson/goworld/render/upload"
+
+	"github.com/onsi/ginkgo/v2"
+)
+
+const (
+	// GoldenUpdateEnv enables golden image updates, as an alternative to the -update-golden flag
+	GoldenUpdateEnv = "GOLDEN_UPDATE"
+
+	// GoldenOutputEnv sets the directory where actual and diff images of failed comparisons are written
+	GoldenOutputEnv = "GOLDEN_OUTPUT"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "overwrite golden images with the rendered results")
+
+// UpdateGolden returns true if golden images should be regenerated instead of compared
+func UpdateGolden() bool {
+	return *updateGolden || os.Getenv(GoldenUpdateEnv) != ""
+}
+
+// ImageDiff holds the difference metrics of two images
+type ImageDiff struct {
+	// Pixels is the total number of pixels compared
+	Pixels int
+
+	// DiffPixels is the number of pixels where any channel differs by more than the tolerance
+	DiffPixels int
+
+	// MaxDelta holds the largest difference of each RGBA channel
+	MaxDelta [4]uint8
+
+	// MeanError is the mean absolute channel difference, in percent of the channel range
+	MeanError float64
+
+	// PSNR is the peak signal-to-noise ratio of the color channels, in decibels. Infinite for identical images
+	PSNR float64
+
+	// SSIM is the mean structural similarity of the luminance, where 1 means identical
+	SSIM float64
+
+	// DeltaE is the mean perceptual color difference (CIE76). Differences below 1 are not noticeable
+	DeltaE float64
+
+	// Image visualizes the difference: the expected image, the actual image,
+	// and a heat map where differences exceeding the tolerance are highlighted in red
+	Image *image.RGBA
+}
+
+// DiffFraction returns the percentage of pixels exceeding the tolerance
+func (d *ImageDiff) DiffFraction() float64 {
+	if d.Pixels == 0 {
+		return 0
+	}
+	return 100 * float64(d.DiffPixels) / float64(d.Pixels)
+}
+
+func (d *ImageDiff) String() string {
+	return fmt.Sprintf("%.3f%% pixels differ (max delta rgba %v), mean error %.3f%%, PSNR %.2f dB, SSIM %.4f, mean ΔE %.3f",
+		d.DiffFraction(), d.MaxDelta, d.MeanError, d.PSNR, d.SSIM, d.DeltaE)
+}
+
+// CompareImages computes the difference between two images of equal size.
+// A pixel differs if any channel differs by more than the tolerance of that channel.
+func CompareImages(expected, actual image.Image, tolerance [4]uint8) (*ImageDiff, error) {
+	if expected.Bounds().Size() != actual.Bounds().Size() {
+		return nil, fmt.Errorf("image sizes dont match, expected %s but was %s", expected.Bounds().Size(), actual.Bounds().Size())
+	}
+	exp, act := toRGBA(expected), toRGBA(actual)
+	width, height := exp.Bounds().Dx(), exp.Bounds().Dy()
+
+	diff := &ImageDiff{
+		Pixels: width * height,
+		Image:  image.NewRGBA(image.Rect(0, 0, 3*width, height)),
+	}
+	draw.Draw(diff.Image, image.Rect(0, 0, width, height), exp, image.Point{}, draw.Src)
+	draw.Draw(diff.Image, image.Rect(width, 0, 2*width, height), act, image.Point{}, draw.Src)
+
+	totalError, squaredError, deltaE := 0.0, 0.0, 0.0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			e, a := exp.RGBAAt(x, y), act.RGBAAt(x, y)
+			ec, ac := [4]uint8{e.R, e.G, e.B, e.A}, [4]uint8{a.R, a.G, a.B, a.A}
+
+			exceeds := false
+			largest := uint8(0)
+			for c := range ec {
+				delta := absDiff(ec[c], ac[c])
+				diff.MaxDelta[c] = max(diff.MaxDelta[c], delta)
+				totalError += float64(delta)
+				if c < 3 {
+					squaredError += float64(delta) * float64(delta)
+				}
+				if delta > tolerance[c] {
+					exceeds = true
+					largest = max(largest, delta)
+				}
+			}
+			deltaE += labDistance(srgbToLab(e), srgbToLab(a))
+
+			// heat map: differences in red, everything else as dimmed luminance of the expected image
+			var heat color.RGBA
+			if exceeds {
+				diff.DiffPixels++
+				heat = color.RGBA{R: 64 + uint8(191*int(largest)/255), A: 255}
+			} else {
+				l := uint8(luminance(e) / 4)
+				heat = color.RGBA{R: l, G: l, B: l, A: 255}
+			}
+			diff.Image.SetRGBA(2*width+x, y, heat)
+		}
+	}
+
+	if diff.Pixels > 0 {
+		diff.MeanError = 100 * totalError / float64(4*255*diff.Pixels)
+		diff.DeltaE = deltaE / float64(diff.Pixels)
+		mse := squaredError / float64(3*diff.Pixels)
+		if mse == 0 {
+			diff.PSNR = math.Inf(1)
+		} else {
+			diff.PSNR = 10 * math.Log10(255*255/mse)
+		}
+	}
+	diff.SSIM = ssim(exp, act)
+
+	return diff, nil
+}
+
+// ssim computes the mean structural similarity of the luminance over 8x8 windows
+func ssim(expected, actual *image.RGBA) float64 {
+	const window = 8
+	const c1 = (0.01 * 255) * (0.01 * 255)
+	const c2 = (0.03 * 255) * (0.03 * 255)
+
+	width, height := expected.Bounds().Dx(), expected.Bounds().Dy()
+	total, windows := 0.0, 0
+	for wy := 0; wy < height; wy += window {
+		for wx := 0; wx < width; wx += window {
+			n := 0.0
+			sumE, sumA, sumEE, sumAA, sumEA := 0.0, 0.0, 0.0, 0.0, 0.0
+			for y := wy; y < min(wy+window, height); y++ {
+				for x := wx; x < min(wx+window, width); x++ {
+					e, a := luminance(expected.RGBAAt(x, y)), luminance(actual.RGBAAt(x, y))
+					sumE += e
+					sumA += a
+					sumEE += e * e
+					sumAA += a * a
+					sumEA += e * a
+					n++
+				}
+			}
+			meanE, meanA := sumE/n, sumA/n
+			varE, varA := sumEE/n-meanE*meanE, sumAA/n-meanA*meanA
+			cov := sumEA/n - meanE*meanA
+			total += ((2*meanE*meanA + c1) * (2*cov + c2)) / ((meanE*meanE + meanA*meanA + c1) * (varE + varA + c2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+func luminance(c color.RGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+type lab struct{ L, A, B float64 }
+
+func srgbToLab(c color.RGBA) lab {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+
+	// xyz relative to the D65 white point
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 0.008856 {
+			return math.Cbrt(t)
+		}
+		return 7.787*t + 16.0/116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+func labDistance(a, b lab) float64 {
+	dl, da, db := a.L-b.L, a.A-b.A, a.B-b.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+//
+// golden image matcher
+//
+
+// MatchGolden compares an image to a golden reference image stored at the given path.
+// When golden updates are enabled, the golden image is overwritten with the actual image instead.
+//
+// On failure, the actual image and a diff image are written to the directory given by GOLDEN_OUTPUT,
+// or the working directory.
+func MatchGolden(path string) *goldenImage {
+	return &goldenImage{
+		Path:          path,
+		Tolerance:     [4]uint8{3, 3, 3, 3},
+		MaxDiffPixels: 0.1,
+		MaxMeanError:  -1,
+		MinSSIM:       0.98,
+		MaxDeltaE:     -1,
+	}
+}
+
+type goldenImage struct {
+	Path string
+
+	// Tolerance is the largest accepted difference of each RGBA channel
+	Tolerance [4]uint8
+
+	// MaxDiffPixels is the largest accepted percentage of pixels exceeding the tolerance. Negative to disable
+	MaxDiffPixels float64
+
+	// MaxMeanError is the largest accepted mean channel error, in percent. Negative to disable
+	MaxMeanError float64
+
+	// MinSSIM is the smallest accepted structural similarity. -1 to disable
+	MinSSIM float64
+
+	// MaxDeltaE is the largest accepted mean perceptual color difference. Negative to disable
+	MaxDeltaE float64
+
+	diff     *ImageDiff
+	failures []string
+	written  []string
+}
+
+// WithTolerance sets the accepted difference of all channels
+func (m *goldenImage) WithTolerance(tolerance uint8) *goldenImage {
+	m.Tolerance = [4]uint8{tolerance, tolerance, tolerance, tolerance}
+	return m
+}
+
+// WithChannelTolerance sets the accepted difference of each channel
+func (m *goldenImage) WithChannelTolerance(r, g, b, a uint8) *goldenImage {
+	m.Tolerance = [4]uint8{r, g, b, a}
+	return m
+}
+
+// WithMaxDiffPixels sets the accepted percentage of pixels exceeding the tolerance
+func (m *goldenImage) WithMaxDiffPixels(percent float64) *goldenImage {
+	m.MaxDiffPixels = percent
+	return m
+}
+
+// WithMaxMeanError sets the accepted mean channel error, in percent
+func (m *goldenImage) WithMaxMeanError(percent float64) *goldenImage {
+	m.MaxMeanError = percent
+	return m
+}
+
+// WithMinSSIM sets the smallest accepted structural similarity
+func (m *goldenImage) WithMinSSIM(ssim float64) *goldenImage {
+	m.MinSSIM = ssim
+	return m
+}
+
+// WithMaxDeltaE sets the accepted mean perceptual color difference
+func (m *goldenImage) WithMaxDeltaE(deltaE float64) *goldenImage {
+	m.MaxDeltaE = deltaE
+	return m
+}
+
+func (m *goldenImage) Match(actualValue interface{}) (success bool, err error) {
+	actual, ok := actualValue.(image.Image)
+	if !ok || actual == nil {
+		return false, fmt.Errorf("expected an image.Image value")
+	}
+
+	if UpdateGolden() {
+		if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+			return false, err
+		}
+		if err := upload.SavePng(actual, m.Path); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(ginkgo.GinkgoWriter, "updated golden image", m.Path)
+		return true, nil
+	}
+
+	infile, err := os.Open(m.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("golden image %s does not exist, run with -update-golden or %s=1 to create it", m.Path, GoldenUpdateEnv)
+		}
+		return false, err
+	}
+	defer infile.Close()
+	expected, _, err := image.Decode(infile)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode golden image %s: %w", m.Path, err)
+	}
+
+	m.diff, err = CompareImages(expected, actual, m.Tolerance)
+	if err != nil {
+		return false, err
+	}
+
+	m.failures = m.failures[:0]
+	if m.MaxDiffPixels >= 0 && m.diff.DiffFraction() > m.MaxDiffPixels {
+		m.failures = append(m.failures, fmt.Sprintf("%.3f%% of pixels exceed the tolerance %v, limit is %.3f%%", m.diff.DiffFraction(), m.Tolerance, m.MaxDiffPixels))
+	}
+	if m.MaxMeanError >= 0 && m.diff.MeanError > m.MaxMeanError {
+		m.failures = append(m.failures, fmt.Sprintf("mean error %.3f%% exceeds %.3f%%", m.diff.MeanError, m.MaxMeanError))
+	}
+	if m.diff.SSIM < m.MinSSIM {
+		m.failures = append(m.failures, fmt.Sprintf("SSIM %.4f is below %.4f", m.diff.SSIM, m.MinSSIM))
+	}
+	if m.MaxDeltaE >= 0 && m.diff.DeltaE > m.MaxDeltaE {
+		m.failures = append(m.failures, fmt.Sprintf("mean ΔE %.3f exceeds %.3f", m.diff.DeltaE, m.MaxDeltaE))
+	}
+
+	if len(m.failures) > 0 {
+		m.written, err = writeFailureImages(m.Path, actual, m.diff.Image)
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeFailureImages writes the actual and diff images of a failed comparison, named after the current spec
+func writeFailureImages(golden string, actual, diff image.Image) ([]string, error) {
+	dir := os.Getenv(GoldenOutputEnv)
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	report := ginkgo.CurrentSpecReport()
+	testName := strings.Join(append(report.ContainerHierarchyTexts, report.LeafNodeText), "_")
+	if testName == "" {
+		testName = strings.TrimSuffix(filepath.Base(golden), filepath.Ext(golden))
+	}
+	testName = strings.NewReplacer(" ", "_", "/", "_", string(filepath.Separator), "_").Replace(testName)
+
+	actualPath := filepath.Join(dir, testName+"_actual.png")
+	diffPath := filepath.Join(dir, testName+"_failure.png")
+	if err := upload.SavePng(actual, actualPath); err != nil {
+		return nil, err
+	}
+	if err := upload.SavePng(diff, diffPath); err != nil {
+		return nil, err
+	}
+	return []string{actualPath, diffPath}, nil
+}
+
+func (m *goldenImage) FailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected image to match golden image %s\n\t%s\n%s\nWrote %s",
+		m.Path, strings.Join(m.failures, "\n\t"), m.diff, strings.Join(m.written, ", "))
+}
+
+func (m *goldenImage) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected image not to match golden image %s", m.Path)
+}
@@ -0,0 +1,81 @@
+package util_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+
+	. "github.com/johanhenriksson/goworld/test/util"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func checker(size int, a, b color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x/4+y/4)%2 == 0 {
+				img.SetRGBA(x, y, a)
+			} else {
+				img.SetRGBA(x, y, b)
+			}
+		}
+	}
+	return img
+}
+
+var _ = Describe("golden images", func() {
+	white := color.RGBA{255, 255, 255, 255}
+	black := color.RGBA{0, 0, 0, 255}
+
+	var dir string
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "golden")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+		GinkgoT().Setenv(GoldenOutputEnv, dir)
+	})
+
+	It("reports no difference between identical images", func() {
+		img := checker(16, white, black)
+		diff, err := CompareImages(img, img, [4]uint8{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.DiffPixels).To(Equal(0))
+		Expect(diff.SSIM).To(BeNumerically("~", 1, 1e-6))
+		Expect(math.IsInf(diff.PSNR, 1)).To(BeTrue())
+		Expect(diff.Image.Bounds().Dx()).To(Equal(48))
+	})
+
+	It("applies tolerance per channel", func() {
+		expected := checker(16, white, black)
+		actual := checker(16, color.RGBA{250, 255, 255, 200}, black)
+
+		diff, err := CompareImages(expected, actual, [4]uint8{3, 3, 3, 255})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.DiffPixels).To(Equal(128))
+		Expect(diff.MaxDelta).To(Equal([4]uint8{5, 0, 0, 55}))
+
+		diff, err = CompareImages(expected, actual, [4]uint8{5, 0, 0, 255})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.DiffPixels).To(Equal(0))
+	})
+
+	It("writes diff images on failure", func() {
+		golden := filepath.Join(dir, "golden.png")
+		GinkgoT().Setenv(GoldenUpdateEnv, "1")
+		Expect(checker(16, white, black)).To(MatchGolden(golden))
+		Expect(golden).To(BeAnExistingFile())
+
+		GinkgoT().Setenv(GoldenUpdateEnv, "")
+		Expect(checker(16, white, black)).To(MatchGolden(golden))
+
+		inverted := checker(16, black, white)
+		Expect(inverted).ToNot(MatchGolden(golden))
+		failures, err := filepath.Glob(filepath.Join(dir, "*_failure.png"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(failures).To(HaveLen(1))
+	})
+})